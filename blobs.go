@@ -9,6 +9,7 @@ import (
 
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/reference"
 )
 
 var (
@@ -40,6 +41,18 @@ func (err ErrBlobInvalidDigest) Error() string {
 		err.Digest, err.Reason)
 }
 
+// ErrBlobMounted returned when a blob is mounted from another repository
+// instead of initiating an upload session.
+type ErrBlobMounted struct {
+	From       reference.Canonical
+	Descriptor Descriptor
+}
+
+func (err ErrBlobMounted) Error() string {
+	return fmt.Sprintf("blob mounted from: %v to: %v",
+		err.From, err.Descriptor)
+}
+
 // Descriptor describes targeted content. Used in conjunction with a blob
 // store, a descriptor can be used to fetch, store and target any kind of
 // blob. The struct also describes the wire protocol format. Fields should
@@ -141,13 +154,30 @@ type BlobIngester interface {
 	// Create allocates a new blob writer to add a blob to this service. The
 	// returned handle can be written to and later resumed using an opaque
 	// identifier. With this approach, one can Close and Resume a BlobWriter
-	// multiple times until the BlobWriter is committed or cancelled.
-	Create(ctx context.Context) (BlobWriter, error)
+	// multiple times until the BlobWriter is committed or cancelled. If the
+	// options ask for the blob to be mounted from another repository and the
+	// mount succeeds, no writer is returned and the error is ErrBlobMounted.
+	Create(ctx context.Context, options ...BlobCreateOption) (BlobWriter, error)
 
 	// Resume attempts to resume a write to a blob, identified by an id.
 	Resume(ctx context.Context, id string) (BlobWriter, error)
 }
 
+// CreateOptions holds the settings of a blob creation, which are configured
+// by BlobCreateOptions.
+type CreateOptions struct {
+	// Mount requests that the blob with the digest of From, which must
+	// already be linked into the repository named by From, is linked
+	// instead of uploaded.
+	Mount struct {
+		ShouldMount bool
+		From        reference.Canonical
+	}
+}
+
+// BlobCreateOption is a function that configures the creation of a blob.
+type BlobCreateOption func(*CreateOptions) error
+
 // BlobWriter provides a handle for inserting data into a blob store.
 // Instances should be obtained from BlobWriteService.Writer and
 // BlobWriteService.Resume. If supported by the store, a writer can be
@@ -54,7 +54,8 @@ func main() {
 			// The following are part of the specification but provided by errcode default.
 			errcode.ErrorCodeUnauthorized.Descriptor(),
 			errcode.ErrorCodeDenied.Descriptor(),
-			errcode.ErrorCodeUnsupported.Descriptor()),
+			errcode.ErrorCodeUnsupported.Descriptor(),
+			errcode.ErrorCodeTooManyRequests.Descriptor()),
 	}
 
 	if err := tmpl.Execute(os.Stdout, data); err != nil {
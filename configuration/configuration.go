@@ -36,6 +36,10 @@ type Configuration struct {
 		// Hooks allows users to configurate the log hooks, to enabling the
 		// sequent handling behavior, when defined levels of log message emit.
 		Hooks []LogHook `yaml:"hooks,omitempty"`
+
+		// AccessLog configures the log of the requests served by the
+		// registry.
+		AccessLog AccessLog `yaml:"accesslog,omitempty"`
 	}
 
 	// Loglevel is the level at which registry operations are logged. This is
@@ -49,6 +53,10 @@ type Configuration struct {
 	// used to gate requests.
 	Auth Auth `yaml:"auth,omitempty"`
 
+	// TokenIssuer configures a token server embedded in the registry, issuing
+	// the tokens verified by the token access controller.
+	TokenIssuer TokenIssuer `yaml:"tokenissuer,omitempty"`
+
 	// Middleware lists all middlewares to be used by the registry.
 	Middleware map[string][]Middleware `yaml:"middleware,omitempty"`
 
@@ -145,6 +153,29 @@ type Configuration struct {
 	Health Health `yaml:"health,omitempty"`
 
 	Proxy Proxy `yaml:"proxy,omitempty"`
+
+	// Quota limits the storage used by repositories.
+	Quota Quota `yaml:"quota,omitempty"`
+
+	// RateLimit limits the rate of requests to the registry.
+	RateLimit RateLimit `yaml:"ratelimit,omitempty"`
+
+	// Tracing configures the export of the spans traced through the
+	// registry.
+	Tracing Tracing `yaml:"tracing,omitempty"`
+
+	// Compatibility configures handling of older versions of the registry
+	// protocol.
+	Compatibility struct {
+		// Schema1 configures the schema1 manifests that are synthesized for
+		// clients which do not accept the stored manifest format.
+		Schema1 struct {
+			// SigningKeyFile is the libtrust key file used to sign schema1
+			// manifests. If not set, a key is generated when the registry
+			// starts.
+			SigningKeyFile string `yaml:"signingkeyfile,omitempty"`
+		} `yaml:"schema1,omitempty"`
+	} `yaml:"compatibility,omitempty"`
 }
 
 // LogHook is composed of hook Level and Type.
@@ -165,6 +196,27 @@ type LogHook struct {
 	MailOptions MailOptions `yaml:"options,omitempty"`
 }
 
+// AccessLog configures the access log, recording a line per request.
+type AccessLog struct {
+	// Disabled turns the access log off.
+	Disabled bool `yaml:"disabled,omitempty"`
+
+	// Format is the format of the records, either "combined", the default,
+	// or "json".
+	Format string `yaml:"format,omitempty"`
+
+	// Path is the file the records are appended to. Records are written to
+	// stdout if no path is given.
+	Path string `yaml:"path,omitempty"`
+
+	// MaxSize is the size in bytes above which the file is rotated. The
+	// file is never rotated if zero.
+	MaxSize int64 `yaml:"maxsize,omitempty"`
+
+	// MaxBackups is the number of rotated files kept, five by default.
+	MaxBackups int `yaml:"maxbackups,omitempty"`
+}
+
 // MailOptions provides the configuration sections to user, for specific handler.
 type MailOptions struct {
 	SMTP struct {
@@ -325,6 +377,8 @@ func (storage Storage) Type() string {
 			// allow configuration of delete
 		case "redirect":
 			// allow configuration of redirect
+		case "catalog":
+			// allow configuration of the catalog index
 		default:
 			storageType = append(storageType, k)
 		}
@@ -366,6 +420,8 @@ func (storage *Storage) UnmarshalYAML(unmarshal func(interface{}) error) error {
 					// allow configuration of delete
 				case "redirect":
 					// allow configuration of redirect
+				case "catalog":
+					// allow configuration of the catalog index
 				default:
 					types = append(types, k)
 				}
@@ -529,6 +585,89 @@ type Proxy struct {
 	Password string `yaml:"password"`
 }
 
+// Quota configures the storage limits, in bytes, of repositories. A
+// repository is subject to its own limit and to the limit of every namespace
+// enclosing it.
+type Quota struct {
+	// Repositories maps repository names to their limit.
+	Repositories map[string]int64 `yaml:"repositories,omitempty"`
+
+	// Namespaces maps namespaces, such as "team-a", to the limit shared by
+	// all the repositories below them, such as "team-a/app".
+	Namespaces map[string]int64 `yaml:"namespaces,omitempty"`
+}
+
+// RateLimit configures the request rate limits of the registry. Requests
+// are limited separately by remote address, user and repository.
+type RateLimit struct {
+	// Routes maps route names, such as "manifest" or "blob", to the limit
+	// of requests to the route.
+	Routes map[string]RouteRateLimit `yaml:"routes,omitempty"`
+
+	// TrustedProxies lists the IP addresses and CIDR ranges of the proxies
+	// trusted to set the X-Forwarded-For and X-Real-Ip headers. The remote
+	// address of other requests is the address of their connection.
+	TrustedProxies []string `yaml:"trustedproxies,omitempty"`
+}
+
+// RouteRateLimit configures the token bucket limiting requests to a route.
+type RouteRateLimit struct {
+	// Rate is the number of requests per second allowed on average.
+	Rate float64 `yaml:"rate"`
+
+	// Burst is the number of requests allowed at once. It defaults to the
+	// rate, rounded up.
+	Burst int `yaml:"burst,omitempty"`
+
+	// By lists what requests are limited by, each with buckets of its own:
+	// "address", "user" and "repository". It defaults to all of them.
+	By []string `yaml:"by,omitempty"`
+}
+
+// Tracing configures the span exporter of the registry. Spans are only
+// exported when an exporter is configured.
+type Tracing struct {
+	// Exporter is the name the span exporter registers itself as, such as
+	// "jsonfile".
+	Exporter string `yaml:"exporter,omitempty"`
+
+	// Options are passed to the span exporter.
+	Options Parameters `yaml:"options,omitempty"`
+}
+
+// TokenIssuer configures the token server embedded in the registry. It
+// authenticates users against an htpasswd file and issues tokens granting the
+// access allowed by an access control list. It is enabled by setting the
+// signing key.
+type TokenIssuer struct {
+	// Path is the path of the token endpoint, "/auth/token" by default. It
+	// is set as the realm of the token access controller.
+	Path string `yaml:"path,omitempty"`
+
+	// Issuer is the name of the issuer of the tokens, which the token access
+	// controller must trust.
+	Issuer string `yaml:"issuer,omitempty"`
+
+	// Service is the name of the registry, tokens only being issued for
+	// this service.
+	Service string `yaml:"service,omitempty"`
+
+	// SigningKey is the libtrust key file used to sign tokens. The root
+	// certificate bundle of the token access controller must contain a
+	// certificate for this key.
+	SigningKey string `yaml:"signingkey,omitempty"`
+
+	// Expiration is how long tokens are valid, 5 minutes by default.
+	Expiration time.Duration `yaml:"expiration,omitempty"`
+
+	// HTPasswd is the htpasswd file authenticating users.
+	HTPasswd string `yaml:"htpasswd,omitempty"`
+
+	// ACL is the access control list file granting access to users. It is
+	// required, access it doesn't grant being denied.
+	ACL string `yaml:"acl,omitempty"`
+}
+
 // Parse parses an input configuration yaml document into a Configuration struct
 // This should generally be capable of handling old configuration format versions
 //
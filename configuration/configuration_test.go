@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 	"gopkg.in/yaml.v2"
@@ -23,6 +24,7 @@ var configStruct = Configuration{
 		Formatter string                 `yaml:"formatter,omitempty"`
 		Fields    map[string]interface{} `yaml:"fields,omitempty"`
 		Hooks     []LogHook              `yaml:"hooks,omitempty"`
+		AccessLog AccessLog              `yaml:"accesslog,omitempty"`
 	}{
 		Fields: map[string]interface{}{"environment": "test"},
 	},
@@ -330,6 +332,122 @@ func (suite *ConfigSuite) TestParseWithDifferentEnvReporting(c *C) {
 	c.Assert(config, DeepEquals, suite.expectedConfig)
 }
 
+// TestParseWithDifferentEnvCompatibility validates that the schema1 signing
+// key can be set with an environment variable
+func (suite *ConfigSuite) TestParseWithDifferentEnvCompatibility(c *C) {
+	suite.expectedConfig.Compatibility.Schema1.SigningKeyFile = "/etc/docker/registry/key.json"
+
+	os.Setenv("REGISTRY_COMPATIBILITY_SCHEMA1_SIGNINGKEYFILE", "/etc/docker/registry/key.json")
+
+	config, err := Parse(bytes.NewReader([]byte(configYamlV0_1)))
+	c.Assert(err, IsNil)
+	c.Assert(config, DeepEquals, suite.expectedConfig)
+}
+
+// TestParseQuota validates that repository and namespace quotas can be
+// configured.
+func (suite *ConfigSuite) TestParseQuota(c *C) {
+	suite.expectedConfig.Quota = Quota{
+		Repositories: map[string]int64{"team-a/app": 1024},
+		Namespaces:   map[string]int64{"team-a": 2048},
+	}
+
+	config, err := Parse(bytes.NewReader([]byte(configYamlV0_1 + `quota:
+  repositories:
+    team-a/app: 1024
+  namespaces:
+    team-a: 2048
+`)))
+	c.Assert(err, IsNil)
+	c.Assert(config, DeepEquals, suite.expectedConfig)
+}
+
+// TestParseRateLimit validates that rate limits can be configured per route.
+func (suite *ConfigSuite) TestParseRateLimit(c *C) {
+	suite.expectedConfig.RateLimit = RateLimit{
+		Routes: map[string]RouteRateLimit{
+			"manifest": {Rate: 10, Burst: 20},
+			"blob":     {Rate: 0.5, By: []string{"address", "user"}},
+		},
+		TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"},
+	}
+
+	config, err := Parse(bytes.NewReader([]byte(configYamlV0_1 + `ratelimit:
+  routes:
+    manifest:
+      rate: 10
+      burst: 20
+    blob:
+      rate: 0.5
+      by: [address, user]
+  trustedproxies:
+    - 10.0.0.0/8
+    - 192.168.1.1
+`)))
+	c.Assert(err, IsNil)
+	c.Assert(config, DeepEquals, suite.expectedConfig)
+}
+
+// TestParseTracing validates that a span exporter can be configured.
+func (suite *ConfigSuite) TestParseTracing(c *C) {
+	suite.expectedConfig.Tracing = Tracing{
+		Exporter: "jsonfile",
+		Options:  Parameters{"path": "/var/log/registry/spans.json"},
+	}
+
+	config, err := Parse(bytes.NewReader([]byte(configYamlV0_1 + `tracing:
+  exporter: jsonfile
+  options:
+    path: /var/log/registry/spans.json
+`)))
+	c.Assert(err, IsNil)
+	c.Assert(config, DeepEquals, suite.expectedConfig)
+}
+
+// TestParseTokenIssuer validates that the embedded token server can be
+// configured.
+func (suite *ConfigSuite) TestParseTokenIssuer(c *C) {
+	suite.expectedConfig.TokenIssuer = TokenIssuer{
+		Issuer:     "registry-token-issuer",
+		Service:    "registry.example.com",
+		SigningKey: "/etc/registry/token.json",
+		Expiration: 10 * time.Minute,
+		HTPasswd:   "/etc/registry/htpasswd",
+		ACL:        "/etc/registry/acl.yml",
+	}
+
+	config, err := Parse(bytes.NewReader([]byte(configYamlV0_1 + `tokenissuer:
+  issuer: registry-token-issuer
+  service: registry.example.com
+  signingkey: /etc/registry/token.json
+  expiration: 10m
+  htpasswd: /etc/registry/htpasswd
+  acl: /etc/registry/acl.yml
+`)))
+	c.Assert(err, IsNil)
+	c.Assert(config, DeepEquals, suite.expectedConfig)
+}
+
+// TestParseAccessLog validates that the access log can be configured.
+func (suite *ConfigSuite) TestParseAccessLog(c *C) {
+	suite.expectedConfig.Log.AccessLog = AccessLog{
+		Format:     "json",
+		Path:       "/var/log/registry/access.log",
+		MaxSize:    104857600,
+		MaxBackups: 3,
+	}
+
+	config, err := Parse(bytes.NewReader([]byte(strings.Replace(configYamlV0_1, "log:\n", `log:
+  accesslog:
+    format: json
+    path: /var/log/registry/access.log
+    maxsize: 104857600
+    maxbackups: 3
+`, 1))))
+	c.Assert(err, IsNil)
+	c.Assert(config, DeepEquals, suite.expectedConfig)
+}
+
 // TestParseInvalidVersion validates that the parser will fail to parse a newer configuration
 // version than the CurrentVersion
 func (suite *ConfigSuite) TestParseInvalidVersion(c *C) {
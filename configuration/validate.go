@@ -0,0 +1,612 @@
+package configuration
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/acl"
+	"github.com/docker/distribution/registry/auth/htpasswd"
+	registrymiddleware "github.com/docker/distribution/registry/middleware/registry"
+	repositorymiddleware "github.com/docker/distribution/registry/middleware/repository"
+	"github.com/docker/distribution/registry/storage/driver/factory"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+	"github.com/docker/distribution/tracing"
+	"github.com/docker/libtrust"
+)
+
+// ValidationError is a problem found in a configuration, located by the YAML
+// path of the offending value, such as "storage.cache.blobdescriptor".
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (err ValidationError) Error() string {
+	return err.Path + ": " + err.Message
+}
+
+// ValidationErrors lists every problem found in a configuration.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate checks every section of the configuration, returning
+// ValidationErrors listing all the problems found, if any. Beyond the checks
+// made when parsing, it checks that the storage driver and access controller
+// accept their parameters, that the middlewares, hooks and span exporter
+// configured are registered, that the files referenced exist and that
+// durations are sane.
+//
+// The storage drivers, middlewares, access controllers and span exporters
+// are only known once their packages are imported. The storage driver and
+// access controller are created to check their parameters, which may connect
+// to their backends.
+func (config *Configuration) Validate() error {
+	var v validator
+
+	v.validateLog(config)
+	v.validateStorage(config)
+	v.validateAuth(config)
+	v.validateTokenIssuer(config)
+	v.validateMiddleware(config)
+	v.validateHTTP(config)
+	v.validateNotifications(config)
+	v.validateRedis(config)
+	v.validateHealth(config)
+	v.validateProxy(config)
+	v.validateQuota(config)
+	v.validateRateLimit(config)
+	v.validateTracing(config)
+	v.validateCompatibility(config)
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// validator accumulates the problems found in a configuration.
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// duration checks a duration which may be left unset. Durations given as
+// plain numbers are parsed as nanoseconds, so very short ones likely lack a
+// unit.
+func (v *validator) duration(path string, d time.Duration) {
+	switch {
+	case d < 0:
+		v.errorf(path, "duration must not be negative: %v", d)
+	case d > 0 && d < time.Millisecond:
+		v.errorf(path, "duration %v is shorter than a millisecond, a unit such as \"s\" may be missing", d)
+	}
+}
+
+// durationString checks a duration given as a string, such as "168h".
+func (v *validator) durationString(path string, value interface{}) {
+	s, ok := value.(string)
+	if !ok {
+		v.errorf(path, "duration must be a string, such as \"10s\": %v", value)
+		return
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		v.errorf(path, "%v", err)
+		return
+	}
+	v.duration(path, d)
+}
+
+func (v *validator) boolean(path string, value interface{}) {
+	if _, ok := value.(bool); !ok {
+		v.errorf(path, "value must be true or false: %v", value)
+	}
+}
+
+// file checks that the file at name exists.
+func (v *validator) file(path, name string) {
+	if _, err := os.Stat(name); err != nil {
+		v.errorf(path, "%v", err)
+	}
+}
+
+// absoluteURL checks that rawurl is an absolute http or https URL.
+func (v *validator) absoluteURL(path, rawurl string) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		v.errorf(path, "%v", err)
+		return
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.errorf(path, "URL must be absolute, such as \"https://example.com\": %q", rawurl)
+	}
+}
+
+func (v *validator) hostPort(path, addr string) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		v.errorf(path, "%v", err)
+	}
+}
+
+// options checks that the keys of a section are known, as misspelled keys
+// are otherwise ignored. It returns the options as a map keyed by strings.
+func (v *validator) options(path string, value interface{}, known ...string) map[string]interface{} {
+	options := make(map[string]interface{})
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for k, option := range value {
+			options[k] = option
+		}
+	case Parameters:
+		for k, option := range value {
+			options[k] = option
+		}
+	case map[interface{}]interface{}:
+		for k, option := range value {
+			options[fmt.Sprint(k)] = option
+		}
+	default:
+		v.errorf(path, "section must contain options: %v", value)
+		return nil
+	}
+
+	var unknown []string
+	for k := range options {
+		if !contains(known, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, k := range unknown {
+		v.errorf(path+"."+k, "unknown option, expected one of %s", strings.Join(known, ", "))
+	}
+
+	return options
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) validateLog(config *Configuration) {
+	switch config.Log.Formatter {
+	case "", "text", "json", "logstash":
+	default:
+		v.errorf("log.formatter", "unknown formatter %q, expected one of text, json, logstash", config.Log.Formatter)
+	}
+
+	for i, hook := range config.Log.Hooks {
+		path := fmt.Sprintf("log.hooks[%d]", i)
+		if hook.Type != "mail" {
+			v.errorf(path+".type", "unknown hook type %q, expected mail", hook.Type)
+			continue
+		}
+
+		for j, level := range hook.Levels {
+			if _, err := logrus.ParseLevel(level); err != nil {
+				v.errorf(fmt.Sprintf("%s.levels[%d]", path, j), "%v", err)
+			}
+		}
+
+		if hook.MailOptions.SMTP.Addr == "" {
+			v.errorf(path+".options.smtp.addr", "address of the mail server is required")
+		} else {
+			v.hostPort(path+".options.smtp.addr", hook.MailOptions.SMTP.Addr)
+		}
+
+		if len(hook.MailOptions.To) == 0 {
+			v.errorf(path+".options.to", "at least one recipient is required")
+		}
+	}
+
+	accessLog := config.Log.AccessLog
+	switch accessLog.Format {
+	case "", "combined", "json":
+	default:
+		v.errorf("log.accesslog.format", "unknown format %q, expected one of combined, json", accessLog.Format)
+	}
+
+	if accessLog.Path != "" {
+		v.file("log.accesslog.path", filepath.Dir(accessLog.Path))
+	}
+
+	if accessLog.MaxSize < 0 {
+		v.errorf("log.accesslog.maxsize", "size must not be negative: %d", accessLog.MaxSize)
+	}
+
+	if accessLog.MaxBackups < 0 {
+		v.errorf("log.accesslog.maxbackups", "number of backups must not be negative: %d", accessLog.MaxBackups)
+	}
+}
+
+func (v *validator) validateStorage(config *Configuration) {
+	storageType := config.Storage.Type()
+	if storageType == "" {
+		v.errorf("storage", "no storage driver configured")
+	} else if _, err := factory.Create(storageType, config.Storage.Parameters()); err != nil {
+		v.errorf("storage."+storageType, "%v", err)
+	}
+
+	if maintenance, ok := config.Storage["maintenance"]; ok {
+		options := v.options("storage.maintenance", maintenance, "uploadpurging", "readonly")
+
+		if purging, ok := options["uploadpurging"]; ok {
+			// Once configured, upload purging requires all its options.
+			path := "storage.maintenance.uploadpurging"
+			purgingOptions := v.options(path, purging, "enabled", "age", "interval", "dryrun")
+			if enabled, ok := purgingOptions["enabled"]; ok {
+				v.boolean(path+".enabled", enabled)
+			}
+
+			if purgingOptions != nil && purgingOptions["enabled"] != false {
+				for _, k := range []string{"age", "interval", "dryrun"} {
+					value, ok := purgingOptions[k]
+					switch {
+					case !ok:
+						v.errorf(path+"."+k, "option is required to purge uploads")
+					case k == "dryrun":
+						v.boolean(path+"."+k, value)
+					default:
+						v.durationString(path+"."+k, value)
+					}
+				}
+			}
+		}
+
+		if readOnly, ok := options["readonly"]; ok {
+			readOnlyOptions := v.options("storage.maintenance.readonly", readOnly, "enabled")
+			if enabled, ok := readOnlyOptions["enabled"]; ok {
+				v.boolean("storage.maintenance.readonly.enabled", enabled)
+			}
+		}
+	}
+
+	if cache, ok := config.Storage["cache"]; ok {
+		options := v.options("storage.cache", cache, "blobdescriptor", "layerinfo")
+		for _, k := range []string{"blobdescriptor", "layerinfo"} {
+			value, ok := options[k]
+			if !ok {
+				continue
+			}
+
+			switch value {
+			case "inmemory":
+			case "redis":
+				if config.Redis.Addr == "" {
+					v.errorf("storage.cache."+k, "redis cache requires redis.addr to be configured")
+				}
+			default:
+				v.errorf("storage.cache."+k, "unknown cache type %v, expected one of inmemory, redis", value)
+			}
+		}
+	}
+
+	for _, section := range []struct{ name, option string }{
+		{"delete", "enabled"},
+		{"redirect", "disable"},
+		{"catalog", "index"},
+	} {
+		if parameters, ok := config.Storage[section.name]; ok {
+			path := "storage." + section.name
+			options := v.options(path, parameters, section.option)
+			if value, ok := options[section.option]; ok {
+				v.boolean(path+"."+section.option, value)
+			}
+		}
+	}
+}
+
+func (v *validator) validateAuth(config *Configuration) {
+	authType := config.Auth.Type()
+	if authType == "" {
+		return
+	}
+
+	if _, err := auth.GetAccessController(authType, config.Auth.Parameters()); err != nil {
+		v.errorf("auth."+authType, "%v", err)
+	}
+
+	// Client certificates are only verified by the TLS server with client
+	// certificate authorities.
+	if authType == "x509" && (config.HTTP.TLS.Certificate == "" || len(config.HTTP.TLS.ClientCAs) == 0) {
+		v.errorf("auth.x509", "requires http.tls.certificate and http.tls.clientcas to verify client certificates")
+	}
+}
+
+func (v *validator) validateTokenIssuer(config *Configuration) {
+	issuer := config.TokenIssuer
+	if issuer == (TokenIssuer{}) {
+		return
+	}
+
+	if issuer.SigningKey == "" {
+		v.errorf("tokenissuer.signingkey", "signing key is required to enable the token issuer")
+	} else if _, err := libtrust.LoadKeyFile(issuer.SigningKey); err != nil {
+		v.errorf("tokenissuer.signingkey", "%v", err)
+	}
+
+	if issuer.Path != "" && (!strings.HasPrefix(issuer.Path, "/") || strings.HasPrefix(issuer.Path, "/v2/")) {
+		v.errorf("tokenissuer.path", "path must be absolute and outside of /v2/: %q", issuer.Path)
+	}
+
+	if issuer.Issuer == "" {
+		v.errorf("tokenissuer.issuer", "issuer is required")
+	}
+
+	if issuer.Service == "" {
+		v.errorf("tokenissuer.service", "service is required")
+	}
+
+	if issuer.Expiration != 0 {
+		v.duration("tokenissuer.expiration", issuer.Expiration)
+	}
+
+	if issuer.HTPasswd == "" {
+		v.errorf("tokenissuer.htpasswd", "htpasswd file is required")
+	} else if _, err := htpasswd.LoadHTPasswd(issuer.HTPasswd); err != nil {
+		v.errorf("tokenissuer.htpasswd", "%v", err)
+	}
+
+	if issuer.ACL == "" {
+		v.errorf("tokenissuer.acl", "access control list is required")
+	} else if _, err := acl.Load(issuer.ACL); err != nil {
+		v.errorf("tokenissuer.acl", "%v", err)
+	}
+}
+
+func (v *validator) validateMiddleware(config *Configuration) {
+	registered := map[string]func(name string) bool{
+		"registry":   registrymiddleware.Registered,
+		"repository": repositorymiddleware.Registered,
+		"storage":    storagemiddleware.Registered,
+	}
+
+	for kind, middlewares := range config.Middleware {
+		isRegistered, ok := registered[kind]
+		if !ok {
+			v.errorf("middleware."+kind, "unknown kind of middleware, expected one of registry, repository, storage")
+			continue
+		}
+
+		for i, middleware := range middlewares {
+			path := fmt.Sprintf("middleware.%s[%d].name", kind, i)
+			if middleware.Name == "" {
+				v.errorf(path, "name is required")
+			} else if !isRegistered(middleware.Name) {
+				v.errorf(path, "no %s middleware registered with name %q", kind, middleware.Name)
+			}
+		}
+	}
+}
+
+func (v *validator) validateHTTP(config *Configuration) {
+	switch config.HTTP.Net {
+	case "", "tcp", "unix":
+	default:
+		v.errorf("http.net", "unknown network %q, expected one of tcp, unix", config.HTTP.Net)
+	}
+
+	if config.HTTP.Host != "" {
+		v.absoluteURL("http.host", config.HTTP.Host)
+	}
+
+	if config.HTTP.Prefix != "" && !strings.HasPrefix(config.HTTP.Prefix, "/") {
+		v.errorf("http.prefix", "prefix must start with \"/\": %q", config.HTTP.Prefix)
+	}
+
+	tlsConfig := config.HTTP.TLS
+	switch {
+	case tlsConfig.Certificate == "" && tlsConfig.Key != "":
+		v.errorf("http.tls.certificate", "certificate is required with a key")
+	case tlsConfig.Certificate != "" && tlsConfig.Key == "":
+		v.errorf("http.tls.key", "key is required with a certificate")
+	case tlsConfig.Certificate != "":
+		if _, err := tls.LoadX509KeyPair(tlsConfig.Certificate, tlsConfig.Key); err != nil {
+			v.errorf("http.tls", "%v", err)
+		}
+	}
+
+	for i, ca := range tlsConfig.ClientCAs {
+		path := fmt.Sprintf("http.tls.clientcas[%d]", i)
+		pem, err := ioutil.ReadFile(ca)
+		if err != nil {
+			v.errorf(path, "%v", err)
+			continue
+		}
+
+		if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			v.errorf(path, "no PEM encoded certificate found in %s", ca)
+		}
+	}
+}
+
+func (v *validator) validateNotifications(config *Configuration) {
+	for i, endpoint := range config.Notifications.Endpoints {
+		path := fmt.Sprintf("notifications.endpoints[%d]", i)
+		if endpoint.Name == "" {
+			v.errorf(path+".name", "name is required")
+		}
+
+		v.absoluteURL(path+".url", endpoint.URL)
+		v.duration(path+".timeout", endpoint.Timeout)
+		v.duration(path+".backoff", endpoint.Backoff)
+
+		if endpoint.Threshold < 0 {
+			v.errorf(path+".threshold", "threshold must not be negative: %d", endpoint.Threshold)
+		}
+	}
+}
+
+func (v *validator) validateRedis(config *Configuration) {
+	if config.Redis.Addr == "" {
+		return
+	}
+
+	v.hostPort("redis.addr", config.Redis.Addr)
+	v.duration("redis.dialtimeout", config.Redis.DialTimeout)
+	v.duration("redis.readtimeout", config.Redis.ReadTimeout)
+	v.duration("redis.writetimeout", config.Redis.WriteTimeout)
+	v.duration("redis.pool.idletimeout", config.Redis.Pool.IdleTimeout)
+
+	if config.Redis.Pool.MaxIdle < 0 {
+		v.errorf("redis.pool.maxidle", "number of connections must not be negative: %d", config.Redis.Pool.MaxIdle)
+	}
+
+	if config.Redis.Pool.MaxActive < 0 {
+		v.errorf("redis.pool.maxactive", "number of connections must not be negative: %d", config.Redis.Pool.MaxActive)
+	}
+}
+
+func (v *validator) validateHealth(config *Configuration) {
+	health := config.Health
+	v.duration("health.storagedriver.interval", health.StorageDriver.Interval)
+	v.threshold("health.storagedriver.threshold", health.StorageDriver.Threshold)
+
+	// Checks are registered by name, which must be unique.
+	names := make(map[string]string)
+	unique := func(path, name string) {
+		if previous, ok := names[name]; ok {
+			v.errorf(path, "health check %s is already configured by %s", name, previous)
+		}
+		names[name] = path
+	}
+
+	for i, checker := range health.FileCheckers {
+		path := fmt.Sprintf("health.file[%d]", i)
+		if checker.File == "" {
+			v.errorf(path+".file", "file is required")
+		}
+		unique(path+".file", checker.File)
+		v.duration(path+".interval", checker.Interval)
+	}
+
+	for i, checker := range health.HTTPCheckers {
+		path := fmt.Sprintf("health.http[%d]", i)
+		v.absoluteURL(path+".uri", checker.URI)
+		unique(path+".uri", checker.URI)
+
+		if checker.StatusCode != 0 && (checker.StatusCode < 100 || checker.StatusCode > 599) {
+			v.errorf(path+".statuscode", "invalid HTTP status code: %d", checker.StatusCode)
+		}
+
+		v.duration(path+".timeout", checker.Timeout)
+		v.duration(path+".interval", checker.Interval)
+		v.threshold(path+".threshold", checker.Threshold)
+	}
+
+	for i, checker := range health.TCPCheckers {
+		path := fmt.Sprintf("health.tcp[%d]", i)
+		v.hostPort(path+".addr", checker.Addr)
+		unique(path+".addr", checker.Addr)
+		v.duration(path+".timeout", checker.Timeout)
+		v.duration(path+".interval", checker.Interval)
+		v.threshold(path+".threshold", checker.Threshold)
+	}
+}
+
+func (v *validator) threshold(path string, threshold int) {
+	if threshold < 0 {
+		v.errorf(path, "threshold must not be negative: %d", threshold)
+	}
+}
+
+func (v *validator) validateProxy(config *Configuration) {
+	if config.Proxy.RemoteURL != "" {
+		v.absoluteURL("proxy.remoteurl", config.Proxy.RemoteURL)
+	}
+}
+
+func (v *validator) validateQuota(config *Configuration) {
+	quota := func(section, name string, limit int64) {
+		path := fmt.Sprintf("quota.%s.%s", section, name)
+		if _, err := reference.ParseNamed(strings.TrimSuffix(name, "/")); err != nil {
+			v.errorf(path, "invalid name: %v", err)
+		}
+
+		if limit <= 0 {
+			v.errorf(path, "quota must be positive: %d", limit)
+		}
+	}
+
+	for name, limit := range config.Quota.Repositories {
+		quota("repositories", name, limit)
+	}
+
+	for namespace, limit := range config.Quota.Namespaces {
+		quota("namespaces", namespace, limit)
+	}
+}
+
+func (v *validator) validateRateLimit(config *Configuration) {
+	router := v2.Router()
+	for name, limit := range config.RateLimit.Routes {
+		path := "ratelimit.routes." + name
+		// The token endpoint of the token issuer is rate limited as the
+		// "token" route.
+		if router.Get(name) == nil && (name != "token" || config.TokenIssuer.SigningKey == "") {
+			v.errorf(path, "unknown route %q", name)
+		}
+
+		if limit.Rate <= 0 {
+			v.errorf(path+".rate", "rate must be positive: %v", limit.Rate)
+		}
+
+		if limit.Burst < 0 {
+			v.errorf(path+".burst", "burst must not be negative: %d", limit.Burst)
+		}
+	}
+
+	for i, proxy := range config.RateLimit.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			v.errorf(fmt.Sprintf("ratelimit.trustedproxies[%d]", i), "invalid IP address or CIDR range: %q", proxy)
+		}
+	}
+}
+
+func (v *validator) validateTracing(config *Configuration) {
+	if config.Tracing.Exporter != "" && !tracing.Registered(config.Tracing.Exporter) {
+		v.errorf("tracing.exporter", "no span exporter registered with name %q", config.Tracing.Exporter)
+	}
+}
+
+func (v *validator) validateCompatibility(config *Configuration) {
+	if keyFile := config.Compatibility.Schema1.SigningKeyFile; keyFile != "" {
+		if _, err := libtrust.LoadKeyFile(keyFile); err != nil {
+			v.errorf("compatibility.schema1.signingkeyfile", "%v", err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package configuration
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+
+	_ "github.com/docker/distribution/registry/auth/silly"
+	_ "github.com/docker/distribution/registry/auth/x509"
+	_ "github.com/docker/distribution/registry/storage/driver/inmemory"
+	. "gopkg.in/check.v1"
+)
+
+// TestValidate validates that a sound configuration has no problems.
+func (suite *ConfigSuite) TestValidate(c *C) {
+	config, err := Parse(bytes.NewReader([]byte(`
+version: 0.1
+log:
+  formatter: json
+  hooks:
+    - type: mail
+      levels:
+        - panic
+      options:
+        smtp:
+          addr: smtp.example.com:25
+        to:
+          - errors@example.com
+storage:
+  inmemory:
+  cache:
+    blobdescriptor: inmemory
+  maintenance:
+    uploadpurging:
+      enabled: true
+      age: 168h
+      interval: 24h
+      dryrun: false
+  redirect:
+    disable: true
+auth:
+  silly:
+    realm: realm-test
+    service: service-test
+notifications:
+  endpoints:
+    - name: listener
+      url: https://listener.example.com/events
+      timeout: 500ms
+      threshold: 5
+      backoff: 1s
+ratelimit:
+  routes:
+    manifest:
+      rate: 10
+`)))
+	c.Assert(err, IsNil)
+	c.Assert(config.Validate(), IsNil)
+}
+
+// TestValidateProblems validates that every problem of a configuration is
+// reported with its YAML path.
+func (suite *ConfigSuite) TestValidateProblems(c *C) {
+	config, err := Parse(bytes.NewReader([]byte(`
+version: 0.1
+log:
+  formatter: xml
+storage:
+  inmemory:
+  cache:
+    blobdescriptr: inmemory
+  maintenance:
+    uploadpurging:
+      age: 168h
+      interval: 24
+  redirect:
+    disable: "no"
+auth:
+  unknown:
+    realm: realm-test
+middleware:
+  storage:
+    - name: cloudfrnt
+http:
+  tls:
+    certificate: /nonexistent/registry.crt
+notifications:
+  endpoints:
+    - name: listener
+      url: listener.example.com/events
+      timeout: 500
+ratelimit:
+  trustedproxies:
+    - proxy.example.com
+tracing:
+  exporter: zipkin
+`)))
+	c.Assert(err, IsNil)
+
+	err = config.Validate()
+	c.Assert(err, NotNil)
+
+	var paths []string
+	for _, problem := range err.(ValidationErrors) {
+		paths = append(paths, problem.Path)
+	}
+
+	c.Assert(paths, DeepEquals, []string{
+		"log.formatter",
+		"storage.maintenance.uploadpurging.interval",
+		"storage.maintenance.uploadpurging.dryrun",
+		"storage.cache.blobdescriptr",
+		"storage.redirect.disable",
+		"auth.unknown",
+		"middleware.storage[0].name",
+		"http.tls.key",
+		"notifications.endpoints[0].url",
+		"notifications.endpoints[0].timeout",
+		"ratelimit.trustedproxies[0]",
+		"tracing.exporter",
+	})
+}
+
+// TestValidateX509WithoutClientCAs validates that the x509 access controller
+// requires client certificates to be verified.
+func (suite *ConfigSuite) TestValidateX509WithoutClientCAs(c *C) {
+	aclPath := filepath.Join(c.MkDir(), "acl.yml")
+	c.Assert(ioutil.WriteFile(aclPath, []byte("rules:\n  - users: [alice]\n    repositories: [\"*\"]\n    actions: [pull]\n"), 0600), IsNil)
+
+	config, err := Parse(bytes.NewReader([]byte(`
+version: 0.1
+storage:
+  inmemory:
+auth:
+  x509:
+    username: cn
+    acl: ` + aclPath + `
+`)))
+	c.Assert(err, IsNil)
+
+	err = config.Validate()
+	c.Assert(err, NotNil)
+	c.Assert(err.(ValidationErrors), HasLen, 1)
+	c.Assert(err.(ValidationErrors)[0].Path, Equals, "auth.x509")
+}
@@ -0,0 +1,203 @@
+package context
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader is the header propagating spans across services, as
+// specified by W3C Trace Context.
+const TraceparentHeader = "traceparent"
+
+// SpanContext identifies a span within a trace, as propagated by the
+// traceparent header. Identifiers are lowercase hex strings.
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+var traceparentRegexp = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})(-.*)?$`)
+
+// ParseTraceparent parses the value of a traceparent header. Versions other
+// than 00 are parsed as far as they are understood, as the specification
+// requires.
+func ParseTraceparent(header string) (SpanContext, error) {
+	matches := traceparentRegexp.FindStringSubmatch(header)
+	if matches == nil {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", header)
+	}
+
+	version, traceID, spanID, flags, rest := matches[1], matches[2], matches[3], matches[4], matches[5]
+	if version == "ff" || (version == "00" && rest != "") {
+		return SpanContext{}, fmt.Errorf("invalid traceparent version in %q", header)
+	}
+
+	if traceID == "00000000000000000000000000000000" || spanID == "0000000000000000" {
+		return SpanContext{}, fmt.Errorf("invalid traceparent ids in %q", header)
+	}
+
+	f, err := strconv.ParseUint(flags, 16, 8)
+	if err != nil {
+		return SpanContext{}, fmt.Errorf("invalid traceparent flags in %q", header)
+	}
+
+	return SpanContext{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Sampled: f&1 == 1,
+	}, nil
+}
+
+// Traceparent returns the value of the traceparent header propagating the
+// span.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return "00-" + sc.TraceID + "-" + sc.SpanID + "-" + flags
+}
+
+// Span is a timed operation within a trace, such as a request or a call to
+// the storage driver. Spans are created by WithSpan and exported once
+// finished.
+type Span struct {
+	Name       string                 `json:"name"`
+	TraceID    string                 `json:"traceId"`
+	SpanID     string                 `json:"spanId"`
+	ParentID   string                 `json:"parentId,omitempty"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Duration   time.Duration          `json:"duration"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+
+	ctx      Context
+	sampled  bool
+	mu       sync.Mutex
+	finished bool
+}
+
+// SpanExporter exports finished spans, such as to a file or a tracing
+// backend. ExportSpan may be called concurrently.
+type SpanExporter interface {
+	ExportSpan(span *Span) error
+}
+
+// WithSpanExporter returns a context exporting the spans created from it to
+// exporter. Spans are only exported if an exporter is set.
+func WithSpanExporter(ctx Context, exporter SpanExporter) Context {
+	return WithValue(ctx, "trace.exporter", exporter)
+}
+
+// WithSpanContext returns a context whose spans are children of the span
+// identified by sc, usually propagated from another service.
+func WithSpanContext(ctx Context, sc SpanContext) Context {
+	return WithValue(ctx, "trace.span.context", sc)
+}
+
+// GetSpanContext returns the context of the current span of ctx, if any.
+func GetSpanContext(ctx Context) (SpanContext, bool) {
+	sc, ok := ctx.Value("trace.span.context").(SpanContext)
+	return sc, ok
+}
+
+// WithSpan starts a span named name in a new context, which is a child of
+// the current span of ctx, if any, or starts a new trace. The span must be
+// finished with Finish.
+//
+// Here is an example of the usage:
+//
+//	func tracedOperation(ctx Context) (err error) {
+//		ctx, span := WithSpan(ctx, "tracedOperation")
+//		defer func() { span.Finish(err) }()
+//		// ... function body ...
+//	}
+func WithSpan(ctx Context, name string) (Context, *Span) {
+	if ctx == nil {
+		ctx = Background()
+	}
+
+	span := &Span{
+		Name:    name,
+		SpanID:  randomID(8),
+		Start:   time.Now(),
+		sampled: true,
+	}
+
+	if parent, ok := GetSpanContext(ctx); ok {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+		span.sampled = parent.Sampled
+	} else {
+		span.TraceID = randomID(16)
+	}
+
+	ctx = WithValue(ctx, "trace.span.context", span.Context())
+	span.ctx = ctx
+
+	return ctx, span
+}
+
+// Context returns the span context identifying the span.
+func (s *Span) Context() SpanContext {
+	return SpanContext{
+		TraceID: s.TraceID,
+		SpanID:  s.SpanID,
+		Sampled: s.sampled,
+	}
+}
+
+// SetAttribute sets an attribute describing the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{})
+	}
+	s.Attributes[key] = value
+}
+
+// Finish ends the span, recording err if not nil, and exports it. Spans are
+// only finished once, later calls being ignored.
+func (s *Span) Finish(err error) {
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	s.finished = true
+	s.End = time.Now()
+	s.Duration = s.End.Sub(s.Start)
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.mu.Unlock()
+
+	exporter, ok := s.ctx.Value("trace.exporter").(SpanExporter)
+	if !ok || !s.sampled {
+		return
+	}
+
+	if err := exporter.ExportSpan(s); err != nil {
+		GetLogger(s.ctx).Errorf("error exporting span %s: %v", s.Name, err)
+	}
+}
+
+// randomID returns a random identifier of n bytes, hex encoded.
+func randomID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("error reading random bytes: %v", err))
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,98 @@
+package context
+
+import (
+	"errors"
+	"testing"
+)
+
+type recordingExporter []*Span
+
+func (re *recordingExporter) ExportSpan(span *Span) error {
+	*re = append(*re, span)
+	return nil
+}
+
+// TestParseTraceparent checks that valid traceparent headers are parsed and
+// formatted back, while invalid ones are rejected.
+func TestParseTraceparent(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(header)
+	if err != nil {
+		t.Fatalf("unexpected error parsing traceparent: %v", err)
+	}
+
+	expected := SpanContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  "00f067aa0ba902b7",
+		Sampled: true,
+	}
+	if sc != expected {
+		t.Fatalf("unexpected span context: %#v != %#v", sc, expected)
+	}
+
+	if sc.Traceparent() != header {
+		t.Fatalf("unexpected traceparent: %q != %q", sc.Traceparent(), header)
+	}
+
+	// Future versions may add fields.
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future"); err != nil {
+		t.Fatalf("unexpected error parsing future traceparent: %v", err)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(invalid); err == nil {
+			t.Fatalf("expected error parsing traceparent %q", invalid)
+		}
+	}
+}
+
+// TestWithSpan checks that spans are linked to their parents and exported
+// once finished.
+func TestWithSpan(t *testing.T) {
+	var exported recordingExporter
+	ctx := WithSpanExporter(Background(), &exported)
+
+	ctx, root := WithSpan(ctx, "root")
+	_, child := WithSpan(ctx, "child")
+	child.SetAttribute("key", "value")
+	child.Finish(errors.New("failed"))
+	child.Finish(nil)
+	root.Finish(nil)
+
+	if len(exported) != 2 || exported[0] != child || exported[1] != root {
+		t.Fatalf("unexpected exported spans: %v", exported)
+	}
+
+	if child.TraceID != root.TraceID || child.ParentID != root.SpanID || root.ParentID != "" {
+		t.Fatalf("spans are not linked: %+v, %+v", root, child)
+	}
+
+	if child.Error != "failed" || child.Attributes["key"] != "value" {
+		t.Fatalf("unexpected child span: %+v", child)
+	}
+
+	// Spans of a remote parent which isn't sampled aren't exported.
+	exported = nil
+	ctx = WithSpanContext(ctx, SpanContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  "00f067aa0ba902b7",
+	})
+	_, remote := WithSpan(ctx, "remote")
+	remote.Finish(nil)
+
+	if remote.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || remote.ParentID != "00f067aa0ba902b7" {
+		t.Fatalf("span is not linked to its remote parent: %+v", remote)
+	}
+
+	if len(exported) != 0 {
+		t.Fatalf("unexpected exported spans: %v", exported)
+	}
+}
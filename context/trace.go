@@ -14,8 +14,8 @@ import (
 // "trace.func" field, corresponding to the function that called WithTrace.
 //
 // The logging keys "trace.id" and "trace.parent.id" are provided to implement
-// dapper-like tracing. This function should be complemented with a WithSpan
-// method that could be used for tracing distributed RPC calls.
+// dapper-like tracing. WithSpan complements this function for tracing
+// distributed RPC calls, with spans propagated across services and exported.
 //
 // The main benefit of this function is to post-process log messages or
 // intercept them in a hook to provide timing data. Trace ids and parent ids
@@ -8,10 +8,6 @@ import (
 	"github.com/docker/distribution/digest"
 )
 
-// ErrManifestNotModified is returned when a conditional manifest GetByTag
-// returns nil due to the client indicating it has the latest version
-var ErrManifestNotModified = errors.New("manifest not modified")
-
 // ErrUnsupported is returned when an unimplemented or unsupported action is
 // performed
 var ErrUnsupported = errors.New("operation unsupported")
@@ -37,6 +33,15 @@ func (err ErrRepositoryNameInvalid) Error() string {
 	return fmt.Sprintf("repository name %q invalid: %v", err.Name, err.Reason)
 }
 
+// ErrTagUnknown is returned if the given tag is not known by the tag service.
+type ErrTagUnknown struct {
+	Tag string
+}
+
+func (err ErrTagUnknown) Error() string {
+	return fmt.Sprintf("unknown tag=%s", err.Tag)
+}
+
 // ErrManifestUnknown is returned if the manifest is not known by the
 // registry.
 type ErrManifestUnknown struct {
@@ -130,33 +130,52 @@ func NewThresholdStatusUpdater(t int) Updater {
 	return &thresholdUpdater{threshold: t}
 }
 
-// PeriodicChecker wraps an updater to provide a periodic checker
-func PeriodicChecker(check Checker, period time.Duration) Checker {
-	u := NewStatusUpdater()
+// periodicChecker is an updater periodically updated with the status of a
+// check, until stopped.
+type periodicChecker struct {
+	Updater
+	stop chan struct{}
+}
+
+// newPeriodicChecker starts updating u with the status of check every
+// period.
+func newPeriodicChecker(u Updater, check Checker, period time.Duration) *periodicChecker {
+	pc := &periodicChecker{
+		Updater: u,
+		stop:    make(chan struct{}),
+	}
+
 	go func() {
 		t := time.NewTicker(period)
+		defer t.Stop()
 		for {
-			<-t.C
-			u.Update(check.Check())
+			select {
+			case <-t.C:
+				u.Update(check.Check())
+			case <-pc.stop:
+				return
+			}
 		}
 	}()
 
-	return u
+	return pc
+}
+
+// Stop stops the periodic checks. It is called when the checker is
+// unregistered.
+func (pc *periodicChecker) Stop() {
+	close(pc.stop)
+}
+
+// PeriodicChecker wraps an updater to provide a periodic checker
+func PeriodicChecker(check Checker, period time.Duration) Checker {
+	return newPeriodicChecker(NewStatusUpdater(), check, period)
 }
 
 // PeriodicThresholdChecker wraps an updater to provide a periodic checker that
 // uses a threshold before it changes status
 func PeriodicThresholdChecker(check Checker, period time.Duration, threshold int) Checker {
-	tu := NewThresholdStatusUpdater(threshold)
-	go func() {
-		t := time.NewTicker(period)
-		for {
-			<-t.C
-			tu.Update(check.Check())
-		}
-	}()
-
-	return tu
+	return newPeriodicChecker(NewThresholdStatusUpdater(threshold), check, period)
 }
 
 // CheckStatus returns a map with all the current health check errors
@@ -200,6 +219,31 @@ func Register(name string, check Checker) {
 	DefaultRegistry.Register(name, check)
 }
 
+// Unregister removes the checker with the provided name, if any. Periodic
+// checkers are stopped.
+func (registry *Registry) Unregister(name string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	check, ok := registry.registeredChecks[name]
+	if !ok {
+		return
+	}
+	delete(registry.registeredChecks, name)
+
+	if stopper, ok := check.(interface {
+		Stop()
+	}); ok {
+		stopper.Stop()
+	}
+}
+
+// Unregister removes the checker with the provided name from the default
+// registry.
+func Unregister(name string) {
+	DefaultRegistry.Unregister(name)
+}
+
 // RegisterFunc allows the convenience of registering a checker directly from
 // an arbitrary func() error.
 func (registry *Registry) RegisterFunc(name string, check func() error) {
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 // TestReturns200IfThereAreNoChecks ensures that the result code of the health
@@ -105,3 +106,34 @@ func TestHealthHandler(t *testing.T) {
 	updater.Update(nil)
 	checkUp(t, "when server is back up") // now we should be back up.
 }
+
+// TestUnregister checks that unregistered checks are stopped and no longer
+// reported, their name being available again.
+func TestUnregister(t *testing.T) {
+	registry := NewRegistry()
+
+	checker := PeriodicChecker(CheckFunc(func() error {
+		return errors.New("This Check did not succeed")
+	}), time.Millisecond)
+	registry.Register("periodic_check", checker)
+
+	time.Sleep(10 * time.Millisecond)
+	if status := registry.CheckStatus(); len(status) != 1 {
+		t.Fatalf("unexpected status: %v", status)
+	}
+
+	registry.Unregister("periodic_check")
+	if status := registry.CheckStatus(); len(status) != 0 {
+		t.Fatalf("unexpected status after unregistering: %v", status)
+	}
+
+	select {
+	case <-checker.(*periodicChecker).stop:
+	default:
+		t.Fatalf("periodic checker was not stopped")
+	}
+
+	// Unregistering unknown checks is a no-op.
+	registry.Unregister("periodic_check")
+	registry.RegisterFunc("periodic_check", func() error { return nil })
+}
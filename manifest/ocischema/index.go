@@ -0,0 +1,166 @@
+package ocischema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+)
+
+// IndexMediaType specifies the mediaType for OCI image indexes.
+const IndexMediaType = "application/vnd.oci.image.index.v1+json"
+
+// IndexSchemaVersion provides a pre-initialized version structure for OCI
+// image indexes.
+var IndexSchemaVersion = manifest.Versioned{
+	SchemaVersion: 2,
+	MediaType:     IndexMediaType,
+}
+
+func init() {
+	indexFunc := func(b []byte) (distribution.Manifest, distribution.Descriptor, error) {
+		m := new(DeserializedImageIndex)
+		if err := m.UnmarshalJSON(b); err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+
+		dgst, err := digest.FromBytes(b)
+		if err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+
+		return m, distribution.Descriptor{
+			MediaType: IndexMediaType,
+			Size:      int64(len(b)),
+			Digest:    dgst,
+		}, nil
+	}
+
+	if err := distribution.RegisterManifestSchema(IndexMediaType, indexFunc); err != nil {
+		panic(fmt.Sprintf("Unable to register manifest: %s", err))
+	}
+}
+
+// Platform describes the platform a manifest referenced by an image index
+// runs on.
+type Platform struct {
+	// Architecture field specifies the CPU architecture, for example
+	// `amd64` or `ppc64le`.
+	Architecture string `json:"architecture"`
+
+	// OS specifies the operating system, for example `linux` or `windows`.
+	OS string `json:"os"`
+
+	// OSVersion is an optional field specifying the operating system
+	// version, for example `10.0.10586`.
+	OSVersion string `json:"os.version,omitempty"`
+
+	// OSFeatures is an optional field specifying an array of strings,
+	// each listing a required OS feature (for example `win32k`).
+	OSFeatures []string `json:"os.features,omitempty"`
+
+	// Variant is an optional field specifying a variant of the CPU, for
+	// example `v7` to specify ARMv7 when architecture is `arm`.
+	Variant string `json:"variant,omitempty"`
+}
+
+// A ManifestDescriptor references a manifest from an image index.
+type ManifestDescriptor struct {
+	Descriptor
+
+	// Platform optionally specifies which platform the manifest pointed to
+	// by the descriptor runs on.
+	Platform *Platform `json:"platform,omitempty"`
+}
+
+// ImageIndex references manifests for various platforms.
+type ImageIndex struct {
+	manifest.Versioned
+
+	// Manifests references platform specific manifests.
+	Manifests []ManifestDescriptor `json:"manifests"`
+
+	// Annotations holds arbitrary metadata about the image index.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// References returns the distribution descriptors for the referenced
+// manifests.
+func (m ImageIndex) References() []distribution.Descriptor {
+	dependencies := make([]distribution.Descriptor, len(m.Manifests))
+	for i := range m.Manifests {
+		dependencies[i] = m.Manifests[i].Descriptor.Descriptor
+	}
+
+	return dependencies
+}
+
+// DeserializedImageIndex wraps ImageIndex with a copy of the original JSON.
+type DeserializedImageIndex struct {
+	ImageIndex
+
+	// canonical is the canonical byte representation of the ImageIndex.
+	canonical []byte
+}
+
+var _ distribution.Manifest = &DeserializedImageIndex{}
+
+// FromImageIndex takes an ImageIndex structure, marshals it to JSON, and
+// returns a DeserializedImageIndex which contains the index and its JSON
+// representation.
+func FromImageIndex(m ImageIndex) (*DeserializedImageIndex, error) {
+	var deserialized DeserializedImageIndex
+	deserialized.ImageIndex = m
+
+	var err error
+	deserialized.canonical, err = json.MarshalIndent(&m, "", "   ")
+	return &deserialized, err
+}
+
+// UnmarshalJSON populates a new ImageIndex struct from JSON data. The media
+// type is optional in OCI image indexes, but must match if it is set.
+func (m *DeserializedImageIndex) UnmarshalJSON(b []byte) error {
+	m.canonical = make([]byte, len(b), len(b))
+	// store image index in canonical
+	copy(m.canonical, b)
+
+	// Unmarshal canonical JSON into ImageIndex object
+	var index ImageIndex
+	if err := json.Unmarshal(m.canonical, &index); err != nil {
+		return err
+	}
+
+	if index.SchemaVersion != IndexSchemaVersion.SchemaVersion {
+		return fmt.Errorf("unexpected schema version %d for OCI image index", index.SchemaVersion)
+	}
+
+	if index.MediaType != "" && index.MediaType != IndexMediaType {
+		return fmt.Errorf("unexpected media type %q for OCI image index", index.MediaType)
+	}
+
+	if index.Manifests == nil {
+		return errors.New("OCI image index has no manifests field")
+	}
+
+	m.ImageIndex = index
+	return nil
+}
+
+// MarshalJSON returns the contents of canonical, which must have been set by
+// unmarshaling or construction.
+func (m *DeserializedImageIndex) MarshalJSON() ([]byte, error) {
+	if len(m.canonical) > 0 {
+		return m.canonical, nil
+	}
+
+	return nil, errors.New("JSON representation not initialized in DeserializedImageIndex")
+}
+
+// Payload returns the raw content of the image index. The contents can be
+// used to calculate the content identifier.
+func (m DeserializedImageIndex) Payload() (string, []byte, error) {
+	return IndexMediaType, m.canonical, nil
+}
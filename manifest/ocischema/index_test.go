@@ -0,0 +1,126 @@
+package ocischema
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution"
+)
+
+var expectedIndexSerialization = []byte(`{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.oci.image.index.v1+json",
+   "manifests": [
+      {
+         "mediaType": "application/vnd.oci.image.manifest.v1+json",
+         "size": 985,
+         "digest": "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b",
+         "platform": {
+            "architecture": "amd64",
+            "os": "linux"
+         }
+      },
+      {
+         "mediaType": "application/vnd.oci.image.manifest.v1+json",
+         "size": 2392,
+         "digest": "sha256:6346340964309634683409684360934680934608934608934608934068934608",
+         "annotations": {
+            "platform": "none"
+         }
+      }
+   ],
+   "annotations": {
+      "com.example.key": "value"
+   }
+}`)
+
+func TestImageIndex(t *testing.T) {
+	index := ImageIndex{
+		Versioned: IndexSchemaVersion,
+		Manifests: []ManifestDescriptor{
+			{
+				Descriptor: Descriptor{
+					Descriptor: distribution.Descriptor{
+						Digest:    "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b",
+						Size:      985,
+						MediaType: ManifestMediaType,
+					},
+				},
+				Platform: &Platform{
+					Architecture: "amd64",
+					OS:           "linux",
+				},
+			},
+			{
+				Descriptor: Descriptor{
+					Descriptor: distribution.Descriptor{
+						Digest:    "sha256:6346340964309634683409684360934680934608934608934608934068934608",
+						Size:      2392,
+						MediaType: ManifestMediaType,
+					},
+					Annotations: map[string]string{"platform": "none"},
+				},
+			},
+		},
+		Annotations: map[string]string{"com.example.key": "value"},
+	}
+
+	deserialized, err := FromImageIndex(index)
+	if err != nil {
+		t.Fatalf("error creating DeserializedImageIndex: %v", err)
+	}
+
+	mediaType, canonical, err := deserialized.Payload()
+	if err != nil {
+		t.Fatalf("error getting payload: %v", err)
+	}
+
+	if mediaType != IndexMediaType {
+		t.Fatalf("unexpected media type: %s", mediaType)
+	}
+
+	// Check that the canonical field has the expected value.
+	if !bytes.Equal(expectedIndexSerialization, canonical) {
+		t.Fatalf("index bytes not equal: %q != %q", string(canonical), string(expectedIndexSerialization))
+	}
+
+	var unmarshalled DeserializedImageIndex
+	if err := json.Unmarshal(deserialized.canonical, &unmarshalled); err != nil {
+		t.Fatalf("error unmarshaling image index: %v", err)
+	}
+
+	if !reflect.DeepEqual(&unmarshalled, deserialized) {
+		t.Fatalf("image indexes are different after unmarshaling: %v != %v", unmarshalled, *deserialized)
+	}
+
+	references := deserialized.References()
+	if len(references) != 2 {
+		t.Fatalf("unexpected number of references: %d", len(references))
+	}
+
+	for i := range references {
+		if !reflect.DeepEqual(references[i], index.Manifests[i].Descriptor.Descriptor) {
+			t.Fatalf("unexpected value %d returned by References: %v", i, references[i])
+		}
+	}
+
+	m, desc, err := distribution.UnmarshalManifest(IndexMediaType, canonical)
+	if err != nil {
+		t.Fatalf("error unmarshaling image index: %v", err)
+	}
+
+	if _, ok := m.(*DeserializedImageIndex); !ok {
+		t.Fatalf("unexpected manifest type: %T", m)
+	}
+
+	if desc.MediaType != IndexMediaType || desc.Size != int64(len(canonical)) {
+		t.Fatalf("unexpected descriptor: %v", desc)
+	}
+
+	// An image manifest must not be accepted as an index.
+	if _, _, err := distribution.UnmarshalManifest(IndexMediaType, expectedManifestSerialization); err == nil {
+		t.Fatalf("expected error unmarshaling image manifest as index")
+	}
+}
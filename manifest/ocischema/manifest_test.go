@@ -0,0 +1,148 @@
+package ocischema
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution"
+)
+
+var expectedManifestSerialization = []byte(`{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.oci.image.manifest.v1+json",
+   "config": {
+      "mediaType": "application/vnd.oci.image.config.v1+json",
+      "size": 985,
+      "digest": "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b",
+      "annotations": {
+         "apple": "orange"
+      }
+   },
+   "layers": [
+      {
+         "mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+         "size": 153263,
+         "digest": "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b",
+         "annotations": {
+            "lettuce": "wrap"
+         }
+      }
+   ],
+   "annotations": {
+      "hot": "potato"
+   }
+}`)
+
+func makeTestManifest() Manifest {
+	return Manifest{
+		Versioned: SchemaVersion,
+		Config: Descriptor{
+			Descriptor: distribution.Descriptor{
+				Digest:    "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b",
+				Size:      985,
+				MediaType: ConfigMediaType,
+			},
+			Annotations: map[string]string{"apple": "orange"},
+		},
+		Layers: []Descriptor{
+			{
+				Descriptor: distribution.Descriptor{
+					Digest:    "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b",
+					Size:      153263,
+					MediaType: LayerMediaType,
+				},
+				Annotations: map[string]string{"lettuce": "wrap"},
+			},
+		},
+		Annotations: map[string]string{"hot": "potato"},
+	}
+}
+
+func TestManifest(t *testing.T) {
+	m := makeTestManifest()
+
+	deserialized, err := FromStruct(m)
+	if err != nil {
+		t.Fatalf("error creating DeserializedManifest: %v", err)
+	}
+
+	mediaType, canonical, err := deserialized.Payload()
+	if err != nil {
+		t.Fatalf("error getting payload: %v", err)
+	}
+
+	if mediaType != ManifestMediaType {
+		t.Fatalf("unexpected media type: %s", mediaType)
+	}
+
+	// Check that canonical field matches expected value.
+	if !bytes.Equal(expectedManifestSerialization, canonical) {
+		t.Fatalf("manifest bytes not equal: %q != %q", string(canonical), string(expectedManifestSerialization))
+	}
+
+	var unmarshalled DeserializedManifest
+	if err := json.Unmarshal(deserialized.canonical, &unmarshalled); err != nil {
+		t.Fatalf("error unmarshaling manifest: %v", err)
+	}
+
+	if !reflect.DeepEqual(&unmarshalled, deserialized) {
+		t.Fatalf("manifests are different after unmarshaling: %v != %v", unmarshalled, *deserialized)
+	}
+
+	if unmarshalled.Annotations["hot"] != "potato" ||
+		unmarshalled.Config.Annotations["apple"] != "orange" ||
+		unmarshalled.Layers[0].Annotations["lettuce"] != "wrap" {
+		t.Fatalf("annotations were not preserved: %v", unmarshalled.Manifest)
+	}
+
+	references := deserialized.References()
+	if len(references) != 2 {
+		t.Fatalf("unexpected number of references: %d", len(references))
+	}
+
+	if !reflect.DeepEqual(references[0], m.Config.Descriptor) {
+		t.Fatalf("first reference should be config: %v", references[0])
+	}
+
+	if !reflect.DeepEqual(references[1], m.Layers[0].Descriptor) {
+		t.Fatalf("unexpected layer reference: %v", references[1])
+	}
+}
+
+func TestUnmarshalManifest(t *testing.T) {
+	m, desc, err := distribution.UnmarshalManifest(ManifestMediaType, expectedManifestSerialization)
+	if err != nil {
+		t.Fatalf("error unmarshaling manifest: %v", err)
+	}
+
+	if _, ok := m.(*DeserializedManifest); !ok {
+		t.Fatalf("unexpected manifest type: %T", m)
+	}
+
+	if desc.MediaType != ManifestMediaType || desc.Size != int64(len(expectedManifestSerialization)) {
+		t.Fatalf("unexpected descriptor: %v", desc)
+	}
+
+	// The media type is optional in OCI manifests.
+	withoutMediaType := bytes.Replace(expectedManifestSerialization, []byte(`"mediaType": "application/vnd.oci.image.manifest.v1+json",`), nil, 1)
+	m, _, err = distribution.UnmarshalManifest(ManifestMediaType, withoutMediaType)
+	if err != nil {
+		t.Fatalf("error unmarshaling manifest without media type: %v", err)
+	}
+
+	mediaType, payload, err := m.Payload()
+	if err != nil {
+		t.Fatalf("error getting payload: %v", err)
+	}
+
+	if mediaType != ManifestMediaType || !bytes.Equal(payload, withoutMediaType) {
+		t.Fatalf("unexpected payload for manifest without media type: %s %q", mediaType, payload)
+	}
+
+	invalid := bytes.Replace(expectedManifestSerialization, []byte(ManifestMediaType), []byte(IndexMediaType), 1)
+	if _, _, err := distribution.UnmarshalManifest(ManifestMediaType, invalid); err == nil {
+		t.Fatalf("expected error unmarshaling manifest with wrong media type")
+	}
+}
@@ -0,0 +1,198 @@
+package schema1
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/libtrust"
+)
+
+// gzippedEmptyTar is a gzip-compressed version of an empty tar file
+// (1024 NULL bytes). Schema 1 has no notion of empty layers, so history
+// entries that did not produce a layer refer to this blob instead.
+var gzippedEmptyTar = []byte{
+	31, 139, 8, 0, 0, 9, 110, 136, 0, 255, 98, 24, 5, 163, 96, 20, 140, 88,
+	0, 8, 0, 0, 255, 255, 46, 175, 181, 239, 0, 4, 0, 0,
+}
+
+// digestSHA256GzippedEmptyTar is the canonical sha256 digest of
+// gzippedEmptyTar.
+const digestSHA256GzippedEmptyTar = digest.Digest("sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4")
+
+// imageHistory is a single entry of the history in an image configuration.
+type imageHistory struct {
+	Created    time.Time `json:"created"`
+	Author     string    `json:"author,omitempty"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+	EmptyLayer bool      `json:"empty_layer,omitempty"`
+}
+
+// imageConfig holds the parts of an image configuration needed to build a
+// schema 1 manifest.
+type imageConfig struct {
+	History      []imageHistory `json:"history,omitempty"`
+	Architecture string         `json:"architecture,omitempty"`
+}
+
+// v1Compatibility is the history entry of a layer that is not the top layer.
+type v1Compatibility struct {
+	ID              string    `json:"id"`
+	Parent          string    `json:"parent,omitempty"`
+	Comment         string    `json:"comment,omitempty"`
+	Created         time.Time `json:"created"`
+	ContainerConfig struct {
+		Cmd []string
+	} `json:"container_config,omitempty"`
+	Author    string `json:"author,omitempty"`
+	ThrowAway bool   `json:"throwaway,omitempty"`
+}
+
+// FromConfig synthesizes a schema 1 manifest for the image described by
+// configJSON and signs it with pk. The layers are ordered starting from the
+// base image, as in a schema 2 manifest. If the image history has empty
+// layers, the empty tar blob they refer to is put into bs when it is
+// missing.
+func FromConfig(ctx context.Context, bs distribution.BlobService, pk libtrust.PrivateKey, name, tag string, configJSON []byte, layers []distribution.Descriptor) (*SignedManifest, error) {
+	var img imageConfig
+	if err := json.Unmarshal(configJSON, &img); err != nil {
+		return nil, err
+	}
+
+	history := img.History
+	if len(history) == 0 {
+		// Images built before the history was recorded have one entry per
+		// layer.
+		history = make([]imageHistory, len(layers))
+	}
+
+	nonEmpty := 0
+	for _, h := range history {
+		if !h.EmptyLayer {
+			nonEmpty++
+		}
+	}
+	if nonEmpty != len(layers) {
+		return nil, errors.New("number of layers does not match image history")
+	}
+
+	m := Manifest{
+		Versioned:    SchemaVersion,
+		Name:         name,
+		Tag:          tag,
+		Architecture: img.Architecture,
+		FSLayers:     make([]FSLayer, len(history)),
+		History:      make([]History, len(history)),
+	}
+
+	var (
+		parent         string
+		layerCounter   int
+		emptyLayerUsed bool
+		compat         []byte
+	)
+
+	for i, h := range history {
+		var blobsum digest.Digest
+		if h.EmptyLayer {
+			blobsum = digestSHA256GzippedEmptyTar
+			emptyLayerUsed = true
+		} else {
+			blobsum = layers[layerCounter].Digest
+			layerCounter++
+		}
+
+		// The v1 ID chains the blobsum to the parent, so it is stable for a
+		// given image. The top layer also covers the configuration.
+		idContent := blobsum.Hex() + " " + parent
+		if i == len(history)-1 {
+			idContent += " " + string(configJSON)
+		}
+		v1ID, err := digest.FromBytes([]byte(idContent))
+		if err != nil {
+			return nil, err
+		}
+
+		if i == len(history)-1 {
+			compat, err = topLayerV1Compatibility(configJSON, v1ID.Hex(), parent, h.EmptyLayer)
+		} else {
+			entry := v1Compatibility{
+				ID:        v1ID.Hex(),
+				Parent:    parent,
+				Comment:   h.Comment,
+				Created:   h.Created,
+				Author:    h.Author,
+				ThrowAway: h.EmptyLayer,
+			}
+			entry.ContainerConfig.Cmd = []string{h.CreatedBy}
+			compat, err = json.Marshal(&entry)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Schema 1 lists the top layer first.
+		m.FSLayers[len(history)-1-i] = FSLayer{BlobSum: blobsum}
+		m.History[len(history)-1-i] = History{V1Compatibility: string(compat)}
+
+		parent = v1ID.Hex()
+	}
+
+	if emptyLayerUsed {
+		if _, err := bs.Stat(ctx, digestSHA256GzippedEmptyTar); err != nil {
+			if err != distribution.ErrBlobUnknown {
+				return nil, err
+			}
+
+			if _, err := bs.Put(ctx, LayerMediaType, gzippedEmptyTar); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return Sign(&m, pk)
+}
+
+// topLayerV1Compatibility returns the image configuration in the form used
+// by the history entry of the top layer, which embeds the whole
+// configuration.
+func topLayerV1Compatibility(configJSON []byte, id, parent string, throwaway bool) ([]byte, error) {
+	var config map[string]*json.RawMessage
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return nil, err
+	}
+
+	// These fields have no meaning in schema 1.
+	delete(config, "rootfs")
+	delete(config, "history")
+
+	setField := func(key string, value interface{}) error {
+		p, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		raw := json.RawMessage(p)
+		config[key] = &raw
+		return nil
+	}
+
+	if err := setField("id", id); err != nil {
+		return nil, err
+	}
+	if parent != "" {
+		if err := setField("parent", parent); err != nil {
+			return nil, err
+		}
+	}
+	if throwaway {
+		if err := setField("throwaway", true); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(config)
+}
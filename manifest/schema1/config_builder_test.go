@@ -0,0 +1,188 @@
+package schema1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/libtrust"
+)
+
+type mockBlobService struct {
+	descriptors map[digest.Digest]distribution.Descriptor
+}
+
+func (bs *mockBlobService) Stat(ctx context.Context, dgst digest.Digest) (distribution.Descriptor, error) {
+	if descriptor, ok := bs.descriptors[dgst]; ok {
+		return descriptor, nil
+	}
+	return distribution.Descriptor{}, distribution.ErrBlobUnknown
+}
+
+func (bs *mockBlobService) Get(ctx context.Context, dgst digest.Digest) ([]byte, error) {
+	panic("not implemented")
+}
+
+func (bs *mockBlobService) Open(ctx context.Context, dgst digest.Digest) (distribution.ReadSeekCloser, error) {
+	panic("not implemented")
+}
+
+func (bs *mockBlobService) Put(ctx context.Context, mediaType string, p []byte) (distribution.Descriptor, error) {
+	dgst, err := digest.FromBytes(p)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	d := distribution.Descriptor{
+		MediaType: mediaType,
+		Digest:    dgst,
+		Size:      int64(len(p)),
+	}
+	bs.descriptors[d.Digest] = d
+	return d, nil
+}
+
+func (bs *mockBlobService) Create(ctx context.Context, options ...distribution.BlobCreateOption) (distribution.BlobWriter, error) {
+	panic("not implemented")
+}
+
+func (bs *mockBlobService) Resume(ctx context.Context, id string) (distribution.BlobWriter, error) {
+	panic("not implemented")
+}
+
+
+func TestEmptyTarDigest(t *testing.T) {
+	dgst, err := digest.FromBytes(gzippedEmptyTar)
+	if err != nil {
+		t.Fatalf("unexpected error digesting empty tar: %v", err)
+	}
+
+	if dgst != digestSHA256GzippedEmptyTar {
+		t.Fatalf("unexpected digest for empty tar: %s != %s", dgst, digestSHA256GzippedEmptyTar)
+	}
+}
+
+func TestConfigBuilder(t *testing.T) {
+	imgJSON := []byte(`{
+    "architecture": "amd64",
+    "config": {
+        "Cmd": ["/bin/sh"],
+        "Env": ["PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"]
+    },
+    "created": "2016-01-19T20:24:21.123456789Z",
+    "history": [
+        {
+            "created": "2016-01-19T20:24:20.123456789Z",
+            "created_by": "/bin/sh -c #(nop) ADD file:9e4ca21cbd24dc05b454b6be21c7c639216ae66559b21ba24af0d665c62620dc in /"
+        },
+        {
+            "created": "2016-01-19T20:24:21.123456789Z",
+            "created_by": "/bin/sh -c #(nop) CMD [\"/bin/sh\"]",
+            "empty_layer": true
+        },
+        {
+            "created": "2016-01-19T20:24:21.123456789Z",
+            "author": "Alyssa P. Hacker <alyspdev@example.com>",
+            "created_by": "/bin/sh -c echo hello > /hello"
+        }
+    ],
+    "os": "linux",
+    "rootfs": {
+        "diff_ids": [
+            "sha256:c6f988f4874bb0add23a778f753c65efe992244e148a1d2ec2a8b664fb66bbd1",
+            "sha256:5f70bf18a086007016e948b04aed3b82103a36bea41755b6cddfaf10ace3c6ef"
+        ],
+        "type": "layers"
+    }
+}`)
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("could not generate key for testing: %v", err)
+	}
+
+	bs := &mockBlobService{descriptors: make(map[digest.Digest]distribution.Descriptor)}
+
+	layers := []distribution.Descriptor{
+		{Digest: "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b", Size: 2392},
+		{Digest: "sha256:b5b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7", Size: 985},
+	}
+
+	signed, err := FromConfig(context.Background(), bs, pk, "testrepo", "testtag", imgJSON, layers)
+	if err != nil {
+		t.Fatalf("FromConfig failed: %v", err)
+	}
+
+	if _, err := Verify(signed); err != nil {
+		t.Fatalf("error verifying manifest: %v", err)
+	}
+
+	if signed.Name != "testrepo" || signed.Tag != "testtag" || signed.Architecture != "amd64" {
+		t.Fatalf("unexpected manifest fields: %q %q %q", signed.Name, signed.Tag, signed.Architecture)
+	}
+
+	expectedFSLayers := []FSLayer{
+		{BlobSum: layers[1].Digest},
+		{BlobSum: digestSHA256GzippedEmptyTar},
+		{BlobSum: layers[0].Digest},
+	}
+	if len(signed.FSLayers) != len(expectedFSLayers) {
+		t.Fatalf("unexpected number of layers: %d", len(signed.FSLayers))
+	}
+	for i := range expectedFSLayers {
+		if signed.FSLayers[i] != expectedFSLayers[i] {
+			t.Fatalf("unexpected layer %d: %v != %v", i, signed.FSLayers[i], expectedFSLayers[i])
+		}
+	}
+
+	if _, err := bs.Stat(context.Background(), digestSHA256GzippedEmptyTar); err != nil {
+		t.Fatalf("empty tar blob was not put: %v", err)
+	}
+
+	// The history entries must form a chain from the top layer down.
+	var entries []map[string]interface{}
+	for _, h := range signed.History {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(h.V1Compatibility), &entry); err != nil {
+			t.Fatalf("error unmarshaling v1Compatibility: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	for i := 0; i < len(entries)-1; i++ {
+		if entries[i]["parent"] != entries[i+1]["id"] {
+			t.Fatalf("history entry %d does not reference its parent: %v", i, entries[i])
+		}
+	}
+	if _, ok := entries[len(entries)-1]["parent"]; ok {
+		t.Fatalf("base history entry has a parent: %v", entries[len(entries)-1])
+	}
+
+	top := entries[0]
+	if _, ok := top["rootfs"]; ok {
+		t.Fatalf("top history entry contains rootfs")
+	}
+	if _, ok := top["history"]; ok {
+		t.Fatalf("top history entry contains history")
+	}
+	if top["os"] != "linux" {
+		t.Fatalf("top history entry is missing the image configuration: %v", top)
+	}
+	if entries[1]["throwaway"] != true {
+		t.Fatalf("empty layer history entry is not marked as throwaway: %v", entries[1])
+	}
+
+	// Building the manifest again must produce the same content.
+	again, err := FromConfig(context.Background(), bs, pk, "testrepo", "testtag", imgJSON, layers)
+	if err != nil {
+		t.Fatalf("FromConfig failed: %v", err)
+	}
+	if string(again.Canonical) != string(signed.Canonical) {
+		t.Fatalf("manifest is not stable: %s != %s", again.Canonical, signed.Canonical)
+	}
+
+	if _, err := FromConfig(context.Background(), bs, pk, "testrepo", "testtag", imgJSON, layers[:1]); err == nil {
+		t.Fatalf("expected error for mismatched layers and history")
+	}
+}
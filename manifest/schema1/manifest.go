@@ -2,7 +2,9 @@ package schema1
 
 import (
 	"encoding/json"
+	"fmt"
 
+	"github.com/docker/distribution"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest"
 	"github.com/docker/libtrust"
@@ -16,6 +18,14 @@ const (
 	// that for schema version 1, the the media is optionally
 	// "application/json".
 	ManifestMediaType = "application/vnd.docker.distribution.manifest.v1+json"
+
+	// SignedManifestMediaType specifies the mediaType of a signed manifest,
+	// including its signatures.
+	SignedManifestMediaType = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+
+	// LayerMediaType is the mediaType used for layers referenced by the
+	// manifest.
+	LayerMediaType = "application/vnd.docker.container.image.rootfs.diff+x-gtar"
 )
 
 var (
@@ -26,6 +36,33 @@ var (
 	}
 )
 
+func init() {
+	schema1Func := func(b []byte) (distribution.Manifest, distribution.Descriptor, error) {
+		sm := new(SignedManifest)
+		if err := sm.UnmarshalJSON(b); err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+
+		dgst, err := digest.FromBytes(sm.Canonical)
+		if err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+
+		return sm, distribution.Descriptor{
+			MediaType: SignedManifestMediaType,
+			Size:      int64(len(sm.Canonical)),
+			Digest:    dgst,
+		}, nil
+	}
+
+	// Schema 1 manifests predate media types, so they are also the default.
+	for _, mediaType := range []string{SignedManifestMediaType, ManifestMediaType, "application/json", ""} {
+		if err := distribution.RegisterManifestSchema(mediaType, schema1Func); err != nil {
+			panic(fmt.Sprintf("Unable to register manifest: %s", err))
+		}
+	}
+}
+
 // Manifest provides the base accessible fields for working with V2 image
 // format in the registry.
 type Manifest struct {
@@ -58,14 +95,26 @@ type SignedManifest struct {
 	// serialization, or the signature check will fail. The manifest byte
 	// representation cannot change or it will have to be re-signed.
 	Raw []byte `json:"-"`
+
+	// Canonical is the signed content of Raw, without the signatures. Its
+	// digest identifies the manifest in the registry.
+	Canonical []byte `json:"-"`
 }
 
+var _ distribution.Manifest = &SignedManifest{}
+
 // UnmarshalJSON populates a new ImageManifest struct from JSON data.
 func (sm *SignedManifest) UnmarshalJSON(b []byte) error {
 	sm.Raw = make([]byte, len(b), len(b))
 	copy(sm.Raw, b)
 
-	p, err := sm.Payload()
+	jsig, err := libtrust.ParsePrettySignature(sm.Raw, "signatures")
+	if err != nil {
+		return err
+	}
+
+	// Resolve the payload in the manifest.
+	p, err := jsig.Payload()
 	if err != nil {
 		return err
 	}
@@ -76,19 +125,28 @@ func (sm *SignedManifest) UnmarshalJSON(b []byte) error {
 	}
 
 	sm.Manifest = manifest
+	sm.Canonical = p
 	return nil
 }
 
-// Payload returns the raw, signed content of the signed manifest. The
-// contents can be used to calculate the content identifier.
-func (sm *SignedManifest) Payload() ([]byte, error) {
-	jsig, err := libtrust.ParsePrettySignature(sm.Raw, "signatures")
-	if err != nil {
-		return nil, err
+// References returns the descriptors of the layers of the manifest.
+func (sm *SignedManifest) References() []distribution.Descriptor {
+	dependencies := make([]distribution.Descriptor, len(sm.FSLayers))
+	for i, fsLayer := range sm.FSLayers {
+		dependencies[i] = distribution.Descriptor{
+			MediaType: LayerMediaType,
+			Digest:    fsLayer.BlobSum,
+		}
 	}
 
-	// Resolve the payload in the manifest.
-	return jsig.Payload()
+	return dependencies
+}
+
+// Payload returns the signed manifest as served by the registry, along with
+// its media type. The digest of Canonical, rather than of the payload,
+// identifies the manifest.
+func (sm *SignedManifest) Payload() (string, []byte, error) {
+	return SignedManifestMediaType, sm.Raw, nil
 }
 
 // Signatures returns the signatures as provided by
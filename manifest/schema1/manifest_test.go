@@ -6,6 +6,8 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
 	"github.com/docker/libtrust"
 )
 
@@ -78,6 +80,36 @@ func TestManifestVerification(t *testing.T) {
 	}
 }
 
+func TestUnmarshalManifest(t *testing.T) {
+	env := genEnv(t)
+
+	// Schema 1 manifests are also unmarshaled when no media type is known.
+	for _, mediaType := range []string{SignedManifestMediaType, "application/json; charset=utf-8", ""} {
+		m, desc, err := distribution.UnmarshalManifest(mediaType, env.signed.Raw)
+		if err != nil {
+			t.Fatalf("error unmarshaling manifest as %q: %v", mediaType, err)
+		}
+
+		if !reflect.DeepEqual(m, env.signed) {
+			t.Fatalf("manifests are different after unmarshaling: %v != %v", m, env.signed)
+		}
+
+		expected, err := digest.FromBytes(env.signed.Canonical)
+		if err != nil {
+			t.Fatalf("error digesting manifest: %v", err)
+		}
+
+		if desc.Digest != expected || desc.MediaType != SignedManifestMediaType {
+			t.Fatalf("unexpected descriptor: %v", desc)
+		}
+	}
+
+	references := env.signed.References()
+	if len(references) != len(env.signed.FSLayers) || references[0].Digest != env.signed.FSLayers[0].BlobSum {
+		t.Fatalf("unexpected references: %v", references)
+	}
+}
+
 func genEnv(t *testing.T) *testEnv {
 	pk, err := libtrust.GenerateECP256PrivateKey()
 	if err != nil {
@@ -0,0 +1,140 @@
+package schema2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+)
+
+const (
+	// ManifestMediaType specifies the mediaType for the current version.
+	ManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+
+	// ConfigMediaType specifies the mediaType for the image configuration.
+	ConfigMediaType = "application/vnd.docker.container.image.v1+json"
+
+	// LayerMediaType is the mediaType used for layers referenced by the
+	// manifest.
+	LayerMediaType = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+var (
+	// SchemaVersion provides a pre-initialized version structure for this
+	// packages version of the manifest.
+	SchemaVersion = manifest.Versioned{
+		SchemaVersion: 2,
+		MediaType:     ManifestMediaType,
+	}
+)
+
+func init() {
+	schema2Func := func(b []byte) (distribution.Manifest, distribution.Descriptor, error) {
+		m := new(DeserializedManifest)
+		if err := m.UnmarshalJSON(b); err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+
+		dgst, err := digest.FromBytes(b)
+		if err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+
+		return m, distribution.Descriptor{
+			MediaType: ManifestMediaType,
+			Size:      int64(len(b)),
+			Digest:    dgst,
+		}, nil
+	}
+
+	if err := distribution.RegisterManifestSchema(ManifestMediaType, schema2Func); err != nil {
+		panic(fmt.Sprintf("Unable to register manifest: %s", err))
+	}
+}
+
+// Manifest defines a schema2 manifest.
+type Manifest struct {
+	manifest.Versioned
+
+	// Config references the image configuration as a blob.
+	Config distribution.Descriptor `json:"config"`
+
+	// Layers lists descriptors for the layers referenced by the
+	// configuration, base layer first.
+	Layers []distribution.Descriptor `json:"layers"`
+}
+
+// References returns the descriptors of this manifest's references, the
+// configuration followed by the layers.
+func (m Manifest) References() []distribution.Descriptor {
+	references := make([]distribution.Descriptor, 0, 1+len(m.Layers))
+	references = append(references, m.Config)
+	references = append(references, m.Layers...)
+	return references
+}
+
+// DeserializedManifest wraps Manifest with a copy of the original JSON.
+// It satisfies the distribution.Manifest interface.
+type DeserializedManifest struct {
+	Manifest
+
+	// canonical is the canonical byte representation of the Manifest.
+	canonical []byte
+}
+
+var _ distribution.Manifest = &DeserializedManifest{}
+
+// FromStruct takes a Manifest structure, marshals it to JSON, and returns a
+// DeserializedManifest which contains the manifest and its JSON
+// representation.
+func FromStruct(m Manifest) (*DeserializedManifest, error) {
+	var deserialized DeserializedManifest
+	deserialized.Manifest = m
+
+	var err error
+	deserialized.canonical, err = json.MarshalIndent(&m, "", "   ")
+	return &deserialized, err
+}
+
+// UnmarshalJSON populates a new Manifest struct from JSON data.
+func (m *DeserializedManifest) UnmarshalJSON(b []byte) error {
+	m.canonical = make([]byte, len(b), len(b))
+	// store manifest in canonical
+	copy(m.canonical, b)
+
+	// Unmarshal canonical JSON into Manifest object
+	var manifest Manifest
+	if err := json.Unmarshal(m.canonical, &manifest); err != nil {
+		return err
+	}
+
+	if manifest.SchemaVersion != SchemaVersion.SchemaVersion {
+		return fmt.Errorf("unexpected schema version %d for schema2 manifest", manifest.SchemaVersion)
+	}
+
+	if manifest.MediaType != ManifestMediaType {
+		return fmt.Errorf("unexpected media type %q for schema2 manifest", manifest.MediaType)
+	}
+
+	m.Manifest = manifest
+	return nil
+}
+
+// MarshalJSON returns the contents of canonical, which must have been set by
+// unmarshaling or construction.
+func (m *DeserializedManifest) MarshalJSON() ([]byte, error) {
+	if len(m.canonical) > 0 {
+		return m.canonical, nil
+	}
+
+	return nil, errors.New("JSON representation not initialized in DeserializedManifest")
+}
+
+// Payload returns the raw content of the manifest. The contents can be used
+// to calculate the content identifier.
+func (m DeserializedManifest) Payload() (string, []byte, error) {
+	return m.MediaType, m.canonical, nil
+}
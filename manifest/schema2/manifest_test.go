@@ -0,0 +1,123 @@
+package schema2
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution"
+)
+
+var expectedManifestSerialization = []byte(`{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+   "config": {
+      "mediaType": "application/vnd.docker.container.image.v1+json",
+      "size": 985,
+      "digest": "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b"
+   },
+   "layers": [
+      {
+         "mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip",
+         "size": 153263,
+         "digest": "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b"
+      }
+   ]
+}`)
+
+func makeTestManifest() Manifest {
+	return Manifest{
+		Versioned: SchemaVersion,
+		Config: distribution.Descriptor{
+			Digest:    "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b",
+			Size:      985,
+			MediaType: ConfigMediaType,
+		},
+		Layers: []distribution.Descriptor{
+			{
+				Digest:    "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b",
+				Size:      153263,
+				MediaType: LayerMediaType,
+			},
+		},
+	}
+}
+
+func TestManifest(t *testing.T) {
+	m := makeTestManifest()
+
+	deserialized, err := FromStruct(m)
+	if err != nil {
+		t.Fatalf("error creating DeserializedManifest: %v", err)
+	}
+
+	mediaType, canonical, err := deserialized.Payload()
+	if err != nil {
+		t.Fatalf("error getting payload: %v", err)
+	}
+
+	if mediaType != ManifestMediaType {
+		t.Fatalf("unexpected media type: %s", mediaType)
+	}
+
+	// Check that the canonical field is the same as json.MarshalIndent
+	// with these parameters.
+	p, err := json.MarshalIndent(&m, "", "   ")
+	if err != nil {
+		t.Fatalf("error marshaling manifest: %v", err)
+	}
+
+	if !bytes.Equal(p, canonical) {
+		t.Fatalf("manifest bytes not equal: %q != %q", string(canonical), string(p))
+	}
+
+	// Check that canonical field matches expected value.
+	if !bytes.Equal(expectedManifestSerialization, canonical) {
+		t.Fatalf("manifest bytes not equal: %q != %q", string(canonical), string(expectedManifestSerialization))
+	}
+
+	var unmarshalled DeserializedManifest
+	if err := json.Unmarshal(deserialized.canonical, &unmarshalled); err != nil {
+		t.Fatalf("error unmarshaling manifest: %v", err)
+	}
+
+	if !reflect.DeepEqual(&unmarshalled, deserialized) {
+		t.Fatalf("manifests are different after unmarshaling: %v != %v", unmarshalled, *deserialized)
+	}
+
+	references := deserialized.References()
+	if len(references) != 2 {
+		t.Fatalf("unexpected number of references: %d", len(references))
+	}
+
+	if !reflect.DeepEqual(references[0], m.Config) {
+		t.Fatalf("first reference should be config: %v", references[0])
+	}
+
+	if !reflect.DeepEqual(references[1], m.Layers[0]) {
+		t.Fatalf("unexpected layer reference: %v", references[1])
+	}
+}
+
+func TestUnmarshalManifest(t *testing.T) {
+	m, desc, err := distribution.UnmarshalManifest(ManifestMediaType, expectedManifestSerialization)
+	if err != nil {
+		t.Fatalf("error unmarshaling manifest: %v", err)
+	}
+
+	if _, ok := m.(*DeserializedManifest); !ok {
+		t.Fatalf("unexpected manifest type: %T", m)
+	}
+
+	if desc.MediaType != ManifestMediaType || desc.Size != int64(len(expectedManifestSerialization)) {
+		t.Fatalf("unexpected descriptor: %v", desc)
+	}
+
+	// A manifest list, or anything else, must not be accepted as a schema2
+	// manifest.
+	invalid := bytes.Replace(expectedManifestSerialization, []byte(ManifestMediaType), []byte("application/json"), 1)
+	if _, _, err := distribution.UnmarshalManifest(ManifestMediaType, invalid); err == nil {
+		t.Fatalf("expected error unmarshaling manifest with wrong media type")
+	}
+}
@@ -0,0 +1,84 @@
+package distribution
+
+import (
+	"fmt"
+	"mime"
+)
+
+// Manifest represents a registry object specifying a set of references and an
+// optional target.
+type Manifest interface {
+	// References returns a list of objects which make up this manifest, such
+	// as the image config, layers or the manifests of a manifest list.
+	References() []Descriptor
+
+	// Payload provides the serialized format of the manifest, in addition to
+	// the media type.
+	Payload() (mediaType string, payload []byte, err error)
+}
+
+// UnmarshalFunc implements manifest unmarshalling for a given media type. It
+// returns the manifest along with its descriptor, the digest of which
+// identifies the manifest in the registry.
+type UnmarshalFunc func([]byte) (Manifest, Descriptor, error)
+
+var mappings = make(map[string]UnmarshalFunc)
+
+// ManifestMediaTypes returns the supported media types for manifests.
+func ManifestMediaTypes() (mediaTypes []string) {
+	for t := range mappings {
+		if t != "" {
+			mediaTypes = append(mediaTypes, t)
+		}
+	}
+	return
+}
+
+// UnmarshalManifest unmarshals p using the function registered for the media
+// type in ctHeader, which may be a Content-Type header value. Unknown media
+// types fall back to the function registered for the empty media type, if
+// any.
+func UnmarshalManifest(ctHeader string, p []byte) (Manifest, Descriptor, error) {
+	// Look up the actual media type, ignoring any parameters.
+	var mediaType string
+	if ctHeader != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(ctHeader)
+		if err != nil {
+			return nil, Descriptor{}, err
+		}
+	}
+
+	unmarshalFunc, ok := mappings[mediaType]
+	if !ok {
+		unmarshalFunc, ok = mappings[""]
+		if !ok {
+			return nil, Descriptor{}, fmt.Errorf("unsupported manifest media type and no default available: %s", mediaType)
+		}
+	}
+
+	return unmarshalFunc(p)
+}
+
+// DescribeManifest returns the descriptor of a manifest, as determined by the
+// function registered for its media type.
+func DescribeManifest(m Manifest) (Descriptor, error) {
+	mediaType, p, err := m.Payload()
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	_, desc, err := UnmarshalManifest(mediaType, p)
+	return desc, err
+}
+
+// RegisterManifestSchema registers an UnmarshalFunc for a given media type.
+// This should be called from specific manifest packages during
+// initialization.
+func RegisterManifestSchema(mediaType string, u UnmarshalFunc) error {
+	if _, ok := mappings[mediaType]; ok {
+		return fmt.Errorf("manifest media type registration would overwrite existing: %s", mediaType)
+	}
+	mappings[mediaType] = u
+	return nil
+}
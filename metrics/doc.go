@@ -0,0 +1,30 @@
+// Package metrics provides counters, gauges and histograms exposed in the
+// Prometheus text format.
+//
+// The metrics package works expvar style. By importing the package the debug
+// server is getting a "/metrics" endpoint serving all the metrics registered
+// with the DefaultRegistry, which Prometheus can scrape directly:
+//
+//	# curl localhost:5001/metrics
+//	# HELP registry_http_requests_total Number of HTTP requests, by route, method and status code.
+//	# TYPE registry_http_requests_total counter
+//	registry_http_requests_total{route="manifest",method="GET",code="200"} 3
+//
+// Metrics are declared once, usually as package variables, and registered
+// when the package is initialized:
+//
+//	var requests = metrics.NewCounterVec("myapp_requests_total", "Number of requests, by route.", "route")
+//
+//	func init() {
+//	  metrics.Register(requests)
+//	}
+//
+// Values are then updated for a given set of label values, which must be in
+// the order the labels were declared in:
+//
+//	requests.Inc("manifest")
+//
+// Values maintained elsewhere, such as the expvar counters of a package, can
+// be exposed with NewCounterFunc and NewGaugeFunc, which read them each time
+// the metrics are served.
+package metrics
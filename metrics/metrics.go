@@ -0,0 +1,400 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the default upper bounds of histogram buckets, suited to
+// durations in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector is a family of metrics, sharing a name and help text. Collectors
+// are created with the New functions of this package.
+type Collector interface {
+	// Name returns the name of the metric family.
+	Name() string
+
+	// collect writes the metric family to buf in the text format.
+	collect(buf *bytes.Buffer)
+}
+
+// Registry holds collectors, which it serves in the text format.
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]Collector
+}
+
+// NewRegistry returns a new, empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]Collector),
+	}
+}
+
+// DefaultRegistry is the registry served on "/metrics" by the default HTTP
+// serve mux.
+var DefaultRegistry *Registry
+
+// Register adds collectors to the registry. It panics if a collector of the
+// same name is already registered, as expvar.Publish does.
+func (registry *Registry) Register(collectors ...Collector) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for _, c := range collectors {
+		if _, ok := registry.collectors[c.Name()]; ok {
+			panic("metric " + c.Name() + " is already registered")
+		}
+		registry.collectors[c.Name()] = c
+	}
+}
+
+// Register adds collectors to the DefaultRegistry.
+func Register(collectors ...Collector) {
+	DefaultRegistry.Register(collectors...)
+}
+
+// ServeHTTP writes the metrics of the registry in the text format, sorted by
+// name.
+func (registry *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	registry.mu.Lock()
+	names := make([]string, 0, len(registry.collectors))
+	for name := range registry.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]Collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, registry.collectors[name])
+	}
+	registry.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, c := range collectors {
+		c.collect(&buf)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Content-Length", fmt.Sprint(buf.Len()))
+	buf.WriteTo(w)
+}
+
+// desc describes a metric family.
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+// Name returns the name of the metric family.
+func (d *desc) Name() string {
+	return d.name
+}
+
+func (d *desc) writeHeader(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", d.name, helpEscaper.Replace(d.help))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+// key returns the key of a set of label values, panicking if they don't
+// match the labels of the metric.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s has labels %v, got values %v", d.name, d.labels, values))
+	}
+
+	return strings.Join(values, "\xff")
+}
+
+// writeSample writes a sample of the metric family, with the given suffix
+// and an extra label, if any, after the labels of the family.
+func (d *desc) writeSample(buf *bytes.Buffer, suffix string, values []string, extraLabel, extraValue string, value float64) {
+	buf.WriteString(d.name)
+	buf.WriteString(suffix)
+
+	if len(values) > 0 || extraLabel != "" {
+		buf.WriteByte('{')
+		for i, label := range d.labels {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeLabel(buf, label, values[i])
+		}
+		if extraLabel != "" {
+			if len(values) > 0 {
+				buf.WriteByte(',')
+			}
+			writeLabel(buf, extraLabel, extraValue)
+		}
+		buf.WriteByte('}')
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(value))
+	buf.WriteByte('\n')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func writeLabel(buf *bytes.Buffer, label, value string) {
+	buf.WriteString(label)
+	buf.WriteString(`="`)
+	buf.WriteString(labelEscaper.Replace(value))
+	buf.WriteByte('"')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sample is a value of a metric, for a set of label values.
+type sample struct {
+	values []string
+	value  float64
+}
+
+// sampleVec holds the samples of a counter or a gauge.
+type sampleVec struct {
+	desc
+	mu      sync.Mutex
+	samples map[string]*sample
+}
+
+func (v *sampleVec) add(delta float64, values []string) {
+	key := v.key(values)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{values: append([]string(nil), values...)}
+		v.samples[key] = s
+	}
+	s.value += delta
+}
+
+func (v *sampleVec) set(value float64, values []string) {
+	key := v.key(values)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.samples[key] = &sample{values: append([]string(nil), values...), value: value}
+}
+
+func (v *sampleVec) collect(buf *bytes.Buffer) {
+	v.writeHeader(buf)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.samples))
+	for key := range v.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := v.samples[key]
+		v.writeSample(buf, "", s.values, "", "", s.value)
+	}
+}
+
+// CounterVec is a family of counters, partitioned by label values.
+type CounterVec struct {
+	sampleVec
+}
+
+// NewCounterVec returns a family of counters with the given labels.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{sampleVec{
+		desc:    desc{name: name, help: help, typ: "counter", labels: labels},
+		samples: make(map[string]*sample),
+	}}
+}
+
+// Inc increments the counter of the label values.
+func (c *CounterVec) Inc(values ...string) {
+	c.add(1, values)
+}
+
+// Add adds delta, which must not be negative, to the counter of the label
+// values.
+func (c *CounterVec) Add(delta float64, values ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("counter %s can't decrease: %v", c.name, delta))
+	}
+	c.add(delta, values)
+}
+
+// GaugeVec is a family of gauges, partitioned by label values.
+type GaugeVec struct {
+	sampleVec
+}
+
+// NewGaugeVec returns a family of gauges with the given labels.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{sampleVec{
+		desc:    desc{name: name, help: help, typ: "gauge", labels: labels},
+		samples: make(map[string]*sample),
+	}}
+}
+
+// Set sets the gauge of the label values.
+func (g *GaugeVec) Set(value float64, values ...string) {
+	g.set(value, values)
+}
+
+// Add adds delta, which may be negative, to the gauge of the label values.
+func (g *GaugeVec) Add(delta float64, values ...string) {
+	g.add(delta, values)
+}
+
+// histogram is a distribution of observations, for a set of label values.
+type histogram struct {
+	values []string
+	counts []uint64 // per bucket, the last one being +Inf
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec is a family of histograms, partitioned by label values.
+type HistogramVec struct {
+	desc
+	buckets    []float64
+	mu         sync.Mutex
+	histograms map[string]*histogram
+}
+
+// NewHistogramVec returns a family of histograms with the given bucket upper
+// bounds, sorted in increasing order, and labels. An additional +Inf bucket
+// is always included.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("buckets of histogram %s are not sorted: %v", name, buckets))
+	}
+
+	return &HistogramVec{
+		desc:       desc{name: name, help: help, typ: "histogram", labels: labels},
+		buckets:    buckets,
+		histograms: make(map[string]*histogram),
+	}
+}
+
+// Observe adds an observation to the histogram of the label values.
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	key := h.key(values)
+	bucket := sort.SearchFloat64s(h.buckets, value)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist, ok := h.histograms[key]
+	if !ok {
+		hist = &histogram{
+			values: append([]string(nil), values...),
+			counts: make([]uint64, len(h.buckets)+1),
+		}
+		h.histograms[key] = hist
+	}
+
+	hist.counts[bucket]++
+	hist.sum += value
+	hist.count++
+}
+
+func (h *HistogramVec) collect(buf *bytes.Buffer) {
+	h.writeHeader(buf)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.histograms))
+	for key := range h.histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		hist := h.histograms[key]
+
+		var cumulative uint64
+		for i, count := range hist.counts {
+			cumulative += count
+
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			h.writeSample(buf, "_bucket", hist.values, "le", formatFloat(le), float64(cumulative))
+		}
+
+		h.writeSample(buf, "_sum", hist.values, "", "", hist.sum)
+		h.writeSample(buf, "_count", hist.values, "", "", float64(hist.count))
+	}
+}
+
+// Sample is a value read by a metric function, for a set of label values.
+type Sample struct {
+	Values []string
+	Value  float64
+}
+
+// funcCollector reads its samples from a function each time it is collected.
+type funcCollector struct {
+	desc
+	f func() []Sample
+}
+
+// NewCounterFunc returns a family of counters read from f, which returns a
+// sample per set of label values.
+func NewCounterFunc(name, help string, f func() []Sample, labels ...string) Collector {
+	return &funcCollector{
+		desc: desc{name: name, help: help, typ: "counter", labels: labels},
+		f:    f,
+	}
+}
+
+// NewGaugeFunc returns a family of gauges read from f, which returns a sample
+// per set of label values.
+func NewGaugeFunc(name, help string, f func() []Sample, labels ...string) Collector {
+	return &funcCollector{
+		desc: desc{name: name, help: help, typ: "gauge", labels: labels},
+		f:    f,
+	}
+}
+
+func (fc *funcCollector) collect(buf *bytes.Buffer) {
+	fc.writeHeader(buf)
+
+	for _, s := range fc.f() {
+		fc.key(s.Values)
+		fc.writeSample(buf, "", s.Values, "", "", s.Value)
+	}
+}
+
+func init() {
+	DefaultRegistry = NewRegistry()
+	http.Handle("/metrics", DefaultRegistry)
+}
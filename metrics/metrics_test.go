@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRegistry checks the text format of the metrics served by a registry.
+func TestRegistry(t *testing.T) {
+	requests := NewCounterVec("test_requests_total", "Number of requests.", "route", "code")
+	requests.Inc("manifest", "200")
+	requests.Inc("manifest", "200")
+	requests.Add(3, "blob", "404")
+
+	pending := NewGaugeVec("test_pending", "Pending\nevents.")
+	pending.Set(5)
+	pending.Add(-2)
+
+	durations := NewHistogramVec("test_duration_seconds", "Request durations.", []float64{0.1, 1}, "route")
+	durations.Observe(0.05, "manifest")
+	durations.Observe(0.1, "manifest")
+	durations.Observe(0.5, "manifest")
+	durations.Observe(2, "manifest")
+
+	hits := NewCounterFunc("test_hits_total", "Number of hits.", func() []Sample {
+		return []Sample{{Values: []string{`a"b\c`}, Value: 7}}
+	}, "name")
+
+	registry := NewRegistry()
+	registry.Register(requests, pending, durations, hits)
+
+	w := httptest.NewRecorder()
+	registry.ServeHTTP(w, &http.Request{})
+
+	expected := `# HELP test_duration_seconds Request durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="manifest",le="0.1"} 2
+test_duration_seconds_bucket{route="manifest",le="1"} 3
+test_duration_seconds_bucket{route="manifest",le="+Inf"} 4
+test_duration_seconds_sum{route="manifest"} 2.65
+test_duration_seconds_count{route="manifest"} 4
+# HELP test_hits_total Number of hits.
+# TYPE test_hits_total counter
+test_hits_total{name="a\"b\\c"} 7
+# HELP test_pending Pending\nevents.
+# TYPE test_pending gauge
+test_pending 3
+# HELP test_requests_total Number of requests.
+# TYPE test_requests_total counter
+test_requests_total{route="blob",code="404"} 3
+test_requests_total{route="manifest",code="200"} 2
+`
+
+	if body := w.Body.String(); body != expected {
+		t.Fatalf("unexpected metrics:\n%s\nexpected:\n%s", body, expected)
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Fatalf("unexpected content type: %q", contentType)
+	}
+}
+
+// TestRegisterDuplicate checks that metrics can't be registered twice.
+func TestRegisterDuplicate(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(NewCounterVec("test_total", "Test."))
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected registering a duplicate metric to panic")
+		}
+	}()
+	registry.Register(NewGaugeVec("test_total", "Test."))
+}
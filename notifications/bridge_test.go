@@ -3,10 +3,13 @@ package notifications
 import (
 	"testing"
 
+	"github.com/docker/distribution"
 	"github.com/docker/distribution/digest"
 
 	"github.com/docker/libtrust"
 
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/ocischema"
 	"github.com/docker/distribution/manifest/schema1"
 
 	"github.com/docker/distribution/registry/api/v2"
@@ -74,6 +77,114 @@ func TestEventBridgeManifestDeleted(t *testing.T) {
 	}
 }
 
+func TestEventBridgeTagDeleted(t *testing.T) {
+	l := createTestEnv(t, testSinkFn(func(events ...Event) error {
+		checkCommon(t, events...)
+
+		event := events[0]
+		if event.Action != EventActionUntag {
+			t.Fatalf("unexpected event action: %q != %q", event.Action, EventActionUntag)
+		}
+
+		if event.Target.Tag != m.Tag {
+			t.Fatalf("unexpected tag on event target: %q != %q", event.Target.Tag, m.Tag)
+		}
+
+		u, err := ub.BuildManifestURL(repo, m.Tag)
+		if err != nil {
+			t.Fatalf("error building expected url: %v", err)
+		}
+
+		if event.Target.URL != u {
+			t.Fatalf("incorrect url passed: %q != %q", event.Target.URL, u)
+		}
+
+		return nil
+	}))
+
+	desc := distribution.Descriptor{
+		MediaType: schema1.SignedManifestMediaType,
+		Size:      int64(len(payload)),
+		Digest:    dgst,
+	}
+	if err := l.TagDeleted(repo, m.Tag, desc); err != nil {
+		t.Fatalf("unexpected error notifying tag delete: %v", err)
+	}
+}
+
+func TestEventBridgeOCIManifestPushed(t *testing.T) {
+	// OCI content may leave out its media type, the event must carry it
+	// regardless.
+	versioned := manifest.Versioned{SchemaVersion: 2}
+	config := ocischema.Descriptor{
+		Descriptor: distribution.Descriptor{
+			MediaType: ocischema.ConfigMediaType,
+			Size:      985,
+			Digest:    "sha256:1a9ec845ee94c202b2d5da74a24f0ed2058318bfa9879fa541efaecba272e86b",
+		},
+	}
+	ociManifest, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned:   versioned,
+		Config:      config,
+		Annotations: map[string]string{"org.opencontainers.image.ref.name": "latest"},
+	})
+	if err != nil {
+		t.Fatalf("error building manifest: %v", err)
+	}
+
+	desc, err := distribution.DescribeManifest(ociManifest)
+	if err != nil {
+		t.Fatalf("error describing manifest: %v", err)
+	}
+
+	index, err := ocischema.FromImageIndex(ocischema.ImageIndex{
+		Versioned: versioned,
+		Manifests: []ocischema.ManifestDescriptor{{Descriptor: ocischema.Descriptor{Descriptor: desc}}},
+	})
+	if err != nil {
+		t.Fatalf("error building image index: %v", err)
+	}
+
+	for _, tc := range []struct {
+		manifest  distribution.Manifest
+		mediaType string
+	}{
+		{ociManifest, ocischema.ManifestMediaType},
+		{index, ocischema.IndexMediaType},
+	} {
+		_, p, err := tc.manifest.Payload()
+		if err != nil {
+			t.Fatalf("error getting payload: %v", err)
+		}
+
+		expectedDigest, err := digest.FromBytes(p)
+		if err != nil {
+			t.Fatalf("error digesting payload: %v", err)
+		}
+
+		l := NewBridge(ub, source, actor, request, testSinkFn(func(events ...Event) error {
+			if len(events) != 1 {
+				t.Fatalf("unexpected number of events: %v != 1", len(events))
+			}
+
+			target := events[0].Target
+			if target.MediaType != tc.mediaType {
+				t.Fatalf("unexpected media type: %q != %q", target.MediaType, tc.mediaType)
+			}
+
+			if target.Digest != expectedDigest || target.Length != int64(len(p)) {
+				t.Fatalf("unexpected event target: %v", target)
+			}
+
+			return nil
+		}))
+
+		if err := l.ManifestPushed(repo, tc.manifest); err != nil {
+			t.Fatalf("unexpected error notifying manifest push: %v", err)
+		}
+	}
+}
+
 func createTestEnv(t *testing.T, fn testSinkFn) Listener {
 	pk, err := libtrust.GenerateECP256PrivateKey()
 	if err != nil {
@@ -85,11 +196,7 @@ func createTestEnv(t *testing.T, fn testSinkFn) Listener {
 		t.Fatalf("error signing manifest: %v", err)
 	}
 
-	payload, err = sm.Payload()
-	if err != nil {
-		t.Fatalf("error getting manifest payload: %v", err)
-	}
-
+	payload = sm.Canonical
 	dgst, err = digest.FromBytes(payload)
 	if err != nil {
 		t.Fatalf("error digesting manifest payload: %v", err)
@@ -106,6 +213,10 @@ func checkCommonManifest(t *testing.T, action string, events ...Event) {
 		t.Fatalf("unexpected event action: %q != %q", event.Action, action)
 	}
 
+	if event.Target.MediaType != schema1.SignedManifestMediaType {
+		t.Fatalf("unexpected media type: %q != %q", event.Target.MediaType, schema1.SignedManifestMediaType)
+	}
+
 	u, err := ub.BuildManifestURL(repo, dgst.String())
 	if err != nil {
 		t.Fatalf("error building expected url: %v", err)
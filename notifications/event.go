@@ -12,6 +12,7 @@ const (
 	EventActionPull   = "pull"
 	EventActionPush   = "push"
 	EventActionDelete = "delete"
+	EventActionUntag  = "untag"
 )
 
 const (
@@ -61,6 +62,9 @@ type Event struct {
 		// Repository identifies the named repository.
 		Repository string `json:"repository,omitempty"`
 
+		// Tag provides the tag of the target, for tag events.
+		Tag string `json:"tag,omitempty"`
+
 		// URL provides a direct link to the content.
 		URL string `json:"url,omitempty"`
 	} `json:"target,omitempty"`
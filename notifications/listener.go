@@ -7,18 +7,17 @@ import (
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
-	"github.com/docker/distribution/manifest/schema1"
 )
 
 // ManifestListener describes a set of methods for listening to events related to manifests.
 type ManifestListener interface {
-	ManifestPushed(repo string, sm *schema1.SignedManifest) error
-	ManifestPulled(repo string, sm *schema1.SignedManifest) error
+	ManifestPushed(repo string, sm distribution.Manifest) error
+	ManifestPulled(repo string, sm distribution.Manifest) error
 
 	// TODO(stevvooe): Please note that delete support is still a little shaky
 	// and we'll need to propagate these in the future.
 
-	ManifestDeleted(repo string, sm *schema1.SignedManifest) error
+	ManifestDeleted(repo string, sm distribution.Manifest) error
 }
 
 // BlobListener describes a listener that can respond to layer related events.
@@ -32,10 +31,18 @@ type BlobListener interface {
 	BlobDeleted(repo string, desc distribution.Descriptor) error
 }
 
+// TagListener describes a listener that can respond to tag related events.
+type TagListener interface {
+	// TagDeleted is called when tag, which pointed at the manifest described
+	// by desc, is removed from the repository.
+	TagDeleted(repo string, tag string, desc distribution.Descriptor) error
+}
+
 // Listener combines all repository events into a single interface.
 type Listener interface {
 	ManifestListener
 	BlobListener
+	TagListener
 }
 
 type repositoryListener struct {
@@ -69,12 +76,19 @@ func (rl *repositoryListener) Blobs(ctx context.Context) distribution.BlobStore
 	}
 }
 
+func (rl *repositoryListener) Tags(ctx context.Context) distribution.TagService {
+	return &tagServiceListener{
+		TagService: rl.Repository.Tags(ctx),
+		parent:     rl,
+	}
+}
+
 type manifestServiceListener struct {
 	distribution.ManifestService
 	parent *repositoryListener
 }
 
-func (msl *manifestServiceListener) Get(dgst digest.Digest) (*schema1.SignedManifest, error) {
+func (msl *manifestServiceListener) Get(dgst digest.Digest) (distribution.Manifest, error) {
 	sm, err := msl.ManifestService.Get(dgst)
 	if err == nil {
 		if err := msl.parent.listener.ManifestPulled(msl.parent.Repository.Name(), sm); err != nil {
@@ -85,8 +99,8 @@ func (msl *manifestServiceListener) Get(dgst digest.Digest) (*schema1.SignedMani
 	return sm, err
 }
 
-func (msl *manifestServiceListener) Put(sm *schema1.SignedManifest) error {
-	err := msl.ManifestService.Put(sm)
+func (msl *manifestServiceListener) Put(sm distribution.Manifest) (digest.Digest, error) {
+	dgst, err := msl.ManifestService.Put(sm)
 
 	if err == nil {
 		if err := msl.parent.listener.ManifestPushed(msl.parent.Repository.Name(), sm); err != nil {
@@ -94,18 +108,36 @@ func (msl *manifestServiceListener) Put(sm *schema1.SignedManifest) error {
 		}
 	}
 
-	return err
+	return dgst, err
 }
 
-func (msl *manifestServiceListener) GetByTag(tag string, options ...distribution.ManifestServiceOption) (*schema1.SignedManifest, error) {
-	sm, err := msl.ManifestService.GetByTag(tag, options...)
-	if err == nil {
-		if err := msl.parent.listener.ManifestPulled(msl.parent.Repository.Name(), sm); err != nil {
-			logrus.Errorf("error dispatching manifest pull to listener: %v", err)
-		}
+// tagServiceListener wraps the tag service of the repository so that tag
+// operations pass through the listener.
+type tagServiceListener struct {
+	distribution.TagService
+	parent *repositoryListener
+}
+
+var _ distribution.TagService = &tagServiceListener{}
+
+func (tsl *tagServiceListener) Untag(ctx context.Context, tag string) error {
+	// Resolve the tag first so the event can describe what it pointed at.
+	// Errors are left to Untag to report.
+	desc, resolveErr := tsl.TagService.Get(ctx, tag)
+
+	if err := tsl.TagService.Untag(ctx, tag); err != nil {
+		return err
 	}
 
-	return sm, err
+	if resolveErr != nil {
+		context.GetLogger(ctx).Errorf("error resolving tag %q before untagging: %v", tag, resolveErr)
+	}
+
+	if err := tsl.parent.listener.TagDeleted(tsl.parent.Repository.Name(), tag, desc); err != nil {
+		context.GetLogger(ctx).Errorf("error dispatching tag delete to listener: %v", err)
+	}
+
+	return nil
 }
 
 type blobServiceListener struct {
@@ -171,9 +203,13 @@ func (bsl *blobServiceListener) Put(ctx context.Context, mediaType string, p []b
 	return desc, err
 }
 
-func (bsl *blobServiceListener) Create(ctx context.Context) (distribution.BlobWriter, error) {
-	wr, err := bsl.BlobStore.Create(ctx)
-	return bsl.decorateWriter(wr), err
+func (bsl *blobServiceListener) Create(ctx context.Context, options ...distribution.BlobCreateOption) (distribution.BlobWriter, error) {
+	wr, err := bsl.BlobStore.Create(ctx, options...)
+	if err != nil {
+		// A mounted blob returns no writer to decorate.
+		return nil, err
+	}
+	return bsl.decorateWriter(wr), nil
 }
 
 func (bsl *blobServiceListener) Resume(ctx context.Context, id string) (distribution.BlobWriter, error) {
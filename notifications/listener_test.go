@@ -43,6 +43,7 @@ func TestListener(t *testing.T) {
 		"layer:push": 2,
 		"layer:pull": 2,
 		// "layer:delete":    0, // deletes not supported for now
+		"tag:delete": 1,
 	}
 
 	if !reflect.DeepEqual(tl.ops, expectedOps) {
@@ -55,22 +56,27 @@ type testListener struct {
 	ops map[string]int
 }
 
-func (tl *testListener) ManifestPushed(repo string, sm *schema1.SignedManifest) error {
+func (tl *testListener) ManifestPushed(repo string, sm distribution.Manifest) error {
 	tl.ops["manifest:push"]++
 
 	return nil
 }
 
-func (tl *testListener) ManifestPulled(repo string, sm *schema1.SignedManifest) error {
+func (tl *testListener) ManifestPulled(repo string, sm distribution.Manifest) error {
 	tl.ops["manifest:pull"]++
 	return nil
 }
 
-func (tl *testListener) ManifestDeleted(repo string, sm *schema1.SignedManifest) error {
+func (tl *testListener) ManifestDeleted(repo string, sm distribution.Manifest) error {
 	tl.ops["manifest:delete"]++
 	return nil
 }
 
+func (tl *testListener) TagDeleted(repo string, tag string, desc distribution.Descriptor) error {
+	tl.ops["tag:delete"]++
+	return nil
+}
+
 func (tl *testListener) BlobPushed(repo string, desc distribution.Descriptor) error {
 	tl.ops["layer:push"]++
 	return nil
@@ -158,35 +164,58 @@ func checkExerciseRepository(t *testing.T, repository distribution.Repository) {
 		t.Fatal(err.Error())
 	}
 
-	if err = manifests.Put(sm); err != nil {
+	dgst, err := manifests.Put(sm)
+	if err != nil {
 		t.Fatalf("unexpected error putting the manifest: %v", err)
 	}
 
-	p, err := sm.Payload()
-	if err != nil {
-		t.Fatalf("unexpected error getting manifest payload: %v", err)
+	if err := repository.Tags(ctx).Tag(ctx, tag, distribution.Descriptor{Digest: dgst}); err != nil {
+		t.Fatalf("unexpected error tagging the manifest: %v", err)
 	}
 
-	dgst, err := digest.FromBytes(p)
+	expected, err := digest.FromBytes(sm.Canonical)
 	if err != nil {
 		t.Fatalf("unexpected error digesting manifest payload: %v", err)
 	}
 
-	fetchedByManifest, err := manifests.Get(dgst)
+	if dgst != expected {
+		t.Fatalf("unexpected manifest digest: %s != %s", dgst, expected)
+	}
+
+	fetchedManifest, err := manifests.Get(dgst)
 	if err != nil {
 		t.Fatalf("unexpected error fetching manifest: %v", err)
 	}
 
+	fetchedByManifest, ok := fetchedManifest.(*schema1.SignedManifest)
+	if !ok {
+		t.Fatalf("unexpected manifest type: %T", fetchedManifest)
+	}
+
 	if fetchedByManifest.Tag != sm.Tag {
 		t.Fatalf("retrieved unexpected manifest: %v", err)
 	}
 
-	fetched, err := manifests.GetByTag(tag)
+	desc, err := repository.Tags(ctx).Get(ctx, tag)
+	if err != nil {
+		t.Fatalf("unexpected error resolving tag: %v", err)
+	}
+
+	fetchedManifest, err = manifests.Get(desc.Digest)
 	if err != nil {
 		t.Fatalf("unexpected error fetching manifest: %v", err)
 	}
 
+	fetched, ok := fetchedManifest.(*schema1.SignedManifest)
+	if !ok {
+		t.Fatalf("unexpected manifest type: %T", fetchedManifest)
+	}
+
 	if fetched.Tag != fetchedByManifest.Tag {
 		t.Fatalf("retrieved unexpected manifest: %v", err)
 	}
+
+	if err := repository.Tags(ctx).Untag(ctx, tag); err != nil {
+		t.Fatalf("unexpected error untagging the manifest: %v", err)
+	}
 }
@@ -4,7 +4,10 @@ import (
 	"expvar"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
+
+	"github.com/docker/distribution/metrics"
 )
 
 // EndpointMetrics track various actions taken by the endpoint, typically by
@@ -149,4 +152,62 @@ func init() {
 	}))
 
 	registry.(*expvar.Map).Set("notifications", &notifications)
+
+	metrics.Register(
+		metrics.NewGaugeFunc("registry_notifications_pending",
+			"Number of events queued for delivery, by endpoint.",
+			func() []metrics.Sample {
+				return endpointSamples(func(em *EndpointMetrics) []metrics.Sample {
+					return []metrics.Sample{{Value: float64(em.Pending)}}
+				})
+			}, "endpoint"),
+		metrics.NewCounterFunc("registry_notifications_events_total",
+			"Number of events queued for delivery, by endpoint.",
+			func() []metrics.Sample {
+				return endpointSamples(func(em *EndpointMetrics) []metrics.Sample {
+					return []metrics.Sample{{Value: float64(em.Events)}}
+				})
+			}, "endpoint"),
+		metrics.NewCounterFunc("registry_notifications_deliveries_total",
+			"Number of events delivered, by endpoint and result.",
+			func() []metrics.Sample {
+				return endpointSamples(func(em *EndpointMetrics) []metrics.Sample {
+					return []metrics.Sample{
+						{Values: []string{"success"}, Value: float64(em.Successes)},
+						{Values: []string{"failure"}, Value: float64(em.Failures)},
+						{Values: []string{"error"}, Value: float64(em.Errors)},
+					}
+				})
+			}, "endpoint", "result"))
+}
+
+// endpointSamples returns the samples read by f from the metrics of each
+// registered endpoint, with the endpoint name prepended to their values.
+// Samples of endpoints registered several times under the same name are
+// summed.
+func endpointSamples(f func(em *EndpointMetrics) []metrics.Sample) []metrics.Sample {
+	endpoints.mu.Lock()
+	defer endpoints.mu.Unlock()
+
+	var samples []metrics.Sample
+	indexes := make(map[string]int)
+	for _, e := range endpoints.registered {
+		var em EndpointMetrics
+		e.ReadMetrics(&em)
+
+		for _, s := range f(&em) {
+			s.Values = append([]string{e.Name()}, s.Values...)
+
+			key := strings.Join(s.Values, "\xff")
+			if i, ok := indexes[key]; ok {
+				samples[i].Value += s.Value
+				continue
+			}
+
+			indexes[key] = len(samples)
+			samples = append(samples, s)
+		}
+	}
+
+	return samples
 }
@@ -63,6 +63,27 @@ var (
 		Description:    "Returned when a service is not available",
 		HTTPStatusCode: http.StatusServiceUnavailable,
 	})
+
+	// ErrorCodeTooManyRequests is returned if a client attempts too many
+	// times to contact a service endpoint.
+	ErrorCodeTooManyRequests = Register("errcode", ErrorDescriptor{
+		Value:   "TOOMANYREQUESTS",
+		Message: "too many requests",
+		Description: `Returned when a client attempts to contact a
+		service too many times. The Retry-After header tells when the
+		request may be retried.`,
+		HTTPStatusCode: http.StatusTooManyRequests,
+	})
+
+	// ErrorCodeInvalidRequest is returned if the parameters of a request
+	// are invalid.
+	ErrorCodeInvalidRequest = Register("errcode", ErrorDescriptor{
+		Value:   "INVALIDREQUEST",
+		Message: "invalid request",
+		Description: `Returned when the parameters of a request are
+		invalid. The detail describes the invalid parameter.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
 )
 
 var nextCode = 1000
@@ -133,4 +133,16 @@ var (
 		longer proceed.`,
 		HTTPStatusCode: http.StatusNotFound,
 	})
+	// ErrorCodeDeniedQuota is returned when content would take a repository
+	// or one of its namespaces over its storage quota.
+	ErrorCodeDeniedQuota = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:   "DENIED_QUOTA",
+		Message: "storage quota exceeded",
+		Description: `When a blob upload is completed, a blob is mounted or
+		a manifest is put, the registry checks that the storage used by the
+		repository stays within the configured quotas of the repository and
+		of the namespaces enclosing it. The error detail describes the
+		exceeded quota.`,
+		HTTPStatusCode: http.StatusForbidden,
+	})
 )
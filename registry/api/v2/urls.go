@@ -112,8 +112,9 @@ func (ub *URLBuilder) BuildCatalogURL(values ...url.Values) (string, error) {
 	return appendValuesURL(catalogURL, values...).String(), nil
 }
 
-// BuildTagsURL constructs a url to list the tags in the named repository.
-func (ub *URLBuilder) BuildTagsURL(name string) (string, error) {
+// BuildTagsURL constructs a url to list the tags in the named repository,
+// appending any url values, such as pagination parameters, provided.
+func (ub *URLBuilder) BuildTagsURL(name string, values ...url.Values) (string, error) {
 	route := ub.cloneRoute(RouteNameTags)
 
 	tagsURL, err := route.URL("name", name)
@@ -121,7 +122,20 @@ func (ub *URLBuilder) BuildTagsURL(name string) (string, error) {
 		return "", err
 	}
 
-	return tagsURL.String(), nil
+	return appendValuesURL(tagsURL, values...).String(), nil
+}
+
+// BuildUsageURL constructs a url to fetch the storage used by the named
+// repository.
+func (ub *URLBuilder) BuildUsageURL(name string) (string, error) {
+	route := ub.cloneRoute(RouteNameUsage)
+
+	usageURL, err := route.URL("name", name)
+	if err != nil {
+		return "", err
+	}
+
+	return usageURL.String(), nil
 }
 
 // BuildManifestURL constructs a url for the manifest identified by name and
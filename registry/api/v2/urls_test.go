@@ -26,6 +26,13 @@ func makeURLBuilderTestCases(urlBuilder *URLBuilder) []urlBuilderTestCase {
 				return urlBuilder.BuildTagsURL("foo/bar")
 			},
 		},
+		{
+			description:  "test usage url",
+			expectedPath: "/v2/foo/bar/usage",
+			build: func() (string, error) {
+				return urlBuilder.BuildUsageURL("foo/bar")
+			},
+		},
 		{
 			description:  "test manifest url",
 			expectedPath: "/v2/foo/bar/manifests/tag",
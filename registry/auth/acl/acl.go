@@ -0,0 +1,364 @@
+// Package acl implements access control lists granting users and groups
+// actions on repositories, for use by access controllers and token issuers.
+//
+// An access control list is read from a YAML file, such as:
+//
+//	groups:
+//	  admins: [alice]
+//	rules:
+//	  - groups: [admins]
+//	    repositories: ["*"]
+//	    actions: ["*"]
+//	  - users: ["*"]
+//	    repositories: ["${user}/*"]
+//	    actions: [pull, push]
+//	  - users: ["*"]
+//	    repositories: ["library/*"]
+//	    actions: [pull]
+//
+// In repository patterns, "*" matches any sequence of characters, including
+// "/", and "${user}" is replaced by the name of the user. Patterns naming the
+// user never match users whose name holds a "*". The actions are pull, push
+// and delete, "*" standing for all of them. The delete action allows
+// deleting tags, while deleting manifests and blobs by digest requires "*".
+// A user matching any rule may list the catalog, which only shows the
+// repositories they may pull.
+//
+// Rules may also require the claims of users authenticated with a token to
+// match patterns, such as:
+//
+//	rules:
+//	  - claims:
+//	      repository_owner: example
+//	      ref: refs/heads/*
+//	    repositories: ["example/*"]
+//	    actions: [pull, push]
+package acl
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/registry/auth"
+	"gopkg.in/yaml.v2"
+)
+
+// ACL maps users and groups to the actions they may perform on
+// repositories. Actions not granted by any rule are denied.
+type ACL struct {
+	// Groups maps the name of groups to their members, in addition to the
+	// groups users are known to belong to by the access controller.
+	Groups map[string][]string `yaml:"groups,omitempty"`
+
+	// Rules grant actions on repositories.
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule grants actions on the repositories matching its patterns to some
+// users and the members of some groups.
+type Rule struct {
+	// Users lists the names of the users the rule applies to, "*" standing
+	// for any authenticated user.
+	Users []string `yaml:"users,omitempty"`
+
+	// Groups lists the groups whose members the rule applies to.
+	Groups []string `yaml:"groups,omitempty"`
+
+	// Claims maps the name of claims to the pattern one of their values
+	// must match for the rule to apply. Users not authenticated with a
+	// token have no claims.
+	Claims map[string]string `yaml:"claims,omitempty"`
+
+	// Repositories lists the patterns of the repository names.
+	Repositories []string `yaml:"repositories"`
+
+	// Actions lists the actions granted.
+	Actions []string `yaml:"actions"`
+}
+
+// Subject is an authenticated user whose access is evaluated.
+type Subject struct {
+	Name   string
+	Groups []string
+
+	// Claims maps the name of the claims of the user to their values.
+	Claims map[string][]string
+}
+
+// actions lists the actions which can be granted on repositories.
+var actions = []string{"pull", "push", "delete"}
+
+// Parse reads an access control list from rd.
+func Parse(rd io.Reader) (*ACL, error) {
+	p, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	acl := new(ACL)
+	if err := yaml.Unmarshal(p, acl); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range acl.Rules {
+		if len(rule.Users) == 0 && len(rule.Groups) == 0 && len(rule.Claims) == 0 {
+			return nil, fmt.Errorf("rule %d applies to no users, groups or claims", i)
+		}
+
+		if len(rule.Repositories) == 0 {
+			return nil, fmt.Errorf("rule %d grants no repositories", i)
+		}
+
+		if len(rule.Actions) == 0 {
+			return nil, fmt.Errorf("rule %d grants no actions", i)
+		}
+
+		for _, action := range rule.Actions {
+			if action != "*" && !contains(actions, action) {
+				return nil, fmt.Errorf("rule %d grants unknown action %q", i, action)
+			}
+		}
+	}
+
+	return acl, nil
+}
+
+// Load reads the access control list from the file at path.
+func Load(path string) (*ACL, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	acl, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	return acl, nil
+}
+
+// Granted returns the actions, among those requested, which subject may
+// perform on resource.
+func (acl *ACL) Granted(subject Subject, resource auth.Resource, requested []string) []string {
+	var granted []string
+	for _, action := range requested {
+		if acl.allowed(subject, auth.Access{Resource: resource, Action: action}) {
+			granted = append(granted, action)
+		}
+	}
+
+	return granted
+}
+
+// Allowed reports whether subject may perform every access.
+func (acl *ACL) Allowed(subject Subject, access ...auth.Access) bool {
+	for _, access := range access {
+		if !acl.allowed(subject, access) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AccessChecker returns the checker of the access granted to subject by the
+// access control list, for the contexts authorized for subject.
+func (acl *ACL) AccessChecker(subject Subject) auth.AccessChecker {
+	return func(access auth.Access) bool {
+		return acl.allowed(subject, access)
+	}
+}
+
+func (acl *ACL) allowed(subject Subject, access auth.Access) bool {
+	groups := acl.groups(subject)
+
+	for _, rule := range acl.Rules {
+		if !rule.appliesTo(subject, groups) {
+			continue
+		}
+
+		switch access.Type {
+		case "registry":
+			if access.Name == "catalog" && access.Action == "*" {
+				return true
+			}
+		case "repository":
+			if rule.grants(access.Action) && rule.matches(subject.Name, access.Name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// groups returns the groups subject belongs to, including those of the
+// access control list. The groups of subject are copied, as they may be
+// shared, such as by a cache of the groups of users.
+func (acl *ACL) groups(subject Subject) []string {
+	groups := append([]string(nil), subject.Groups...)
+	for group, members := range acl.Groups {
+		if contains(members, subject.Name) {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
+
+// appliesTo reports whether the rule applies to subject, a member of groups.
+// Subject must match every claim of the rule and, unless the rule lists none,
+// one of its users or groups.
+func (rule Rule) appliesTo(subject Subject, groups []string) bool {
+	for claim, pattern := range rule.Claims {
+		if !matchAny(pattern, subject.Claims[claim]) {
+			return false
+		}
+	}
+
+	if len(rule.Users) == 0 && len(rule.Groups) == 0 {
+		return true
+	}
+
+	if contains(rule.Users, "*") || contains(rule.Users, subject.Name) {
+		return true
+	}
+
+	for _, group := range groups {
+		if contains(rule.Groups, group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (rule Rule) grants(action string) bool {
+	return contains(rule.Actions, "*") || contains(rule.Actions, action)
+}
+
+// matches reports whether the named repository matches any pattern of the
+// rule for user. Patterns naming the user never match user names holding a
+// "*", which would match other repositories once substituted.
+func (rule Rule) matches(user, name string) bool {
+	for _, pattern := range rule.Repositories {
+		if strings.Contains(pattern, "${user}") {
+			if strings.Contains(user, "*") {
+				continue
+			}
+			pattern = strings.Replace(pattern, "${user}", user, -1)
+		}
+
+		if match(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// match reports whether name matches pattern, in which "*" matches any
+// sequence of characters.
+func match(pattern, name string) bool {
+	star := strings.Index(pattern, "*")
+	if star < 0 {
+		return pattern == name
+	}
+
+	if !strings.HasPrefix(name, pattern[:star]) {
+		return false
+	}
+
+	rest := pattern[star+1:]
+	for i := star; i <= len(name); i++ {
+		if match(rest, name[i:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAny reports whether any of values matches pattern.
+func matchAny(pattern string, values []string) bool {
+	for _, value := range values {
+		if match(pattern, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// File is an access control list read from a file, which is read again once
+// modified, so that changes apply without restarting the registry.
+type File struct {
+	path string
+
+	mu      sync.Mutex
+	acl     *ACL
+	modTime time.Time
+	size    int64
+}
+
+// OpenFile reads the access control list from the file at path.
+func OpenFile(path string) (*File, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	acl, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{path: path, acl: acl, modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+// ACL returns the access control list of the file, reading it again if
+// modified. If the modified file is invalid, the access control list
+// previously read is kept until the file is modified again.
+func (f *File) ACL() *ACL {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		log.Errorf("error checking access control list %s: %v", f.path, err)
+		return f.acl
+	}
+
+	if fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return f.acl
+	}
+	f.modTime, f.size = fi.ModTime(), fi.Size()
+
+	acl, err := Load(f.path)
+	if err != nil {
+		log.Errorf("error reloading access control list, keeping the previous one: %v", err)
+		return f.acl
+	}
+
+	log.Infof("reloaded access control list %s", f.path)
+	f.acl = acl
+	return f.acl
+}
@@ -0,0 +1,170 @@
+package acl
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/registry/auth"
+)
+
+const testACL = `
+groups:
+  admins: [alice]
+rules:
+  - groups: [admins]
+    repositories: ["*"]
+    actions: ["*"]
+  - users: ["*"]
+    repositories: ["${user}/*"]
+    actions: [pull, push]
+  - users: [bob]
+    repositories: ["library/*"]
+    actions: [pull]
+  - groups: [ci]
+    repositories: ["builds/*/cache"]
+    actions: [pull, push, delete]
+  - claims:
+      repository_owner: example
+      ref: refs/heads/*
+    repositories: ["example/*"]
+    actions: [pull, push]
+  - users: [ci]
+    claims:
+      environment: production
+    repositories: ["releases/*"]
+    actions: [push]
+`
+
+func repository(name string) auth.Resource {
+	return auth.Resource{Type: "repository", Name: name}
+}
+
+func TestGranted(t *testing.T) {
+	acl, err := Parse(strings.NewReader(testACL))
+	if err != nil {
+		t.Fatalf("unexpected error parsing acl: %v", err)
+	}
+
+	all := []string{"pull", "push", "delete"}
+	for _, testcase := range []struct {
+		subject  Subject
+		resource auth.Resource
+		granted  []string
+	}{
+		{Subject{Name: "alice"}, repository("foo/bar"), all},
+		{Subject{Name: "bob"}, repository("bob/app"), []string{"pull", "push"}},
+		{Subject{Name: "bob"}, repository("bob/app/nested"), []string{"pull", "push"}},
+		{Subject{Name: "bob"}, repository("bobby/app"), nil},
+		{Subject{Name: "*"}, repository("bob/app"), nil},
+		{Subject{Name: "b*"}, repository("bob/app"), nil},
+		{Subject{Name: "bob"}, repository("library/ubuntu"), []string{"pull"}},
+		{Subject{Name: "carol"}, repository("library/ubuntu"), nil},
+		{Subject{Name: "carol", Groups: []string{"ci"}}, repository("builds/app/cache"), all},
+		{Subject{Name: "carol", Groups: []string{"ci"}}, repository("builds/app/cache/old"), nil},
+		{Subject{Name: "bob"}, auth.Resource{Type: "registry", Name: "catalog"}, nil},
+		{Subject{Name: "repo:example/app", Claims: map[string][]string{"repository_owner": {"example"}, "ref": {"refs/heads/main"}}}, repository("example/app"), []string{"pull", "push"}},
+		{Subject{Name: "repo:example/app", Claims: map[string][]string{"repository_owner": {"example"}, "ref": {"refs/tags/v1"}}}, repository("example/app"), nil},
+		{Subject{Name: "repo:example/app", Claims: map[string][]string{"repository_owner": {"example"}}}, repository("example/app"), nil},
+		{Subject{Name: "carol"}, repository("example/app"), nil},
+		{Subject{Name: "ci", Claims: map[string][]string{"environment": {"staging", "production"}}}, repository("releases/app"), []string{"push"}},
+		{Subject{Name: "bob", Claims: map[string][]string{"environment": {"production"}}}, repository("releases/app"), nil},
+	} {
+		granted := acl.Granted(testcase.subject, testcase.resource, all)
+		if !reflect.DeepEqual(granted, testcase.granted) {
+			t.Fatalf("unexpected actions granted to %+v on %v: %v != %v", testcase.subject, testcase.resource, granted, testcase.granted)
+		}
+	}
+
+	catalog := auth.Access{Resource: auth.Resource{Type: "registry", Name: "catalog"}, Action: "*"}
+	if !acl.Allowed(Subject{Name: "bob"}, catalog) {
+		t.Fatalf("expected users matching a rule to be allowed to list the catalog")
+	}
+
+	if acl.Allowed(Subject{Name: "bob"}, auth.Access{Resource: repository("library/ubuntu"), Action: "pull"}, auth.Access{Resource: repository("library/ubuntu"), Action: "push"}) {
+		t.Fatalf("expected access to be denied when any action isn't granted")
+	}
+}
+
+func TestGroupsNotShared(t *testing.T) {
+	acl, err := Parse(strings.NewReader(testACL))
+	if err != nil {
+		t.Fatalf("unexpected error parsing acl: %v", err)
+	}
+
+	// The groups of the subject have room to append the groups of the
+	// access control list in place.
+	shared := make([]string, 1, 2)
+	shared[0] = "ci"
+	if groups := acl.groups(Subject{Name: "alice", Groups: shared}); !reflect.DeepEqual(groups, []string{"ci", "admins"}) {
+		t.Fatalf("unexpected groups: %v", groups)
+	}
+
+	if extra := shared[:2][1]; extra != "" {
+		t.Fatalf("groups of the subject were written to: %q", extra)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		"rules:\n  - repositories: [foo]\n    actions: [pull]\n",
+		"rules:\n  - users: [bob]\n    actions: [pull]\n",
+		"rules:\n  - users: [bob]\n    repositories: [foo]\n",
+		"rules:\n  - users: [bob]\n    repositories: [foo]\n    actions: [write]\n",
+		"rules: {}\n",
+	} {
+		if _, err := Parse(strings.NewReader(invalid)); err == nil {
+			t.Fatalf("expected error parsing acl %q", invalid)
+		}
+	}
+}
+
+// TestFile checks that the access control list of a file is read again once
+// modified, invalid modifications being ignored.
+func TestFile(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "acl")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	write := func(content string, modTime time.Time) {
+		if err := ioutil.WriteFile(tmpfile.Name(), []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error writing acl: %v", err)
+		}
+		if err := os.Chtimes(tmpfile.Name(), modTime, modTime); err != nil {
+			t.Fatalf("unexpected error setting modification time: %v", err)
+		}
+	}
+
+	pull := auth.Access{Resource: repository("library/ubuntu"), Action: "pull"}
+	now := time.Now()
+
+	write(testACL, now.Add(-time.Hour))
+	f, err := OpenFile(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("unexpected error opening acl: %v", err)
+	}
+
+	if !f.ACL().Allowed(Subject{Name: "bob"}, pull) {
+		t.Fatalf("expected bob to be allowed to pull")
+	}
+
+	write("rules: []\n", now.Add(-time.Minute))
+	if f.ACL().Allowed(Subject{Name: "bob"}, pull) {
+		t.Fatalf("expected modified acl to deny bob pulling")
+	}
+
+	write("rules: {}\n", now)
+	if f.ACL() == nil || f.ACL().Allowed(Subject{Name: "bob"}, pull) {
+		t.Fatalf("expected invalid acl to be ignored")
+	}
+
+	if _, err := OpenFile(tmpfile.Name()); err == nil {
+		t.Fatalf("expected error opening invalid acl")
+	}
+}
@@ -33,6 +33,7 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -82,11 +83,18 @@ type AccessController interface {
 	// a `*http.Request` value. If the error is non-nil, access should always
 	// be denied. The error may be of type Challenge, in which case the caller
 	// may have the Challenge handle the request or choose what action to take
-	// based on the Challenge header or response status. The returned context
-	// object should have a "auth.user" value set to a UserInfo struct.
+	// based on the Challenge header or response status. The error is
+	// ErrAccessDenied if the request is authenticated but the access isn't
+	// granted. The returned context object should have a "auth.user" value
+	// set to a UserInfo struct.
 	Authorized(ctx context.Context, access ...Access) (context.Context, error)
 }
 
+// ErrAccessDenied is returned by access controllers which authenticated the
+// request but don't grant the requested access. Unlike a Challenge, asking for
+// credentials, it is answered with 403 Forbidden.
+var ErrAccessDenied = errors.New("access denied")
+
 // WithUser returns a context with the authorized user info.
 func WithUser(ctx context.Context, user UserInfo) context.Context {
 	return userInfoContext{
@@ -111,6 +119,26 @@ func (uic userInfoContext) Value(key interface{}) interface{} {
 	return uic.Context.Value(key)
 }
 
+// AccessChecker reports whether an authorized context is granted access,
+// without authenticating it again. Access controllers enforcing a policy of
+// their own set it on the contexts they return, so that handlers can filter
+// resources, such as the repositories of the catalog, at the cost of a lookup.
+// Access controllers only verifying the access granted to a request, such as
+// by a token, do not.
+type AccessChecker func(access Access) bool
+
+// WithAccessChecker returns a context whose access is checked by checker.
+func WithAccessChecker(ctx context.Context, checker AccessChecker) context.Context {
+	return context.WithValue(ctx, "auth.accesschecker", checker)
+}
+
+// GetAccessChecker returns the access checker of an authorized context, if
+// its access controller set one.
+func GetAccessChecker(ctx context.Context) (AccessChecker, bool) {
+	checker, ok := ctx.Value("auth.accesschecker").(AccessChecker)
+	return checker, ok
+}
+
 // InitFunc is the type of an AccessController factory function and is used
 // to register the constructor for different AccesController backends.
 type InitFunc func(options map[string]interface{}) (AccessController, error)
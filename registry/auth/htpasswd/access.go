@@ -1,6 +1,7 @@
 // Package htpasswd provides a simple authentication scheme that checks for the
 // user credential hash in an htpasswd formatted file in a configuration-determined
-// location.
+// location. Access can be restricted with an access control list file,
+// granting users actions on repositories, which is read again once modified.
 //
 // This authentication method MUST be used under TLS, as simple token-replay attack is possible.
 package htpasswd
@@ -9,10 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/acl"
 )
 
 var (
@@ -25,7 +26,11 @@ var (
 
 type accessController struct {
 	realm    string
-	htpasswd *htpasswd
+	htpasswd *HTPasswd
+
+	// acl grants access to users, any authenticated user being granted
+	// any access if nil.
+	acl *acl.File
 }
 
 var _ auth.AccessController = &accessController{}
@@ -41,18 +46,24 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 		return nil, fmt.Errorf(`"path" must be set for htpasswd access controller`)
 	}
 
-	f, err := os.Open(path.(string))
+	h, err := LoadHTPasswd(path.(string))
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	h, err := newHTPasswd(f)
-	if err != nil {
-		return nil, err
+	ac := &accessController{realm: realm.(string), htpasswd: h}
+
+	if aclPath, present := options["acl"]; present {
+		if _, ok := aclPath.(string); !ok {
+			return nil, fmt.Errorf(`"acl" must be the path of an access control list file`)
+		}
+
+		if ac.acl, err = acl.OpenFile(aclPath.(string)); err != nil {
+			return nil, err
+		}
 	}
 
-	return &accessController{realm: realm.(string), htpasswd: h}, nil
+	return ac, nil
 }
 
 func (ac *accessController) Authorized(ctx context.Context, accessRecords ...auth.Access) (context.Context, error) {
@@ -69,7 +80,7 @@ func (ac *accessController) Authorized(ctx context.Context, accessRecords ...aut
 		}
 	}
 
-	if err := ac.htpasswd.authenticateUser(username, password); err != nil {
+	if err := ac.htpasswd.AuthenticateUser(username, password); err != nil {
 		context.GetLogger(ctx).Errorf("error authenticating user %q: %v", username, err)
 		return nil, &challenge{
 			realm: ac.realm,
@@ -77,7 +88,19 @@ func (ac *accessController) Authorized(ctx context.Context, accessRecords ...aut
 		}
 	}
 
-	return auth.WithUser(ctx, auth.UserInfo{Name: username}), nil
+	checker := auth.AccessChecker(func(auth.Access) bool { return true })
+	if ac.acl != nil {
+		checker = ac.acl.ACL().AccessChecker(acl.Subject{Name: username})
+	}
+
+	for _, access := range accessRecords {
+		if !checker(access) {
+			context.GetLogger(ctx).Warnf("user %q denied access to %v", username, accessRecords)
+			return nil, auth.ErrAccessDenied
+		}
+	}
+
+	return auth.WithAccessChecker(auth.WithUser(ctx, auth.UserInfo{Name: username}), checker), nil
 }
 
 // challenge implements the auth.Challenge interface.
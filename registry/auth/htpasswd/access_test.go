@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/docker/distribution/context"
@@ -120,3 +121,81 @@ func TestBasicAccessController(t *testing.T) {
 	}
 
 }
+
+func TestBasicAccessControllerACL(t *testing.T) {
+	htpasswdFile, err := ioutil.TempFile("", "htpasswd-test")
+	if err != nil {
+		t.Fatal("could not create temporary htpasswd file")
+	}
+	defer os.Remove(htpasswdFile.Name())
+	if _, err = htpasswdFile.WriteString(`frodo:$2y$05$926C3y10Quzn/LnqQH86VOEVh/18T6RnLaS.khre96jLNL/7e.K5W
+MiShil:$2y$05$0oHgwMehvoe8iAWS8I.7l.KoECXrwVaC16RPfaSCU5eVTFrATuMI2
+`); err != nil {
+		t.Fatal("could not write temporary htpasswd file")
+	}
+	htpasswdFile.Close()
+
+	aclFile, err := ioutil.TempFile("", "acl-test")
+	if err != nil {
+		t.Fatal("could not create temporary acl file")
+	}
+	defer os.Remove(aclFile.Name())
+	if _, err = aclFile.WriteString(`
+groups:
+  royals: [MiShil]
+rules:
+  - users: [frodo]
+    repositories: ["shire/*"]
+    actions: [pull, push]
+  - groups: [royals]
+    repositories: ["*"]
+    actions: ["*"]
+`); err != nil {
+		t.Fatal("could not write temporary acl file")
+	}
+	aclFile.Close()
+
+	accessController, err := newAccessController(map[string]interface{}{
+		"realm": "The-Shire",
+		"path":  htpasswdFile.Name(),
+		"acl":   aclFile.Name(),
+	})
+	if err != nil {
+		t.Fatalf("error creating access controller: %v", err)
+	}
+
+	access := func(name, action string) auth.Access {
+		return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: action}
+	}
+
+	for _, testcase := range []struct {
+		username, password string
+		access             []auth.Access
+		err                error
+	}{
+		{"frodo", "baggins", []auth.Access{access("shire/bagend", "pull"), access("shire/bagend", "push")}, nil},
+		{"frodo", "baggins", []auth.Access{access("shire/bagend", "delete")}, auth.ErrAccessDenied},
+		{"frodo", "baggins", []auth.Access{access("mordor/barad-dur", "pull")}, auth.ErrAccessDenied},
+		{"MiShil", "새주", []auth.Access{access("mordor/barad-dur", "delete")}, nil},
+	} {
+		req, err := http.NewRequest("GET", "/v2/", nil)
+		if err != nil {
+			t.Fatalf("error allocating new request: %v", err)
+		}
+		req.SetBasicAuth(testcase.username, testcase.password)
+
+		_, err = accessController.Authorized(context.WithRequest(context.Background(), req), testcase.access...)
+		if err != testcase.err {
+			t.Fatalf("unexpected error authorizing %s for %v: %v != %v", testcase.username, testcase.access, err, testcase.err)
+		}
+	}
+
+	// Wrong credentials are still challenged, rather than denied.
+	req, _ := http.NewRequest("GET", "/v2/", nil)
+	req.SetBasicAuth("frodo", "sackville")
+	if _, err := accessController.Authorized(context.WithRequest(context.Background(), req), access("shire/bagend", "pull")); err == nil {
+		t.Fatal("expected wrong credentials to be refused")
+	} else if _, ok := err.(auth.Challenge); !ok {
+		t.Fatalf("expected challenge for wrong credentials, got %v", err)
+	}
+}
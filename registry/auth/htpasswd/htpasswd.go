@@ -4,30 +4,44 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-// htpasswd holds a path to a system .htpasswd file and the machinery to parse
-// it. Only bcrypt hash entries are supported.
-type htpasswd struct {
+// HTPasswd holds the entries of a system .htpasswd file and the machinery to
+// parse it. Only bcrypt hash entries are supported. It authenticates users for
+// the access controller and for other components, such as the token issuer
+// embedded in the registry.
+type HTPasswd struct {
 	entries map[string][]byte // maps username to password byte slice.
 }
 
-// newHTPasswd parses the reader and returns an htpasswd or an error.
-func newHTPasswd(rd io.Reader) (*htpasswd, error) {
+// NewHTPasswd parses the reader and returns an HTPasswd or an error.
+func NewHTPasswd(rd io.Reader) (*HTPasswd, error) {
 	entries, err := parseHTPasswd(rd)
 	if err != nil {
 		return nil, err
 	}
 
-	return &htpasswd{entries: entries}, nil
+	return &HTPasswd{entries: entries}, nil
+}
+
+// LoadHTPasswd parses the htpasswd file at path.
+func LoadHTPasswd(path string) (*HTPasswd, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return NewHTPasswd(f)
 }
 
 // AuthenticateUser checks a given user:password credential against the
 // receiving HTPasswd's file. If the check passes, nil is returned.
-func (htpasswd *htpasswd) authenticateUser(username string, password string) error {
+func (htpasswd *HTPasswd) AuthenticateUser(username string, password string) error {
 	credentials, ok := htpasswd.entries[username]
 	if !ok {
 		// timing attack paranoia
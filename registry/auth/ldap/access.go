@@ -0,0 +1,443 @@
+// Package ldap provides an access controller authenticating the basic
+// credentials of requests by binding to an LDAP directory, such as Active
+// Directory, as the user. The groups of the user are searched in the
+// directory and mapped to repository permissions by an access control list.
+//
+// Successful authentications are cached for a while, so that the directory
+// isn't queried for every request.
+//
+// This authentication method MUST be used under TLS, as simple token-replay attack is possible.
+package ldap
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/acl"
+	"gopkg.in/ldap.v2"
+)
+
+var (
+	// ErrInvalidCredential is returned when the auth token does not authenticate correctly.
+	ErrInvalidCredential = errors.New("invalid authorization credential")
+
+	// ErrAuthenticationFailure returned when authentication failure to be presented to agent.
+	ErrAuthenticationFailure = errors.New("authentication failure")
+)
+
+const (
+	defaultGroupFilter    = "(member=${dn})"
+	defaultGroupAttribute = "cn"
+	defaultCacheTTL       = 5 * time.Minute
+	defaultTimeout        = 10 * time.Second
+)
+
+type accessController struct {
+	realm string
+
+	// addr is the address of the directory, reached with TLS if tlsConfig
+	// is set, after connecting if startTLS.
+	addr      string
+	tlsConfig *tls.Config
+	startTLS  bool
+	timeout   time.Duration
+
+	// userDN is the template of the DN users bind as, such as
+	// "uid=${user},ou=people,dc=example,dc=com".
+	userDN string
+
+	// groupBaseDN is where groups are searched with groupFilter, their name
+	// being groupAttribute. Groups aren't searched if groupBaseDN is empty.
+	groupBaseDN    string
+	groupFilter    string
+	groupAttribute string
+
+	// acl grants access to users and groups.
+	acl *acl.File
+
+	cache *cache
+}
+
+var _ auth.AccessController = &accessController{}
+
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	ac := &accessController{
+		groupFilter:    defaultGroupFilter,
+		groupAttribute: defaultGroupAttribute,
+		timeout:        defaultTimeout,
+	}
+
+	stringOptions := map[string]*string{
+		"realm":          &ac.realm,
+		"userdn":         &ac.userDN,
+		"groupbasedn":    &ac.groupBaseDN,
+		"groupfilter":    &ac.groupFilter,
+		"groupattribute": &ac.groupAttribute,
+	}
+	for name, value := range stringOptions {
+		if option, present := options[name]; present {
+			s, ok := option.(string)
+			if !ok {
+				return nil, fmt.Errorf("%q must be a string for ldap access controller", name)
+			}
+			*value = s
+		}
+	}
+
+	if ac.realm == "" {
+		return nil, fmt.Errorf(`"realm" must be set for ldap access controller`)
+	}
+
+	if ac.userDN == "" || !strings.Contains(ac.userDN, "${user}") {
+		return nil, fmt.Errorf(`"userdn" must be set for ldap access controller, the user name replacing ${user}`)
+	}
+
+	rawurl, _ := options["url"].(string)
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf(`"url" must be set for ldap access controller, such as ldaps://ldap.example.com`)
+	}
+
+	var insecureSkipVerify bool
+	for name, value := range map[string]*bool{
+		"starttls":           &ac.startTLS,
+		"insecureskipverify": &insecureSkipVerify,
+	} {
+		if option, present := options[name]; present {
+			b, ok := option.(bool)
+			if !ok {
+				return nil, fmt.Errorf("%q must be a boolean for ldap access controller", name)
+			}
+			*value = b
+		}
+	}
+
+	host, port := u.Host, ""
+	if h, p, err := net.SplitHostPort(u.Host); err == nil {
+		host, port = h, p
+	}
+
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+	case "ldaps":
+		if port == "" {
+			port = "636"
+		}
+		if ac.startTLS {
+			return nil, fmt.Errorf(`"starttls" can't be used with ldaps url for ldap access controller`)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported url scheme for ldap access controller: %q", u.Scheme)
+	}
+	ac.addr = net.JoinHostPort(host, port)
+
+	if u.Scheme == "ldaps" || ac.startTLS {
+		ac.tlsConfig = &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: insecureSkipVerify,
+		}
+	}
+
+	cacheTTL := defaultCacheTTL
+	for name, value := range map[string]*time.Duration{
+		"cachettl": &cacheTTL,
+		"timeout":  &ac.timeout,
+	} {
+		if option, present := options[name]; present {
+			d, err := parseDuration(option)
+			if err != nil {
+				return nil, fmt.Errorf("%q must be a duration for ldap access controller: %v", name, err)
+			}
+			*value = d
+		}
+	}
+
+	if ac.cache, err = newCache(cacheTTL); err != nil {
+		return nil, err
+	}
+
+	// Any user of the directory may bind, so access is only granted by the
+	// access control list.
+	aclPath, _ := options["acl"].(string)
+	if aclPath == "" {
+		return nil, fmt.Errorf(`"acl" must be set to the path of an access control list file for ldap access controller`)
+	}
+
+	if ac.acl, err = acl.OpenFile(aclPath); err != nil {
+		return nil, err
+	}
+
+	return ac, nil
+}
+
+func (ac *accessController) Authorized(ctx context.Context, accessRecords ...auth.Access) (context.Context, error) {
+	req, err := context.GetRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   ErrInvalidCredential,
+		}
+	}
+
+	groups, ok := ac.cache.get(username, password)
+	if !ok {
+		groups, err = ac.authenticate(username, password)
+		if err != nil {
+			if err != ErrAuthenticationFailure {
+				return nil, err
+			}
+
+			context.GetLogger(ctx).Errorf("error authenticating user %q: %v", username, err)
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   ErrAuthenticationFailure,
+			}
+		}
+
+		ac.cache.put(username, password, groups)
+	}
+
+	checker := ac.acl.ACL().AccessChecker(acl.Subject{Name: username, Groups: groups})
+
+	for _, access := range accessRecords {
+		if !checker(access) {
+			context.GetLogger(ctx).Warnf("user %q denied access to %v", username, accessRecords)
+			return nil, auth.ErrAccessDenied
+		}
+	}
+
+	return auth.WithAccessChecker(auth.WithUser(ctx, auth.UserInfo{Name: username}), checker), nil
+}
+
+// authenticate binds to the directory as the user and returns the groups the
+// user belongs to. ErrAuthenticationFailure is returned if the credentials
+// are refused, other errors meaning the directory couldn't be queried.
+func (ac *accessController) authenticate(username, password string) ([]string, error) {
+	// Binding without password is an anonymous bind, which most directories
+	// accept.
+	if username == "" || password == "" {
+		return nil, ErrAuthenticationFailure
+	}
+
+	conn, err := ac.dial()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to ldap server %s: %v", ac.addr, err)
+	}
+	defer conn.Close()
+
+	userDN := strings.Replace(ac.userDN, "${user}", escapeDN(username), -1)
+	if err := conn.Bind(userDN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrAuthenticationFailure
+		}
+		return nil, fmt.Errorf("error binding to ldap server %s as %q: %v", ac.addr, userDN, err)
+	}
+
+	if ac.groupBaseDN == "" {
+		return nil, nil
+	}
+
+	filter := strings.NewReplacer(
+		"${user}", ldap.EscapeFilter(username),
+		"${dn}", ldap.EscapeFilter(userDN),
+	).Replace(ac.groupFilter)
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		ac.groupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter, []string{ac.groupAttribute}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error searching groups of %q with filter %q: %v", userDN, filter, err)
+	}
+
+	var groups []string
+	for _, entry := range result.Entries {
+		groups = append(groups, entry.GetAttributeValues(ac.groupAttribute)...)
+	}
+
+	return groups, nil
+}
+
+// dial connects to the directory, securing the connection with TLS if
+// configured.
+func (ac *accessController) dial() (*ldap.Conn, error) {
+	c, err := net.DialTimeout("tcp", ac.addr, ac.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	isTLS := ac.tlsConfig != nil && !ac.startTLS
+	if isTLS {
+		tc := tls.Client(c, ac.tlsConfig)
+		tc.SetDeadline(time.Now().Add(ac.timeout))
+		if err := tc.Handshake(); err != nil {
+			c.Close()
+			return nil, err
+		}
+		tc.SetDeadline(time.Time{})
+		c = tc
+	}
+
+	conn := ldap.NewConn(c, isTLS)
+	conn.Start()
+	conn.SetTimeout(ac.timeout)
+
+	if ac.startTLS {
+		if err := conn.StartTLS(ac.tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// escapeDN escapes the special characters of a DN attribute value.
+func escapeDN(value string) string {
+	var escaped []byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case strings.IndexByte(`,+"\<>;=`, c) >= 0,
+			c == '#' && i == 0,
+			c == ' ' && (i == 0 || i == len(value)-1):
+			escaped = append(escaped, '\\', c)
+		default:
+			escaped = append(escaped, c)
+		}
+	}
+
+	return string(escaped)
+}
+
+func parseDuration(option interface{}) (time.Duration, error) {
+	switch d := option.(type) {
+	case time.Duration:
+		return d, nil
+	case string:
+		return time.ParseDuration(d)
+	default:
+		return 0, fmt.Errorf("invalid duration: %v", option)
+	}
+}
+
+// cache remembers the groups of users successfully authenticated, until
+// their ttl expires. Credentials are identified by a keyed hash, so that
+// passwords aren't kept in memory.
+type cache struct {
+	ttl time.Duration
+	key []byte
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	groups  []string
+	expires time.Time
+}
+
+func newCache(ttl time.Duration) (*cache, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("error generating ldap cache key: %v", err)
+	}
+
+	return &cache{ttl: ttl, key: key, entries: make(map[string]cacheEntry)}, nil
+}
+
+func (c *cache) id(username, password string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(username))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	return string(mac.Sum(nil))
+}
+
+// get returns the groups of the user, if authenticated with password less
+// than ttl ago.
+func (c *cache) get(username, password string) ([]string, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	id := c.id(username, password)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(c.entries, id)
+		return nil, false
+	}
+
+	return entry.groups, true
+}
+
+// put remembers that the user authenticated with password, removing the
+// expired entries.
+func (c *cache) put(username, password string, groups []string) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	id := c.id(username, password)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, id)
+		}
+	}
+
+	c.entries[id] = cacheEntry{groups: groups, expires: now.Add(c.ttl)}
+}
+
+// challenge implements the auth.Challenge interface.
+type challenge struct {
+	realm string
+	err   error
+}
+
+var _ auth.Challenge = challenge{}
+
+// SetHeaders sets the basic challenge header on the response.
+func (ch challenge) SetHeaders(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", ch.realm))
+}
+
+func (ch challenge) Error() string {
+	return fmt.Sprintf("basic authentication challenge for realm %q: %s", ch.realm, ch.err)
+}
+
+func init() {
+	auth.Register("ldap", auth.InitFunc(newAccessController))
+}
@@ -0,0 +1,220 @@
+package ldap
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+)
+
+const (
+	aliceDN = "uid=alice,ou=people,dc=example,dc=com"
+	bobDN   = "uid=bob,ou=people,dc=example,dc=com"
+)
+
+func newTestAccessController(t *testing.T, td *testDirectory, options map[string]interface{}) (auth.AccessController, string) {
+	aclFile, err := ioutil.TempFile("", "ldap-acl")
+	if err != nil {
+		t.Fatalf("unexpected error creating acl file: %v", err)
+	}
+	defer aclFile.Close()
+
+	if _, err := aclFile.WriteString(`
+rules:
+  - groups: [developers]
+    repositories: ["team/*"]
+    actions: [pull, push]
+  - users: ["*"]
+    repositories: ["library/*"]
+    actions: [pull]
+`); err != nil {
+		t.Fatalf("unexpected error writing acl file: %v", err)
+	}
+
+	defaults := map[string]interface{}{
+		"realm":       "ldap-realm",
+		"url":         td.url(),
+		"userdn":      "uid=${user},ou=people,dc=example,dc=com",
+		"groupbasedn": "ou=groups,dc=example,dc=com",
+		"groupfilter": "(&(objectClass=groupOfNames)(member=${dn}))",
+		"acl":         aclFile.Name(),
+	}
+	for name, value := range options {
+		defaults[name] = value
+	}
+
+	accessController, err := newAccessController(defaults)
+	if err != nil {
+		t.Fatalf("unexpected error creating access controller: %v", err)
+	}
+
+	return accessController, aclFile.Name()
+}
+
+func authorize(accessController auth.AccessController, username, password string, access ...auth.Access) (context.Context, error) {
+	req, _ := http.NewRequest("GET", "/v2/", nil)
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	return accessController.Authorized(context.WithRequest(context.Background(), req), access...)
+}
+
+func repositoryAccess(name, action string) auth.Access {
+	return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: action}
+}
+
+func TestAccessController(t *testing.T) {
+	td := newTestDirectory(t,
+		map[string]string{
+			aliceDN: "wonderland",
+			bobDN:   "builder",
+		},
+		map[string][]string{
+			"(&(objectClass=groupOfNames)(member=" + aliceDN + "))": {"developers"},
+		})
+	defer td.close()
+
+	accessController, aclPath := newTestAccessController(t, td, nil)
+	defer os.Remove(aclPath)
+
+	if _, err := authorize(accessController, "", ""); err == nil {
+		t.Fatal("expected request without credentials to be challenged")
+	} else if _, ok := err.(auth.Challenge); !ok {
+		t.Fatalf("expected challenge, got %v", err)
+	}
+
+	if _, err := authorize(accessController, "alice", "looking-glass"); err == nil {
+		t.Fatal("expected wrong password to be challenged")
+	} else if _, ok := err.(auth.Challenge); !ok {
+		t.Fatalf("expected challenge, got %v", err)
+	}
+
+	push := []auth.Access{repositoryAccess("team/app", "pull"), repositoryAccess("team/app", "push")}
+	authCtx, err := authorize(accessController, "alice", "wonderland", push...)
+	if err != nil {
+		t.Fatalf("unexpected error authorizing alice: %v", err)
+	}
+
+	if userInfo, ok := authCtx.Value("auth.user").(auth.UserInfo); !ok || userInfo.Name != "alice" {
+		t.Fatalf("unexpected user info: %v", authCtx.Value("auth.user"))
+	}
+
+	if _, err := authorize(accessController, "alice", "wonderland", repositoryAccess("library/ubuntu", "push")); err != auth.ErrAccessDenied {
+		t.Fatalf("expected alice to be denied pushing to library/ubuntu: %v", err)
+	}
+
+	if _, err := authorize(accessController, "bob", "builder", push...); err != auth.ErrAccessDenied {
+		t.Fatalf("expected bob, not a developer, to be denied pushing to team/app: %v", err)
+	}
+
+	if _, err := authorize(accessController, "bob", "builder", repositoryAccess("library/ubuntu", "pull")); err != nil {
+		t.Fatalf("unexpected error authorizing bob to pull: %v", err)
+	}
+
+	// Alice and bob bound once each, the wrong password once, subsequent
+	// requests using the cache.
+	if binds := td.bindCount(); binds != 3 {
+		t.Fatalf("unexpected number of binds: %d != 3", binds)
+	}
+
+	// A different password isn't authenticated by the cache.
+	if _, err := authorize(accessController, "alice", "looking-glass"); err == nil {
+		t.Fatal("expected wrong password to be challenged once alice is cached")
+	}
+}
+
+func TestAccessControllerWithoutCache(t *testing.T) {
+	td := newTestDirectory(t, map[string]string{aliceDN: "wonderland"}, nil)
+	defer td.close()
+
+	accessController, aclPath := newTestAccessController(t, td, map[string]interface{}{
+		"cachettl": "0s",
+	})
+	defer os.Remove(aclPath)
+
+	for i := 0; i < 2; i++ {
+		if _, err := authorize(accessController, "alice", "wonderland", repositoryAccess("library/ubuntu", "pull")); err != nil {
+			t.Fatalf("unexpected error authorizing alice: %v", err)
+		}
+	}
+
+	if binds := td.bindCount(); binds != 2 {
+		t.Fatalf("unexpected number of binds: %d != 2", binds)
+	}
+}
+
+func TestAccessControllerDirectoryUnavailable(t *testing.T) {
+	td := newTestDirectory(t, nil, nil)
+	accessController, aclPath := newTestAccessController(t, td, map[string]interface{}{
+		"timeout": "1s",
+	})
+	defer os.Remove(aclPath)
+	td.close()
+
+	_, err := authorize(accessController, "alice", "wonderland")
+	if err == nil {
+		t.Fatal("expected error authorizing with the directory unavailable")
+	}
+
+	if _, ok := err.(auth.Challenge); ok {
+		t.Fatalf("unexpected challenge with the directory unavailable: %v", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	c, err := newCache(50 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error creating cache: %v", err)
+	}
+
+	c.put("alice", "wonderland", []string{"developers"})
+	if groups, ok := c.get("alice", "wonderland"); !ok || len(groups) != 1 {
+		t.Fatalf("expected cached groups of alice: %v", groups)
+	}
+
+	if _, ok := c.get("alice", "looking-glass"); ok {
+		t.Fatal("unexpected cache hit with another password")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := c.get("alice", "wonderland"); ok {
+		t.Fatal("unexpected cache hit once expired")
+	}
+}
+
+func TestEscapeDN(t *testing.T) {
+	for value, expected := range map[string]string{
+		"alice":         "alice",
+		"doe, john":     `doe\, john`,
+		"#admin":        `\#admin`,
+		" a+b=c ":       `\ a\+b\=c\ `,
+		`quote"back\\`:  `quote\"back\\\\`,
+		"<script>;evil": `\<script\>\;evil`,
+	} {
+		if escaped := escapeDN(value); escaped != expected {
+			t.Fatalf("unexpected escaping of %q: %q != %q", value, escaped, expected)
+		}
+	}
+}
+
+func TestNewAccessControllerInvalid(t *testing.T) {
+	for _, options := range []map[string]interface{}{
+		{"url": "ldap://localhost", "userdn": "uid=${user},dc=example,dc=com"},
+		{"realm": "ldap-realm", "userdn": "uid=${user},dc=example,dc=com"},
+		{"realm": "ldap-realm", "url": "ldap://localhost", "userdn": "uid=admin,dc=example,dc=com"},
+		{"realm": "ldap-realm", "url": "http://localhost", "userdn": "uid=${user},dc=example,dc=com"},
+		{"realm": "ldap-realm", "url": "ldaps://localhost", "userdn": "uid=${user},dc=example,dc=com", "starttls": true},
+		{"realm": "ldap-realm", "url": "ldap://localhost", "userdn": "uid=${user},dc=example,dc=com", "cachettl": 5},
+		{"realm": "ldap-realm", "url": "ldap://localhost", "userdn": "uid=${user},dc=example,dc=com"},
+		{"realm": "ldap-realm", "url": "ldap://localhost", "userdn": "uid=${user},dc=example,dc=com", "acl": 5},
+	} {
+		if _, err := newAccessController(options); err == nil {
+			t.Fatalf("expected error creating access controller with options %v", options)
+		}
+	}
+}
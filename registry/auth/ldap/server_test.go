@@ -0,0 +1,147 @@
+package ldap
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"gopkg.in/asn1-ber.v1"
+	"gopkg.in/ldap.v2"
+)
+
+// testDirectory is an in-process stand-in for an LDAP server, answering the
+// bind and search requests of the access controller.
+type testDirectory struct {
+	listener net.Listener
+
+	// passwords maps the DN of users to their password.
+	passwords map[string]string
+
+	// groups maps search filters to the names of the groups found.
+	groups map[string][]string
+
+	mu    sync.Mutex
+	binds int
+}
+
+func newTestDirectory(t *testing.T, passwords map[string]string, groups map[string][]string) *testDirectory {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error listening: %v", err)
+	}
+
+	td := &testDirectory{
+		listener:  listener,
+		passwords: passwords,
+		groups:    groups,
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go td.serve(conn)
+		}
+	}()
+
+	return td
+}
+
+// url returns the url of the directory.
+func (td *testDirectory) url() string {
+	return "ldap://" + td.listener.Addr().String()
+}
+
+func (td *testDirectory) close() {
+	td.listener.Close()
+}
+
+// bindCount returns the number of bind requests received.
+func (td *testDirectory) bindCount() int {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+
+	return td.binds
+}
+
+func (td *testDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		messageID, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		var responses []*ber.Packet
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, _ := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+
+			td.mu.Lock()
+			td.binds++
+			td.mu.Unlock()
+
+			resultCode := ldap.LDAPResultSuccess
+			if expected, ok := td.passwords[dn]; !ok || expected != password {
+				resultCode = ldap.LDAPResultInvalidCredentials
+			}
+			responses = append(responses, ldapResult(ldap.ApplicationBindResponse, resultCode))
+		case ldap.ApplicationSearchRequest:
+			baseDN, _ := op.Children[0].Value.(string)
+			filter, err := ldap.DecompileFilter(op.Children[6])
+			if err != nil {
+				responses = append(responses, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError))
+				break
+			}
+
+			for _, group := range td.groups[filter] {
+				responses = append(responses, searchResultEntry("cn="+group+","+baseDN, "cn", group))
+			}
+			responses = append(responses, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		default:
+			// Unbind requests and anything unexpected end the connection.
+			return
+		}
+
+		for _, response := range responses {
+			message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+			message.AppendChild(response)
+			if _, err := conn.Write(message.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func ldapResult(tag int, resultCode int) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(tag), nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, resultCode, "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return result
+}
+
+func searchResultEntry(dn, attribute, value string) *ber.Packet {
+	values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+	values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+
+	attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+	attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attribute, "Type"))
+	attr.AppendChild(values)
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	attributes.AppendChild(attr)
+
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "DN"))
+	entry.AppendChild(attributes)
+	return entry
+}
@@ -0,0 +1,246 @@
+// Package oidc provides an access controller accepting the JSON Web Tokens
+// issued by OpenID Connect providers, such as the ID tokens CI systems hold,
+// as bearer tokens. Tokens are verified against the JSON Web Key Set of the
+// provider, and their claims mapped to repository permissions by an access
+// control list.
+//
+// Unlike the token access controller, tokens aren't issued for the registry
+// and list no access: the provider only vouches for the identity of the
+// bearer, which the access control list grants access to.
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/acl"
+)
+
+// Errors used and exported by this package.
+var (
+	ErrTokenRequired = errors.New("authorization token required")
+	ErrInvalidToken  = errors.New("invalid token")
+)
+
+const (
+	defaultUserClaim   = "sub"
+	defaultGroupsClaim = "groups"
+	defaultJWKSRefresh = time.Hour
+	defaultTimeout     = 10 * time.Second
+)
+
+type accessController struct {
+	realm   string
+	service string
+
+	// issuer and audience are the values the iss and aud claims of tokens
+	// must have.
+	issuer   string
+	audience string
+
+	keys *keySet
+
+	// userClaim names the claim holding the name of users, groupsClaim the
+	// claim listing their groups.
+	userClaim   string
+	groupsClaim string
+
+	acl *acl.File
+}
+
+var _ auth.AccessController = &accessController{}
+
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	ac := &accessController{
+		userClaim:   defaultUserClaim,
+		groupsClaim: defaultGroupsClaim,
+	}
+
+	var jwks, aclPath string
+	stringOptions := map[string]*string{
+		"realm":       &ac.realm,
+		"service":     &ac.service,
+		"issuer":      &ac.issuer,
+		"audience":    &ac.audience,
+		"jwks":        &jwks,
+		"userclaim":   &ac.userClaim,
+		"groupsclaim": &ac.groupsClaim,
+		"acl":         &aclPath,
+	}
+	for name, value := range stringOptions {
+		if option, present := options[name]; present {
+			s, ok := option.(string)
+			if !ok {
+				return nil, fmt.Errorf("%q must be a string for oidc access controller", name)
+			}
+			*value = s
+		}
+	}
+
+	for _, required := range []string{"realm", "issuer", "audience", "jwks", "userclaim", "acl"} {
+		if *stringOptions[required] == "" {
+			return nil, fmt.Errorf("%q must be set for oidc access controller", required)
+		}
+	}
+
+	jwksRefresh := defaultJWKSRefresh
+	if option, present := options["jwksrefresh"]; present {
+		d, err := parseDuration(option)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf(`"jwksrefresh" must be a positive duration for oidc access controller`)
+		}
+		jwksRefresh = d
+	}
+
+	var err error
+	if ac.keys, err = newKeySet(jwks, &http.Client{Timeout: defaultTimeout}, jwksRefresh); err != nil {
+		return nil, fmt.Errorf("unable to load oidc key set %q: %v", jwks, err)
+	}
+
+	if ac.acl, err = acl.OpenFile(aclPath); err != nil {
+		return nil, err
+	}
+
+	return ac, nil
+}
+
+// Authorized verifies the bearer token of the request and checks the access
+// control list grants its subject the requested access.
+func (ac *accessController) Authorized(ctx context.Context, accessRecords ...auth.Access) (context.Context, error) {
+	req, err := context.GetRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.SplitN(req.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return nil, ac.challenge(ErrTokenRequired, accessRecords)
+	}
+
+	t, err := ac.verify(strings.TrimSpace(parts[1]))
+	if err != nil {
+		context.GetLogger(ctx).Errorf("error verifying oidc token: %v", err)
+		return nil, ac.challenge(ErrInvalidToken, accessRecords)
+	}
+
+	subject := acl.Subject{
+		Name:   t.values(ac.userClaim)[0],
+		Groups: t.values(ac.groupsClaim),
+		Claims: make(map[string][]string, len(t.claims)),
+	}
+	for claim := range t.claims {
+		subject.Claims[claim] = t.values(claim)
+	}
+
+	checker := ac.acl.ACL().AccessChecker(subject)
+	for _, access := range accessRecords {
+		if !checker(access) {
+			context.GetLogger(ctx).Warnf("user %q denied access to %v", subject.Name, accessRecords)
+			return nil, auth.ErrAccessDenied
+		}
+	}
+
+	return auth.WithAccessChecker(auth.WithUser(ctx, auth.UserInfo{Name: subject.Name}), checker), nil
+}
+
+// verify parses the token and checks its signature and claims.
+func (ac *accessController) verify(raw string) (*token, error) {
+	t, err := parseToken(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.verifySignature(ac.keys.lookup(t.header.KeyID)); err != nil {
+		return nil, err
+	}
+
+	if err := t.validate(ac.issuer, ac.audience, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if users := t.values(ac.userClaim); len(users) != 1 || users[0] == "" {
+		return nil, fmt.Errorf("token has no %q claim naming the user", ac.userClaim)
+	}
+
+	return t, nil
+}
+
+func (ac *accessController) challenge(err error, accessRecords []auth.Access) *challenge {
+	return &challenge{realm: ac.realm, service: ac.service, scope: scope(accessRecords), err: err}
+}
+
+// scope returns the scope parameter of a challenge for accessRecords, such as
+// "repository:foo/bar:pull,push".
+func scope(accessRecords []auth.Access) string {
+	var (
+		resources []auth.Resource
+		actions   = make(map[auth.Resource][]string)
+	)
+	for _, access := range accessRecords {
+		if _, ok := actions[access.Resource]; !ok {
+			resources = append(resources, access.Resource)
+		}
+		actions[access.Resource] = append(actions[access.Resource], access.Action)
+	}
+
+	scopes := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		scopes = append(scopes, fmt.Sprintf("%s:%s:%s", resource.Type, resource.Name, strings.Join(actions[resource], ",")))
+	}
+
+	return strings.Join(scopes, " ")
+}
+
+func parseDuration(option interface{}) (time.Duration, error) {
+	switch d := option.(type) {
+	case time.Duration:
+		return d, nil
+	case string:
+		return time.ParseDuration(d)
+	default:
+		return 0, fmt.Errorf("invalid duration: %v", option)
+	}
+}
+
+// challenge implements the auth.Challenge interface.
+type challenge struct {
+	realm   string
+	service string
+	scope   string
+	err     error
+}
+
+var _ auth.Challenge = challenge{}
+
+// SetHeaders sets the bearer challenge header on the response, as described
+// by RFC 6750, with the invalid_token error code if a token was presented.
+func (ch challenge) SetHeaders(w http.ResponseWriter) {
+	header := fmt.Sprintf("Bearer realm=%q", ch.realm)
+	if ch.service != "" {
+		header = fmt.Sprintf("%s,service=%q", header, ch.service)
+	}
+
+	if ch.scope != "" {
+		header = fmt.Sprintf("%s,scope=%q", header, ch.scope)
+	}
+
+	if ch.err == ErrInvalidToken {
+		header = fmt.Sprintf("%s,error=%q", header, "invalid_token")
+	}
+
+	w.Header().Set("WWW-Authenticate", header)
+}
+
+func (ch challenge) Error() string {
+	return fmt.Sprintf("oidc authentication challenge for realm %q: %s", ch.realm, ch.err)
+}
+
+// init registers the oidc auth backend.
+func init() {
+	auth.Register("oidc", auth.InitFunc(newAccessController))
+}
@@ -0,0 +1,335 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+)
+
+const (
+	testIssuer   = "https://token.example.com"
+	testAudience = "registry.example.com"
+)
+
+// testKey is a key tokens are signed with in tests.
+type testKey struct {
+	id         string
+	privateKey crypto.Signer
+}
+
+func newRSAKey(t *testing.T, id string) testKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error generating rsa key: %v", err)
+	}
+
+	return testKey{id: id, privateKey: privateKey}
+}
+
+func newECKey(t *testing.T, id string) testKey {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating ecdsa key: %v", err)
+	}
+
+	return testKey{id: id, privateKey: privateKey}
+}
+
+func encodeInt(p []byte) string {
+	return base64.RawURLEncoding.EncodeToString(p)
+}
+
+// keySetJSON returns the JSON Web Key Set of the public keys.
+func keySetJSON(t *testing.T, keys ...testKey) []byte {
+	var set struct {
+		Keys []rawJSONWebKey `json:"keys"`
+	}
+
+	for _, key := range keys {
+		switch privateKey := key.privateKey.(type) {
+		case *rsa.PrivateKey:
+			set.Keys = append(set.Keys, rawJSONWebKey{
+				KeyType: "RSA",
+				KeyID:   key.id,
+				Use:     "sig",
+				N:       encodeInt(privateKey.N.Bytes()),
+				E:       encodeInt([]byte{1, 0, 1}),
+			})
+		case *ecdsa.PrivateKey:
+			set.Keys = append(set.Keys, rawJSONWebKey{
+				KeyType: "EC",
+				KeyID:   key.id,
+				Curve:   "P-256",
+				X:       encodeInt(privateKey.X.Bytes()),
+				Y:       encodeInt(privateKey.Y.Bytes()),
+			})
+		}
+	}
+
+	p, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("unexpected error encoding key set: %v", err)
+	}
+
+	return p
+}
+
+// sign returns a token with claims, signed with key.
+func (key testKey) sign(t *testing.T, claims map[string]interface{}) string {
+	alg := "RS256"
+	if _, ok := key.privateKey.(*ecdsa.PrivateKey); ok {
+		alg = "ES256"
+	}
+
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": alg, "kid": key.id})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("unexpected error encoding claims: %v", err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch privateKey := key.privateKey.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		if r, s, err = ecdsa.Sign(rand.Reader, privateKey, digest[:]); err == nil {
+			// The signature is the concatenation of r and s, each padded
+			// to 32 bytes.
+			signature = make([]byte, 64)
+			rBytes, sBytes := r.Bytes(), s.Bytes()
+			copy(signature[32-len(rBytes):], rBytes)
+			copy(signature[64-len(sBytes):], sBytes)
+		}
+	}
+	if err != nil {
+		t.Fatalf("unexpected error signing token: %v", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// claims returns valid claims for subject, with extra claims.
+func claims(subject string, extra map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss": testIssuer,
+		"aud": testAudience,
+		"sub": subject,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range extra {
+		claims[name] = value
+	}
+
+	return claims
+}
+
+const testACL = `
+rules:
+  - groups: [developers]
+    repositories: ["team/*"]
+    actions: [pull, push]
+  - claims:
+      repository_owner: example
+      ref: refs/heads/main
+    repositories: ["example/*"]
+    actions: [pull, push]
+  - users: ["*"]
+    repositories: ["library/*"]
+    actions: [pull]
+`
+
+func writeTempFile(t *testing.T, prefix string, p []byte) string {
+	f, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(p); err != nil {
+		t.Fatalf("unexpected error writing temporary file: %v", err)
+	}
+
+	return f.Name()
+}
+
+func authorize(accessController auth.AccessController, rawToken string, access ...auth.Access) (context.Context, error) {
+	req, _ := http.NewRequest("GET", "/v2/", nil)
+	if rawToken != "" {
+		req.Header.Set("Authorization", "Bearer "+rawToken)
+	}
+
+	return accessController.Authorized(context.WithRequest(context.Background(), req), access...)
+}
+
+func repositoryAccess(name, action string) auth.Access {
+	return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: action}
+}
+
+func TestAccessController(t *testing.T) {
+	rsaKey, ecKey, untrustedKey := newRSAKey(t, "rsa"), newECKey(t, "ec"), newRSAKey(t, "rsa")
+
+	jwksPath := writeTempFile(t, "jwks", keySetJSON(t, rsaKey, ecKey))
+	defer os.Remove(jwksPath)
+	aclPath := writeTempFile(t, "acl", []byte(testACL))
+	defer os.Remove(aclPath)
+
+	accessController, err := newAccessController(map[string]interface{}{
+		"realm":    "https://auth.example.com/token",
+		"service":  testAudience,
+		"issuer":   testIssuer,
+		"audience": testAudience,
+		"jwks":     jwksPath,
+		"acl":      aclPath,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating access controller: %v", err)
+	}
+
+	push := []auth.Access{repositoryAccess("team/app", "pull"), repositoryAccess("team/app", "push")}
+
+	_, err = authorize(accessController, "", push...)
+	challenge, ok := err.(auth.Challenge)
+	if !ok {
+		t.Fatalf("expected request without token to be challenged: %v", err)
+	}
+
+	resp := httptest.NewRecorder()
+	challenge.SetHeaders(resp)
+	expected := `Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:team/app:pull,push"`
+	if header := resp.Header().Get("WWW-Authenticate"); header != expected {
+		t.Fatalf("unexpected challenge: %q != %q", header, expected)
+	}
+
+	for _, key := range []testKey{rsaKey, ecKey} {
+		authCtx, err := authorize(accessController, key.sign(t, claims("alice", map[string]interface{}{"groups": []string{"developers"}})), push...)
+		if err != nil {
+			t.Fatalf("unexpected error authorizing token signed with %s key: %v", key.id, err)
+		}
+
+		if userInfo, ok := authCtx.Value("auth.user").(auth.UserInfo); !ok || userInfo.Name != "alice" {
+			t.Fatalf("unexpected user info: %v", authCtx.Value("auth.user"))
+		}
+	}
+
+	if _, err := authorize(accessController, rsaKey.sign(t, claims("bob", nil)), push...); err != auth.ErrAccessDenied {
+		t.Fatalf("expected bob, not a developer, to be denied pushing to team/app: %v", err)
+	}
+
+	if _, err := authorize(accessController, rsaKey.sign(t, claims("bob", nil)), repositoryAccess("library/ubuntu", "pull")); err != nil {
+		t.Fatalf("unexpected error authorizing bob to pull: %v", err)
+	}
+
+	ci := map[string]interface{}{"repository_owner": "example", "ref": "refs/heads/main"}
+	if _, err := authorize(accessController, ecKey.sign(t, claims("repo:example/app:ref:refs/heads/main", ci)), repositoryAccess("example/app", "push")); err != nil {
+		t.Fatalf("unexpected error authorizing ci token by its claims: %v", err)
+	}
+
+	ci["ref"] = "refs/heads/feature"
+	if _, err := authorize(accessController, ecKey.sign(t, claims("repo:example/app:ref:refs/heads/feature", ci)), repositoryAccess("example/app", "push")); err != auth.ErrAccessDenied {
+		t.Fatalf("expected ci token of another branch to be denied: %v", err)
+	}
+
+	for description, rawToken := range map[string]string{
+		"malformed":       "not.a.token",
+		"untrusted key":   untrustedKey.sign(t, claims("alice", nil)),
+		"other issuer":    rsaKey.sign(t, claims("alice", map[string]interface{}{"iss": "https://evil.example.com"})),
+		"other audience":  rsaKey.sign(t, claims("alice", map[string]interface{}{"aud": []string{"other.example.com"}})),
+		"expired":         rsaKey.sign(t, claims("alice", map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()})),
+		"no expiration":   rsaKey.sign(t, claims("alice", map[string]interface{}{"exp": nil})),
+		"not yet valid":   rsaKey.sign(t, claims("alice", map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()})),
+		"no subject":      rsaKey.sign(t, claims("", nil)),
+		"tampered claims": tamper(rsaKey.sign(t, claims("bob", nil)), claims("alice", map[string]interface{}{"groups": []string{"developers"}})),
+		"algorithm none":  strings.Join(strings.Split(rsaKey.sign(t, claims("alice", nil)), ".")[:2], ".") + ".",
+	} {
+		_, err := authorize(accessController, rawToken, push...)
+		challenge, ok := err.(auth.Challenge)
+		if !ok {
+			t.Fatalf("expected %s token to be challenged: %v", description, err)
+		}
+
+		resp := httptest.NewRecorder()
+		challenge.SetHeaders(resp)
+		if header := resp.Header().Get("WWW-Authenticate"); !strings.HasSuffix(header, `,error="invalid_token"`) {
+			t.Fatalf("expected %s token challenge to report an invalid token: %q", description, header)
+		}
+	}
+
+	// An audience among several is accepted.
+	if _, err := authorize(accessController, rsaKey.sign(t, claims("bob", map[string]interface{}{"aud": []string{"other.example.com", testAudience}})), repositoryAccess("library/ubuntu", "pull")); err != nil {
+		t.Fatalf("unexpected error authorizing token with several audiences: %v", err)
+	}
+}
+
+// tamper replaces the claims of a signed token.
+func tamper(rawToken string, claims map[string]interface{}) string {
+	parts := strings.Split(rawToken, ".")
+	payload, _ := json.Marshal(claims)
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+	return strings.Join(parts, ".")
+}
+
+func TestNewAccessControllerInvalid(t *testing.T) {
+	jwksPath := writeTempFile(t, "jwks", keySetJSON(t, newECKey(t, "ec")))
+	defer os.Remove(jwksPath)
+	aclPath := writeTempFile(t, "acl", []byte(testACL))
+	defer os.Remove(aclPath)
+	invalidJWKSPath := writeTempFile(t, "jwks", []byte(`{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`))
+	defer os.Remove(invalidJWKSPath)
+
+	valid := map[string]interface{}{
+		"realm":    "https://auth.example.com/token",
+		"issuer":   testIssuer,
+		"audience": testAudience,
+		"jwks":     jwksPath,
+		"acl":      aclPath,
+	}
+	if _, err := newAccessController(valid); err != nil {
+		t.Fatalf("unexpected error creating access controller: %v", err)
+	}
+
+	for name, value := range map[string]interface{}{
+		"realm":       "",
+		"issuer":      "",
+		"audience":    "",
+		"jwks":        "/nonexistent/jwks.json",
+		"acl":         "",
+		"jwksrefresh": "0s",
+		"userclaim":   5,
+	} {
+		options := make(map[string]interface{})
+		for k, v := range valid {
+			options[k] = v
+		}
+		options[name] = value
+
+		if _, err := newAccessController(options); err == nil {
+			t.Fatalf("expected error creating access controller with %s %v", name, value)
+		}
+	}
+
+	valid["jwks"] = invalidJWKSPath
+	if _, err := newAccessController(valid); err == nil {
+		t.Fatal("expected error creating access controller with a key set without signing keys")
+	}
+}
@@ -0,0 +1,295 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// jsonWebKey is a public key of a JSON Web Key Set tokens may be signed with.
+type jsonWebKey struct {
+	id string
+
+	// alg is the algorithm the key is restricted to, any algorithm
+	// supported by the key being accepted if empty.
+	alg string
+
+	key crypto.PublicKey
+}
+
+// supports reports whether the key may verify signatures using alg.
+func (k jsonWebKey) supports(alg string) bool {
+	if k.alg != "" && k.alg != alg {
+		return false
+	}
+
+	switch key := k.key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256"
+	case *ecdsa.PublicKey:
+		return alg == "ES256" && key.Curve == elliptic.P256()
+	}
+
+	return false
+}
+
+// rawJSONWebKey is the JSON representation of RSA and elliptic curve keys, as
+// described in RFC 7517 and RFC 7518.
+type rawJSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+
+	// RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// Elliptic curve keys.
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+// parseKeySet reads the keys of a JSON Web Key Set. Keys which can't verify
+// signatures, such as encryption keys, are ignored.
+func parseKeySet(p []byte) ([]jsonWebKey, error) {
+	var set struct {
+		Keys []rawJSONWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(p, &set); err != nil {
+		return nil, fmt.Errorf("invalid key set: %v", err)
+	}
+
+	var keys []jsonWebKey
+	for i, raw := range set.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+
+		key, err := parseKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %d of key set: %v", i, err)
+		}
+
+		if key != nil {
+			keys = append(keys, jsonWebKey{id: raw.KeyID, alg: raw.Algorithm, key: key})
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("key set has no RSA or P-256 signing keys")
+	}
+
+	return keys, nil
+}
+
+// parseKey returns the public key of raw, or nil if its type isn't
+// supported.
+func parseKey(raw rawJSONWebKey) (crypto.PublicKey, error) {
+	switch raw.KeyType {
+	case "RSA":
+		n, err := decodeInt(raw.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %v", err)
+		}
+
+		e, err := decodeInt(raw.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if raw.Curve != "P-256" {
+			return nil, nil
+		}
+
+		x, err := decodeInt(raw.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %v", err)
+		}
+
+		y, err := decodeInt(raw.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %v", err)
+		}
+
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("point not on curve P-256")
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+
+	return nil, nil
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing value")
+	}
+
+	p, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(p), nil
+}
+
+// keySet is a JSON Web Key Set read from a file, or fetched from a URL. Key
+// sets fetched from a URL are fetched again once older than refresh, or when
+// a token is signed with an unknown key, so that the keys of the identity
+// provider can be rotated.
+type keySet struct {
+	source string
+	client *http.Client
+
+	// refresh is the time after which keys fetched from a URL are fetched
+	// again, minRefresh the time before which they aren't fetched again
+	// for unknown keys.
+	refresh    time.Duration
+	minRefresh time.Duration
+
+	mu      sync.Mutex
+	keys    []jsonWebKey
+	fetched time.Time
+
+	// fetching is closed once the fetch in progress completes, lookups
+	// needing fresh keys waiting for it rather than fetching them again.
+	fetching chan struct{}
+}
+
+// newKeySet reads the key set of source, a path or an http or https URL.
+func newKeySet(source string, client *http.Client, refresh time.Duration) (*keySet, error) {
+	ks := &keySet{
+		source:     source,
+		client:     client,
+		refresh:    refresh,
+		minRefresh: time.Minute,
+	}
+
+	keys, err := ks.load()
+	if err != nil {
+		return nil, err
+	}
+	ks.keys, ks.fetched = keys, time.Now()
+
+	return ks, nil
+}
+
+func (ks *keySet) remote() bool {
+	return strings.HasPrefix(ks.source, "http://") || strings.HasPrefix(ks.source, "https://")
+}
+
+func (ks *keySet) load() ([]jsonWebKey, error) {
+	if !ks.remote() {
+		p, err := ioutil.ReadFile(ks.source)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := parseKeySet(p)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", ks.source, err)
+		}
+
+		return keys, nil
+	}
+
+	resp, err := ks.client.Get(ks.source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching %s: %s", ks.source, resp.Status)
+	}
+
+	p, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %v", ks.source, err)
+	}
+
+	keys, err := parseKeySet(p)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %v", ks.source, err)
+	}
+
+	return keys, nil
+}
+
+// lookup returns the keys identified by id, or every key if id is empty.
+func (ks *keySet) lookup(id string) []jsonWebKey {
+	ks.mu.Lock()
+	keys := ks.find(id)
+	if !ks.remote() {
+		ks.mu.Unlock()
+		return keys
+	}
+
+	age := time.Since(ks.fetched)
+	if age < ks.refresh && (len(keys) > 0 || age < ks.minRefresh) {
+		ks.mu.Unlock()
+		return keys
+	}
+
+	if fetching := ks.fetching; fetching != nil {
+		ks.mu.Unlock()
+		<-fetching
+	} else {
+		fetching = make(chan struct{})
+		ks.fetching = fetching
+		ks.mu.Unlock()
+
+		// The keys are fetched without holding the lock, so that lookups of
+		// fresh keys aren't held up by the identity provider.
+		fetched, err := ks.load()
+
+		ks.mu.Lock()
+		ks.fetched = time.Now()
+		if err != nil {
+			log.Errorf("error refreshing key set, keeping the previous keys: %v", err)
+		} else {
+			ks.keys = fetched
+		}
+		ks.fetching = nil
+		ks.mu.Unlock()
+		close(fetching)
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	return ks.find(id)
+}
+
+func (ks *keySet) find(id string) []jsonWebKey {
+	if id == "" {
+		return ks.keys
+	}
+
+	var keys []jsonWebKey
+	for _, key := range ks.keys {
+		if key.id == id {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,198 @@
+package oidc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testKeyServer serves a JSON Web Key Set which can be changed, counting the
+// requests.
+type testKeyServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	keySet   []byte
+	status   int
+	requests int
+}
+
+func newTestKeyServer(keySet []byte) *testKeyServer {
+	ts := &testKeyServer{keySet: keySet, status: http.StatusOK}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+
+		ts.requests++
+		w.WriteHeader(ts.status)
+		w.Write(ts.keySet)
+	}))
+
+	return ts
+}
+
+func (ts *testKeyServer) set(keySet []byte, status int) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.keySet, ts.status = keySet, status
+}
+
+func (ts *testKeyServer) requestCount() int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	return ts.requests
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	oldKey, newKey := newECKey(t, "old"), newECKey(t, "new")
+
+	ts := newTestKeyServer(keySetJSON(t, oldKey))
+	defer ts.Close()
+
+	ks, err := newKeySet(ts.URL, http.DefaultClient, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error fetching key set: %v", err)
+	}
+
+	if keys := ks.lookup("old"); len(keys) != 1 {
+		t.Fatalf("expected old key to be found: %v", keys)
+	}
+
+	// The provider rotates its keys, but they were just fetched.
+	ts.set(keySetJSON(t, oldKey, newKey), http.StatusOK)
+	if keys := ks.lookup("new"); len(keys) != 0 {
+		t.Fatalf("unexpected new key found before the minimum refresh time: %v", keys)
+	}
+
+	if requests := ts.requestCount(); requests != 1 {
+		t.Fatalf("unexpected number of requests: %d != 1", requests)
+	}
+
+	// Unknown keys are fetched once the minimum refresh time elapsed.
+	ks.minRefresh = 0
+	if keys := ks.lookup("new"); len(keys) != 1 {
+		t.Fatalf("expected new key to be fetched: %v", keys)
+	}
+
+	// Known keys don't cause any request until the refresh time elapsed.
+	if keys := ks.lookup("old"); len(keys) != 1 || ts.requestCount() != 2 {
+		t.Fatalf("unexpected lookup of known key: %v, %d requests", keys, ts.requestCount())
+	}
+
+	// Failing to fetch the keys keeps the previous ones.
+	ks.refresh = 0
+	ts.set([]byte("unavailable"), http.StatusServiceUnavailable)
+	if keys := ks.lookup("old"); len(keys) != 1 || ts.requestCount() != 3 {
+		t.Fatalf("expected old key to be kept: %v, %d requests", keys, ts.requestCount())
+	}
+
+	// Keys removed by the provider are no longer trusted.
+	ts.set(keySetJSON(t, newKey), http.StatusOK)
+	if keys := ks.lookup("old"); len(keys) != 0 {
+		t.Fatalf("expected old key to be removed: %v", keys)
+	}
+
+	if keys := ks.lookup(""); len(keys) != 1 || keys[0].id != "new" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+}
+
+// TestRemoteKeySetConcurrentRefresh checks that lookups needing fresh keys
+// share a single fetch, and that lookups of known keys don't wait for it.
+func TestRemoteKeySetConcurrentRefresh(t *testing.T) {
+	oldKey, newKey := newECKey(t, "old"), newECKey(t, "new")
+
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	received, release := make(chan struct{}, 1), make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		first := requests == 1
+		mu.Unlock()
+
+		if first {
+			w.Write(keySetJSON(t, oldKey))
+			return
+		}
+
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		<-release
+		w.Write(keySetJSON(t, oldKey, newKey))
+	}))
+	defer ts.Close()
+
+	ks, err := newKeySet(ts.URL, http.DefaultClient, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error fetching key set: %v", err)
+	}
+	ks.minRefresh = 0
+
+	var wg sync.WaitGroup
+	results := make(chan []jsonWebKey, 10)
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- ks.lookup("new")
+		}()
+	}
+
+	<-received
+
+	// The key set is being fetched, but known keys are still available.
+	if keys := ks.lookup("old"); len(keys) != 1 {
+		t.Fatalf("expected old key to be found during the fetch: %v", keys)
+	}
+
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for keys := range results {
+		if len(keys) != 1 || keys[0].id != "new" {
+			t.Fatalf("expected new key to be fetched: %v", keys)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 {
+		t.Fatalf("unexpected number of requests: %d != 2", requests)
+	}
+}
+
+func TestParseKeySet(t *testing.T) {
+	rsaKey := keySetJSON(t, newRSAKey(t, "rsa"))
+
+	keys, err := parseKeySet(rsaKey)
+	if err != nil {
+		t.Fatalf("unexpected error parsing key set: %v", err)
+	}
+
+	if len(keys) != 1 || !keys[0].supports("RS256") || keys[0].supports("ES256") {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	for _, invalid := range []string{
+		`not json`,
+		`{"keys": []}`,
+		`{"keys": [{"kty": "RSA", "use": "enc", "n": "AQAB", "e": "AQAB"}]}`,
+		`{"keys": [{"kty": "RSA", "e": "AQAB"}]}`,
+		`{"keys": [{"kty": "EC", "crv": "P-384", "x": "AQAB", "y": "AQAB"}]}`,
+		`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQAB", "y": "AQAB"}]}`,
+	} {
+		if _, err := parseKeySet([]byte(invalid)); err == nil {
+			t.Fatalf("expected error parsing key set %s", invalid)
+		}
+	}
+}
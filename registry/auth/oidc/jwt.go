@@ -0,0 +1,195 @@
+package oidc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// token is a JSON Web Token, as issued by OpenID Connect providers.
+type token struct {
+	header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+
+	// claims maps the names of the claims to their values, numbers being
+	// decoded as json.Number.
+	claims map[string]interface{}
+
+	// signed is the part of the token covered by signature.
+	signed    string
+	signature []byte
+}
+
+// parseToken decodes the compact serialization of a JSON Web Token, without
+// verifying it.
+func parseToken(raw string) (*token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	t := new(token)
+	p, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode header: %v", err)
+	}
+
+	if err := json.Unmarshal(p, &t.header); err != nil {
+		return nil, fmt.Errorf("unable to decode header: %v", err)
+	}
+
+	if p, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		return nil, fmt.Errorf("unable to decode claims: %v", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	if err := dec.Decode(&t.claims); err != nil || t.claims == nil {
+		return nil, fmt.Errorf("unable to decode claims: %v", err)
+	}
+
+	if t.signature, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return nil, fmt.Errorf("unable to decode signature: %v", err)
+	}
+	t.signed = parts[0] + "." + parts[1]
+
+	return t, nil
+}
+
+// verifySignature checks the token is signed with one of keys.
+func (t *token) verifySignature(keys []jsonWebKey) error {
+	alg := t.header.Algorithm
+	if alg != "RS256" && alg != "ES256" {
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+
+	digest := sha256.Sum256([]byte(t.signed))
+	for _, key := range keys {
+		if !key.supports(alg) {
+			continue
+		}
+
+		switch key := key.key.(type) {
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], t.signature) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			if len(t.signature) != 64 {
+				continue
+			}
+
+			r := new(big.Int).SetBytes(t.signature[:32])
+			s := new(big.Int).SetBytes(t.signature[32:])
+			if ecdsa.Verify(key, digest[:], r, s) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("token not signed with a trusted %s key, key ID %q", alg, t.header.KeyID)
+}
+
+// validate checks the token was issued by issuer for audience, and is valid
+// at now.
+func (t *token) validate(issuer, audience string, now time.Time) error {
+	if iss, _ := t.claims["iss"].(string); iss != issuer {
+		return fmt.Errorf("token from untrusted issuer %q", iss)
+	}
+
+	if !contains(t.values("aud"), audience) {
+		return fmt.Errorf("token intended for another audience: %q", t.values("aud"))
+	}
+
+	exp, ok := t.time("exp")
+	if !ok {
+		return errors.New("token has no expiration time")
+	}
+
+	if !now.Before(exp) {
+		return fmt.Errorf("token expired at %v", exp)
+	}
+
+	if nbf, ok := t.time("nbf"); ok && now.Before(nbf) {
+		return fmt.Errorf("token not to be used before %v", nbf)
+	}
+
+	return nil
+}
+
+// time returns the value of a claim holding a time in seconds since the
+// epoch.
+func (t *token) time(claim string) (time.Time, bool) {
+	n, ok := t.claims[claim].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	if seconds, err := n.Int64(); err == nil {
+		return time.Unix(seconds, 0), true
+	}
+
+	// Fractions of seconds are allowed, though unusual.
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(seconds), 0), true
+}
+
+// values returns the string values of a claim. Strings, numbers and booleans
+// have a single value, arrays have the values of their elements, and other
+// claims have none.
+func (t *token) values(claim string) []string {
+	switch value := t.claims[claim].(type) {
+	case []interface{}:
+		var values []string
+		for _, element := range value {
+			if s, ok := scalar(element); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		if s, ok := scalar(value); ok {
+			return []string{s}
+		}
+	}
+
+	return nil
+}
+
+func scalar(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case string:
+		return value, true
+	case json.Number:
+		return value.String(), true
+	case bool:
+		return fmt.Sprint(value), true
+	}
+
+	return "", false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -65,7 +65,8 @@ func (ac *accessController) Authorized(ctx context.Context, accessRecords ...aut
 		return nil, &challenge
 	}
 
-	return auth.WithUser(ctx, auth.UserInfo{Name: "silly"}), nil
+	ctx = auth.WithUser(ctx, auth.UserInfo{Name: "silly"})
+	return auth.WithAccessChecker(ctx, func(auth.Access) bool { return true }), nil
 }
 
 type challenge struct {
@@ -259,6 +259,8 @@ func (ac *accessController) Authorized(ctx context.Context, accessItems ...auth.
 		}
 	}
 
+	// The token only holds the access granted for this request, not the
+	// policy of the token server, so no access checker is set.
 	return auth.WithUser(ctx, auth.UserInfo{Name: token.Claims.Subject}), nil
 }
 
@@ -0,0 +1,78 @@
+package token
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/libtrust"
+)
+
+// Issuer creates JSON Web Tokens granting access to resources, which the
+// access controller verifies if the certificate of the signing key is part
+// of its root certificate bundle.
+type Issuer struct {
+	// Name is the issuer of the tokens, to be trusted by the access
+	// controller.
+	Name string
+
+	// SigningKey signs the tokens, its key ID being set in their header.
+	SigningKey libtrust.PrivateKey
+
+	// Expiration is how long tokens are valid.
+	Expiration time.Duration
+}
+
+// CreateJWT returns the compact serialization of a token granting subject
+// access to resources of audience.
+func (issuer *Issuer) CreateJWT(subject, audience string, access []*ResourceActions) (string, error) {
+	randomBytes := make([]byte, 15)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", fmt.Errorf("unable to read random bytes for jwt id: %s", err)
+	}
+
+	now := time.Now()
+	claimSet := &ClaimSet{
+		Issuer:     issuer.Name,
+		Subject:    subject,
+		Audience:   audience,
+		Expiration: now.Add(issuer.Expiration).Unix(),
+		NotBefore:  now.Unix(),
+		IssuedAt:   now.Unix(),
+		JWTID:      base64.URLEncoding.EncodeToString(randomBytes),
+		Access:     access,
+	}
+
+	claimSetBytes, err := json.Marshal(claimSet)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal claim set: %s", err)
+	}
+
+	// The signing algorithm, part of the header, depends on the type of the
+	// key, which reports it when signing.
+	_, alg, err := issuer.SigningKey.Sign(strings.NewReader(""), crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("unable to determine signing algorithm: %s", err)
+	}
+
+	joseHeaderBytes, err := json.Marshal(&Header{
+		Type:       "JWT",
+		SigningAlg: alg,
+		KeyID:      issuer.SigningKey.KeyID(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal jose header: %s", err)
+	}
+
+	payload := joseBase64UrlEncode(joseHeaderBytes) + TokenSeparator + joseBase64UrlEncode(claimSetBytes)
+	signatureBytes, _, err := issuer.SigningKey.Sign(strings.NewReader(payload), crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("unable to sign jwt payload: %s", err)
+	}
+
+	return payload + TokenSeparator + joseBase64UrlEncode(signatureBytes), nil
+}
@@ -283,7 +283,7 @@ func TestAccessController(t *testing.T) {
 		Action: "baz",
 	}
 
-	ctx := context.WithValue(nil, "http.request", req)
+	ctx := context.WithValue(context.Background(), "http.request", req)
 	authCtx, err := accessController.Authorized(ctx, testAccess)
 	challenge, ok := err.(auth.Challenge)
 	if !ok {
@@ -383,4 +383,61 @@ func TestAccessController(t *testing.T) {
 	if userInfo.Name != "foo" {
 		t.Fatalf("expected user name %q, got %q", "foo", userInfo.Name)
 	}
+
+	// The access granted by the token is not a policy for other resources.
+	if _, ok := auth.GetAccessChecker(authCtx); ok {
+		t.Fatal("token accessController set an access checker")
+	}
+}
+
+// TestIssuer checks that the tokens created by an issuer are verified using
+// the key ID of the signing key.
+func TestIssuer(t *testing.T) {
+	rootKeys, err := makeRootKeys(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &Issuer{
+		Name:       "test-issuer",
+		SigningKey: rootKeys[0],
+		Expiration: 5 * time.Minute,
+	}
+
+	access := []*ResourceActions{
+		{
+			Type:    "repository",
+			Name:    "foo/bar",
+			Actions: []string{"pull"},
+		},
+	}
+
+	rawToken, err := issuer.CreateJWT("foo", "test-audience", access)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := NewToken(rawToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token.Header.KeyID != rootKeys[0].KeyID() || token.Claims.Subject != "foo" || len(token.Claims.Access) != 1 {
+		t.Fatalf("unexpected token: %+v %+v", token.Header, token.Claims)
+	}
+
+	if err := token.Verify(VerifyOptions{
+		TrustedIssuers:    []string{"test-issuer"},
+		AcceptedAudiences: []string{"test-audience"},
+		TrustedKeys:       makeTrustedKeyMap(rootKeys),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := token.Verify(VerifyOptions{
+		TrustedIssuers:    []string{"test-issuer"},
+		AcceptedAudiences: []string{"test-audience"},
+	}); err == nil {
+		t.Fatal("expected token signed by an untrusted key to be refused")
+	}
 }
@@ -0,0 +1,158 @@
+// Package x509 provides an access controller identifying users by the client
+// certificate they presented, as verified by the TLS server against the
+// certificate authorities of http.tls.clientcas. The user name is the common
+// name of the certificate, or one of its URI subject alternative names, and
+// its groups are the organizational units of the certificate subject.
+//
+// The registry must terminate TLS itself, as client certificates verified by
+// a proxy are unknown to the registry.
+package x509
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/acl"
+)
+
+var (
+	// ErrNoCertificate is returned when the request has no verified client
+	// certificate.
+	ErrNoCertificate = errors.New("no verified client certificate")
+
+	// ErrNoIdentity is returned when the client certificate has no common
+	// name or URI naming the user.
+	ErrNoIdentity = errors.New("client certificate names no user")
+)
+
+const (
+	usernameCommonName = "cn"
+	usernameURI        = "uri"
+)
+
+type accessController struct {
+	// username is where the user name is taken from, the common name or
+	// the first URI subject alternative name starting with uriPrefix, which
+	// is removed from the name.
+	username  string
+	uriPrefix string
+
+	// acl grants access to users and groups.
+	acl *acl.File
+}
+
+var _ auth.AccessController = &accessController{}
+
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	ac := &accessController{username: usernameCommonName}
+
+	var aclPath string
+	for name, value := range map[string]*string{
+		"username":  &ac.username,
+		"uriprefix": &ac.uriPrefix,
+		"acl":       &aclPath,
+	} {
+		if option, present := options[name]; present {
+			s, ok := option.(string)
+			if !ok {
+				return nil, fmt.Errorf("%q must be a string for x509 access controller", name)
+			}
+			*value = s
+		}
+	}
+
+	switch ac.username {
+	case usernameCommonName:
+		if ac.uriPrefix != "" {
+			return nil, fmt.Errorf(`"uriprefix" requires "username" to be %q for x509 access controller`, usernameURI)
+		}
+	case usernameURI:
+	default:
+		return nil, fmt.Errorf(`"username" must be %q or %q for x509 access controller`, usernameCommonName, usernameURI)
+	}
+
+	// Any certificate issued by the client certificate authorities is
+	// accepted, so access is only granted by the access control list.
+	if aclPath == "" {
+		return nil, fmt.Errorf(`"acl" must be set for x509 access controller`)
+	}
+
+	var err error
+	if ac.acl, err = acl.OpenFile(aclPath); err != nil {
+		return nil, err
+	}
+
+	return ac, nil
+}
+
+// Authorized identifies the user by the verified client certificate of the
+// request and checks the access control list grants the requested access.
+func (ac *accessController) Authorized(ctx context.Context, accessRecords ...auth.Access) (context.Context, error) {
+	req, err := context.GetRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil, &challenge{err: ErrNoCertificate}
+	}
+	cert := req.TLS.VerifiedChains[0][0]
+
+	username := ac.name(cert)
+	if username == "" {
+		context.GetLogger(ctx).Errorf("client certificate %q names no user", cert.Subject.CommonName)
+		return nil, &challenge{err: ErrNoIdentity}
+	}
+
+	checker := ac.acl.ACL().AccessChecker(acl.Subject{Name: username, Groups: cert.Subject.OrganizationalUnit})
+
+	for _, access := range accessRecords {
+		if !checker(access) {
+			context.GetLogger(ctx).Warnf("user %q denied access to %v", username, accessRecords)
+			return nil, auth.ErrAccessDenied
+		}
+	}
+
+	return auth.WithAccessChecker(auth.WithUser(ctx, auth.UserInfo{Name: username}), checker), nil
+}
+
+// name returns the name of the user cert was issued to, or an empty string.
+func (ac *accessController) name(cert *x509.Certificate) string {
+	if ac.username == usernameCommonName {
+		return cert.Subject.CommonName
+	}
+
+	for _, uri := range cert.URIs {
+		if s := uri.String(); strings.HasPrefix(s, ac.uriPrefix) {
+			return strings.TrimPrefix(s, ac.uriPrefix)
+		}
+	}
+
+	return ""
+}
+
+// challenge implements the auth.Challenge interface.
+type challenge struct {
+	err error
+}
+
+var _ auth.Challenge = challenge{}
+
+// SetHeaders sets no header: HTTP has no challenge scheme asking for client
+// certificates, which are requested by the TLS handshake.
+func (ch challenge) SetHeaders(w http.ResponseWriter) {
+}
+
+func (ch challenge) Error() string {
+	return fmt.Sprintf("x509 authentication challenge: %s", ch.err)
+}
+
+// init registers the x509 auth backend.
+func init() {
+	auth.Register("x509", auth.InitFunc(newAccessController))
+}
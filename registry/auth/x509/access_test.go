@@ -0,0 +1,158 @@
+package x509
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+)
+
+// authorize checks access for a request with the verified client certificate
+// cert, or without any certificate if nil.
+func authorize(accessController auth.AccessController, cert *x509.Certificate, access ...auth.Access) (context.Context, error) {
+	req, _ := http.NewRequest("GET", "https://registry.example.com/v2/", nil)
+	if cert != nil {
+		req.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert, {Subject: pkix.Name{CommonName: "Example CA"}}}},
+		}
+	}
+
+	return accessController.Authorized(context.WithRequest(context.Background(), req), access...)
+}
+
+func repositoryAccess(name, action string) auth.Access {
+	return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: action}
+}
+
+func certificate(commonName string, organizationalUnits []string, uris ...string) *x509.Certificate {
+	cert := &x509.Certificate{
+		Subject: pkix.Name{CommonName: commonName, OrganizationalUnit: organizationalUnits},
+	}
+	for _, uri := range uris {
+		u, _ := url.Parse(uri)
+		cert.URIs = append(cert.URIs, u)
+	}
+
+	return cert
+}
+
+// writeACL writes an access control list file, to be removed by the caller.
+func writeACL(t *testing.T, content string) string {
+	aclFile, err := ioutil.TempFile("", "x509-acl")
+	if err != nil {
+		t.Fatalf("unexpected error creating acl file: %v", err)
+	}
+	defer aclFile.Close()
+
+	if _, err := aclFile.WriteString(content); err != nil {
+		t.Fatalf("unexpected error writing acl file: %v", err)
+	}
+
+	return aclFile.Name()
+}
+
+func TestAccessController(t *testing.T) {
+	aclPath := writeACL(t, `
+rules:
+  - groups: [builders]
+    repositories: ["team/*"]
+    actions: [pull, push]
+  - users: ["*"]
+    repositories: ["${user}/*"]
+    actions: [pull, push]
+`)
+	defer os.Remove(aclPath)
+
+	accessController, err := newAccessController(map[string]interface{}{"acl": aclPath})
+	if err != nil {
+		t.Fatalf("unexpected error creating access controller: %v", err)
+	}
+
+	if _, err := authorize(accessController, nil); err == nil {
+		t.Fatal("expected request without client certificate to be challenged")
+	} else if _, ok := err.(auth.Challenge); !ok {
+		t.Fatalf("expected challenge, got %v", err)
+	}
+
+	if _, err := authorize(accessController, certificate("", nil)); err == nil {
+		t.Fatal("expected client certificate without common name to be challenged")
+	} else if _, ok := err.(auth.Challenge); !ok {
+		t.Fatalf("expected challenge, got %v", err)
+	}
+
+	push := []auth.Access{repositoryAccess("team/app", "pull"), repositoryAccess("team/app", "push")}
+	authCtx, err := authorize(accessController, certificate("ci", []string{"builders"}), push...)
+	if err != nil {
+		t.Fatalf("unexpected error authorizing ci: %v", err)
+	}
+
+	if userInfo, ok := authCtx.Value("auth.user").(auth.UserInfo); !ok || userInfo.Name != "ci" {
+		t.Fatalf("unexpected user info: %v", authCtx.Value("auth.user"))
+	}
+
+	if _, err := authorize(accessController, certificate("alice", nil), push...); err != auth.ErrAccessDenied {
+		t.Fatalf("expected alice, not a builder, to be denied pushing to team/app: %v", err)
+	}
+
+	if _, err := authorize(accessController, certificate("alice", nil), repositoryAccess("alice/app", "push")); err != nil {
+		t.Fatalf("unexpected error authorizing alice to push to her repository: %v", err)
+	}
+}
+
+func TestAccessControllerURI(t *testing.T) {
+	aclPath := writeACL(t, `
+rules:
+  - users: [ci/builder]
+    repositories: ["team/*"]
+    actions: [pull, push]
+`)
+	defer os.Remove(aclPath)
+
+	accessController, err := newAccessController(map[string]interface{}{
+		"username":  "uri",
+		"uriprefix": "spiffe://example.com/",
+		"acl":       aclPath,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating access controller: %v", err)
+	}
+
+	cert := certificate("builder.example.com", nil, "https://builder.example.com", "spiffe://example.com/ci/builder")
+	authCtx, err := authorize(accessController, cert, repositoryAccess("team/app", "push"))
+	if err != nil {
+		t.Fatalf("unexpected error authorizing certificate with uri: %v", err)
+	}
+
+	if userInfo, ok := authCtx.Value("auth.user").(auth.UserInfo); !ok || userInfo.Name != "ci/builder" {
+		t.Fatalf("unexpected user info: %v", authCtx.Value("auth.user"))
+	}
+
+	if _, err := authorize(accessController, certificate("builder.example.com", nil, "spiffe://other.example.com/ci/builder")); err == nil {
+		t.Fatal("expected certificate without matching uri to be challenged")
+	}
+}
+
+func TestNewAccessControllerInvalid(t *testing.T) {
+	aclPath := writeACL(t, "rules: []\n")
+	defer os.Remove(aclPath)
+
+	for _, options := range []map[string]interface{}{
+		{"username": "email", "acl": aclPath},
+		{"username": "cn", "uriprefix": "spiffe://example.com/", "acl": aclPath},
+		{"acl": "/nonexistent/acl.yml"},
+		{"uriprefix": 5, "acl": aclPath},
+		{"username": "cn"},
+	} {
+		if _, err := newAccessController(options); err == nil {
+			t.Fatalf("expected error creating access controller with options %v", options)
+		}
+	}
+}
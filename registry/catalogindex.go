@@ -0,0 +1,47 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage"
+	"github.com/spf13/cobra"
+)
+
+// RebuildCatalogIndexCmd is the cobra command that corresponds to the
+// rebuild-catalog-index subcommand. It can be run while the registry is
+// serving requests.
+var RebuildCatalogIndexCmd = &cobra.Command{
+	Use:   "rebuild-catalog-index <config>",
+	Short: "`rebuild-catalog-index` indexes all repositories and tags for the catalog",
+	Long:  "`rebuild-catalog-index` indexes all repositories and their tags for the catalog. Run it once before enabling the catalog index on existing storage.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		ctx, err := configureLogging(context.Background(), config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error configuring logger: %v\n", err)
+			os.Exit(1)
+		}
+
+		driver, err := newStorageDriver(config)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		indexed, err := storage.RebuildCatalogIndex(ctx, driver)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to rebuild catalog index: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("indexed %d repositories\n", indexed)
+	},
+}
@@ -9,12 +9,16 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
+	_ "github.com/docker/distribution/manifest/manifestlist" // registers the manifest list format
+	_ "github.com/docker/distribution/manifest/ocischema"    // registers the OCI image manifest and index formats
 	"github.com/docker/distribution/manifest/schema1"
+	_ "github.com/docker/distribution/manifest/schema2" // registers the schema2 format
 	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/client/transport"
@@ -25,6 +29,20 @@ import (
 // Registry provides an interface for calling Repositories, which returns a catalog of repositories.
 type Registry interface {
 	Repositories(ctx context.Context, repos []string, last string) (n int, err error)
+
+	// FilteredRepositories behaves as Repositories, only listing the
+	// repositories matching filter which the client is allowed to pull.
+	FilteredRepositories(ctx context.Context, repos []string, last string, filter RepositoryFilter) (n int, err error)
+}
+
+// RepositoryFilter narrows down the repositories listed from the catalog. An
+// empty field matches every repository.
+type RepositoryFilter struct {
+	// Prefix only matches repositories starting with the value.
+	Prefix string
+
+	// Query only matches repositories containing the value.
+	Query string
 }
 
 // NewRegistry creates a registry namespace which can be used to get a listing of repositories
@@ -56,10 +74,23 @@ type registry struct {
 // of the slice, starting at the value provided in 'last'.  The number of entries will be returned along with io.EOF if there
 // are no more entries
 func (r *registry) Repositories(ctx context.Context, entries []string, last string) (int, error) {
+	return r.FilteredRepositories(ctx, entries, last, RepositoryFilter{})
+}
+
+// FilteredRepositories returns the portion of the catalog matching filter, as
+// Repositories does for the whole catalog.
+func (r *registry) FilteredRepositories(ctx context.Context, entries []string, last string, filter RepositoryFilter) (int, error) {
 	var numFilled int
 	var returnErr error
 
 	values := buildCatalogValues(len(entries), last)
+	if filter.Prefix != "" {
+		values.Set("prefix", filter.Prefix)
+	}
+	if filter.Query != "" {
+		values.Set("q", filter.Query)
+	}
+
 	u, err := r.ub.BuildCatalogURL(values)
 	if err != nil {
 		return 0, err
@@ -152,10 +183,22 @@ func (r *repository) Manifests(ctx context.Context, options ...distribution.Mani
 		name:   r.Name(),
 		ub:     r.ub,
 		client: r.client,
-		etags:  make(map[string]string),
 	}, nil
 }
 
+func (r *repository) Tags(ctx context.Context) distribution.TagService {
+	return &tags{
+		name:   r.Name(),
+		ub:     r.ub,
+		client: r.client,
+		manifests: &manifests{
+			name:   r.Name(),
+			ub:     r.ub,
+			client: r.client,
+		},
+	}
+}
+
 func (r *repository) Signatures() distribution.SignatureService {
 	ms, _ := r.Manifests(r.context)
 	return &signatures{
@@ -172,7 +215,13 @@ func (s *signatures) Get(dgst digest.Digest) ([][]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return m.Signatures()
+
+	// Only schema1 manifests carry signatures.
+	sm, ok := m.(*schema1.SignedManifest)
+	if !ok {
+		return nil, nil
+	}
+	return sm.Signatures()
 }
 
 func (s *signatures) Put(dgst digest.Digest, signatures ...[]byte) error {
@@ -183,169 +232,353 @@ type manifests struct {
 	name   string
 	ub     *v2.URLBuilder
 	client *http.Client
-	etags  map[string]string
 }
 
-func (ms *manifests) Tags() ([]string, error) {
-	u, err := ms.ub.BuildTagsURL(ms.name)
+func (ms *manifests) Exists(dgst digest.Digest) (bool, error) {
+	u, err := ms.ub.BuildManifestURL(ms.name, dgst.String())
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := ms.client.Head(u)
+	if err != nil {
+		return false, err
+	}
+
+	if SuccessStatus(resp.StatusCode) {
+		return true, nil
+	} else if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return false, handleErrorResponse(resp)
+}
+
+func (ms *manifests) Get(dgst digest.Digest) (distribution.Manifest, error) {
+	u, err := ms.ub.BuildManifestURL(ms.name, dgst.String())
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", u, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := ms.client.Get(u)
+	for _, t := range distribution.ManifestMediaTypes() {
+		req.Header.Add("Accept", t)
+	}
+
+	resp, err := ms.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if SuccessStatus(resp.StatusCode) {
-		b, err := ioutil.ReadAll(resp.Body)
+		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
 			return nil, err
 		}
 
-		tagsResponse := struct {
-			Tags []string `json:"tags"`
-		}{}
-		if err := json.Unmarshal(b, &tagsResponse); err != nil {
+		m, _, err := distribution.UnmarshalManifest(resp.Header.Get("Content-Type"), body)
+		if err != nil {
 			return nil, err
 		}
-
-		return tagsResponse.Tags, nil
+		return m, nil
 	}
 	return nil, handleErrorResponse(resp)
 }
 
-func (ms *manifests) Exists(dgst digest.Digest) (bool, error) {
-	// Call by Tag endpoint since the API uses the same
-	// URL endpoint for tags and digests.
-	return ms.ExistsByTag(dgst.String())
+// Put uploads the manifest by digest, returning the digest reported by the
+// registry.
+func (ms *manifests) Put(m distribution.Manifest) (digest.Digest, error) {
+	desc, err := distribution.DescribeManifest(m)
+	if err != nil {
+		return "", err
+	}
+
+	return ms.put(desc.Digest.String(), m)
 }
 
-func (ms *manifests) ExistsByTag(tag string) (bool, error) {
-	u, err := ms.ub.BuildManifestURL(ms.name, tag)
+func (ms *manifests) put(reference string, m distribution.Manifest) (digest.Digest, error) {
+	manifestURL, err := ms.ub.BuildManifestURL(ms.name, reference)
 	if err != nil {
-		return false, err
+		return "", err
 	}
 
-	resp, err := ms.client.Head(u)
+	mediaType, p, err := m.Payload()
 	if err != nil {
-		return false, err
+		return "", err
 	}
 
+	// todo(richardscothern): do something with options here when they become applicable
+
+	putRequest, err := http.NewRequest("PUT", manifestURL, bytes.NewReader(p))
+	if err != nil {
+		return "", err
+	}
+
+	putRequest.Header.Set("Content-Type", mediaType)
+
+	resp, err := ms.client.Do(putRequest)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
 	if SuccessStatus(resp.StatusCode) {
-		return true, nil
-	} else if resp.StatusCode == http.StatusNotFound {
-		return false, nil
+		return digest.ParseDigest(resp.Header.Get("Docker-Content-Digest"))
 	}
-	return false, handleErrorResponse(resp)
+	return "", handleErrorResponse(resp)
 }
 
-func (ms *manifests) Get(dgst digest.Digest) (*schema1.SignedManifest, error) {
-	// Call by Tag endpoint since the API uses the same
-	// URL endpoint for tags and digests.
-	return ms.GetByTag(dgst.String())
-}
+func (ms *manifests) Delete(dgst digest.Digest) error {
+	u, err := ms.ub.BuildManifestURL(ms.name, dgst.String())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
 
-// AddEtagToTag allows a client to supply an eTag to GetByTag which will be
-// used for a conditional HTTP request.  If the eTag matches, a nil manifest
-// and nil error will be returned. etag is automatically quoted when added to
-// this map.
-func AddEtagToTag(tag, etag string) distribution.ManifestServiceOption {
-	return func(ms distribution.ManifestService) error {
-		if ms, ok := ms.(*manifests); ok {
-			ms.etags[tag] = fmt.Sprintf(`"%s"`, etag)
-			return nil
-		}
-		return fmt.Errorf("etag options is a client-only option")
+	resp, err := ms.client.Do(req)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
+
+	if SuccessStatus(resp.StatusCode) {
+		return nil
+	}
+	return handleErrorResponse(resp)
 }
 
-func (ms *manifests) GetByTag(tag string, options ...distribution.ManifestServiceOption) (*schema1.SignedManifest, error) {
-	for _, option := range options {
-		err := option(ms)
+// tags implements remote tagging operations.
+type tags struct {
+	name      string
+	ub        *v2.URLBuilder
+	client    *http.Client
+	manifests *manifests
+}
+
+// All returns all tags of the repository, following the pagination links
+// provided by the registry until the full set has been received.
+func (t *tags) All(ctx context.Context) ([]string, error) {
+	u, err := t.ub.BuildTagsURL(t.name)
+	if err != nil {
+		return nil, err
+	}
+
+	var allTags []string
+	for u != "" {
+		var tags []string
+		tags, u, err = t.fetch(u)
 		if err != nil {
 			return nil, err
 		}
+		allTags = append(allTags, tags...)
 	}
 
-	u, err := ms.ub.BuildManifestURL(ms.name, tag)
+	return allTags, nil
+}
+
+// List fills tags with the tags of the repository following last, up to the
+// size of tags. io.EOF is returned if there are no more tags.
+func (t *tags) List(ctx context.Context, tags []string, last string) (int, error) {
+	u, err := t.ub.BuildTagsURL(t.name, buildCatalogValues(len(tags), last))
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	req, err := http.NewRequest("GET", u, nil)
+
+	page, next, err := t.fetch(u)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	if _, ok := ms.etags[tag]; ok {
-		req.Header.Set("If-None-Match", ms.etags[tag])
+	n := copy(tags, page)
+	if next == "" {
+		return n, io.EOF
 	}
-	resp, err := ms.client.Do(req)
+	return n, nil
+}
+
+// fetch retrieves a single page of tags from u, returning the url of the next
+// page, if any, taken from the Link header of the response.
+func (t *tags) fetch(u string) ([]string, string, error) {
+	resp, err := t.client.Get(u)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusNotModified {
-		return nil, distribution.ErrManifestNotModified
-	} else if SuccessStatus(resp.StatusCode) {
-		var sm schema1.SignedManifest
-		decoder := json.NewDecoder(resp.Body)
 
-		if err := decoder.Decode(&sm); err != nil {
-			return nil, err
+	if !SuccessStatus(resp.StatusCode) {
+		return nil, "", handleErrorResponse(resp)
+	}
+
+	tagsResponse := struct {
+		Tags []string `json:"tags"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&tagsResponse); err != nil {
+		return nil, "", err
+	}
+
+	link := resp.Header.Get("Link")
+	if link == "" {
+		return tagsResponse.Tags, "", nil
+	}
+
+	next, err := parseNextLink(link, u)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return tagsResponse.Tags, next, nil
+}
+
+// parseNextLink extracts the url of the "next" relation from an RFC5988 Link
+// header, resolving it against base. An empty url is returned if the header
+// has no such relation.
+func parseNextLink(link, base string) (string, error) {
+	for _, value := range strings.Split(link, ",") {
+		parts := strings.Split(value, ";")
+		target := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			return "", fmt.Errorf("invalid link header: %q", link)
+		}
+
+		for _, param := range parts[1:] {
+			if strings.Replace(strings.TrimSpace(param), " ", "", -1) == `rel="next"` {
+				return sanitizeLocation(strings.Trim(target, "<>"), base)
+			}
 		}
-		return &sm, nil
 	}
-	return nil, handleErrorResponse(resp)
+
+	// Only the next relation is followed, others mark the last page.
+	return "", nil
 }
 
-func (ms *manifests) Put(m *schema1.SignedManifest) error {
-	manifestURL, err := ms.ub.BuildManifestURL(ms.name, m.Tag)
+// Get issues a HEAD request for the manifest with the given tag and
+// describes the manifest it resolves to from the response headers.
+func (t *tags) Get(ctx context.Context, tag string) (distribution.Descriptor, error) {
+	u, err := t.ub.BuildManifestURL(t.name, tag)
 	if err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 
-	// todo(richardscothern): do something with options here when they become applicable
-
-	putRequest, err := http.NewRequest("PUT", manifestURL, bytes.NewReader(m.Raw))
+	req, err := http.NewRequest("HEAD", u, nil)
 	if err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 
-	resp, err := ms.client.Do(putRequest)
+	for _, mt := range distribution.ManifestMediaTypes() {
+		req.Header.Add("Accept", mt)
+	}
+
+	resp, err := t.client.Do(req)
 	if err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 	defer resp.Body.Close()
 
-	if SuccessStatus(resp.StatusCode) {
-		// TODO(dmcgowan): make use of digest header
-		return nil
+	switch {
+	case SuccessStatus(resp.StatusCode):
+		return descriptorFromResponse(resp)
+	case resp.StatusCode == http.StatusNotFound:
+		return distribution.Descriptor{}, distribution.ErrTagUnknown{Tag: tag}
+	default:
+		return distribution.Descriptor{}, handleErrorResponse(resp)
 	}
-	return handleErrorResponse(resp)
 }
 
-func (ms *manifests) Delete(dgst digest.Digest) error {
-	u, err := ms.ub.BuildManifestURL(ms.name, dgst.String())
+// Tag points tag at the manifest described by desc, which is fetched from
+// the registry and uploaded again under the tag.
+func (t *tags) Tag(ctx context.Context, tag string, desc distribution.Descriptor) error {
+	m, err := t.manifests.Get(desc.Digest)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.manifests.put(tag, m)
+	return err
+}
+
+// Untag removes the tag from the repository. The manifest it points to is
+// kept.
+func (t *tags) Untag(ctx context.Context, tag string) error {
+	u, err := t.ub.BuildManifestURL(t.name, tag)
 	if err != nil {
 		return err
 	}
+
 	req, err := http.NewRequest("DELETE", u, nil)
 	if err != nil {
 		return err
 	}
 
-	resp, err := ms.client.Do(req)
+	resp, err := t.client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if SuccessStatus(resp.StatusCode) {
+	switch {
+	case SuccessStatus(resp.StatusCode):
 		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		return distribution.ErrTagUnknown{Tag: tag}
+	default:
+		return handleErrorResponse(resp)
 	}
-	return handleErrorResponse(resp)
+}
+
+// Lookup returns the tags that currently point at the digest of desc. The
+// registry has no endpoint for this, so every tag is resolved in turn.
+func (t *tags) Lookup(ctx context.Context, desc distribution.Descriptor) ([]string, error) {
+	all, err := t.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	for _, tag := range all {
+		tagDesc, err := t.Get(ctx, tag)
+		if err != nil {
+			if _, ok := err.(distribution.ErrTagUnknown); ok {
+				continue
+			}
+			return nil, err
+		}
+
+		if tagDesc.Digest == desc.Digest {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, nil
+}
+
+// descriptorFromResponse describes the manifest of a successful manifest
+// response using its headers.
+func descriptorFromResponse(resp *http.Response) (distribution.Descriptor, error) {
+	dgst, err := digest.ParseDigest(resp.Header.Get("Docker-Content-Digest"))
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	desc := distribution.Descriptor{
+		MediaType: resp.Header.Get("Content-Type"),
+		Digest:    dgst,
+	}
+
+	if contentLength := resp.Header.Get("Content-Length"); contentLength != "" {
+		length, err := strconv.ParseInt(contentLength, 10, 64)
+		if err != nil {
+			return distribution.Descriptor{}, err
+		}
+		desc.Size = length
+	}
+
+	return desc, nil
 }
 
 type blobs struct {
@@ -431,8 +664,39 @@ func (bs *blobs) Put(ctx context.Context, mediaType string, p []byte) (distribut
 	return writer.Commit(ctx, desc)
 }
 
-func (bs *blobs) Create(ctx context.Context) (distribution.BlobWriter, error) {
-	u, err := bs.ub.BuildBlobUploadURL(bs.name)
+// WithMountFrom returns a BlobCreateOption which makes Create attempt to
+// mount the blob from the repository named by ref, which must also carry the
+// digest of the blob, before falling back to an upload.
+func WithMountFrom(ref reference.Canonical) distribution.BlobCreateOption {
+	return func(opts *distribution.CreateOptions) error {
+		opts.Mount.ShouldMount = true
+		opts.Mount.From = ref
+		return nil
+	}
+}
+
+// Create starts a blob upload. If the options ask for a mount and the
+// registry mounts the blob, ErrBlobMounted is returned instead of a writer.
+func (bs *blobs) Create(ctx context.Context, options ...distribution.BlobCreateOption) (distribution.BlobWriter, error) {
+	var opts distribution.CreateOptions
+	for _, option := range options {
+		if err := option(&opts); err != nil {
+			return nil, err
+		}
+	}
+
+	var values []url.Values
+	if opts.Mount.ShouldMount {
+		values = append(values, url.Values{
+			"mount": {opts.Mount.From.Digest().String()},
+			"from":  {opts.Mount.From.Name()},
+		})
+	}
+
+	u, err := bs.ub.BuildBlobUploadURL(bs.name, values...)
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := bs.client.Post(u, "", nil)
 	if err != nil {
@@ -440,7 +704,14 @@ func (bs *blobs) Create(ctx context.Context) (distribution.BlobWriter, error) {
 	}
 	defer resp.Body.Close()
 
-	if SuccessStatus(resp.StatusCode) {
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		desc, err := bs.statter.Stat(ctx, opts.Mount.From.Digest())
+		if err != nil {
+			return nil, err
+		}
+		return nil, distribution.ErrBlobMounted{From: opts.Mount.From, Descriptor: desc}
+	case http.StatusAccepted:
 		// TODO(dmcgowan): Check for invalid UUID
 		uuid := resp.Header.Get("Docker-Upload-UUID")
 		location, err := sanitizeLocation(resp.Header.Get("Location"), u)
@@ -455,8 +726,9 @@ func (bs *blobs) Create(ctx context.Context) (distribution.BlobWriter, error) {
 			startedAt: time.Now(),
 			location:  location,
 		}, nil
+	default:
+		return nil, handleErrorResponse(resp)
 	}
-	return nil, handleErrorResponse(resp)
 }
 
 func (bs *blobs) Resume(ctx context.Context, id string) (distribution.BlobWriter, error) {
@@ -3,11 +3,13 @@ package client
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
@@ -18,6 +20,7 @@ import (
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest"
 	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
 	"github.com/docker/distribution/testutil"
 	"github.com/docker/distribution/uuid"
@@ -419,6 +422,81 @@ func TestBlobUploadMonolithic(t *testing.T) {
 	}
 }
 
+func TestBlobMount(t *testing.T) {
+	dgst, content := newRandomBlob(1024)
+	var m testutil.RequestResponseMap
+	repo := "test.example.com/uploadrepo"
+	sourceRepo := "test.example.com/sourcerepo"
+
+	namedRef, err := reference.ParseNamed(sourceRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonicalRef, err := reference.WithDigest(namedRef, dgst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m = append(m, testutil.RequestResponseMapping{
+		Request: testutil.Request{
+			Method:      "POST",
+			Route:       "/v2/" + repo + "/blobs/uploads/",
+			QueryParams: map[string][]string{"from": {sourceRepo}, "mount": {dgst.String()}},
+		},
+		Response: testutil.Response{
+			StatusCode: http.StatusCreated,
+			Headers: http.Header(map[string][]string{
+				"Content-Length":        {"0"},
+				"Location":              {"/v2/" + repo + "/blobs/" + dgst.String()},
+				"Docker-Content-Digest": {dgst.String()},
+			}),
+		},
+	})
+	m = append(m, testutil.RequestResponseMapping{
+		Request: testutil.Request{
+			Method: "HEAD",
+			Route:  "/v2/" + repo + "/blobs/" + dgst.String(),
+		},
+		Response: testutil.Response{
+			StatusCode: http.StatusOK,
+			Headers: http.Header(map[string][]string{
+				"Content-Length": {fmt.Sprint(len(content))},
+				"Last-Modified":  {time.Now().Add(-1 * time.Second).Format(time.ANSIC)},
+			}),
+		},
+	})
+
+	e, c := testServer(m)
+	defer c()
+
+	ctx := context.Background()
+	r, err := NewRepository(ctx, repo, e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := r.Blobs(ctx)
+
+	bw, err := l.Create(ctx, WithMountFrom(canonicalRef))
+	if bw != nil {
+		t.Fatalf("Expected blob writer to be nil, was %v", bw)
+	}
+
+	if ebm, ok := err.(distribution.ErrBlobMounted); ok {
+		if ebm.From.Digest() != dgst {
+			t.Fatalf("Unexpected digest: %s, expected %s", ebm.From.Digest(), dgst)
+		}
+		if ebm.From.Name() != sourceRepo {
+			t.Fatalf("Unexpected from: %s, expected %s", ebm.From.Name(), sourceRepo)
+		}
+		if ebm.Descriptor.Size != int64(len(content)) {
+			t.Fatalf("Unexpected size: %d, expected %d", ebm.Descriptor.Size, len(content))
+		}
+	} else {
+		t.Fatalf("Unexpected error: %v, expected an ErrBlobMounted", err)
+	}
+}
+
 func newRandomSchemaV1Manifest(name, tag string, blobCount int) (*schema1.SignedManifest, digest.Digest, []byte) {
 	blobs := make([]schema1.FSLayer, blobCount)
 	history := make([]schema1.History, blobCount)
@@ -451,51 +529,12 @@ func newRandomSchemaV1Manifest(name, tag string, blobCount int) (*schema1.Signed
 		panic(err)
 	}
 
-	p, err := sm.Payload()
+	dgst, err := digest.FromBytes(sm.Canonical)
 	if err != nil {
 		panic(err)
 	}
 
-	dgst, err := digest.FromBytes(p)
-	if err != nil {
-		panic(err)
-	}
-
-	return sm, dgst, p
-}
-
-func addTestManifestWithEtag(repo, reference string, content []byte, m *testutil.RequestResponseMap, dgst string) {
-	actualDigest, _ := digest.FromBytes(content)
-	getReqWithEtag := testutil.Request{
-		Method: "GET",
-		Route:  "/v2/" + repo + "/manifests/" + reference,
-		Headers: http.Header(map[string][]string{
-			"If-None-Match": {fmt.Sprintf(`"%s"`, dgst)},
-		}),
-	}
-
-	var getRespWithEtag testutil.Response
-	if actualDigest.String() == dgst {
-		getRespWithEtag = testutil.Response{
-			StatusCode: http.StatusNotModified,
-			Body:       []byte{},
-			Headers: http.Header(map[string][]string{
-				"Content-Length": {"0"},
-				"Last-Modified":  {time.Now().Add(-1 * time.Second).Format(time.ANSIC)},
-			}),
-		}
-	} else {
-		getRespWithEtag = testutil.Response{
-			StatusCode: http.StatusOK,
-			Body:       content,
-			Headers: http.Header(map[string][]string{
-				"Content-Length": {fmt.Sprint(len(content))},
-				"Last-Modified":  {time.Now().Add(-1 * time.Second).Format(time.ANSIC)},
-			}),
-		}
-
-	}
-	*m = append(*m, testutil.RequestResponseMapping{Request: getReqWithEtag, Response: getRespWithEtag})
+	return sm, dgst, sm.Canonical
 }
 
 func addTestManifest(repo, reference string, content []byte, m *testutil.RequestResponseMap) {
@@ -586,16 +625,33 @@ func TestManifestFetch(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if err := checkEqualManifest(manifest, m1); err != nil {
+	v1manifest, ok := manifest.(*schema1.SignedManifest)
+	if !ok {
+		t.Fatalf("Unexpected manifest type from Get: %T", manifest)
+	}
+	if err := checkEqualManifest(v1manifest, m1); err != nil {
 		t.Fatal(err)
 	}
 }
 
-func TestManifestFetchWithEtag(t *testing.T) {
+func TestTagGet(t *testing.T) {
 	repo := "test.example.com/repo/by/tag"
-	_, d1, p1 := newRandomSchemaV1Manifest(repo, "latest", 6)
+	_, dgst, p := newRandomSchemaV1Manifest(repo, "latest", 6)
 	var m testutil.RequestResponseMap
-	addTestManifestWithEtag(repo, "latest", p1, &m, d1.String())
+	m = append(m, testutil.RequestResponseMapping{
+		Request: testutil.Request{
+			Method: "HEAD",
+			Route:  "/v2/" + repo + "/manifests/latest",
+		},
+		Response: testutil.Response{
+			StatusCode: http.StatusOK,
+			Headers: http.Header(map[string][]string{
+				"Content-Length":        {fmt.Sprint(len(p))},
+				"Content-Type":          {schema1.SignedManifestMediaType},
+				"Docker-Content-Digest": {dgst.String()},
+			}),
+		},
+	})
 
 	e, c := testServer(m)
 	defer c()
@@ -605,14 +661,26 @@ func TestManifestFetchWithEtag(t *testing.T) {
 		t.Fatal(err)
 	}
 	ctx := context.Background()
-	ms, err := r.Manifests(ctx)
+	tags := r.Tags(ctx)
+
+	desc, err := tags.Get(ctx, "latest")
 	if err != nil {
 		t.Fatal(err)
 	}
+	if desc.Digest != dgst {
+		t.Fatalf("Unexpected digest for tag: %s != %s", desc.Digest, dgst)
+	}
+	if desc.Size != int64(len(p)) {
+		t.Fatalf("Unexpected size for tag: %d != %d", desc.Size, len(p))
+	}
+	if desc.MediaType != schema1.SignedManifestMediaType {
+		t.Fatalf("Unexpected media type for tag: %s", desc.MediaType)
+	}
 
-	_, err = ms.GetByTag("latest", AddEtagToTag("latest", d1.String()))
-	if err != distribution.ErrManifestNotModified {
-		t.Fatal(err)
+	if _, err := tags.Get(ctx, "missing"); true {
+		if _, ok := err.(distribution.ErrTagUnknown); !ok {
+			t.Fatalf("Expected tag unknown error, got %#v", err)
+		}
 	}
 }
 
@@ -656,6 +724,42 @@ func TestManifestDelete(t *testing.T) {
 	// TODO(dmcgowan): Check for specific unknown error
 }
 
+func TestTagUntag(t *testing.T) {
+	repo := "test.example.com/repo/untag"
+	var m testutil.RequestResponseMap
+	m = append(m, testutil.RequestResponseMapping{
+		Request: testutil.Request{
+			Method: "DELETE",
+			Route:  "/v2/" + repo + "/manifests/pr-1234",
+		},
+		Response: testutil.Response{
+			StatusCode: http.StatusAccepted,
+			Headers: http.Header(map[string][]string{
+				"Content-Length": {"0"},
+			}),
+		},
+	})
+
+	e, c := testServer(m)
+	defer c()
+
+	r, err := NewRepository(context.Background(), repo, e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	tags := r.Tags(ctx)
+
+	if err := tags.Untag(ctx, "pr-1234"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tags.Untag(ctx, "pr-1234"); true {
+		if _, ok := err.(distribution.ErrTagUnknown); !ok {
+			t.Fatalf("Expected tag unknown error, got %#v", err)
+		}
+	}
+}
+
 func TestManifestPut(t *testing.T) {
 	repo := "test.example.com/repo/delete"
 	m1, dgst, _ := newRandomSchemaV1Manifest(repo, "other", 6)
@@ -663,7 +767,7 @@ func TestManifestPut(t *testing.T) {
 	m = append(m, testutil.RequestResponseMapping{
 		Request: testutil.Request{
 			Method: "PUT",
-			Route:  "/v2/" + repo + "/manifests/other",
+			Route:  "/v2/" + repo + "/manifests/" + dgst.String(),
 			Body:   m1.Raw,
 		},
 		Response: testutil.Response{
@@ -688,13 +792,53 @@ func TestManifestPut(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := ms.Put(m1); err != nil {
+	putDigest, err := ms.Put(m1)
+	if err != nil {
 		t.Fatal(err)
 	}
 
+	if putDigest != dgst {
+		t.Fatalf("Unexpected digest from Put: %s != %s", putDigest, dgst)
+	}
+
 	// TODO(dmcgowan): Check for invalid input error
 }
 
+func TestManifestTag(t *testing.T) {
+	repo := "test.example.com/repo/tag"
+	m1, dgst, _ := newRandomSchemaV1Manifest(repo, "other", 6)
+	var m testutil.RequestResponseMap
+	addTestManifest(repo, dgst.String(), m1.Raw, &m)
+	m = append(m, testutil.RequestResponseMapping{
+		Request: testutil.Request{
+			Method: "PUT",
+			Route:  "/v2/" + repo + "/manifests/other",
+			Body:   m1.Raw,
+		},
+		Response: testutil.Response{
+			StatusCode: http.StatusCreated,
+			Headers: http.Header(map[string][]string{
+				"Content-Length":        {"0"},
+				"Docker-Content-Digest": {dgst.String()},
+			}),
+		},
+	})
+
+	e, c := testServer(m)
+	defer c()
+
+	r, err := NewRepository(context.Background(), repo, e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	tags := r.Tags(ctx)
+
+	if err := tags.Tag(ctx, "other", distribution.Descriptor{Digest: dgst}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestManifestTags(t *testing.T) {
 	repo := "test.example.com/repo/tags/list"
 	tagsList := []byte(strings.TrimSpace(`
@@ -731,12 +875,7 @@ func TestManifestTags(t *testing.T) {
 		t.Fatal(err)
 	}
 	ctx := context.Background()
-	ms, err := r.Manifests(ctx)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	tags, err := ms.Tags()
+	tags, err := r.Tags(ctx).All(ctx)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -749,6 +888,83 @@ func TestManifestTags(t *testing.T) {
 	// TODO(dmcgowan): Check for error cases
 }
 
+func TestManifestTagsPaginated(t *testing.T) {
+	repo := "test.example.com/repo/tags/list"
+	pages := [][]string{{"tag1", "tag2"}, {"tag3", "tag4"}, {"tag5"}}
+
+	var m testutil.RequestResponseMap
+	for i, page := range pages {
+		body, err := json.Marshal(struct {
+			Name string   `json:"name"`
+			Tags []string `json:"tags"`
+		}{Name: repo, Tags: page})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var queryParams map[string][]string
+		if i > 0 {
+			queryParams = map[string][]string{
+				"n":    {"2"},
+				"last": {pages[i-1][len(pages[i-1])-1]},
+			}
+		}
+
+		headers := http.Header(map[string][]string{
+			"Content-Length": {fmt.Sprint(len(body))},
+		})
+		if i < len(pages)-1 {
+			headers.Set("Link", fmt.Sprintf(`</v2/%s/tags/list?n=2&last=%s>; rel="next"`, repo, page[len(page)-1]))
+		}
+
+		mapping := testutil.RequestResponseMapping{
+			Request: testutil.Request{
+				Method:      "GET",
+				Route:       "/v2/" + repo + "/tags/list",
+				QueryParams: queryParams,
+			},
+			Response: testutil.Response{
+				StatusCode: http.StatusOK,
+				Body:       body,
+				Headers:    headers,
+			},
+		}
+		m = append(m, mapping)
+
+		// The last page is requested again through List below.
+		if i == len(pages)-1 {
+			m = append(m, mapping)
+		}
+	}
+
+	e, c := testServer(m)
+	defer c()
+
+	r, err := NewRepository(context.Background(), repo, e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	tags, err := r.Tags(ctx).All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"tag1", "tag2", "tag3", "tag4", "tag5"}; !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("Unexpected tags: %v, expected %v", tags, expected)
+	}
+
+	entries := make([]string, 2)
+	n, err := r.Tags(ctx).List(ctx, entries, "tag4")
+	if err != io.EOF {
+		t.Fatalf("Expected end of tags, got %v", err)
+	}
+
+	if n != 1 || entries[0] != "tag5" {
+		t.Fatalf("Unexpected tags listed after tag4: %v", entries[:n])
+	}
+}
+
 func TestManifestUnauthorized(t *testing.T) {
 	repo := "test.example.com/repo"
 	_, dgst, _ := newRandomSchemaV1Manifest(repo, "latest", 6)
@@ -862,6 +1078,48 @@ func TestCatalogInParts(t *testing.T) {
 	}
 }
 
+func TestCatalogFiltered(t *testing.T) {
+	var m testutil.RequestResponseMap
+	addTestCatalog(
+		"/v2/_catalog?n=2&prefix=foo%2F&q=bar",
+		[]byte("{\"repositories\":[\"foo/bar\", \"foo/barbaz\"]}"),
+		"</v2/_catalog?last=foo%2Fbarbaz&n=2&prefix=foo%2F&q=bar>", &m)
+	addTestCatalog(
+		"/v2/_catalog?last=foo%2Fbarbaz&n=2&prefix=foo%2F&q=bar",
+		[]byte("{\"repositories\":[\"foo/foobar\"]}"),
+		"", &m)
+
+	e, c := testServer(m)
+	defer c()
+
+	entries := make([]string, 2)
+
+	r, err := NewRegistry(context.Background(), e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	filter := RepositoryFilter{Prefix: "foo/", Query: "bar"}
+	numFilled, err := r.FilteredRepositories(ctx, entries, "", filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if numFilled != 2 || entries[0] != "foo/bar" || entries[1] != "foo/barbaz" {
+		t.Fatalf("Got wrong repos: %v", entries[:numFilled])
+	}
+
+	numFilled, err = r.FilteredRepositories(ctx, entries, "foo/barbaz", filter)
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+
+	if numFilled != 1 || entries[0] != "foo/foobar" {
+		t.Fatalf("Got wrong repos: %v", entries[:numFilled])
+	}
+}
+
 func TestSanitizeLocation(t *testing.T) {
 	for _, testcase := range []struct {
 		description string
@@ -4,6 +4,8 @@ import (
 	"io"
 	"net/http"
 	"sync"
+
+	"github.com/docker/distribution/context"
 )
 
 // RequestModifier represents an object which will do an inplace
@@ -28,6 +30,25 @@ func (h headerModifier) ModifyRequest(req *http.Request) error {
 	return nil
 }
 
+type traceparentModifier struct {
+	ctx context.Context
+}
+
+// NewTraceparentRequestModifier returns a new RequestModifier which will set
+// the traceparent header of a request to the current span of ctx, if any, so
+// that the spans of the remote service belong to the same trace.
+func NewTraceparentRequestModifier(ctx context.Context) RequestModifier {
+	return traceparentModifier{ctx: ctx}
+}
+
+func (t traceparentModifier) ModifyRequest(req *http.Request) error {
+	if sc, ok := context.GetSpanContext(t.ctx); ok {
+		req.Header.Set(context.TraceparentHeader, sc.Traceparent())
+	}
+
+	return nil
+}
+
 // NewTransport creates a new transport which will apply modifiers to
 // the request on a RoundTrip call.
 func NewTransport(base http.RoundTripper, modifiers ...RequestModifier) http.RoundTripper {
@@ -0,0 +1,148 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/factory"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+	"github.com/spf13/cobra"
+)
+
+// GCCmd is the cobra command that corresponds to the garbage-collect
+// subcommand. Unless --online is given, it should only be run while the
+// registry is stopped or in read-only mode.
+var GCCmd = &cobra.Command{
+	Use:   "garbage-collect <config>",
+	Short: "`garbage-collect` deletes blobs not referenced by any manifest",
+	Long:  "`garbage-collect` deletes blobs not referenced by any manifest.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		ctx, err := configureLogging(context.Background(), config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error configuring logger: %v\n", err)
+			os.Exit(1)
+		}
+
+		driver, err := newStorageDriver(config)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		// Deletion is required to remove untagged manifests.
+		registry, err := storage.NewRegistry(ctx, driver, storage.EnableDelete)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct registry: %v\n", err)
+			os.Exit(1)
+		}
+
+		opts := storage.GCOpts{
+			DryRun:         gcDryRun,
+			RemoveUntagged: gcDeleteUntagged,
+			Repositories:   gcRepositories,
+			Online:         gcOnline,
+			GracePeriod:    gcGracePeriod,
+		}
+
+		result, err := storage.MarkAndSweep(ctx, driver, registry, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to garbage collect: %v\n", err)
+			os.Exit(1)
+		}
+
+		printGCSummary(result, gcDryRun)
+
+		if len(result.Errors) > 0 {
+			for _, err := range result.Errors {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	},
+}
+
+var (
+	gcDryRun         bool
+	gcDeleteUntagged bool
+	gcRepositories   repositoryList
+	gcOnline         bool
+	gcGracePeriod    time.Duration
+)
+
+func init() {
+	GCCmd.Flags().BoolVarP(&gcDryRun, "dry-run", "d", false, "report what would be deleted without deleting anything")
+	GCCmd.Flags().BoolVarP(&gcDeleteUntagged, "delete-untagged", "m", false, "delete manifests that are not currently referenced by a tag")
+	GCCmd.Flags().VarP(&gcRepositories, "repository", "r", "restrict collection to the named repository; may be given multiple times")
+	GCCmd.Flags().BoolVar(&gcOnline, "online", false, "collect while the registry accepts writes; requires strongly consistent storage")
+	GCCmd.Flags().DurationVar(&gcGracePeriod, "grace-period", time.Hour, "with --online, keep blobs written within this period before collection started")
+}
+
+// newStorageDriver creates the storage driver described by config, wrapped
+// in the configured storage middleware.
+func newStorageDriver(config *configuration.Configuration) (storagedriver.StorageDriver, error) {
+	driver, err := factory.Create(config.Storage.Type(), config.Storage.Parameters())
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct %s driver: %v", config.Storage.Type(), err)
+	}
+
+	for _, mw := range config.Middleware["storage"] {
+		driver, err = storagemiddleware.Get(mw.Name, mw.Options, driver)
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure storage middleware (%s): %v", mw.Name, err)
+		}
+	}
+
+	return driver, nil
+}
+
+// printGCSummary writes a summary of a garbage collection run to stdout.
+func printGCSummary(result storage.GCResult, dryRun bool) {
+	verb := "deleted"
+	if dryRun {
+		verb = "would delete"
+	}
+
+	fmt.Printf("marked %d blobs\n", len(result.Marked))
+	for _, manifest := range result.RemovedManifests {
+		fmt.Printf("%s untagged manifest %s\n", verb, manifest)
+	}
+	for _, dgst := range result.Swept {
+		fmt.Printf("%s blob %s\n", verb, dgst)
+	}
+	fmt.Printf("%s %d untagged manifests and %d blobs, reclaiming %d bytes, with %d errors\n",
+		verb, len(result.RemovedManifests), len(result.Swept), result.ReclaimedBytes, len(result.Errors))
+}
+
+// repositoryList is a flag value collecting repository names from repeated
+// or comma separated flags.
+type repositoryList []string
+
+func (rl *repositoryList) String() string {
+	return strings.Join(*rl, ",")
+}
+
+func (rl *repositoryList) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*rl = append(*rl, name)
+		}
+	}
+	return nil
+}
+
+func (rl *repositoryList) Type() string {
+	return "repository"
+}
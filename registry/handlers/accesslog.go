@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+)
+
+// defaultAccessLogMaxBackups is the number of rotated access log files kept,
+// unless configured otherwise.
+const defaultAccessLogMaxBackups = 5
+
+// accessRecord describes a request served by the registry. It is created
+// when the request comes in, completed by the dispatcher of its route and
+// written once the response is sent.
+type accessRecord struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id"`
+	RemoteAddr string    `json:"remote_addr"`
+	User       string    `json:"user,omitempty"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Proto      string    `json:"proto"`
+	Route      string    `json:"route,omitempty"`
+	Repository string    `json:"repository,omitempty"`
+	Reference  string    `json:"reference,omitempty"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	Duration   float64   `json:"duration"`
+	Redirected bool      `json:"redirected"`
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// accessLogger writes access records in the configured format.
+type accessLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format string
+}
+
+// newAccessLogger returns an access logger for the configuration, nil if the
+// access log is disabled.
+func newAccessLogger(config configuration.AccessLog) (*accessLogger, error) {
+	if config.Disabled {
+		return nil, nil
+	}
+
+	format := config.Format
+	switch format {
+	case "":
+		format = "combined"
+	case "combined", "json":
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", format)
+	}
+
+	if config.MaxSize < 0 || config.MaxBackups < 0 {
+		return nil, fmt.Errorf("access log maxsize and maxbackups must not be negative")
+	}
+
+	var w io.Writer = os.Stdout
+	if config.Path != "" {
+		maxBackups := config.MaxBackups
+		if maxBackups == 0 {
+			maxBackups = defaultAccessLogMaxBackups
+		}
+
+		file, err := openRotatingFile(config.Path, config.MaxSize, maxBackups)
+		if err != nil {
+			return nil, err
+		}
+		w = file
+	}
+
+	return &accessLogger{w: w, format: format}, nil
+}
+
+// log writes the record of a request.
+func (l *accessLogger) log(record *accessRecord) error {
+	var p []byte
+	switch l.format {
+	case "json":
+		var err error
+		p, err = json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		p = append(p, '\n')
+	default:
+		p = []byte(combinedRecord(record))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err := l.w.Write(p)
+	return err
+}
+
+// combinedRecord formats the record in the Apache combined log format, the
+// fields specific to the registry following the standard ones.
+func combinedRecord(record *accessRecord) string {
+	return fmt.Sprintf("%s - %s [%s] %q %d %d %q %q request_id=%s route=%s repository=%s reference=%s duration=%.6f redirected=%t\n",
+		record.RemoteAddr,
+		orDash(record.User),
+		record.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		record.Method+" "+record.URI+" "+record.Proto,
+		record.Status,
+		record.Bytes,
+		record.Referer,
+		record.UserAgent,
+		orDash(record.RequestID),
+		orDash(record.Route),
+		orDash(record.Repository),
+		orDash(record.Reference),
+		record.Duration,
+		record.Redirected)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// withAccessRecord returns a context holding a new access record for the
+// request, if the access log is enabled.
+func (app *App) withAccessRecord(ctx context.Context, r *http.Request) context.Context {
+	if app.accessLogger == nil {
+		return ctx
+	}
+
+	return ctxu.WithValue(ctx, "http.request.accessrecord", &accessRecord{
+		Time:       time.Now(),
+		RemoteAddr: ctxu.RemoteIP(r),
+		Method:     r.Method,
+		URI:        r.RequestURI,
+		Proto:      r.Proto,
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+	})
+}
+
+// recordRoute completes the access record of a request dispatched to a route
+// with the identity of the user and the content requested.
+func recordRoute(context *Context, r *http.Request) {
+	record, ok := context.Value("http.request.accessrecord").(*accessRecord)
+	if !ok {
+		return
+	}
+
+	if route := mux.CurrentRoute(r); route != nil {
+		record.Route = route.GetName()
+	}
+	record.User = ctxu.GetStringValue(context, "auth.user.name")
+	record.Repository = getName(context)
+
+	record.Reference = ctxu.GetStringValue(context, "vars.digest")
+	if record.Reference == "" {
+		record.Reference = ctxu.GetStringValue(context, "vars.reference")
+	}
+}
+
+// logAccess writes the access record of a request once its response is
+// sent.
+func (app *App) logAccess(ctx context.Context) {
+	record, ok := ctx.Value("http.request.accessrecord").(*accessRecord)
+	if !ok {
+		return
+	}
+
+	record.RequestID = ctxu.GetRequestID(ctx)
+	record.Duration = time.Since(record.Time).Seconds()
+	record.Bytes, _ = ctx.Value("http.response.written").(int64)
+
+	record.Status, _ = ctx.Value("http.response.status").(int)
+	if record.Status == 0 {
+		record.Status = http.StatusOK
+	}
+
+	// Blobs are only redirected to the URL of the storage driver.
+	record.Redirected = record.Route == v2.RouteNameBlob && record.Status == http.StatusTemporaryRedirect
+
+	if err := app.accessLogger.log(record); err != nil {
+		ctxu.GetLogger(ctx).Errorf("error writing access log: %v", err)
+	}
+}
+
+// rotatingFile is a file which is rotated once larger than its maximum
+// size, keeping a number of rotated files named after it with a numbered
+// suffix, the most recent first.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// openRotatingFile opens the file at path for appending, creating it if
+// missing. The file is never rotated if maxSize is zero.
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	rf := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	rf.file = file
+	rf.size = fi.Size()
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate renames the file to the first backup, shifting the others and
+// removing the oldest, then opens a new file.
+func (rf *rotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+
+	backup := func(i int) string {
+		return fmt.Sprintf("%s.%d", rf.path, i)
+	}
+
+	err := os.Remove(backup(rf.maxBackups))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+
+	for i := rf.maxBackups - 1; i > 0 && err == nil; i-- {
+		if err = os.Rename(backup(i), backup(i+1)); os.IsNotExist(err) {
+			err = nil
+		}
+	}
+
+	if err == nil {
+		err = os.Rename(rf.path, backup(1))
+	}
+
+	// The file is reopened even if it couldn't be rotated, so that records
+	// keep being written.
+	if openErr := rf.open(); openErr != nil {
+		return openErr
+	}
+
+	return err
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/digest"
+)
+
+// TestAccessLog checks that a record describing the repository and content
+// of each request is written to the access log.
+func TestAccessLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	checkErr(t, err, "creating temporary directory")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "access.log")
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+	}
+	config.HTTP.Headers = headerConfig
+	config.Log.AccessLog.Format = "json"
+	config.Log.AccessLog.Path = path
+
+	env := newTestEnvWithConfig(t, &config)
+
+	content := []byte("access log layer")
+	dgst, err := digest.FromBytes(content)
+	checkErr(t, err, "digesting layer")
+
+	uploadURLBase, _ := startPushLayer(t, env.builder, "foo/bar")
+	pushLayer(t, env.builder, "foo/bar", dgst, uploadURLBase, bytes.NewReader(content))
+
+	layerURL, err := env.builder.BuildBlobURL("foo/bar", dgst)
+	checkErr(t, err, "building blob url")
+
+	resp, err := http.Get(layerURL)
+	checkErr(t, err, "fetching layer")
+	resp.Body.Close()
+	checkResponse(t, "fetching layer", resp, http.StatusOK)
+
+	manifestURL, err := env.builder.BuildManifestURL("foo/bar", "latest")
+	checkErr(t, err, "building manifest url")
+
+	resp, err = http.Get(manifestURL)
+	checkErr(t, err, "fetching manifest")
+	resp.Body.Close()
+	checkResponse(t, "fetching unknown manifest", resp, http.StatusNotFound)
+
+	f, err := os.Open(path)
+	checkErr(t, err, "opening access log")
+	defer f.Close()
+
+	var records []accessRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record accessRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("unexpected error decoding access record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+
+	// The upload is logged first, once started and once completed.
+	if len(records) != 4 {
+		t.Fatalf("unexpected number of access records: %d != 4", len(records))
+	}
+
+	blob, manifest := records[2], records[3]
+	if blob.Route != "blob" || blob.Repository != "foo/bar" || blob.Reference != dgst.String() ||
+		blob.Method != "GET" || blob.Status != http.StatusOK || blob.Bytes != int64(len(content)) ||
+		blob.Redirected || blob.RequestID == "" {
+		t.Fatalf("unexpected blob access record: %+v", blob)
+	}
+
+	if manifest.Route != "manifest" || manifest.Repository != "foo/bar" || manifest.Reference != "latest" ||
+		manifest.Status != http.StatusNotFound {
+		t.Fatalf("unexpected manifest access record: %+v", manifest)
+	}
+}
+
+// TestAccessLogCombined checks the format of records in the Apache combined
+// log format.
+func TestAccessLogCombined(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &accessLogger{w: &buf, format: "combined"}
+
+	record := &accessRecord{
+		Time:       time.Date(2016, time.March, 1, 12, 30, 0, 0, time.UTC),
+		RequestID:  "f9c4e1b0",
+		RemoteAddr: "10.0.0.1",
+		User:       "alice",
+		Method:     "HEAD",
+		URI:        "/v2/foo/bar/manifests/latest",
+		Proto:      "HTTP/1.1",
+		Route:      "manifest",
+		Repository: "foo/bar",
+		Reference:  "latest",
+		Status:     http.StatusOK,
+		Bytes:      0,
+		Duration:   0.25,
+		UserAgent:  "docker/1.10.0",
+	}
+	if err := logger.log(record); err != nil {
+		t.Fatalf("unexpected error logging access: %v", err)
+	}
+
+	expected := `10.0.0.1 - alice [01/Mar/2016:12:30:00 +0000] "HEAD /v2/foo/bar/manifests/latest HTTP/1.1" 200 0 "" "docker/1.10.0" ` +
+		"request_id=f9c4e1b0 route=manifest repository=foo/bar reference=latest duration=0.250000 redirected=false\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected combined record:\n%q\n%q", buf.String(), expected)
+	}
+
+	if _, err := newAccessLogger(configuration.AccessLog{Format: "common"}); err == nil {
+		t.Fatalf("expected error creating access logger with unknown format")
+	}
+}
+
+// TestRotatingFile checks that files are rotated once larger than their
+// maximum size, keeping at most the configured number of backups.
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	checkErr(t, err, "creating temporary directory")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "access.log")
+	rf, err := openRotatingFile(path, 10, 2)
+	checkErr(t, err, "opening rotating file")
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := rf.Write([]byte(line)); err != nil {
+			t.Fatalf("unexpected error writing %q: %v", line, err)
+		}
+	}
+	rf.file.Close()
+
+	for name, expected := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		p, err := ioutil.ReadFile(name)
+		checkErr(t, err, "reading "+name)
+		if string(p) != expected {
+			t.Fatalf("unexpected content of %s: %q != %q", name, p, expected)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected oldest backup to be removed: %v", err)
+	}
+
+	matches, _ := filepath.Glob(path + "*")
+	if len(matches) != 3 {
+		t.Fatalf("unexpected files: %s", strings.Join(matches, ", "))
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -18,13 +19,18 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/docker/distribution"
 	"github.com/docker/distribution/configuration"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
 	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/distribution/registry/api/errcode"
 	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/auth"
 	_ "github.com/docker/distribution/registry/storage/driver/inmemory"
 	"github.com/docker/distribution/testutil"
 	"github.com/docker/libtrust"
@@ -204,6 +210,136 @@ func checkLink(t *testing.T, urlStr string, numEntries int, last string) url.Val
 	return urlValues
 }
 
+// denyPullAccessController grants any access except pulling the denied
+// repositories, counting the requests it authorizes.
+type denyPullAccessController struct {
+	denied     map[string]bool
+	authorized int
+}
+
+func (ac *denyPullAccessController) granted(access auth.Access) bool {
+	return access.Type != "repository" || access.Action != "pull" || !ac.denied[access.Name]
+}
+
+func (ac *denyPullAccessController) Authorized(ctx context.Context, accessItems ...auth.Access) (context.Context, error) {
+	ac.authorized++
+
+	for _, access := range accessItems {
+		if !ac.granted(access) {
+			return nil, fmt.Errorf("pull access to %s denied", access.Name)
+		}
+	}
+
+	return auth.WithAccessChecker(ctx, ac.granted), nil
+}
+
+// TestCatalogAPISearch tests filtering the /v2/_catalog endpoint by prefix
+// and substring, omitting repositories the caller may not pull.
+func TestCatalogAPISearch(t *testing.T) {
+	env := newTestEnv(t, false)
+
+	images := []string{"bar/aaaa", "foo/aaaa", "foo/abab", "foo/bbbb", "foo/cccc", "foo/secret", "foobar/aaaa"}
+	for _, image := range images {
+		pushRandomSchema2Manifest(t, env, image)
+	}
+
+	// Repositories are pushed first, as pushing also requires pull access.
+	accessController := &denyPullAccessController{denied: map[string]bool{"foo/secret": true}}
+	env.app.accessController = accessController
+
+	getCatalog := func(msg string, values url.Values) ([]string, string) {
+		catalogURL, err := env.builder.BuildCatalogURL(values)
+		checkErr(t, err, "building catalog url")
+
+		resp, err := http.Get(catalogURL)
+		checkErr(t, err, msg)
+		defer resp.Body.Close()
+
+		checkResponse(t, msg, resp, http.StatusOK)
+
+		var ctlg catalogAPIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&ctlg); err != nil {
+			t.Fatalf("error decoding catalog response: %v", err)
+		}
+
+		return ctlg.Repositories, resp.Header.Get("Link")
+	}
+
+	repos, link := getCatalog("searching by prefix", url.Values{"prefix": []string{"foo/"}, "n": []string{"2"}})
+	if expected := []string{"foo/aaaa", "foo/abab"}; !reflect.DeepEqual(repos, expected) {
+		t.Fatalf("unexpected repositories: %v != %v", repos, expected)
+	}
+
+	newValues := checkLink(t, link, 2, "foo/abab")
+	if newValues.Get("prefix") != "foo/" {
+		t.Fatalf("catalog link lost the prefix: %q", link)
+	}
+
+	repos, link = getCatalog("searching by prefix after last", newValues)
+	if expected := []string{"foo/bbbb", "foo/cccc"}; !reflect.DeepEqual(repos, expected) {
+		t.Fatalf("unexpected repositories: %v != %v", repos, expected)
+	}
+
+	// foo/secret is the only remaining match, but may not be pulled.
+	newValues = checkLink(t, link, 2, "foo/cccc")
+	repos, link = getCatalog("searching by prefix after last", newValues)
+	if len(repos) != 0 || link != "" {
+		t.Fatalf("unexpected repositories %v with link %q", repos, link)
+	}
+
+	repos, link = getCatalog("searching by substring", url.Values{"q": []string{"aa"}})
+	if expected := []string{"bar/aaaa", "foo/aaaa", "foobar/aaaa"}; !reflect.DeepEqual(repos, expected) {
+		t.Fatalf("unexpected repositories: %v != %v", repos, expected)
+	}
+	if link != "" {
+		t.Fatalf("unexpected link header: %q", link)
+	}
+
+	repos, _ = getCatalog("searching by prefix and substring", url.Values{"prefix": []string{"foo"}, "q": []string{"b"}})
+	if expected := []string{"foo/abab", "foo/bbbb", "foobar/aaaa"}; !reflect.DeepEqual(repos, expected) {
+		t.Fatalf("unexpected repositories: %v != %v", repos, expected)
+	}
+
+	// The unfiltered catalog omits the same repositories.
+	repos, _ = getCatalog("listing the catalog", url.Values{})
+	if expected := []string{"bar/aaaa", "foo/aaaa", "foo/abab", "foo/bbbb", "foo/cccc", "foobar/aaaa"}; !reflect.DeepEqual(repos, expected) {
+		t.Fatalf("unexpected repositories: %v != %v", repos, expected)
+	}
+
+	// Each request is authorized once, the access to the repositories
+	// being checked without authenticating again.
+	if accessController.authorized != 6 {
+		t.Fatalf("unexpected number of authorizations: %d != 6", accessController.authorized)
+	}
+
+	// Access controllers without an access checker, such as the token one,
+	// only grant the catalog itself, which is listed whole.
+	env.app.accessController = catalogOnlyAccessController{}
+	repos, _ = getCatalog("listing the catalog without an access checker", url.Values{})
+	if !reflect.DeepEqual(repos, images) {
+		t.Fatalf("unexpected repositories: %v != %v", repos, images)
+	}
+
+	repos, _ = getCatalog("searching without an access checker", url.Values{"prefix": []string{"foo/s"}})
+	if expected := []string{"foo/secret"}; !reflect.DeepEqual(repos, expected) {
+		t.Fatalf("unexpected repositories: %v != %v", repos, expected)
+	}
+}
+
+// catalogOnlyAccessController grants the catalog access only, as a token
+// scoped to the catalog, without setting an access checker.
+type catalogOnlyAccessController struct{}
+
+func (catalogOnlyAccessController) Authorized(ctx context.Context, accessItems ...auth.Access) (context.Context, error) {
+	for _, access := range accessItems {
+		if access.Type != "registry" || access.Name != "catalog" {
+			return nil, fmt.Errorf("access to %s denied", access.Name)
+		}
+	}
+
+	return ctx, nil
+}
+
 func contains(elems []string, e string) bool {
 	for _, elem := range elems {
 		if elem == e {
@@ -681,6 +817,83 @@ func TestStartPushReadOnly(t *testing.T) {
 	checkResponse(t, "starting push in read-only mode", resp, http.StatusMethodNotAllowed)
 }
 
+// TestBlobMount checks that starting an upload with the mount parameters links
+// a blob from another repository instead of allocating an upload.
+func TestBlobMount(t *testing.T) {
+	env := newTestEnv(t, false)
+
+	sourceName := "foo/source"
+	targetName := "foo/target"
+
+	p := make([]byte, 1024)
+	if _, err := rand.Read(p); err != nil {
+		t.Fatalf("unexpected error generating blob content: %v", err)
+	}
+	dgst, err := digest.FromBytes(p)
+	checkErr(t, err, "computing blob digest")
+
+	uploadURLBase, _ := startPushLayer(t, env.builder, sourceName)
+	pushLayer(t, env.builder, sourceName, dgst, uploadURLBase, bytes.NewReader(p))
+
+	mountURL, err := env.builder.BuildBlobUploadURL(targetName, url.Values{
+		"mount": []string{dgst.String()},
+		"from":  []string{sourceName},
+	})
+	checkErr(t, err, "building mount url")
+
+	resp, err := http.Post(mountURL, "", nil)
+	checkErr(t, err, "mounting blob")
+	defer resp.Body.Close()
+
+	checkResponse(t, "mounting blob", resp, http.StatusCreated)
+
+	blobURL, err := env.builder.BuildBlobURL(targetName, dgst)
+	checkErr(t, err, "building blob url")
+
+	checkHeaders(t, resp, http.Header{
+		"Location":              []string{blobURL},
+		"Content-Length":        []string{"0"},
+		"Docker-Content-Digest": []string{dgst.String()},
+	})
+
+	resp, err = http.Get(blobURL)
+	checkErr(t, err, "fetching mounted blob")
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching mounted blob", resp, http.StatusOK)
+
+	// Mounting from a repository without the blob starts a regular upload.
+	mountURL, err = env.builder.BuildBlobUploadURL("foo/other", url.Values{
+		"mount": []string{dgst.String()},
+		"from":  []string{"foo/empty"},
+	})
+	checkErr(t, err, "building mount url")
+
+	resp, err = http.Post(mountURL, "", nil)
+	checkErr(t, err, "mounting unknown blob")
+	defer resp.Body.Close()
+
+	checkResponse(t, "mounting unknown blob", resp, http.StatusAccepted)
+	checkHeaders(t, resp, http.Header{
+		"Location":           []string{"*"},
+		"Docker-Upload-UUID": []string{"*"},
+	})
+
+	// An invalid digest is rejected.
+	mountURL, err = env.builder.BuildBlobUploadURL(targetName, url.Values{
+		"mount": []string{"sha256:invalid"},
+		"from":  []string{sourceName},
+	})
+	checkErr(t, err, "building mount url")
+
+	resp, err = http.Post(mountURL, "", nil)
+	checkErr(t, err, "mounting invalid digest")
+	defer resp.Body.Close()
+
+	checkResponse(t, "mounting invalid digest", resp, http.StatusBadRequest)
+	checkBodyHasErrorCodes(t, "mounting invalid digest", resp, v2.ErrorCodeDigestInvalid)
+}
+
 func httpDelete(url string) (*http.Response, error) {
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
@@ -877,10 +1090,7 @@ func testManifestAPI(t *testing.T, env *testEnv, args manifestArgs) (*testEnv, m
 		t.Fatalf("unexpected error signing manifest: %v", err)
 	}
 
-	payload, err := signedManifest.Payload()
-	checkErr(t, err, "getting manifest payload")
-
-	dgst, err := digest.FromBytes(payload)
+	dgst, err := digest.FromBytes(signedManifest.Canonical)
 	checkErr(t, err, "digesting manifest")
 
 	args.signedManifest = signedManifest
@@ -1023,6 +1233,354 @@ func testManifestAPI(t *testing.T, env *testEnv, args manifestArgs) (*testEnv, m
 	return env, args
 }
 
+// TestManifestAPISchema2 pushes and fetches a schema2 manifest and a manifest
+// list referencing it, checking that each is served with its media type.
+func TestManifestAPISchema2(t *testing.T) {
+	env := newTestEnv(t, false)
+	imageName := "foo/schema2"
+	tag := "schema2tag"
+
+	manifestURL, err := env.builder.BuildManifestURL(imageName, tag)
+	checkErr(t, err, "building manifest url")
+
+	pushBlob := func(mediaType string, p []byte) distribution.Descriptor {
+		dgst, err := digest.FromBytes(p)
+		checkErr(t, err, "digesting blob")
+
+		uploadURLBase, _ := startPushLayer(t, env.builder, imageName)
+		pushLayer(t, env.builder, imageName, dgst, uploadURLBase, bytes.NewReader(p))
+
+		return distribution.Descriptor{MediaType: mediaType, Size: int64(len(p)), Digest: dgst}
+	}
+
+	pushRandomBlob := func(mediaType string) distribution.Descriptor {
+		p := make([]byte, 512)
+		if _, err := rand.Read(p); err != nil {
+			t.Fatalf("unexpected error generating blob: %v", err)
+		}
+
+		return pushBlob(mediaType, p)
+	}
+
+	config := pushBlob(schema2.ConfigMediaType, []byte(`{
+    "architecture": "amd64",
+    "history": [
+        {
+            "created": "2016-01-19T20:24:20.123456789Z",
+            "created_by": "/bin/sh -c #(nop) ADD file:9e4ca21cbd24dc05b454b6be21c7c639216ae66559b21ba24af0d665c62620dc in /"
+        },
+        {
+            "created": "2016-01-19T20:24:21.123456789Z",
+            "created_by": "/bin/sh -c #(nop) CMD [\"/bin/sh\"]",
+            "empty_layer": true
+        }
+    ],
+    "os": "linux",
+    "rootfs": {
+        "diff_ids": [
+            "sha256:c6f988f4874bb0add23a778f753c65efe992244e148a1d2ec2a8b664fb66bbd1"
+        ],
+        "type": "layers"
+    }
+}`))
+	layer := pushRandomBlob(schema2.LayerMediaType)
+	missing := distribution.Descriptor{
+		MediaType: schema2.LayerMediaType,
+		Size:      512,
+		Digest:    "sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b",
+	}
+
+	// -----------------------------
+	// Push a manifest referencing a missing layer
+	invalid, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    config,
+		Layers:    []distribution.Descriptor{layer, missing},
+	})
+	checkErr(t, err, "building manifest")
+
+	resp := putManifest(t, "putting manifest with missing layer", manifestURL, invalid)
+	defer resp.Body.Close()
+	checkResponse(t, "putting manifest with missing layer", resp, http.StatusBadRequest)
+	_, p, counts := checkBodyHasErrorCodes(t, "putting manifest with missing layer", resp, v2.ErrorCodeManifestBlobUnknown)
+	if counts[v2.ErrorCodeManifestBlobUnknown] != 1 {
+		t.Fatalf("expected one unknown blob error: %s", p)
+	}
+
+	// -----------------------------
+	// Push a valid manifest by tag
+	m, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    config,
+		Layers:    []distribution.Descriptor{layer},
+	})
+	checkErr(t, err, "building manifest")
+
+	mediaType, payload, err := m.Payload()
+	checkErr(t, err, "getting manifest payload")
+
+	dgst, err := digest.FromBytes(payload)
+	checkErr(t, err, "digesting manifest")
+
+	manifestDigestURL, err := env.builder.BuildManifestURL(imageName, dgst.String())
+	checkErr(t, err, "building manifest url")
+
+	resp = putManifest(t, "putting schema2 manifest", manifestURL, m)
+	checkResponse(t, "putting schema2 manifest", resp, http.StatusCreated)
+	checkHeaders(t, resp, http.Header{
+		"Location":              []string{manifestDigestURL},
+		"Docker-Content-Digest": []string{dgst.String()},
+	})
+
+	for _, u := range []string{manifestURL, manifestDigestURL} {
+		resp = getManifest(t, "fetching schema2 manifest", u, schema2.ManifestMediaType, schema1.SignedManifestMediaType)
+		defer resp.Body.Close()
+
+		checkResponse(t, "fetching schema2 manifest", resp, http.StatusOK)
+		checkHeaders(t, resp, http.Header{
+			"Content-Type":          []string{mediaType},
+			"Docker-Content-Digest": []string{dgst.String()},
+			"ETag":                  []string{fmt.Sprintf(`"%s"`, dgst)},
+		})
+
+		body, err := ioutil.ReadAll(resp.Body)
+		checkErr(t, err, "reading schema2 manifest")
+
+		if !bytes.Equal(body, payload) {
+			t.Fatalf("manifests do not match: %q != %q", body, payload)
+		}
+	}
+
+	// -----------------------------
+	// Push a manifest list referencing the manifest
+	list, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{
+		{
+			Descriptor: distribution.Descriptor{MediaType: mediaType, Size: int64(len(payload)), Digest: dgst},
+			Platform:   manifestlist.PlatformSpec{Architecture: "amd64", OS: "linux"},
+		},
+	})
+	checkErr(t, err, "building manifest list")
+
+	listMediaType, listPayload, err := list.Payload()
+	checkErr(t, err, "getting manifest list payload")
+
+	listDigest, err := digest.FromBytes(listPayload)
+	checkErr(t, err, "digesting manifest list")
+
+	listURL, err := env.builder.BuildManifestURL(imageName, "list")
+	checkErr(t, err, "building manifest url")
+
+	resp = putManifest(t, "putting manifest list", listURL, list)
+	checkResponse(t, "putting manifest list", resp, http.StatusCreated)
+	checkHeaders(t, resp, http.Header{
+		"Docker-Content-Digest": []string{listDigest.String()},
+	})
+
+	resp = getManifest(t, "fetching manifest list", listURL, manifestlist.ManifestListMediaType, schema2.ManifestMediaType)
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching manifest list", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"Content-Type":          []string{listMediaType},
+		"Docker-Content-Digest": []string{listDigest.String()},
+	})
+
+	body, err := ioutil.ReadAll(resp.Body)
+	checkErr(t, err, "reading manifest list")
+
+	if !bytes.Equal(body, listPayload) {
+		t.Fatalf("manifest lists do not match: %q != %q", body, listPayload)
+	}
+
+	// -----------------------------
+	// Clients accepting schema2 but not manifest lists get the linux/amd64
+	// manifest.
+	resp = getManifest(t, "fetching manifest list as schema2", listURL, schema2.ManifestMediaType)
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching manifest list as schema2", resp, http.StatusOK)
+	checkHeaders(t, resp, http.Header{
+		"Content-Type":          []string{mediaType},
+		"Docker-Content-Digest": []string{dgst.String()},
+	})
+
+	// -----------------------------
+	// Clients that don't accept schema2 get a signed schema1 manifest.
+	for _, u := range []string{manifestURL, listURL} {
+		resp = getManifest(t, "fetching schema2 manifest as schema1", u)
+		defer resp.Body.Close()
+
+		checkResponse(t, "fetching schema2 manifest as schema1", resp, http.StatusOK)
+
+		body, err := ioutil.ReadAll(resp.Body)
+		checkErr(t, err, "reading converted manifest")
+
+		m, desc, err := distribution.UnmarshalManifest(resp.Header.Get("Content-Type"), body)
+		checkErr(t, err, "unmarshaling converted manifest")
+
+		sm, ok := m.(*schema1.SignedManifest)
+		if !ok {
+			t.Fatalf("unexpected manifest type served to schema1 client: %T", m)
+		}
+
+		checkHeaders(t, resp, http.Header{
+			"Content-Type":          []string{schema1.SignedManifestMediaType},
+			"Docker-Content-Digest": []string{desc.Digest.String()},
+			"ETag":                  []string{fmt.Sprintf(`"%s"`, desc.Digest)},
+		})
+
+		if desc.Digest == dgst {
+			t.Fatalf("converted manifest has the digest of the stored manifest")
+		}
+
+		if _, err := schema1.Verify(sm); err != nil {
+			t.Fatalf("error verifying converted manifest: %v", err)
+		}
+
+		if sm.Name != imageName || sm.Architecture != "amd64" {
+			t.Fatalf("unexpected converted manifest: %s", body)
+		}
+
+		if len(sm.FSLayers) != 2 || sm.FSLayers[1].BlobSum != layer.Digest {
+			t.Fatalf("unexpected layers in converted manifest: %v", sm.FSLayers)
+		}
+
+		// The empty layer must be available to the client.
+		emptyLayerURL, err := env.builder.BuildBlobURL(imageName, sm.FSLayers[0].BlobSum)
+		checkErr(t, err, "building blob url")
+
+		resp, err = http.Head(emptyLayerURL)
+		checkErr(t, err, "checking empty layer")
+		checkResponse(t, "checking empty layer", resp, http.StatusOK)
+
+		req, err := http.NewRequest("GET", u, nil)
+		checkErr(t, err, "building request")
+		req.Header.Set("If-None-Match", fmt.Sprintf(`"%s"`, desc.Digest))
+
+		resp, err = http.DefaultClient.Do(req)
+		checkErr(t, err, "fetching converted manifest with etag")
+		checkResponse(t, "fetching converted manifest with etag", resp, http.StatusNotModified)
+	}
+}
+
+func TestManifestAPIOCI(t *testing.T) {
+	env := newTestEnv(t, false)
+	imageName := "foo/oci"
+
+	pushRandomBlob := func(mediaType string) distribution.Descriptor {
+		p := make([]byte, 512)
+		if _, err := rand.Read(p); err != nil {
+			t.Fatalf("unexpected error generating blob: %v", err)
+		}
+
+		dgst, err := digest.FromBytes(p)
+		checkErr(t, err, "digesting blob")
+
+		uploadURLBase, _ := startPushLayer(t, env.builder, imageName)
+		pushLayer(t, env.builder, imageName, dgst, uploadURLBase, bytes.NewReader(p))
+
+		return distribution.Descriptor{MediaType: mediaType, Size: int64(len(p)), Digest: dgst}
+	}
+
+	config := pushRandomBlob(ocischema.ConfigMediaType)
+	layer := pushRandomBlob(ocischema.LayerMediaType)
+
+	// Build tooling often leaves out the optional media type.
+	versioned := manifest.Versioned{SchemaVersion: 2}
+	m, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: versioned,
+		Config:    ocischema.Descriptor{Descriptor: config},
+		Layers: []ocischema.Descriptor{
+			{
+				Descriptor:  layer,
+				Annotations: map[string]string{"org.example.layer": "base"},
+			},
+		},
+		Annotations: map[string]string{"org.opencontainers.image.ref.name": "latest"},
+	})
+	checkErr(t, err, "building manifest")
+
+	desc, err := distribution.DescribeManifest(m)
+	checkErr(t, err, "describing manifest")
+
+	index, err := ocischema.FromImageIndex(ocischema.ImageIndex{
+		Versioned: versioned,
+		Manifests: []ocischema.ManifestDescriptor{
+			{
+				Descriptor: ocischema.Descriptor{Descriptor: desc},
+				Platform:   &ocischema.Platform{Architecture: "amd64", OS: "linux"},
+			},
+		},
+		Annotations: map[string]string{"org.example.index": "true"},
+	})
+	checkErr(t, err, "building image index")
+
+	for _, tc := range []struct {
+		tag      string
+		manifest distribution.Manifest
+	}{
+		{"manifest", m},
+		{"index", index},
+	} {
+		mediaType, payload, err := tc.manifest.Payload()
+		checkErr(t, err, "getting payload")
+
+		dgst, err := digest.FromBytes(payload)
+		checkErr(t, err, "digesting payload")
+
+		manifestURL, err := env.builder.BuildManifestURL(imageName, tc.tag)
+		checkErr(t, err, "building manifest url")
+
+		resp := putManifest(t, "putting OCI content", manifestURL, tc.manifest)
+		checkResponse(t, "putting OCI content", resp, http.StatusCreated)
+		checkHeaders(t, resp, http.Header{
+			"Docker-Content-Digest": []string{dgst.String()},
+		})
+
+		resp = getManifest(t, "fetching OCI content", manifestURL, mediaType)
+		defer resp.Body.Close()
+
+		checkResponse(t, "fetching OCI content", resp, http.StatusOK)
+		checkHeaders(t, resp, http.Header{
+			"Content-Type":          []string{mediaType},
+			"Docker-Content-Digest": []string{dgst.String()},
+		})
+
+		body, err := ioutil.ReadAll(resp.Body)
+		checkErr(t, err, "reading OCI content")
+
+		if !bytes.Equal(body, payload) {
+			t.Fatalf("OCI content was not served unchanged: %q != %q", body, payload)
+		}
+
+		// Clients that don't accept OCI content can't be served.
+		resp = getManifest(t, "fetching OCI content without accepting it", manifestURL, schema2.ManifestMediaType)
+		defer resp.Body.Close()
+
+		checkResponse(t, "fetching OCI content without accepting it", resp, http.StatusNotFound)
+		checkBodyHasErrorCodes(t, "fetching OCI content without accepting it", resp, v2.ErrorCodeManifestUnknown)
+	}
+}
+
+// getManifest fetches the manifest at url, accepting the given media types.
+func getManifest(t *testing.T, msg, url string, accept ...string) *http.Response {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatalf("error creating request for %s: %v", msg, err)
+	}
+
+	for _, mediaType := range accept {
+		req.Header.Add("Accept", mediaType)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error doing request for %s: %v", msg, err)
+	}
+
+	return resp
+}
+
 func testManifestDelete(t *testing.T, env *testEnv, args manifestArgs) {
 	imageName := args.imageName
 	dgst := args.dgst
@@ -1085,6 +1643,90 @@ func testManifestDelete(t *testing.T, env *testEnv, args manifestArgs) {
 
 }
 
+func TestManifestTagDelete(t *testing.T) {
+	env := newTestEnv(t, true)
+	imageName := "foo/untag"
+
+	m := pushRandomSchema2Manifest(t, env, imageName)
+
+	desc, err := distribution.DescribeManifest(m)
+	checkErr(t, err, "describing manifest")
+
+	urlFor := func(reference string) string {
+		u, err := env.builder.BuildManifestURL(imageName, reference)
+		checkErr(t, err, "building manifest url")
+		return u
+	}
+
+	for _, tag := range []string{"latest", "pr-1234"} {
+		resp := putManifest(t, "putting manifest by tag", urlFor(tag), m)
+		checkResponse(t, "putting manifest by tag", resp, http.StatusCreated)
+	}
+
+	// ---------------
+	// Delete the tag
+	resp, err := httpDelete(urlFor("pr-1234"))
+	checkErr(t, err, "deleting tag")
+
+	checkResponse(t, "deleting tag", resp, http.StatusAccepted)
+	checkHeaders(t, resp, http.Header{
+		"Content-Length": []string{"0"},
+	})
+
+	resp = getManifest(t, "fetching deleted tag", urlFor("pr-1234"), schema2.ManifestMediaType)
+	defer resp.Body.Close()
+
+	checkResponse(t, "fetching deleted tag", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "fetching deleted tag", resp, v2.ErrorCodeManifestUnknown)
+
+	// ---------------
+	// The manifest and its other tags are left in place
+	for _, reference := range []string{"latest", desc.Digest.String()} {
+		resp = getManifest(t, "fetching manifest after tag delete", urlFor(reference), schema2.ManifestMediaType)
+		defer resp.Body.Close()
+
+		checkResponse(t, "fetching manifest after tag delete", resp, http.StatusOK)
+		checkHeaders(t, resp, http.Header{
+			"Docker-Content-Digest": []string{desc.Digest.String()},
+		})
+	}
+
+	tagsURL, err := env.builder.BuildTagsURL(imageName)
+	checkErr(t, err, "building tags url")
+
+	resp, err = http.Get(tagsURL)
+	checkErr(t, err, "listing tags")
+	defer resp.Body.Close()
+
+	checkResponse(t, "listing tags", resp, http.StatusOK)
+
+	var tagsResponse tagsAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tagsResponse); err != nil {
+		t.Fatalf("unexpected error decoding tags response: %v", err)
+	}
+
+	if !reflect.DeepEqual(tagsResponse.Tags, []string{"latest"}) {
+		t.Fatalf("unexpected tags after tag delete: %v", tagsResponse.Tags)
+	}
+
+	// ---------------
+	// Delete the already deleted tag
+	resp, err = httpDelete(urlFor("pr-1234"))
+	checkErr(t, err, "re-deleting tag")
+
+	checkResponse(t, "re-deleting tag", resp, http.StatusNotFound)
+	checkBodyHasErrorCodes(t, "re-deleting tag", resp, v2.ErrorCodeManifestUnknown)
+
+	// ---------------
+	// Tags can't be deleted when delete is disabled
+	env = newTestEnv(t, false)
+
+	resp, err = httpDelete(urlFor("latest"))
+	checkErr(t, err, "deleting tag with delete disabled")
+
+	checkResponse(t, "deleting tag with delete disabled", resp, http.StatusMethodNotAllowed)
+}
+
 type testEnv struct {
 	pk      libtrust.PrivateKey
 	ctx     context.Context
@@ -1094,6 +1736,306 @@ type testEnv struct {
 	builder *v2.URLBuilder
 }
 
+// pushRandomSchema2Manifest pushes a random config and layer to the named
+// repository and returns a schema2 manifest referencing them. The manifest
+// itself is not pushed.
+func pushRandomSchema2Manifest(t *testing.T, env *testEnv, imageName string) distribution.Manifest {
+	pushRandomBlob := func(mediaType string) distribution.Descriptor {
+		p := make([]byte, 512)
+		if _, err := rand.Read(p); err != nil {
+			t.Fatalf("unexpected error generating blob: %v", err)
+		}
+
+		dgst, err := digest.FromBytes(p)
+		checkErr(t, err, "digesting blob")
+
+		uploadURLBase, _ := startPushLayer(t, env.builder, imageName)
+		pushLayer(t, env.builder, imageName, dgst, uploadURLBase, bytes.NewReader(p))
+
+		return distribution.Descriptor{MediaType: mediaType, Size: int64(len(p)), Digest: dgst}
+	}
+
+	m, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    pushRandomBlob(schema2.ConfigMediaType),
+		Layers:    []distribution.Descriptor{pushRandomBlob(schema2.LayerMediaType)},
+	})
+	checkErr(t, err, "building manifest")
+
+	return m
+}
+
+// TestTagsAPIPagination walks the tags of a repository page by page using
+// the Link header and checks that unpaginated requests return every tag.
+func TestTagsAPIPagination(t *testing.T) {
+	env := newTestEnv(t, false)
+	imageName := "foo/paginated"
+
+	m := pushRandomSchema2Manifest(t, env, imageName)
+
+	allTags := []string{"v1", "v2", "v3", "v4", "v5"}
+	for _, tag := range allTags {
+		manifestURL, err := env.builder.BuildManifestURL(imageName, tag)
+		checkErr(t, err, "building manifest url")
+
+		resp := putManifest(t, "putting manifest by tag", manifestURL, m)
+		checkResponse(t, "putting manifest by tag", resp, http.StatusCreated)
+	}
+
+	getTags := func(msg, u string) (tagsAPIResponse, string) {
+		resp, err := http.Get(u)
+		checkErr(t, err, msg)
+		defer resp.Body.Close()
+
+		checkResponse(t, msg, resp, http.StatusOK)
+
+		var tagsResponse tagsAPIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&tagsResponse); err != nil {
+			t.Fatalf("unexpected error decoding tags response: %v", err)
+		}
+
+		if tagsResponse.Name != imageName {
+			t.Fatalf("unexpected name in tags response: %q != %q", tagsResponse.Name, imageName)
+		}
+
+		return tagsResponse, resp.Header.Get("Link")
+	}
+
+	tagsURL, err := env.builder.BuildTagsURL(imageName, url.Values{"n": []string{"2"}})
+	checkErr(t, err, "building tags url")
+
+	var pages [][]string
+	for tagsURL != "" {
+		tagsResponse, link := getTags("listing tags page", tagsURL)
+		pages = append(pages, tagsResponse.Tags)
+
+		if link == "" {
+			break
+		}
+
+		matches := regexp.MustCompile(`^<(.*)>; rel="next"$`).FindStringSubmatch(link)
+		if len(matches) != 2 {
+			t.Fatalf("unexpected link header: %q", link)
+		}
+
+		next, err := url.Parse(matches[1])
+		checkErr(t, err, "parsing link header")
+
+		base, err := url.Parse(tagsURL)
+		checkErr(t, err, "parsing tags url")
+
+		tagsURL = base.ResolveReference(next).String()
+	}
+
+	if expected := [][]string{{"v1", "v2"}, {"v3", "v4"}, {"v5"}}; !reflect.DeepEqual(pages, expected) {
+		t.Fatalf("unexpected tag pages: %v != %v", pages, expected)
+	}
+
+	tagsURL, err = env.builder.BuildTagsURL(imageName)
+	checkErr(t, err, "building tags url")
+
+	tagsResponse, link := getTags("listing all tags", tagsURL)
+	if link != "" {
+		t.Fatalf("unexpected link header listing all tags: %q", link)
+	}
+
+	if !reflect.DeepEqual(tagsResponse.Tags, allTags) {
+		t.Fatalf("unexpected tags: %v != %v", tagsResponse.Tags, allTags)
+	}
+
+	tagsURL, err = env.builder.BuildTagsURL(imageName, url.Values{"last": []string{"v3"}})
+	checkErr(t, err, "building tags url")
+
+	tagsResponse, _ = getTags("listing tags after last", tagsURL)
+	if expected := []string{"v4", "v5"}; !reflect.DeepEqual(tagsResponse.Tags, expected) {
+		t.Fatalf("unexpected tags after v3: %v != %v", tagsResponse.Tags, expected)
+	}
+}
+
+// TestQuota checks that blob uploads, mounts and manifest puts exceeding the
+// quota of a repository or namespace are denied, and that usage is reported.
+func TestQuota(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Quota: configuration.Quota{
+			Repositories: map[string]int64{"team/app": 1100},
+			Namespaces:   map[string]int64{"team/": 1500},
+		},
+	}
+	config.HTTP.Headers = headerConfig
+	env := newTestEnvWithConfig(t, &config)
+
+	pushBlob := func(name string, p []byte) *http.Response {
+		dgst, err := digest.FromBytes(p)
+		checkErr(t, err, "digesting blob")
+
+		uploadURLBase, _ := startPushLayer(t, env.builder, name)
+		resp, err := doPushLayer(t, env.builder, name, dgst, uploadURLBase, bytes.NewReader(p))
+		checkErr(t, err, "pushing blob")
+		return resp
+	}
+
+	randomBlob := func() []byte {
+		p := make([]byte, 512)
+		if _, err := rand.Read(p); err != nil {
+			t.Fatalf("unexpected error generating blob: %v", err)
+		}
+		return p
+	}
+
+	first := randomBlob()
+	for _, p := range [][]byte{first, randomBlob()} {
+		resp := pushBlob("team/app", p)
+		checkResponse(t, "pushing blob within quota", resp, http.StatusCreated)
+		resp.Body.Close()
+	}
+
+	resp := pushBlob("team/app", randomBlob())
+	defer resp.Body.Close()
+	checkResponse(t, "pushing blob over repository quota", resp, http.StatusForbidden)
+	checkBodyHasErrorCodes(t, "pushing blob over repository quota", resp, v2.ErrorCodeDeniedQuota)
+
+	// Blobs already in the repository use no more storage.
+	resp = pushBlob("team/app", first)
+	defer resp.Body.Close()
+	checkResponse(t, "pushing linked blob", resp, http.StatusCreated)
+
+	resp = pushBlob("team/other", randomBlob())
+	defer resp.Body.Close()
+	checkResponse(t, "pushing blob over namespace quota", resp, http.StatusForbidden)
+	checkBodyHasErrorCodes(t, "pushing blob over namespace quota", resp, v2.ErrorCodeDeniedQuota)
+
+	firstDigest, err := digest.FromBytes(first)
+	checkErr(t, err, "digesting blob")
+
+	mountURL, err := env.builder.BuildBlobUploadURL("team/other", url.Values{
+		"mount": []string{firstDigest.String()},
+		"from":  []string{"team/app"},
+	})
+	checkErr(t, err, "building mount url")
+
+	resp, err = http.Post(mountURL, "", nil)
+	checkErr(t, err, "mounting blob")
+	defer resp.Body.Close()
+	checkResponse(t, "mounting blob over namespace quota", resp, http.StatusForbidden)
+	checkBodyHasErrorCodes(t, "mounting blob over namespace quota", resp, v2.ErrorCodeDeniedQuota)
+
+	// Repositories outside of the namespace are not limited.
+	resp = pushBlob("other/app", randomBlob())
+	defer resp.Body.Close()
+	checkResponse(t, "pushing blob without quota", resp, http.StatusCreated)
+
+	m, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    distribution.Descriptor{MediaType: schema2.ConfigMediaType, Size: int64(len(first)), Digest: firstDigest},
+	})
+	checkErr(t, err, "building manifest")
+
+	manifestURL, err := env.builder.BuildManifestURL("team/app", "latest")
+	checkErr(t, err, "building manifest url")
+
+	resp = putManifest(t, "putting manifest over repository quota", manifestURL, m)
+	defer resp.Body.Close()
+	checkResponse(t, "putting manifest over repository quota", resp, http.StatusForbidden)
+	checkBodyHasErrorCodes(t, "putting manifest over repository quota", resp, v2.ErrorCodeDeniedQuota)
+
+	usageURL, err := env.builder.BuildUsageURL("team/app")
+	checkErr(t, err, "building usage url")
+
+	resp, err = http.Get(usageURL)
+	checkErr(t, err, "fetching usage")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching usage", resp, http.StatusOK)
+
+	var usage usageAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil {
+		t.Fatalf("error decoding usage response: %v", err)
+	}
+
+	expected := usageAPIResponse{
+		Name:  "team/app",
+		Size:  1024,
+		Limit: 1100,
+		Namespaces: []namespaceUsage{
+			{Namespace: "team", Size: 1024, Limit: 1500},
+		},
+	}
+	if !reflect.DeepEqual(usage, expected) {
+		t.Fatalf("unexpected usage: %#v != %#v", usage, expected)
+	}
+}
+
+// TestRateLimitAPI checks that requests over the rate limit of a route are
+// rejected, that other repositories and routes are not affected, and that
+// remote addresses are limited before authorization.
+func TestRateLimitAPI(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		RateLimit: configuration.RateLimit{
+			Routes: map[string]configuration.RouteRateLimit{
+				v2.RouteNameManifest: {Rate: 0.01, Burst: 2, By: []string{"repository"}},
+				v2.RouteNameTags:     {Rate: 0.01, Burst: 1, By: []string{"address"}},
+			},
+		},
+	}
+	config.HTTP.Headers = headerConfig
+	env := newTestEnvWithConfig(t, &config)
+
+	getManifest := func(name string) *http.Response {
+		manifestURL, err := env.builder.BuildManifestURL(name, "latest")
+		checkErr(t, err, "building manifest url")
+
+		resp, err := http.Get(manifestURL)
+		checkErr(t, err, "fetching manifest")
+		return resp
+	}
+
+	for i := 0; i < 2; i++ {
+		resp := getManifest("foo/bar")
+		checkResponse(t, "fetching manifest within rate limit", resp, http.StatusNotFound)
+		resp.Body.Close()
+	}
+
+	resp := getManifest("foo/bar")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching manifest over rate limit", resp, http.StatusTooManyRequests)
+	checkHeaders(t, resp, http.Header{
+		"Retry-After": []string{"100"},
+	})
+	checkBodyHasErrorCodes(t, "fetching manifest over rate limit", resp, errcode.ErrorCodeTooManyRequests)
+
+	resp = getManifest("foo/other")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching manifest of another repository", resp, http.StatusNotFound)
+
+	tagsURL, err := env.builder.BuildTagsURL("foo/bar")
+	checkErr(t, err, "building tags url")
+
+	resp, err = http.Get(tagsURL)
+	checkErr(t, err, "fetching tags")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching tags within rate limit", resp, http.StatusNotFound)
+
+	// Requests failing authorization take from the bucket of their address.
+	env.app.accessController = deniedAccessController{}
+	resp, err = http.Get(tagsURL)
+	checkErr(t, err, "fetching tags")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching tags over rate limit", resp, http.StatusTooManyRequests)
+	checkBodyHasErrorCodes(t, "fetching tags over rate limit", resp, errcode.ErrorCodeTooManyRequests)
+}
+
+// deniedAccessController denies any access.
+type deniedAccessController struct{}
+
+func (deniedAccessController) Authorized(ctx context.Context, accessItems ...auth.Access) (context.Context, error) {
+	return nil, auth.ErrAccessDenied
+}
+
 func newTestEnvMirror(t *testing.T, deleteEnabled bool) *testEnv {
 	config := configuration.Configuration{
 		Storage: configuration.Storage{
@@ -1149,9 +2091,19 @@ func newTestEnvWithConfig(t *testing.T, config *configuration.Configuration) *te
 }
 
 func putManifest(t *testing.T, msg, url string, v interface{}) *http.Response {
-	var body []byte
+	var (
+		body      []byte
+		mediaType string
+	)
 	if sm, ok := v.(*schema1.SignedManifest); ok {
+		// Sent without a media type, like older clients do.
 		body = sm.Raw
+	} else if m, ok := v.(distribution.Manifest); ok {
+		var err error
+		mediaType, body, err = m.Payload()
+		if err != nil {
+			t.Fatalf("unexpected error getting payload of %v: %v", v, err)
+		}
 	} else {
 		var err error
 		body, err = json.MarshalIndent(v, "", "   ")
@@ -1165,6 +2117,10 @@ func putManifest(t *testing.T, msg, url string, v interface{}) *http.Response {
 		t.Fatalf("error creating request for %s: %v", msg, err)
 	}
 
+	if mediaType != "" {
+		req.Header.Set("Content-Type", mediaType)
+	}
+
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		t.Fatalf("error doing put request while %s: %v", msg, err)
@@ -1484,10 +2440,7 @@ func createRepository(env *testEnv, t *testing.T, imageName string, tag string)
 		t.Fatalf("unexpected error signing manifest: %v", err)
 	}
 
-	payload, err := signedManifest.Payload()
-	checkErr(t, err, "getting manifest payload")
-
-	dgst, err := digest.FromBytes(payload)
+	dgst, err := digest.FromBytes(signedManifest.Canonical)
 	checkErr(t, err, "digesting manifest")
 
 	manifestDigestURL, err := env.builder.BuildManifestURL(imageName, dgst.String())
@@ -9,12 +9,14 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/configuration"
 	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/health"
 	"github.com/docker/distribution/health/checks"
 	"github.com/docker/distribution/notifications"
@@ -30,6 +32,8 @@ import (
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/factory"
 	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+	"github.com/docker/distribution/tracing"
+	"github.com/docker/libtrust"
 	"github.com/garyburd/redigo/redis"
 	"github.com/gorilla/mux"
 	"golang.org/x/net/context"
@@ -48,7 +52,9 @@ const defaultCheckInterval = 10 * time.Second
 type App struct {
 	context.Context
 
-	Config *configuration.Configuration
+	// config is the running configuration, replaced as a whole when it is
+	// reloaded. It is read through Config.
+	config *configuration.Configuration
 
 	router           *mux.Router                 // main application router, configured with dispatchers
 	driver           storagedriver.StorageDriver // driver maintains the app global storage driver instance.
@@ -59,19 +65,67 @@ type App struct {
 	// the configuration. Only the Scheme and Host fields are used.
 	httpHost url.URL
 
-	// events contains notification related configuration.
+	// events contains notification related configuration. The sink is
+	// replaced when the configuration is reloaded.
 	events struct {
-		sink   notifications.Sink
+		sink   *reloadableSink
 		source notifications.SourceRecord
 	}
 
 	redis *redis.Pool
 
+	// trustKey is a deprecated key used to sign manifests converted to
+	// schema1 for backward compatibility. It should not be used for any
+	// other purposes.
+	trustKey libtrust.PrivateKey
+
 	// true if this registry is configured as a pull through cache
 	isCache bool
 
 	// true if the registry is in a read-only maintenance mode
 	readOnly bool
+
+	// quotas limits the storage used by repositories and namespaces.
+	quotas quotas
+
+	// rateLimits maps route names to the rate limit of their requests,
+	// enforced with the buckets of rateLimiter.
+	rateLimits  map[string]routeRateLimit
+	rateLimiter rateLimiter
+
+	// trustedProxies are the proxies whose forwarded headers give the
+	// remote address of rate limited requests.
+	trustedProxies []*net.IPNet
+
+	// mu guards the configuration and the access controller, which are
+	// replaced when the configuration is reloaded.
+	mu sync.RWMutex
+
+	// reloadMu serializes calls to Reload.
+	reloadMu sync.Mutex
+
+	// healthRegistry is the registry the health checks of the app were
+	// registered with by RegisterHealthChecks, under the names in
+	// healthChecks.
+	healthRegistry *health.Registry
+	healthChecks   []string
+
+	// logHooks fires the log hooks of the configuration.
+	logHooks *reloadableLogHooks
+
+	// accessLogger writes a record of each request, nil if the access log is
+	// disabled.
+	accessLogger *accessLogger
+}
+
+// Config returns the running configuration of the app, which must not be
+// modified. The configuration returned is replaced, rather than modified,
+// when the configuration is reloaded.
+func (app *App) Config() *configuration.Configuration {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+
+	return app.config
 }
 
 // NewApp takes a configuration and returns a configured app, ready to serve
@@ -79,7 +133,7 @@ type App struct {
 // handlers accordingly.
 func NewApp(ctx context.Context, configuration *configuration.Configuration) *App {
 	app := &App{
-		Config:  configuration,
+		config:  configuration,
 		Context: ctx,
 		router:  v2.RouterWithPrefix(configuration.HTTP.Prefix),
 		isCache: configuration.Proxy.RemoteURL != "",
@@ -95,6 +149,10 @@ func NewApp(ctx context.Context, configuration *configuration.Configuration) *Ap
 	app.register(v2.RouteNameBlob, blobDispatcher)
 	app.register(v2.RouteNameBlobUpload, blobUploadDispatcher)
 	app.register(v2.RouteNameBlobUploadChunk, blobUploadDispatcher)
+	app.register(v2.RouteNameUsage, usageDispatcher)
+
+	app.configureTracing(configuration)
+	app.configureAccessLog(configuration)
 
 	var err error
 	app.driver, err = factory.Create(configuration.Storage.Type(), configuration.Storage.Parameters())
@@ -134,11 +192,32 @@ func NewApp(ctx context.Context, configuration *configuration.Configuration) *Ap
 		panic(err)
 	}
 
+	app.quotas, err = newQuotas(configuration.Quota)
+	if err != nil {
+		panic(fmt.Sprintf("invalid quota configuration: %v", err))
+	}
+
 	app.configureSecret(configuration)
 	app.configureEvents(configuration)
 	app.configureRedis(configuration)
+	app.configureTokenIssuer(configuration)
+	app.configureRateLimits(configuration)
 	app.configureLogHook(configuration)
 
+	if configuration.Compatibility.Schema1.SigningKeyFile != "" {
+		app.trustKey, err = libtrust.LoadKeyFile(configuration.Compatibility.Schema1.SigningKeyFile)
+		if err != nil {
+			panic(fmt.Sprintf(`could not load schema1 "signingkeyfile": %v`, err))
+		}
+	} else {
+		// Generate an ephemeral key to be used for signing converted
+		// manifests for clients that don't support schema2.
+		app.trustKey, err = libtrust.GenerateECP256PrivateKey()
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	if configuration.HTTP.Host != "" {
 		u, err := url.Parse(configuration.HTTP.Host)
 		if err != nil {
@@ -163,6 +242,25 @@ func NewApp(ctx context.Context, configuration *configuration.Configuration) *Ap
 		}
 	}
 
+	// track usage to enforce quotas
+	if app.quotas.configured() {
+		options = append(options, storage.EnableUsage)
+	}
+
+	// configure the catalog index
+	if c, ok := configuration.Storage["catalog"]; ok {
+		switch v := c["index"].(type) {
+		case bool:
+			if v {
+				options = append(options, storage.EnableCatalogIndex)
+				ctxu.GetLogger(app).Infof("using catalog index")
+			}
+		case nil:
+		default:
+			panic(fmt.Sprintf("invalid type for catalog config: %#v", c))
+		}
+	}
+
 	// configure redirects
 	var redirectDisabled bool
 	if redirectConfig, ok := configuration.Storage["redirect"]; ok {
@@ -228,15 +326,9 @@ func NewApp(ctx context.Context, configuration *configuration.Configuration) *Ap
 		panic(err)
 	}
 
-	authType := configuration.Auth.Type()
-
-	if authType != "" {
-		accessController, err := auth.GetAccessController(configuration.Auth.Type(), configuration.Auth.Parameters())
-		if err != nil {
-			panic(fmt.Sprintf("unable to configure authorization (%s): %v", authType, err))
-		}
-		app.accessController = accessController
-		ctxu.GetLogger(app).Debugf("configured %q access controller", authType)
+	app.accessController, err = app.newAccessController(configuration)
+	if err != nil {
+		panic(err.Error())
 	}
 
 	// configure as a pull through cache
@@ -252,6 +344,32 @@ func NewApp(ctx context.Context, configuration *configuration.Configuration) *Ap
 	return app
 }
 
+// newAccessController returns the access controller of the configuration, nil
+// if authorization isn't configured.
+func (app *App) newAccessController(configuration *configuration.Configuration) (auth.AccessController, error) {
+	authType := configuration.Auth.Type()
+	if authType == "" {
+		return nil, nil
+	}
+
+	accessController, err := auth.GetAccessController(authType, configuration.Auth.Parameters())
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure authorization (%s): %v", authType, err)
+	}
+
+	ctxu.GetLogger(app).Debugf("configured %q access controller", authType)
+	return accessController, nil
+}
+
+// getAccessController returns the access controller authorizing requests,
+// nil if authorization isn't configured.
+func (app *App) getAccessController() auth.AccessController {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+
+	return app.accessController
+}
+
 // RegisterHealthChecks is an awful hack to defer health check registration
 // control to callers. This should only ever be called once per registry
 // process, typically in a main function. The correct way would be register
@@ -263,13 +381,15 @@ func (app *App) RegisterHealthChecks(healthRegistries ...*health.Registry) {
 	if len(healthRegistries) > 1 {
 		panic("RegisterHealthChecks called with more than one registry")
 	}
-	healthRegistry := health.DefaultRegistry
+	app.healthRegistry = health.DefaultRegistry
 	if len(healthRegistries) == 1 {
-		healthRegistry = healthRegistries[0]
+		app.healthRegistry = healthRegistries[0]
 	}
+	app.healthChecks = nil
 
-	if app.Config.Health.StorageDriver.Enabled {
-		interval := app.Config.Health.StorageDriver.Interval
+	config := app.Config()
+	if config.Health.StorageDriver.Enabled {
+		interval := config.Health.StorageDriver.Interval
 		if interval == 0 {
 			interval = defaultCheckInterval
 		}
@@ -279,23 +399,23 @@ func (app *App) RegisterHealthChecks(healthRegistries ...*health.Registry) {
 			return err                          // any error will be treated as failure
 		}
 
-		if app.Config.Health.StorageDriver.Threshold != 0 {
-			healthRegistry.RegisterPeriodicThresholdFunc("storagedriver_"+app.Config.Storage.Type(), interval, app.Config.Health.StorageDriver.Threshold, storageDriverCheck)
+		if config.Health.StorageDriver.Threshold != 0 {
+			app.registerHealthCheck("storagedriver_"+config.Storage.Type(), health.PeriodicThresholdChecker(health.CheckFunc(storageDriverCheck), interval, config.Health.StorageDriver.Threshold))
 		} else {
-			healthRegistry.RegisterPeriodicFunc("storagedriver_"+app.Config.Storage.Type(), interval, storageDriverCheck)
+			app.registerHealthCheck("storagedriver_"+config.Storage.Type(), health.PeriodicChecker(health.CheckFunc(storageDriverCheck), interval))
 		}
 	}
 
-	for _, fileChecker := range app.Config.Health.FileCheckers {
+	for _, fileChecker := range config.Health.FileCheckers {
 		interval := fileChecker.Interval
 		if interval == 0 {
 			interval = defaultCheckInterval
 		}
 		ctxu.GetLogger(app).Infof("configuring file health check path=%s, interval=%d", fileChecker.File, interval/time.Second)
-		healthRegistry.Register(fileChecker.File, health.PeriodicChecker(checks.FileChecker(fileChecker.File), interval))
+		app.registerHealthCheck(fileChecker.File, health.PeriodicChecker(checks.FileChecker(fileChecker.File), interval))
 	}
 
-	for _, httpChecker := range app.Config.Health.HTTPCheckers {
+	for _, httpChecker := range config.Health.HTTPCheckers {
 		interval := httpChecker.Interval
 		if interval == 0 {
 			interval = defaultCheckInterval
@@ -310,14 +430,14 @@ func (app *App) RegisterHealthChecks(healthRegistries ...*health.Registry) {
 
 		if httpChecker.Threshold != 0 {
 			ctxu.GetLogger(app).Infof("configuring HTTP health check uri=%s, interval=%d, threshold=%d", httpChecker.URI, interval/time.Second, httpChecker.Threshold)
-			healthRegistry.Register(httpChecker.URI, health.PeriodicThresholdChecker(checker, interval, httpChecker.Threshold))
+			app.registerHealthCheck(httpChecker.URI, health.PeriodicThresholdChecker(checker, interval, httpChecker.Threshold))
 		} else {
 			ctxu.GetLogger(app).Infof("configuring HTTP health check uri=%s, interval=%d", httpChecker.URI, interval/time.Second)
-			healthRegistry.Register(httpChecker.URI, health.PeriodicChecker(checker, interval))
+			app.registerHealthCheck(httpChecker.URI, health.PeriodicChecker(checker, interval))
 		}
 	}
 
-	for _, tcpChecker := range app.Config.Health.TCPCheckers {
+	for _, tcpChecker := range config.Health.TCPCheckers {
 		interval := tcpChecker.Interval
 		if interval == 0 {
 			interval = defaultCheckInterval
@@ -327,14 +447,21 @@ func (app *App) RegisterHealthChecks(healthRegistries ...*health.Registry) {
 
 		if tcpChecker.Threshold != 0 {
 			ctxu.GetLogger(app).Infof("configuring TCP health check addr=%s, interval=%d, threshold=%d", tcpChecker.Addr, interval/time.Second, tcpChecker.Threshold)
-			healthRegistry.Register(tcpChecker.Addr, health.PeriodicThresholdChecker(checker, interval, tcpChecker.Threshold))
+			app.registerHealthCheck(tcpChecker.Addr, health.PeriodicThresholdChecker(checker, interval, tcpChecker.Threshold))
 		} else {
 			ctxu.GetLogger(app).Infof("configuring TCP health check addr=%s, interval=%d", tcpChecker.Addr, interval/time.Second)
-			healthRegistry.Register(tcpChecker.Addr, health.PeriodicChecker(checker, interval))
+			app.registerHealthCheck(tcpChecker.Addr, health.PeriodicChecker(checker, interval))
 		}
 	}
 }
 
+// registerHealthCheck registers the checker with the health registry of the
+// app, keeping its name to unregister it when the configuration is reloaded.
+func (app *App) registerHealthCheck(name string, check health.Checker) {
+	app.healthRegistry.Register(name, check)
+	app.healthChecks = append(app.healthChecks, name)
+}
+
 // register a handler with the application, by route name. The handler will be
 // passed through the application filters and context will be constructed at
 // request time.
@@ -351,6 +478,29 @@ func (app *App) register(routeName string, dispatch dispatchFunc) {
 
 // configureEvents prepares the event sink for action.
 func (app *App) configureEvents(configuration *configuration.Configuration) {
+	app.events.sink = &reloadableSink{sink: app.newEventSink(configuration)}
+
+	// Populate registry event source
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = configuration.HTTP.Addr
+	} else {
+		// try to pick the port off the config
+		_, port, err := net.SplitHostPort(configuration.HTTP.Addr)
+		if err == nil {
+			hostname = net.JoinHostPort(hostname, port)
+		}
+	}
+
+	app.events.source = notifications.SourceRecord{
+		Addr:       hostname,
+		InstanceID: ctxu.GetStringValue(app, "instance.id"),
+	}
+}
+
+// newEventSink returns a sink broadcasting events to the notification
+// endpoints of the configuration.
+func (app *App) newEventSink(configuration *configuration.Configuration) notifications.Sink {
 	// Configure all of the endpoint sinks.
 	var sinks []notifications.Sink
 	for _, endpoint := range configuration.Notifications.Endpoints {
@@ -374,24 +524,7 @@ func (app *App) configureEvents(configuration *configuration.Configuration) {
 	// replacing broadcaster with a rabbitmq implementation. It's recommended
 	// that the registry instances also act as the workers to keep deployment
 	// simple.
-	app.events.sink = notifications.NewBroadcaster(sinks...)
-
-	// Populate registry event source
-	hostname, err := os.Hostname()
-	if err != nil {
-		hostname = configuration.HTTP.Addr
-	} else {
-		// try to pick the port off the config
-		_, port, err := net.SplitHostPort(configuration.HTTP.Addr)
-		if err == nil {
-			hostname = net.JoinHostPort(hostname, port)
-		}
-	}
-
-	app.events.source = notifications.SourceRecord{
-		Addr:       hostname,
-		InstanceID: ctxu.GetStringValue(app, "instance.id"),
-	}
+	return notifications.NewBroadcaster(sinks...)
 }
 
 func (app *App) configureRedis(configuration *configuration.Configuration) {
@@ -476,6 +609,61 @@ func (app *App) configureRedis(configuration *configuration.Configuration) {
 	}))
 }
 
+// configureRateLimits sets up the request rate limits. The token buckets are
+// kept in redis if it is configured, so that they are shared by all registry
+// instances, and in memory otherwise.
+func (app *App) configureRateLimits(configuration *configuration.Configuration) {
+	limits, err := newRateLimits(configuration.RateLimit, app.router)
+	if err != nil {
+		panic(fmt.Sprintf("invalid rate limit configuration: %v", err))
+	}
+
+	proxies, err := parseTrustedProxies(configuration.RateLimit.TrustedProxies)
+	if err != nil {
+		panic(fmt.Sprintf("invalid rate limit configuration: %v", err))
+	}
+
+	if len(limits) == 0 {
+		return
+	}
+	app.rateLimits = limits
+	app.trustedProxies = proxies
+
+	if app.redis != nil {
+		app.rateLimiter = &redisRateLimiter{pool: app.redis}
+		ctxu.GetLogger(app).Infof("using redis rate limiter")
+	} else {
+		app.rateLimiter = newMemoryRateLimiter()
+		ctxu.GetLogger(app).Infof("using inmemory rate limiter")
+	}
+}
+
+// configureTracing sets up the export of the spans of the registry, if an
+// exporter is configured.
+func (app *App) configureTracing(configuration *configuration.Configuration) {
+	if configuration.Tracing.Exporter == "" {
+		return
+	}
+
+	exporter, err := tracing.Get(configuration.Tracing.Exporter, configuration.Tracing.Options)
+	if err != nil {
+		panic(fmt.Sprintf("unable to configure span exporter (%s): %v", configuration.Tracing.Exporter, err))
+	}
+
+	app.Context = ctxu.WithSpanExporter(app.Context, exporter)
+	ctxu.GetLogger(app).Infof("exporting spans to %s", configuration.Tracing.Exporter)
+}
+
+// configureAccessLog sets up the access log of the registry, unless disabled.
+func (app *App) configureAccessLog(configuration *configuration.Configuration) {
+	logger, err := newAccessLogger(configuration.Log.AccessLog)
+	if err != nil {
+		panic(fmt.Sprintf("unable to configure access log: %v", err))
+	}
+
+	app.accessLogger = logger
+}
+
 // configureLogHook prepares logging hook parameters.
 func (app *App) configureLogHook(configuration *configuration.Configuration) {
 	entry, ok := ctxu.GetLogger(app).(*log.Entry)
@@ -484,8 +672,7 @@ func (app *App) configureLogHook(configuration *configuration.Configuration) {
 		return
 	}
 
-	logger := entry.Logger
-
+	var hooks []log.Hook
 	for _, configHook := range configuration.Log.Hooks {
 		if !configHook.Disabled {
 			switch configHook.Type {
@@ -500,11 +687,19 @@ func (app *App) configureLogHook(configuration *configuration.Configuration) {
 					From:     configHook.MailOptions.From,
 					To:       configHook.MailOptions.To,
 				}
-				logger.Hooks.Add(hook)
+				hooks = append(hooks, hook)
 			default:
 			}
 		}
 	}
+
+	// The logger only gets the hooks of the app once, so that they can be
+	// replaced on reload while the logger fires them.
+	if app.logHooks == nil {
+		app.logHooks = &reloadableLogHooks{}
+		entry.Logger.Hooks.Add(app.logHooks)
+	}
+	app.logHooks.replace(hooks)
 }
 
 // configureSecret creates a random secret if a secret wasn't included in the
@@ -525,7 +720,7 @@ func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Instantiate an http context here so we can track the error codes
 	// returned by the request router.
-	ctx := defaultContextManager.context(app, w, r)
+	ctx := defaultContextManager.context(app.withAccessRecord(app, r), w, r)
 
 	defer func() {
 		status, ok := ctx.Value("http.response.status").(int)
@@ -534,6 +729,7 @@ func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 	defer defaultContextManager.release(ctx)
+	defer app.logAccess(ctx)
 
 	// NOTE(stevvooe): Total hack to get instrumented responsewriter from context.
 	var err error
@@ -547,6 +743,15 @@ func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	app.router.ServeHTTP(w, r)
 }
 
+// addHeaders adds the headers of the configuration to the response.
+func (app *App) addHeaders(w http.ResponseWriter) {
+	for headerName, headerValues := range app.Config().HTTP.Headers {
+		for _, value := range headerValues {
+			w.Header().Add(headerName, value)
+		}
+	}
+}
+
 // dispatchFunc takes a context and request and returns a constructed handler
 // for the route. The dispatcher will use this to dynamically create request
 // specific handlers for each endpoint without creating a new router for each
@@ -560,19 +765,32 @@ type dispatchFunc func(ctx *Context, r *http.Request) http.Handler
 // handler, using the dispatch factory function.
 func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		for headerName, headerValues := range app.Config.HTTP.Headers {
-			for _, value := range headerValues {
-				w.Header().Add(headerName, value)
-			}
-		}
+		start := time.Now()
+
+		app.addHeaders(w)
 
 		context := app.context(w, r)
+		defer observeRequest(context, r, start)
+		defer traceRequest(context, r)()
+		defer recordRoute(context, r)
+
+		// The remote address is limited first, so that requests failing
+		// authorization are limited as well.
+		if err := app.rateLimited(w, r, context, rateLimitAddress); err != nil {
+			ctxu.GetLogger(context).Warnf("rejecting request: %v", err)
+			return
+		}
 
 		if err := app.authorized(w, r, context); err != nil {
 			ctxu.GetLogger(context).Warnf("error authorizing context: %v", err)
 			return
 		}
 
+		if err := app.rateLimited(w, r, context, rateLimitUser, rateLimitRepository); err != nil {
+			ctxu.GetLogger(context).Warnf("rejecting request: %v", err)
+			return
+		}
+
 		// Add username to request logging
 		context.Context = ctxu.WithLogger(context.Context, ctxu.GetLogger(context.Context, "auth.user.name"))
 
@@ -600,7 +818,7 @@ func (app *App) dispatcher(dispatch dispatchFunc) http.Handler {
 				repository,
 				app.eventBridge(context, r))
 
-			context.Repository, err = applyRepoMiddleware(context.Context, context.Repository, app.Config.Middleware["repository"])
+			context.Repository, err = applyRepoMiddleware(context.Context, context.Repository, app.Config().Middleware["repository"])
 			if err != nil {
 				ctxu.GetLogger(context).Errorf("error initializing repository middleware: %v", err)
 				context.Errors = append(context.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
@@ -689,14 +907,25 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 	ctxu.GetLogger(context).Debug("authorizing request")
 	repo := getName(context)
 
-	if app.accessController == nil {
+	accessController := app.getAccessController()
+	if accessController == nil {
 		return nil // access controller is not enabled.
 	}
 
+	// The token endpoint authenticates its users itself, to issue the
+	// tokens the access controller asks for.
+	if route := mux.CurrentRoute(r); route != nil && route.GetName() == routeNameToken {
+		return nil
+	}
+
 	var accessRecords []auth.Access
 
 	if repo != "" {
-		accessRecords = appendAccessRecords(accessRecords, r.Method, repo)
+		if isTagDelete(r) {
+			accessRecords = appendTagDeleteAccessRecord(accessRecords, repo)
+		} else {
+			accessRecords = appendAccessRecords(accessRecords, r.Method, repo)
+		}
 	} else {
 		// Only allow the name not to be set on the base route.
 		if app.nameRequired(r) {
@@ -714,7 +943,9 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 		accessRecords = appendCatalogAccessRecord(accessRecords, r)
 	}
 
-	ctx, err := app.accessController.Authorized(context.Context, accessRecords...)
+	authCtx, span := ctxu.WithSpan(context.Context, "auth.Authorized")
+	ctx, err := accessController.Authorized(authCtx, accessRecords...)
+	span.Finish(err)
 	if err != nil {
 		switch err := err.(type) {
 		case auth.Challenge:
@@ -725,6 +956,15 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 				ctxu.GetLogger(context).Errorf("error serving error json: %v (from %v)", err, context.Errors)
 			}
 		default:
+			if err == auth.ErrAccessDenied {
+				// The user is known, asking for other credentials would
+				// be pointless.
+				if err := errcode.ServeJSON(w, errcode.ErrorCodeDenied.WithDetail(accessRecords)); err != nil {
+					ctxu.GetLogger(context).Errorf("error serving error json: %v (from %v)", err, context.Errors)
+				}
+				break
+			}
+
 			// This condition is a potential security problem either in
 			// the configuration or whatever is backing the access
 			// controller. Just return a bad request with no information
@@ -736,6 +976,12 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 		return err
 	}
 
+	// The spans following authorization belong to the request span, rather
+	// than to the span of the access controller.
+	if sc, ok := ctxu.GetSpanContext(context.Context); ok {
+		ctx = ctxu.WithSpanContext(ctx, sc)
+	}
+
 	// TODO(stevvooe): This pattern needs to be cleaned up a bit. One context
 	// should be replaced by another, rather than replacing the context on a
 	// mutable object.
@@ -758,7 +1004,7 @@ func (app *App) eventBridge(ctx *Context, r *http.Request) notifications.Listene
 func (app *App) nameRequired(r *http.Request) bool {
 	route := mux.CurrentRoute(r)
 	routeName := route.GetName()
-	return route == nil || (routeName != v2.RouteNameBase && routeName != v2.RouteNameCatalog)
+	return route == nil || (routeName != v2.RouteNameBase && routeName != v2.RouteNameCatalog && routeName != routeNameToken)
 }
 
 // apiBase implements a simple yes-man for doing overall checks against the
@@ -808,6 +1054,35 @@ func appendAccessRecords(records []auth.Access, method string, repo string) []au
 	return records
 }
 
+// appendTagDeleteAccessRecord adds the access record for deleting a tag,
+// which only requires the delete action, to the records list.
+func appendTagDeleteAccessRecord(records []auth.Access, repo string) []auth.Access {
+	return append(records,
+		auth.Access{
+			Resource: auth.Resource{
+				Type: "repository",
+				Name: repo,
+			},
+			Action: "delete",
+		})
+}
+
+// isTagDelete reports whether the request deletes a tag, rather than a
+// manifest or blob identified by digest.
+func isTagDelete(r *http.Request) bool {
+	if r.Method != "DELETE" {
+		return false
+	}
+
+	route := mux.CurrentRoute(r)
+	if route == nil || route.GetName() != v2.RouteNameManifest {
+		return false
+	}
+
+	_, err := digest.ParseDigest(mux.Vars(r)["reference"])
+	return err != nil
+}
+
 // Add the access record for the catalog if it's our current route
 func appendCatalogAccessRecord(accessRecords []auth.Access, r *http.Request) []auth.Access {
 	route := mux.CurrentRoute(r)
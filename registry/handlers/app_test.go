@@ -6,10 +6,12 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/docker/distribution/configuration"
 	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/registry/api/errcode"
 	"github.com/docker/distribution/registry/api/v2"
 	"github.com/docker/distribution/registry/auth"
@@ -31,7 +33,7 @@ func TestAppDispatcher(t *testing.T) {
 		t.Fatalf("error creating registry: %v", err)
 	}
 	app := &App{
-		Config:   &configuration.Configuration{},
+		config:   &configuration.Configuration{},
 		Context:  ctx,
 		router:   v2.Router(),
 		driver:   driver,
@@ -214,6 +216,131 @@ func TestNewApp(t *testing.T) {
 	}
 }
 
+// denyingAccessController authenticates every request and denies access.
+type denyingAccessController struct{}
+
+func (denyingAccessController) Authorized(ctx context.Context, access ...auth.Access) (context.Context, error) {
+	return nil, auth.ErrAccessDenied
+}
+
+// TestAppDenied checks that requests denied by the access controller are
+// answered with the DENIED error code, rather than challenged.
+func TestAppDenied(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": nil,
+		},
+	}
+	app := NewApp(context.Background(), &config)
+	app.accessController = denyingAccessController{}
+
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	builder, err := v2.NewURLBuilderFromString(server.URL)
+	if err != nil {
+		t.Fatalf("error creating urlbuilder: %v", err)
+	}
+
+	tagsURL, err := builder.BuildTagsURL("foo/bar")
+	if err != nil {
+		t.Fatalf("error creating tags url: %v", err)
+	}
+
+	resp, err := http.Get(tagsURL)
+	if err != nil {
+		t.Fatalf("unexpected error during GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("unexpected status code: %v != %v", resp.StatusCode, http.StatusForbidden)
+	}
+
+	if resp.Header.Get("WWW-Authenticate") != "" {
+		t.Fatalf("unexpected challenge: %q", resp.Header.Get("WWW-Authenticate"))
+	}
+
+	var errs errcode.Errors
+	if err := json.NewDecoder(resp.Body).Decode(&errs); err != nil {
+		t.Fatalf("error decoding error response: %v", err)
+	}
+
+	if len(errs) != 1 || errs[0].(errcode.ErrorCoder).ErrorCode() != errcode.ErrorCodeDenied {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+// recordingAccessController denies all requests, recording the access they
+// asked for.
+type recordingAccessController struct {
+	mu     sync.Mutex
+	access []auth.Access
+}
+
+func (ac *recordingAccessController) Authorized(ctx context.Context, access ...auth.Access) (context.Context, error) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.access = access
+	return nil, auth.ErrAccessDenied
+}
+
+func (ac *recordingAccessController) recorded() []auth.Access {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return ac.access
+}
+
+// TestAppDeleteAccess checks that deleting a tag only requires the delete
+// action, while deleting manifests and blobs by digest requires all actions.
+func TestAppDeleteAccess(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": nil,
+		},
+	}
+	app := NewApp(context.Background(), &config)
+	accessController := &recordingAccessController{}
+	app.accessController = accessController
+
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	builder, err := v2.NewURLBuilderFromString(server.URL)
+	if err != nil {
+		t.Fatalf("error creating urlbuilder: %v", err)
+	}
+
+	dgst := digest.Digest("sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	tagURL, _ := builder.BuildManifestURL("foo/bar", "latest")
+	manifestURL, _ := builder.BuildManifestURL("foo/bar", dgst.String())
+	blobURL, _ := builder.BuildBlobURL("foo/bar", dgst)
+
+	for _, testcase := range []struct {
+		url    string
+		action string
+	}{
+		{url: tagURL, action: "delete"},
+		{url: manifestURL, action: "*"},
+		{url: blobURL, action: "*"},
+	} {
+		req, _ := http.NewRequest("DELETE", testcase.url, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error during DELETE: %v", err)
+		}
+		resp.Body.Close()
+
+		expected := []auth.Access{{
+			Resource: auth.Resource{Type: "repository", Name: "foo/bar"},
+			Action:   testcase.action,
+		}}
+		if access := accessController.recorded(); !reflect.DeepEqual(access, expected) {
+			t.Fatalf("unexpected access for %s: %v != %v", testcase.url, access, expected)
+		}
+	}
+}
+
 // Test the access record accumulator
 func TestAppendAccessRecords(t *testing.T) {
 	repo := "testRepo"
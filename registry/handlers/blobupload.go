@@ -9,8 +9,10 @@ import (
 	"github.com/docker/distribution"
 	ctxu "github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/api/errcode"
 	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/auth"
 	"github.com/gorilla/handlers"
 )
 
@@ -35,7 +37,7 @@ func blobUploadDispatcher(ctx *Context, r *http.Request) http.Handler {
 	}
 
 	if buh.UUID != "" {
-		state, err := hmacKey(ctx.Config.HTTP.Secret).unpackUploadState(r.FormValue("_state"))
+		state, err := hmacKey(ctx.Config().HTTP.Secret).unpackUploadState(r.FormValue("_state"))
 		if err != nil {
 			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				ctxu.GetLogger(ctx).Infof("error resolving upload: %v", err)
@@ -118,11 +120,31 @@ type blobUploadHandler struct {
 // StartBlobUpload begins the blob upload process and allocates a server-side
 // blob writer session.
 func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Request) {
+	var options []distribution.BlobCreateOption
+
+	fromRepo := r.FormValue("from")
+	mountDigest := r.FormValue("mount")
+
+	if mountDigest != "" && fromRepo != "" {
+		opt, err := buh.createBlobMountOption(fromRepo, mountDigest)
+		if err != nil {
+			buh.Errors = append(buh.Errors, err)
+			return
+		}
+		if opt != nil {
+			options = append(options, opt)
+		}
+	}
+
 	blobs := buh.Repository.Blobs(buh)
-	upload, err := blobs.Create(buh)
+	upload, err := blobs.Create(buh, options...)
 
 	if err != nil {
-		if err == distribution.ErrUnsupported {
+		if ebm, ok := err.(distribution.ErrBlobMounted); ok {
+			if err := buh.writeBlobCreatedHeaders(w, ebm.Descriptor); err != nil {
+				buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+			}
+		} else if err == distribution.ErrUnsupported {
 			buh.Errors = append(buh.Errors, errcode.ErrorCodeUnsupported)
 		} else {
 			buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
@@ -221,6 +243,24 @@ func (buh *blobUploadHandler) PutBlobUploadComplete(w http.ResponseWriter, r *ht
 		return
 	}
 
+	// All data was written, so the offset is the size of the blob.
+	size, err := buh.Upload.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	if err := buh.checkQuota(size, buh.blobLinked(dgst)); err != nil {
+		buh.Errors = append(buh.Errors, err)
+
+		// The upload can't be completed, so release its storage.
+		if err := buh.Upload.Cancel(buh); err != nil {
+			ctxu.GetLogger(buh).Errorf("error canceling upload after error: %v", err)
+		}
+
+		return
+	}
+
 	desc, err := buh.Upload.Commit(buh, distribution.Descriptor{
 		Digest: dgst,
 
@@ -255,17 +295,10 @@ func (buh *blobUploadHandler) PutBlobUploadComplete(w http.ResponseWriter, r *ht
 		return
 	}
 
-	// Build our canonical blob url
-	blobURL, err := buh.urlBuilder.BuildBlobURL(buh.Repository.Name(), desc.Digest)
-	if err != nil {
+	if err := buh.writeBlobCreatedHeaders(w, desc); err != nil {
 		buh.Errors = append(buh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 		return
 	}
-
-	w.Header().Set("Location", blobURL)
-	w.Header().Set("Content-Length", "0")
-	w.Header().Set("Docker-Content-Digest", desc.Digest.String())
-	w.WriteHeader(http.StatusCreated)
 }
 
 // CancelBlobUpload cancels an in-progress upload of a blob.
@@ -284,6 +317,88 @@ func (buh *blobUploadHandler) CancelBlobUpload(w http.ResponseWriter, r *http.Re
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// createBlobMountOption returns the option used to mount the blob identified
+// by mountDigest from fromRepo. A nil option is returned, leaving a regular
+// upload to proceed, when the caller lacks pull access on fromRepo. Errors
+// are returned as errcode values ready to be sent to the client.
+func (buh *blobUploadHandler) createBlobMountOption(fromRepo, mountDigest string) (distribution.BlobCreateOption, error) {
+	dgst, err := digest.ParseDigest(mountDigest)
+	if err != nil {
+		return nil, v2.ErrorCodeDigestInvalid.WithDetail(err)
+	}
+
+	ref, err := reference.ParseNamed(fromRepo)
+	if err != nil {
+		return nil, v2.ErrorCodeNameInvalid.WithDetail(err)
+	}
+
+	canonical, err := reference.WithDigest(ref, dgst)
+	if err != nil {
+		return nil, v2.ErrorCodeDigestInvalid.WithDetail(err)
+	}
+
+	if accessController := buh.App.getAccessController(); accessController != nil {
+		if _, err := accessController.Authorized(buh, auth.Access{
+			Resource: auth.Resource{
+				Type: "repository",
+				Name: fromRepo,
+			},
+			Action: "pull",
+		}); err != nil {
+			ctxu.GetLogger(buh).Debugf("not mounting blob %s from %s: %v", dgst, fromRepo, err)
+			return nil, nil
+		}
+	}
+
+	// A mounted blob is accounted like an uploaded one. If the blob is not
+	// available in fromRepo, the mount fails and the upload is checked.
+	if repo, err := buh.App.registry.Repository(buh, fromRepo); err == nil {
+		if desc, err := repo.Blobs(buh).Stat(buh, dgst); err == nil {
+			if err := buh.checkQuota(desc.Size, buh.blobLinked(dgst)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return func(opts *distribution.CreateOptions) error {
+		opts.Mount.ShouldMount = true
+		opts.Mount.From = canonical
+		return nil
+	}, nil
+}
+
+// blobLinked returns a function reporting whether the blob identified by dgst
+// is already linked into the repository, for use with checkQuota.
+func (buh *blobUploadHandler) blobLinked(dgst digest.Digest) func() (bool, error) {
+	return func() (bool, error) {
+		_, err := buh.Repository.Blobs(buh).Stat(buh, dgst)
+		switch err {
+		case nil:
+			return true, nil
+		case distribution.ErrBlobUnknown:
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+}
+
+// writeBlobCreatedHeaders writes the standard headers describing a newly
+// created blob. A 201 Created is written as well as the canonical URL and
+// blob digest.
+func (buh *blobUploadHandler) writeBlobCreatedHeaders(w http.ResponseWriter, desc distribution.Descriptor) error {
+	blobURL, err := buh.urlBuilder.BuildBlobURL(buh.Repository.Name(), desc.Digest)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", blobURL)
+	w.Header().Set("Content-Length", "0")
+	w.Header().Set("Docker-Content-Digest", desc.Digest.String())
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
 // blobUploadResponse provides a standard request for uploading blobs and
 // chunk responses. This sets the correct headers but the response status is
 // left to the caller. The fresh argument is used to ensure that new blob
@@ -307,7 +422,7 @@ func (buh *blobUploadHandler) blobUploadResponse(w http.ResponseWriter, r *http.
 	buh.State.Offset = offset
 	buh.State.StartedAt = buh.Upload.StartedAt()
 
-	token, err := hmacKey(buh.Config.HTTP.Secret).packUploadState(buh.State)
+	token, err := hmacKey(buh.Config().HTTP.Secret).packUploadState(buh.State)
 	if err != nil {
 		ctxu.GetLogger(buh).Infof("error building upload state token: %s", err)
 		return err
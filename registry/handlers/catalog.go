@@ -7,8 +7,10 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/auth"
 	"github.com/gorilla/handlers"
 )
 
@@ -33,8 +35,6 @@ type catalogAPIResponse struct {
 }
 
 func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
-	var moreEntries = true
-
 	q := r.URL.Query()
 	lastEntry := q.Get("last")
 	maxEntries, err := strconv.Atoi(q.Get("n"))
@@ -42,12 +42,13 @@ func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 		maxEntries = maximumReturnedEntries
 	}
 
-	repos := make([]string, maxEntries)
+	filter := catalogFilter{
+		prefix: q.Get("prefix"),
+		query:  q.Get("q"),
+	}
 
-	filled, err := ch.App.registry.Repositories(ch.Context, repos, lastEntry)
-	if err == io.EOF {
-		moreEntries = false
-	} else if err != nil {
+	repos, moreEntries, err := ch.filteredRepositories(filter, maxEntries, lastEntry)
+	if err != nil {
 		ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 		return
 	}
@@ -55,7 +56,7 @@ func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
 	// Add a link header if there are more entries to retrieve
-	if moreEntries {
+	if moreEntries && len(repos) > 0 {
 		lastEntry = repos[len(repos)-1]
 		urlStr, err := createLinkEntry(r.URL.String(), maxEntries, lastEntry)
 		if err != nil {
@@ -67,24 +68,108 @@ func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 
 	enc := json.NewEncoder(w)
 	if err := enc.Encode(catalogAPIResponse{
-		Repositories: repos[0:filled],
+		Repositories: repos,
 	}); err != nil {
 		ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 		return
 	}
 }
 
+// catalogFilter selects the repositories returned by a catalog search.
+type catalogFilter struct {
+	prefix string // only repositories starting with prefix
+	query  string // only repositories containing query
+}
+
+func (f catalogFilter) empty() bool {
+	return f.prefix == "" && f.query == ""
+}
+
+func (f catalogFilter) matches(name string) bool {
+	return strings.HasPrefix(name, f.prefix) && strings.Contains(name, f.query)
+}
+
+// filteredRepositories pages through the catalog after last, collecting up
+// to maxEntries repositories matching filter which the caller may pull, an
+// empty filter matching every repository. It also reports whether more
+// matching repositories may follow.
+func (ch *catalogHandler) filteredRepositories(filter catalogFilter, maxEntries int, last string) ([]string, bool, error) {
+	matched := []string{}
+	if maxEntries == 0 {
+		return matched, false, nil
+	}
+
+	// All names with the prefix sort after the prefix without its last
+	// byte, so the catalog can be entered right before the first match.
+	if filter.prefix != "" {
+		if start := filter.prefix[:len(filter.prefix)-1]; last < start {
+			last = start
+		}
+	}
+
+	batch := make([]string, maximumReturnedEntries)
+	for {
+		filled, err := ch.App.registry.Repositories(ch.Context, batch, last)
+		if err != nil && err != io.EOF {
+			return nil, false, err
+		}
+		eof := err == io.EOF
+
+		for i, name := range batch[:filled] {
+			last = name
+
+			if name > filter.prefix && !strings.HasPrefix(name, filter.prefix) {
+				// The catalog is sorted, so no later name has the prefix.
+				return matched, false, nil
+			}
+
+			if !filter.matches(name) || !ch.canPull(name) {
+				continue
+			}
+
+			matched = append(matched, name)
+			if len(matched) == maxEntries {
+				return matched, !eof || i < filled-1, nil
+			}
+		}
+
+		if eof {
+			return matched, false, nil
+		}
+	}
+}
+
+// canPull reports whether the caller may pull the named repository, as
+// checked by the access checker of the context authorized for the catalog,
+// without authenticating the caller again. Without an access checker, such
+// as under token authentication, where the catalog access is granted by the
+// token server, every repository is listed.
+func (ch *catalogHandler) canPull(name string) bool {
+	checker, ok := auth.GetAccessChecker(ch.Context)
+	if !ok {
+		return true
+	}
+
+	return checker(auth.Access{
+		Resource: auth.Resource{
+			Type: "repository",
+			Name: name,
+		},
+		Action: "pull",
+	})
+}
+
 // Use the original URL from the request to create a new URL for
-// the link header
+// the link header, keeping any filters of the request.
 func createLinkEntry(origURL string, maxEntries int, lastEntry string) (string, error) {
 	calledURL, err := url.Parse(origURL)
 	if err != nil {
 		return "", err
 	}
 
-	v := url.Values{}
-	v.Add("n", strconv.Itoa(maxEntries))
-	v.Add("last", lastEntry)
+	v := calledURL.Query()
+	v.Set("n", strconv.Itoa(maxEntries))
+	v.Set("last", lastEntry)
 
 	calledURL.RawQuery = v.Encode()
 
@@ -2,19 +2,21 @@ package handlers
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
 	"strings"
 
 	"github.com/docker/distribution"
 	ctxu "github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
 	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/distribution/registry/api/errcode"
 	"github.com/docker/distribution/registry/api/v2"
 	"github.com/gorilla/handlers"
-	"golang.org/x/net/context"
 )
 
 // imageManifestDispatcher takes the request context and builds the
@@ -33,7 +35,8 @@ func imageManifestDispatcher(ctx *Context, r *http.Request) http.Handler {
 	}
 
 	mhandler := handlers.MethodHandler{
-		"GET": http.HandlerFunc(imageManifestHandler.GetImageManifest),
+		"GET":  http.HandlerFunc(imageManifestHandler.GetImageManifest),
+		"HEAD": http.HandlerFunc(imageManifestHandler.GetImageManifest),
 	}
 
 	if !ctx.readOnly {
@@ -62,42 +65,125 @@ func (imh *imageManifestHandler) GetImageManifest(w http.ResponseWriter, r *http
 		return
 	}
 
-	var sm *schema1.SignedManifest
 	if imh.Tag != "" {
-		sm, err = manifests.GetByTag(imh.Tag)
-	} else {
-		if etagMatch(r, imh.Digest.String()) {
-			w.WriteHeader(http.StatusNotModified)
+		desc, err := imh.Repository.Tags(imh).Get(imh, imh.Tag)
+		if err != nil {
+			imh.Errors = append(imh.Errors, v2.ErrorCodeManifestUnknown.WithDetail(err))
 			return
 		}
-		sm, err = manifests.Get(imh.Digest)
+		imh.Digest = desc.Digest
 	}
 
+	manifest, err := manifests.Get(imh.Digest)
 	if err != nil {
 		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestUnknown.WithDetail(err))
 		return
 	}
 
-	// Get the digest, if we don't already have it.
-	if imh.Digest == "" {
-		dgst, err := digestManifest(imh, sm)
+	accepted := acceptedMediaTypes(r)
+
+	// OCI content has no older format it could be converted to, so it is
+	// only served to clients that accept it.
+	switch manifest.(type) {
+	case *ocischema.DeserializedManifest, *ocischema.DeserializedImageIndex:
+		mediaType, _, err := manifest.Payload()
 		if err != nil {
-			imh.Errors = append(imh.Errors, v2.ErrorCodeDigestInvalid.WithDetail(err))
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 			return
 		}
-		if etagMatch(r, dgst.String()) {
-			w.WriteHeader(http.StatusNotModified)
+
+		if !accepted[mediaType] {
+			imh.Errors = append(imh.Errors, v2.ErrorCodeManifestUnknown.WithDetail(fmt.Sprintf("manifest of type %s is not accepted by the client", mediaType)))
+			return
+		}
+	}
+
+	// Clients that don't accept manifest lists get the manifest for the
+	// default platform instead.
+	if manifestList, ok := manifest.(*manifestlist.DeserializedManifestList); ok && !accepted[manifestlist.ManifestListMediaType] {
+		manifest, err = imh.defaultPlatformManifest(manifests, manifestList)
+		if err != nil {
+			imh.Errors = append(imh.Errors, v2.ErrorCodeManifestUnknown.WithDetail(err))
 			return
 		}
+	}
 
-		imh.Digest = dgst
+	// Clients that don't accept schema2 get a schema1 manifest synthesized
+	// from the image configuration.
+	if schema2Manifest, ok := manifest.(*schema2.DeserializedManifest); ok && !accepted[schema2.ManifestMediaType] {
+		manifest, err = imh.convertSchema2Manifest(schema2Manifest)
+		if err != nil {
+			ctxu.GetLogger(imh).Errorf("error converting schema2 manifest to schema1: %v", err)
+			imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithDetail(err))
+			return
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.Header().Set("Content-Length", fmt.Sprint(len(sm.Raw)))
-	w.Header().Set("Docker-Content-Digest", imh.Digest.String())
-	w.Header().Set("Etag", fmt.Sprintf(`"%s"`, imh.Digest))
-	w.Write(sm.Raw)
+	// The digest is that of the manifest actually served, which differs
+	// from the requested one if the manifest was converted.
+	desc, err := distribution.DescribeManifest(manifest)
+	if err != nil {
+		ctxu.GetLogger(imh).Errorf("error digesting manifest: %v", err)
+		imh.Errors = append(imh.Errors, v2.ErrorCodeDigestInvalid.WithDetail(err))
+		return
+	}
+
+	if etagMatch(r, desc.Digest.String()) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	ct, p, err := manifest.Payload()
+	if err != nil {
+		imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", ct)
+	w.Header().Set("Content-Length", fmt.Sprint(len(p)))
+	w.Header().Set("Docker-Content-Digest", desc.Digest.String())
+	w.Header().Set("Etag", fmt.Sprintf(`"%s"`, desc.Digest))
+	w.Write(p)
+}
+
+// defaultPlatformManifest returns the manifest referenced by manifestList
+// for linux/amd64, the only platform older clients know about.
+func (imh *imageManifestHandler) defaultPlatformManifest(manifests distribution.ManifestService, manifestList *manifestlist.DeserializedManifestList) (distribution.Manifest, error) {
+	for _, descriptor := range manifestList.Manifests {
+		if descriptor.Platform.Architecture == "amd64" && descriptor.Platform.OS == "linux" {
+			return manifests.Get(descriptor.Digest)
+		}
+	}
+
+	return nil, fmt.Errorf("manifest list has no manifest for linux/amd64")
+}
+
+// convertSchema2Manifest synthesizes a schema1 manifest from a schema2
+// manifest and its image configuration, signed with the registry's key.
+func (imh *imageManifestHandler) convertSchema2Manifest(schema2Manifest *schema2.DeserializedManifest) (distribution.Manifest, error) {
+	blobs := imh.Repository.Blobs(imh)
+	configJSON, err := blobs.Get(imh, schema2Manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema1.FromConfig(imh, blobs, imh.trustKey, imh.Repository.Name(), imh.Tag, configJSON, schema2Manifest.Layers)
+}
+
+// acceptedMediaTypes returns the set of media types listed in the Accept
+// headers of the request, without their parameters.
+func acceptedMediaTypes(r *http.Request) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, acceptHeader := range r.Header["Accept"] {
+		for _, mediaType := range strings.Split(acceptHeader, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(mediaType))
+			if err != nil {
+				continue
+			}
+			accepted[mediaType] = true
+		}
+	}
+	return accepted
 }
 
 func etagMatch(r *http.Request, etag string) bool {
@@ -124,30 +210,27 @@ func (imh *imageManifestHandler) PutImageManifest(w http.ResponseWriter, r *http
 		return
 	}
 
-	var manifest schema1.SignedManifest
-	if err := json.Unmarshal(jsonBuf.Bytes(), &manifest); err != nil {
-		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithDetail(err))
-		return
-	}
-
-	dgst, err := digestManifest(imh, &manifest)
+	// The format of the manifest is chosen by the Content-Type header,
+	// falling back to schema1 for clients that don't set it.
+	manifest, desc, err := distribution.UnmarshalManifest(r.Header.Get("Content-Type"), jsonBuf.Bytes())
 	if err != nil {
-		imh.Errors = append(imh.Errors, v2.ErrorCodeDigestInvalid.WithDetail(err))
+		imh.Errors = append(imh.Errors, v2.ErrorCodeManifestInvalid.WithDetail(err))
 		return
 	}
 
 	// Validate manifest tag or digest matches payload
 	if imh.Tag != "" {
-		if manifest.Tag != imh.Tag {
-			ctxu.GetLogger(imh).Errorf("invalid tag on manifest payload: %q != %q", manifest.Tag, imh.Tag)
+		// Schema 1 manifests name the tag they are pushed to.
+		if sm, ok := manifest.(*schema1.SignedManifest); ok && sm.Tag != imh.Tag {
+			ctxu.GetLogger(imh).Errorf("invalid tag on manifest payload: %q != %q", sm.Tag, imh.Tag)
 			imh.Errors = append(imh.Errors, v2.ErrorCodeTagInvalid)
 			return
 		}
 
-		imh.Digest = dgst
+		imh.Digest = desc.Digest
 	} else if imh.Digest != "" {
-		if dgst != imh.Digest {
-			ctxu.GetLogger(imh).Errorf("payload digest does match: %q != %q", dgst, imh.Digest)
+		if desc.Digest != imh.Digest {
+			ctxu.GetLogger(imh).Errorf("payload digest does match: %q != %q", desc.Digest, imh.Digest)
 			imh.Errors = append(imh.Errors, v2.ErrorCodeDigestInvalid)
 			return
 		}
@@ -156,7 +239,14 @@ func (imh *imageManifestHandler) PutImageManifest(w http.ResponseWriter, r *http
 		return
 	}
 
-	if err := manifests.Put(&manifest); err != nil {
+	if err := imh.checkQuota(desc.Size, func() (bool, error) {
+		return manifests.Exists(desc.Digest)
+	}); err != nil {
+		imh.Errors = append(imh.Errors, err)
+		return
+	}
+
+	if _, err := manifests.Put(manifest); err != nil {
 		// TODO(stevvooe): These error handling switches really need to be
 		// handled by an app global mapper.
 		if err == distribution.ErrUnsupported {
@@ -186,6 +276,18 @@ func (imh *imageManifestHandler) PutImageManifest(w http.ResponseWriter, r *http
 		return
 	}
 
+	// Tag this manifest
+	if imh.Tag != "" {
+		if err := imh.Repository.Tags(imh).Tag(imh, imh.Tag, distribution.Descriptor{Digest: imh.Digest}); err != nil {
+			if err == distribution.ErrUnsupported {
+				imh.Errors = append(imh.Errors, errcode.ErrorCodeUnsupported)
+				return
+			}
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+			return
+		}
+	}
+
 	// Construct a canonical url for the uploaded manifest.
 	location, err := imh.urlBuilder.BuildManifestURL(imh.Repository.Name(), imh.Digest.String())
 	if err != nil {
@@ -200,10 +302,16 @@ func (imh *imageManifestHandler) PutImageManifest(w http.ResponseWriter, r *http
 	w.WriteHeader(http.StatusCreated)
 }
 
-// DeleteImageManifest removes the manifest with the given digest from the registry.
+// DeleteImageManifest removes the manifest with the given digest from the
+// registry. If a tag is given, only the tag is removed.
 func (imh *imageManifestHandler) DeleteImageManifest(w http.ResponseWriter, r *http.Request) {
 	ctxu.GetLogger(imh).Debug("DeleteImageManifest")
 
+	if imh.Tag != "" {
+		imh.deleteTag(w)
+		return
+	}
+
 	manifests, err := imh.Repository.Manifests(imh)
 	if err != nil {
 		imh.Errors = append(imh.Errors, err)
@@ -232,27 +340,24 @@ func (imh *imageManifestHandler) DeleteImageManifest(w http.ResponseWriter, r *h
 	w.WriteHeader(http.StatusAccepted)
 }
 
-// digestManifest takes a digest of the given manifest. This belongs somewhere
-// better but we'll wait for a refactoring cycle to find that real somewhere.
-func digestManifest(ctx context.Context, sm *schema1.SignedManifest) (digest.Digest, error) {
-	p, err := sm.Payload()
-	if err != nil {
-		if !strings.Contains(err.Error(), "missing signature key") {
-			ctxu.GetLogger(ctx).Errorf("error getting manifest payload: %v", err)
-			return "", err
-		}
-
-		// NOTE(stevvooe): There are no signatures but we still have a
-		// payload. The request will fail later but this is not the
-		// responsibility of this part of the code.
-		p = sm.Raw
+// deleteTag removes the tag from the repository, leaving the manifest it
+// points to in place.
+func (imh *imageManifestHandler) deleteTag(w http.ResponseWriter) {
+	err := imh.Repository.Tags(imh).Untag(imh, imh.Tag)
+	if err == distribution.ErrUnsupported {
+		imh.Errors = append(imh.Errors, errcode.ErrorCodeUnsupported)
+		return
 	}
 
-	dgst, err := digest.FromBytes(p)
 	if err != nil {
-		ctxu.GetLogger(ctx).Errorf("error digesting manifest: %v", err)
-		return "", err
+		switch err.(type) {
+		case distribution.ErrTagUnknown:
+			imh.Errors = append(imh.Errors, v2.ErrorCodeManifestUnknown.WithDetail(err))
+		default:
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		}
+		return
 	}
 
-	return dgst, err
+	w.WriteHeader(http.StatusAccepted)
 }
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docker/distribution/metrics"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+)
+
+var (
+	requestsTotal = metrics.NewCounterVec("registry_http_requests_total",
+		"Number of HTTP requests, by route, method and status code.",
+		"route", "method", "code")
+	requestDuration = metrics.NewHistogramVec("registry_http_request_duration_seconds",
+		"Duration of HTTP requests in seconds, by route and method.",
+		metrics.DefaultBuckets, "route", "method")
+)
+
+func init() {
+	metrics.Register(requestsTotal, requestDuration)
+}
+
+// observeRequest records the status and duration of a request dispatched to
+// a route. Methods the API doesn't use are counted together, so that clients
+// can't create any number of metrics.
+func observeRequest(ctx context.Context, r *http.Request, start time.Time) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return
+	}
+
+	method := r.Method
+	switch method {
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE":
+	default:
+		method = "other"
+	}
+
+	// Handlers writing no response implicitly succeed.
+	status, _ := ctx.Value("http.response.status").(int)
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	requestsTotal.Inc(route.GetName(), method, strconv.Itoa(status))
+	requestDuration.Observe(time.Since(start).Seconds(), route.GetName(), method)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/metrics"
+)
+
+// metricValue returns the value of a sample served by the default metrics
+// registry, zero if missing.
+func metricValue(t *testing.T, sample string) float64 {
+	w := httptest.NewRecorder()
+	metrics.DefaultRegistry.ServeHTTP(w, &http.Request{})
+
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), sample+" "); value != scanner.Text() {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("unexpected error parsing value of %s: %v", sample, err)
+			}
+			return v
+		}
+	}
+
+	return 0
+}
+
+// TestRequestMetrics checks that requests and the storage driver calls they
+// make are counted.
+func TestRequestMetrics(t *testing.T) {
+	env := newTestEnv(t, false)
+
+	const (
+		requests = `registry_http_requests_total{route="manifest",method="GET",code="404"}`
+		duration = `registry_http_request_duration_seconds_count{route="manifest",method="GET"}`
+		calls    = `registry_storage_driver_duration_seconds_count{driver="inmemory",method="GetContent"}`
+	)
+	before := []float64{metricValue(t, requests), metricValue(t, duration), metricValue(t, calls)}
+
+	manifestURL, err := env.builder.BuildManifestURL("foo/bar", "latest")
+	checkErr(t, err, "building manifest url")
+
+	resp, err := http.Get(manifestURL)
+	checkErr(t, err, "fetching manifest")
+	defer resp.Body.Close()
+	checkResponse(t, "fetching unknown manifest", resp, http.StatusNotFound)
+
+	if v := metricValue(t, requests); v != before[0]+1 {
+		t.Fatalf("unexpected request count: %v != %v", v, before[0]+1)
+	}
+	if v := metricValue(t, duration); v != before[1]+1 {
+		t.Fatalf("unexpected request duration count: %v != %v", v, before[1]+1)
+	}
+	if v := metricValue(t, calls); v <= before[2] {
+		t.Fatalf("storage driver calls were not counted: %v <= %v", v, before[2])
+	}
+}
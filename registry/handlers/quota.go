@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/api/v2"
+	"github.com/docker/distribution/registry/storage"
+	"golang.org/x/net/context"
+)
+
+// quotas holds the storage limits, in bytes, configured for repositories and
+// namespaces.
+type quotas struct {
+	repositories map[string]int64
+	namespaces   map[string]int64
+}
+
+// newQuotas validates the quota configuration. Namespaces may be given with
+// a trailing slash.
+func newQuotas(config configuration.Quota) (quotas, error) {
+	q := quotas{
+		repositories: make(map[string]int64, len(config.Repositories)),
+		namespaces:   make(map[string]int64, len(config.Namespaces)),
+	}
+
+	for name, limit := range config.Repositories {
+		if err := validateQuota(name, limit); err != nil {
+			return quotas{}, err
+		}
+		q.repositories[name] = limit
+	}
+
+	for namespace, limit := range config.Namespaces {
+		namespace = strings.TrimSuffix(namespace, "/")
+		if err := validateQuota(namespace, limit); err != nil {
+			return quotas{}, err
+		}
+		q.namespaces[namespace] = limit
+	}
+
+	return q, nil
+}
+
+func validateQuota(name string, limit int64) error {
+	if _, err := reference.ParseNamed(name); err != nil {
+		return fmt.Errorf("invalid name in quota for %q: %v", name, err)
+	}
+
+	if limit <= 0 {
+		return fmt.Errorf("quota for %q must be positive: %d", name, limit)
+	}
+
+	return nil
+}
+
+// configured reports whether any quota is configured.
+func (q quotas) configured() bool {
+	return len(q.repositories) > 0 || len(q.namespaces) > 0
+}
+
+// namespacesOf returns the namespaces with a quota which enclose the named
+// repository, sorted by name.
+func (q quotas) namespacesOf(name string) []string {
+	var namespaces []string
+	for namespace := range q.namespaces {
+		if strings.HasPrefix(name, namespace+"/") {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	sort.Strings(namespaces)
+
+	return namespaces
+}
+
+// apply reports whether any quota limits the named repository.
+func (q quotas) apply(name string) bool {
+	_, ok := q.repositories[name]
+	return ok || len(q.namespacesOf(name)) > 0
+}
+
+// usage returns the storage used by the named repository and the namespaces
+// with a quota enclosing it, along with their limits.
+func (app *App) usage(ctx context.Context, name string) (usageAPIResponse, error) {
+	size, err := storage.RepositoryUsage(ctx, app.driver, name)
+	if err != nil {
+		return usageAPIResponse{}, err
+	}
+
+	usage := usageAPIResponse{
+		Name:  name,
+		Size:  size,
+		Limit: app.quotas.repositories[name],
+	}
+
+	for _, namespace := range app.quotas.namespacesOf(name) {
+		size, err := storage.NamespaceUsage(ctx, app.driver, namespace)
+		if err != nil {
+			return usageAPIResponse{}, err
+		}
+
+		usage.Namespaces = append(usage.Namespaces, namespaceUsage{
+			Namespace: namespace,
+			Size:      size,
+			Limit:     app.quotas.namespaces[namespace],
+		})
+	}
+
+	return usage, nil
+}
+
+// checkQuota returns an error if adding size bytes to the repository of the
+// request would take it, or a namespace enclosing it, over its quota. The
+// linked function reports whether the content is already linked into the
+// repository, in which case it uses no more storage; it is only called when
+// a quota applies. Errors are returned as errcode values ready to be sent to
+// the client.
+func (ctx *Context) checkQuota(size int64, linked func() (bool, error)) error {
+	name := ctx.Repository.Name()
+	if !ctx.App.quotas.apply(name) {
+		return nil
+	}
+
+	ok, err := linked()
+	if err != nil {
+		return errcode.ErrorCodeUnknown.WithDetail(err)
+	} else if ok {
+		return nil
+	}
+
+	usage, err := ctx.App.usage(ctx, name)
+	if err != nil {
+		return errcode.ErrorCodeUnknown.WithDetail(err)
+	}
+
+	if usage.Limit > 0 && usage.Size+size > usage.Limit {
+		ctxu.GetLogger(ctx).Infof("quota of repository %s exceeded: %d + %d > %d", name, usage.Size, size, usage.Limit)
+		return v2.ErrorCodeDeniedQuota.WithDetail(map[string]interface{}{
+			"name":  name,
+			"size":  usage.Size,
+			"limit": usage.Limit,
+		})
+	}
+
+	for _, ns := range usage.Namespaces {
+		if ns.Size+size > ns.Limit {
+			ctxu.GetLogger(ctx).Infof("quota of namespace %s exceeded: %d + %d > %d", ns.Namespace, ns.Size, size, ns.Limit)
+			return v2.ErrorCodeDeniedQuota.WithDetail(ns)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/garyburd/redigo/redis"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+)
+
+// rateLimit describes a token bucket, holding up to burst tokens and
+// refilled with rate tokens per second. Each request takes a token.
+type rateLimit struct {
+	rate  float64
+	burst int
+}
+
+// The dimensions requests are limited by. Each value of a dimension, such as
+// a remote address, has a bucket of its own.
+const (
+	rateLimitAddress    = "address"
+	rateLimitUser       = "user"
+	rateLimitRepository = "repository"
+)
+
+// routeRateLimit describes the limit of the requests to a route, applied to
+// each of its dimensions separately.
+type routeRateLimit struct {
+	limit      rateLimit
+	dimensions map[string]bool
+}
+
+// rateLimiter maintains token buckets, identified by keys.
+type rateLimiter interface {
+	// take takes a token from the bucket identified by key, created full if
+	// missing. If the bucket is empty, no token is taken and the time until
+	// one is available is returned instead.
+	take(ctx context.Context, key string, limit rateLimit) (time.Duration, error)
+}
+
+// newRateLimits validates the rate limits of the configuration, which must
+// only name routes of router.
+func newRateLimits(config configuration.RateLimit, router *mux.Router) (map[string]routeRateLimit, error) {
+	limits := make(map[string]routeRateLimit, len(config.Routes))
+	for name, routeLimit := range config.Routes {
+		if router.Get(name) == nil {
+			return nil, fmt.Errorf("unknown route %q", name)
+		}
+
+		if routeLimit.Rate <= 0 {
+			return nil, fmt.Errorf("rate of route %q must be positive: %v", name, routeLimit.Rate)
+		}
+
+		limit := rateLimit{
+			rate:  routeLimit.Rate,
+			burst: routeLimit.Burst,
+		}
+		if limit.burst <= 0 {
+			limit.burst = int(math.Ceil(limit.rate))
+		}
+
+		by := routeLimit.By
+		if len(by) == 0 {
+			by = []string{rateLimitAddress, rateLimitUser, rateLimitRepository}
+		}
+
+		dimensions := make(map[string]bool, len(by))
+		for _, dimension := range by {
+			switch dimension {
+			case rateLimitAddress, rateLimitUser, rateLimitRepository:
+				dimensions[dimension] = true
+			default:
+				return nil, fmt.Errorf("unknown rate limit dimension %q of route %q", dimension, name)
+			}
+		}
+
+		limits[name] = routeRateLimit{limit: limit, dimensions: dimensions}
+	}
+
+	return limits, nil
+}
+
+// parseTrustedProxies parses the IP addresses and CIDR ranges of the trusted
+// proxies, addresses being ranges of a single address.
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, proxy := range proxies {
+		if _, ipNet, err := net.ParseCIDR(proxy); err == nil {
+			nets = append(nets, ipNet)
+			continue
+		}
+
+		ip := net.ParseIP(proxy)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+	}
+
+	return nets, nil
+}
+
+// trusted returns whether ip is one of the trusted proxies.
+func trusted(proxies []*net.IPNet, ip net.IP) bool {
+	for _, proxy := range proxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP address of the client of the request. The
+// X-Forwarded-For and X-Real-Ip headers are only used for requests from
+// trusted proxies, X-Forwarded-For being walked back from the connection to
+// the first address which isn't a trusted proxy, so that clients can't forge
+// their address.
+func clientIP(r *http.Request, proxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !trusted(proxies, ip) {
+		return host
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				break
+			}
+
+			ip = hop
+			if !trusted(proxies, ip) {
+				break
+			}
+		}
+		return ip.String()
+	}
+
+	if realIP := net.ParseIP(r.Header.Get("X-Real-Ip")); realIP != nil {
+		return realIP.String()
+	}
+
+	return host
+}
+
+// rateLimited takes a token from the bucket of the request for each of the
+// given dimensions limiting its route, such as the bucket of its remote
+// address. Dimensions without a value, such as the user of anonymous
+// requests, are not limited. If a bucket is empty, a 429 Too Many Requests
+// response is written and an error returned. The request proceeds if a
+// bucket can't be checked, so that an unavailable redis doesn't take the
+// registry down.
+func (app *App) rateLimited(w http.ResponseWriter, r *http.Request, context *Context, dimensions ...string) error {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return nil
+	}
+
+	limit, ok := app.rateLimits[route.GetName()]
+	if !ok {
+		return nil
+	}
+
+	for _, dimension := range dimensions {
+		if !limit.dimensions[dimension] {
+			continue
+		}
+
+		var value string
+		switch dimension {
+		case rateLimitAddress:
+			value = clientIP(r, app.trustedProxies)
+		case rateLimitUser:
+			value = ctxu.GetStringValue(context, "auth.user.name")
+		case rateLimitRepository:
+			value = getName(context)
+		}
+		if value == "" {
+			continue
+		}
+
+		key := route.GetName() + "?" + url.Values{dimension: []string{value}}.Encode()
+		wait, err := app.rateLimiter.take(context, key, limit.limit)
+		if err != nil {
+			ctxu.GetLogger(context).Errorf("error checking rate limit: %v", err)
+			continue
+		} else if wait <= 0 {
+			continue
+		}
+
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(wait.Seconds())), 10))
+		if err := errcode.ServeJSON(w, errcode.ErrorCodeTooManyRequests); err != nil {
+			ctxu.GetLogger(context).Errorf("error serving error json: %v (from %v)", err, context.Errors)
+		}
+
+		return fmt.Errorf("rate limit of %s exceeded, retry in %v", key, wait)
+	}
+
+	return nil
+}
+
+// rateLimitSweepInterval is the interval at which full buckets are removed
+// from memory.
+const rateLimitSweepInterval = time.Minute
+
+// rateLimitMaxBuckets is the number of buckets kept in memory, past which the
+// least recently used buckets are removed.
+const rateLimitMaxBuckets = 1 << 16
+
+// memoryRateLimiter keeps token buckets in memory, limiting requests to a
+// single registry instance. Buckets are kept in least recently used order,
+// and at most maxBuckets of them are kept.
+type memoryRateLimiter struct {
+	mu         sync.Mutex
+	buckets    map[string]*list.Element
+	lru        *list.List
+	maxBuckets int
+	swept      time.Time
+	now        func() time.Time
+}
+
+type tokenBucket struct {
+	key     string
+	limit   rateLimit
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens accumulated since the last update of the bucket.
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.burst), b.tokens+elapsed.Seconds()*b.limit.rate)
+		b.updated = now
+	}
+}
+
+func newMemoryRateLimiter() *memoryRateLimiter {
+	return &memoryRateLimiter{
+		buckets:    make(map[string]*list.Element),
+		lru:        list.New(),
+		maxBuckets: rateLimitMaxBuckets,
+		now:        time.Now,
+	}
+}
+
+func (l *memoryRateLimiter) take(ctx context.Context, key string, limit rateLimit) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	var b *tokenBucket
+	if e, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(e)
+		b = e.Value.(*tokenBucket)
+	} else {
+		for len(l.buckets) >= l.maxBuckets {
+			l.remove(l.lru.Back())
+		}
+
+		b = &tokenBucket{key: key, tokens: float64(limit.burst), updated: now}
+		l.buckets[key] = l.lru.PushFront(b)
+	}
+	b.limit = limit
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, nil
+	}
+
+	return time.Duration((1 - b.tokens) / limit.rate * float64(time.Second)), nil
+}
+
+// sweep removes the buckets which have refilled, as they are the same as
+// missing ones. It only runs once per sweep interval.
+func (l *memoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < rateLimitSweepInterval {
+		return
+	}
+	l.swept = now
+
+	for _, e := range l.buckets {
+		b := e.Value.(*tokenBucket)
+		b.refill(now)
+		if b.tokens >= float64(b.limit.burst) {
+			l.remove(e)
+		}
+	}
+}
+
+func (l *memoryRateLimiter) remove(e *list.Element) {
+	l.lru.Remove(e)
+	delete(l.buckets, e.Value.(*tokenBucket).key)
+}
+
+// redisTakeScript implements take atomically in redis. Buckets are hashes of
+// their tokens and update time in milliseconds, which expire once refilled.
+// The time to wait in milliseconds is returned, zero if a token was taken.
+var redisTakeScript = redis.NewScript(1, `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(bucket[1]) or burst
+local updated = tonumber(bucket[2]) or now
+
+if now > updated then
+	tokens = math.min(burst, tokens + (now - updated) / 1000 * rate)
+	updated = now
+end
+
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "updated", updated)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000))
+return wait
+`)
+
+// redisRateLimiter keeps token buckets in redis, limiting requests to all
+// the registry instances sharing it.
+type redisRateLimiter struct {
+	pool *redis.Pool
+}
+
+func (l *redisRateLimiter) take(ctx context.Context, key string, limit rateLimit) (time.Duration, error) {
+	conn := l.pool.Get()
+	defer conn.Close()
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	wait, err := redis.Int64(redisTakeScript.Do(conn, "ratelimit::"+key, limit.rate, limit.burst, now))
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(wait) * time.Millisecond, nil
+}
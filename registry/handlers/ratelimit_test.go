@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/registry/api/v2"
+	"golang.org/x/net/context"
+)
+
+// TestMemoryRateLimiter checks that buckets are refilled over time, limit
+// each key separately and are removed from memory once refilled.
+func TestMemoryRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	limiter := newMemoryRateLimiter()
+	limiter.now = func() time.Time { return now }
+
+	limit := rateLimit{rate: 2, burst: 2}
+
+	take := func(key string, expected time.Duration) {
+		wait, err := limiter.take(ctx, key, limit)
+		if err != nil {
+			t.Fatalf("unexpected error taking token: %v", err)
+		}
+		if wait != expected {
+			t.Fatalf("unexpected wait for %s at %v: %v != %v", key, now, wait, expected)
+		}
+	}
+
+	take("a", 0)
+	take("a", 0)
+	take("a", 500*time.Millisecond)
+
+	// Other keys have their own bucket.
+	take("b", 0)
+
+	now = now.Add(250 * time.Millisecond)
+	take("a", 250*time.Millisecond)
+
+	now = now.Add(250 * time.Millisecond)
+	take("a", 0)
+	take("a", 500*time.Millisecond)
+
+	now = now.Add(2 * rateLimitSweepInterval)
+	take("c", 0)
+	if len(limiter.buckets) != 1 {
+		t.Fatalf("refilled buckets were not removed: %v", limiter.buckets)
+	}
+}
+
+// TestMemoryRateLimiterMaxBuckets checks that the least recently used buckets
+// are removed once the maximum number of buckets is reached.
+func TestMemoryRateLimiterMaxBuckets(t *testing.T) {
+	ctx := context.Background()
+	limiter := newMemoryRateLimiter()
+	limiter.maxBuckets = 2
+
+	limit := rateLimit{rate: 1, burst: 1}
+	for _, key := range []string{"a", "b", "a", "c"} {
+		if _, err := limiter.take(ctx, key, limit); err != nil {
+			t.Fatalf("unexpected error taking token: %v", err)
+		}
+	}
+
+	if len(limiter.buckets) != 2 || limiter.lru.Len() != 2 {
+		t.Fatalf("unexpected number of buckets: %v", limiter.buckets)
+	}
+	if _, ok := limiter.buckets["b"]; ok {
+		t.Fatalf("least recently used bucket was not removed: %v", limiter.buckets)
+	}
+
+	wait, err := limiter.take(ctx, "a", limit)
+	if err != nil {
+		t.Fatalf("unexpected error taking token: %v", err)
+	}
+	if wait <= 0 {
+		t.Fatalf("recently used bucket was removed")
+	}
+}
+
+// TestClientIP checks that forwarded headers are only used for requests from
+// trusted proxies.
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("unexpected error parsing trusted proxies: %v", err)
+	}
+
+	for _, testcase := range []struct {
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		expected   string
+	}{
+		{remoteAddr: "1.2.3.4:5000", expected: "1.2.3.4"},
+		{remoteAddr: "1.2.3.4:5000", forwarded: "5.6.7.8", realIP: "5.6.7.8", expected: "1.2.3.4"},
+		{remoteAddr: "10.1.2.3:5000", expected: "10.1.2.3"},
+		{remoteAddr: "10.1.2.3:5000", forwarded: "5.6.7.8", expected: "5.6.7.8"},
+		{remoteAddr: "192.168.1.1:5000", realIP: "5.6.7.8", expected: "5.6.7.8"},
+		{remoteAddr: "192.168.1.2:5000", realIP: "5.6.7.8", expected: "192.168.1.2"},
+		// Addresses prepended by the client are ignored.
+		{remoteAddr: "10.1.2.3:5000", forwarded: "9.9.9.9, 5.6.7.8, 192.168.1.1", expected: "5.6.7.8"},
+		{remoteAddr: "10.1.2.3:5000", forwarded: "10.0.0.1, 10.0.0.2", expected: "10.0.0.1"},
+	} {
+		r := &http.Request{RemoteAddr: testcase.remoteAddr, Header: make(http.Header)}
+		if testcase.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", testcase.forwarded)
+		}
+		if testcase.realIP != "" {
+			r.Header.Set("X-Real-Ip", testcase.realIP)
+		}
+
+		if ip := clientIP(r, proxies); ip != testcase.expected {
+			t.Fatalf("unexpected client ip for %+v: %q != %q", testcase, ip, testcase.expected)
+		}
+	}
+
+	if _, err := parseTrustedProxies([]string{"proxy.example.com"}); err == nil {
+		t.Fatalf("expected error parsing invalid trusted proxy")
+	}
+}
+
+// TestNewRateLimits checks that routes are limited by every dimension unless
+// configured otherwise, and that unknown dimensions are rejected.
+func TestNewRateLimits(t *testing.T) {
+	router := v2.RouterWithPrefix("")
+
+	limits, err := newRateLimits(configuration.RateLimit{
+		Routes: map[string]configuration.RouteRateLimit{
+			v2.RouteNameManifest: {Rate: 1.5},
+			v2.RouteNameBlob:     {Rate: 1, By: []string{"user"}},
+		},
+	}, router)
+	if err != nil {
+		t.Fatalf("unexpected error creating rate limits: %v", err)
+	}
+
+	expected := map[string]routeRateLimit{
+		v2.RouteNameManifest: {
+			limit:      rateLimit{rate: 1.5, burst: 2},
+			dimensions: map[string]bool{rateLimitAddress: true, rateLimitUser: true, rateLimitRepository: true},
+		},
+		v2.RouteNameBlob: {
+			limit:      rateLimit{rate: 1, burst: 1},
+			dimensions: map[string]bool{rateLimitUser: true},
+		},
+	}
+	if !reflect.DeepEqual(limits, expected) {
+		t.Fatalf("unexpected rate limits: %#v != %#v", limits, expected)
+	}
+
+	if _, err := newRateLimits(configuration.RateLimit{
+		Routes: map[string]configuration.RouteRateLimit{
+			v2.RouteNameBlob: {Rate: 1, By: []string{"tag"}},
+		},
+	}, router); err == nil {
+		t.Fatal("expected unknown dimension to be rejected")
+	}
+}
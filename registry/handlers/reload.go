@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/notifications"
+)
+
+// Reload applies the reloadable parts of configuration to the running app:
+// the log hooks, the notification endpoints, the access controller and the
+// health checks. The log level and formatter are global and left to the
+// caller. Requests in flight aren't interrupted.
+//
+// The configuration is refused, and nothing applied, if it changes anything
+// else, such as the storage driver, which requires a restart. This includes
+// the token issuer and the quotas: the access control list of the token
+// issuer is read again once modified, but its htpasswd file and the quota
+// limits are only read on startup.
+func (app *App) Reload(configuration *configuration.Configuration) error {
+	app.reloadMu.Lock()
+	defer app.reloadMu.Unlock()
+
+	if sections := unreloadableChanges(app.Config(), configuration); len(sections) > 0 {
+		return fmt.Errorf("changes to %s require a restart", strings.Join(sections, ", "))
+	}
+
+	if name, ok := duplicateHealthCheck(configuration); ok {
+		return fmt.Errorf("health check %s is configured twice", name)
+	}
+
+	accessController, err := app.newAccessController(configuration)
+	if err != nil {
+		return err
+	}
+
+	// Nothing can fail past this point, the configuration is applied. As it
+	// only differs from the running one in reloadable parts, it replaces the
+	// running configuration as a whole. Requests reading the previous one
+	// keep an unmodified copy.
+	config := *configuration
+	app.mu.Lock()
+	app.config = &config
+	app.accessController = accessController
+	app.mu.Unlock()
+
+	previous := app.events.sink.replace(app.newEventSink(&config))
+	go func() {
+		// Closing flushes the events pending delivery, which may take a
+		// while if endpoints are down.
+		if err := previous.Close(); err != nil {
+			ctxu.GetLogger(app).Errorf("error closing notification endpoints: %v", err)
+		}
+	}()
+
+	app.configureLogHook(&config)
+
+	// Health checks are only registered by the registry, not by tests.
+	if app.healthRegistry != nil {
+		for _, name := range app.healthChecks {
+			app.healthRegistry.Unregister(name)
+		}
+		app.RegisterHealthChecks(app.healthRegistry)
+	}
+
+	ctxu.GetLogger(app).Infof("configuration reloaded")
+	return nil
+}
+
+// unreloadableChanges returns the sections of the configuration, by yaml
+// name, which differ between the running configuration and config and can't
+// be reloaded.
+func unreloadableChanges(running, config *configuration.Configuration) []string {
+	current, next := reflect.ValueOf(withoutReloadable(*running)), reflect.ValueOf(withoutReloadable(*config))
+
+	var sections []string
+	for i := 0; i < current.NumField(); i++ {
+		if !reflect.DeepEqual(current.Field(i).Interface(), next.Field(i).Interface()) {
+			name := strings.Split(current.Type().Field(i).Tag.Get("yaml"), ",")[0]
+			sections = append(sections, name)
+		}
+	}
+
+	return sections
+}
+
+// withoutReloadable returns a copy of config without the parts applied by
+// Reload.
+func withoutReloadable(config configuration.Configuration) configuration.Configuration {
+	config.Loglevel = ""
+	config.Log.Level = ""
+	config.Log.Formatter = ""
+	config.Log.Hooks = nil
+	config.Notifications = configuration.Notifications{}
+	config.Auth = nil
+	config.Health = configuration.Health{}
+
+	return config
+}
+
+// duplicateHealthCheck returns the name of a health check configured twice,
+// which the health registry would refuse, if any.
+func duplicateHealthCheck(config *configuration.Configuration) (string, bool) {
+	var names []string
+	for _, fileChecker := range config.Health.FileCheckers {
+		names = append(names, fileChecker.File)
+	}
+	for _, httpChecker := range config.Health.HTTPCheckers {
+		names = append(names, httpChecker.URI)
+	}
+	for _, tcpChecker := range config.Health.TCPCheckers {
+		names = append(names, tcpChecker.Addr)
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			return name, true
+		}
+		seen[name] = true
+	}
+
+	return "", false
+}
+
+// reloadableLogHooks fires log hooks which are replaced when the
+// configuration is reloaded, without modifying the hooks of the logger while
+// it fires them.
+type reloadableLogHooks struct {
+	mu    sync.RWMutex
+	hooks []log.Hook
+}
+
+// Levels returns all levels, the levels of each hook being checked by Fire.
+func (rh *reloadableLogHooks) Levels() []log.Level {
+	return []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel, log.WarnLevel, log.InfoLevel, log.DebugLevel}
+}
+
+// Fire fires the current hooks for the level of entry.
+func (rh *reloadableLogHooks) Fire(entry *log.Entry) error {
+	rh.mu.RLock()
+	defer rh.mu.RUnlock()
+
+	var firstErr error
+	for _, hook := range rh.hooks {
+		for _, level := range hook.Levels() {
+			if level != entry.Level {
+				continue
+			}
+
+			if err := hook.Fire(entry); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			break
+		}
+	}
+
+	return firstErr
+}
+
+// replace replaces the current hooks.
+func (rh *reloadableLogHooks) replace(hooks []log.Hook) {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	rh.hooks = hooks
+}
+
+// reloadableSink forwards events to a sink which is replaced when the
+// configuration is reloaded. Requests in flight keep writing to it, their
+// events going to the notification endpoints configured when written.
+type reloadableSink struct {
+	mu   sync.RWMutex
+	sink notifications.Sink
+}
+
+// Write writes the events to the current sink.
+func (rs *reloadableSink) Write(events ...notifications.Event) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	return rs.sink.Write(events...)
+}
+
+// Close closes the current sink.
+func (rs *reloadableSink) Close() error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	return rs.sink.Close()
+}
+
+// replace replaces the current sink, returning the previous one, which no
+// longer receives events.
+func (rs *reloadableSink) replace(sink notifications.Sink) notifications.Sink {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	previous := rs.sink
+	rs.sink = sink
+	return previous
+}
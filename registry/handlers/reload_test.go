@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/health"
+	"github.com/docker/distribution/notifications"
+)
+
+// TestReload checks that the access controller, notification endpoints and
+// health checks of a running app are replaced by reloading its
+// configuration.
+func TestReload(t *testing.T) {
+	tmpfile, err := ioutil.TempFile(os.TempDir(), "healthcheck")
+	checkErr(t, err, "creating temporary file")
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Health: configuration.Health{
+			FileCheckers: []configuration.FileChecker{
+				{
+					Interval: 10 * time.Millisecond,
+					File:     tmpfile.Name(),
+				},
+			},
+		},
+	}
+	config.HTTP.Headers = headerConfig
+
+	env := newTestEnvWithConfig(t, &config)
+	healthRegistry := health.NewRegistry()
+	env.app.RegisterHealthChecks(healthRegistry)
+
+	var delivered int32
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&delivered, 1)
+	}))
+	defer endpoint.Close()
+
+	reloaded := config
+	reloaded.Auth = configuration.Auth{
+		"silly": {
+			"realm":   "realm-test",
+			"service": "service-test",
+		},
+	}
+	reloaded.Notifications = configuration.Notifications{
+		Endpoints: []configuration.Endpoint{
+			{
+				Name:      "reloaded",
+				URL:       endpoint.URL,
+				Timeout:   time.Second,
+				Threshold: 1,
+				Backoff:   time.Second,
+			},
+		},
+	}
+	reloaded.Health = configuration.Health{}
+
+	time.Sleep(50 * time.Millisecond)
+	if status := healthRegistry.CheckStatus(); len(status) != 1 {
+		t.Fatalf("unexpected health status before reloading: %v", status)
+	}
+
+	if err := env.app.Reload(&reloaded); err != nil {
+		t.Fatalf("unexpected error reloading configuration: %v", err)
+	}
+
+	baseURL, err := env.builder.BuildBaseURL()
+	checkErr(t, err, "building base url")
+
+	resp, err := http.Get(baseURL)
+	checkErr(t, err, "fetching base url")
+	resp.Body.Close()
+	checkResponse(t, "fetching base url after enabling authorization", resp, http.StatusUnauthorized)
+
+	if err := env.app.events.sink.Write(notifications.Event{ID: "reloaded"}); err != nil {
+		t.Fatalf("unexpected error writing event: %v", err)
+	}
+
+	for i := 0; atomic.LoadInt32(&delivered) == 0; i++ {
+		if i == 100 {
+			t.Fatalf("event was not delivered to the reloaded endpoint")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if status := healthRegistry.CheckStatus(); len(status) != 0 {
+		t.Fatalf("unexpected health status after reloading: %v", status)
+	}
+
+	if env.app.Config().Auth.Type() != "silly" || len(env.app.Config().Notifications.Endpoints) != 1 {
+		t.Fatalf("configuration of the app was not updated: %+v", env.app.Config())
+	}
+}
+
+// TestReloadRefused checks that configurations changing sections which can't
+// be reloaded are refused, nothing being applied.
+func TestReloadRefused(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+	}
+	app := NewApp(context.Background(), &config)
+
+	reloaded := config
+	reloaded.Storage = configuration.Storage{
+		"filesystem": configuration.Parameters{
+			"rootdirectory": "/tmp/registry",
+		},
+	}
+	reloaded.HTTP.Prefix = "/registry/"
+	reloaded.Auth = configuration.Auth{
+		"silly": {
+			"realm":   "realm-test",
+			"service": "service-test",
+		},
+	}
+
+	err := app.Reload(&reloaded)
+	if err == nil {
+		t.Fatalf("expected error reloading configuration changing the storage driver")
+	}
+
+	if !strings.Contains(err.Error(), "storage, http") {
+		t.Fatalf("error doesn't mention the sections changed: %v", err)
+	}
+
+	if app.getAccessController() != nil || app.Config().Auth != nil {
+		t.Fatalf("access controller was configured by a refused configuration")
+	}
+
+	// Unknown access controllers are refused too.
+	reloaded = config
+	reloaded.Auth = configuration.Auth{"unknown": {}}
+	if err := app.Reload(&reloaded); err == nil {
+		t.Fatalf("expected error reloading configuration with an unknown access controller")
+	}
+
+	// The token issuer and the quotas are only configured on startup.
+	reloaded = config
+	reloaded.TokenIssuer.ACL = "/etc/registry/acl.yml"
+	reloaded.Quota.Repositories = map[string]int64{"foo/bar": 1 << 20}
+	if err := app.Reload(&reloaded); err == nil || !strings.Contains(err.Error(), "tokenissuer, quota") {
+		t.Fatalf("expected error reloading configuration changing the token issuer and quotas: %v", err)
+	}
+}
+
+// TestReloadWhileServing reloads the configuration while requests are
+// served, for the race detector to check the configuration and log hooks
+// are replaced safely.
+func TestReloadWhileServing(t *testing.T) {
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+	}
+	config.HTTP.Headers = headerConfig
+
+	env := newTestEnvWithConfig(t, &config)
+	baseURL, err := env.builder.BuildBaseURL()
+	checkErr(t, err, "building base url")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			resp, err := http.Get(baseURL)
+			if err != nil {
+				t.Errorf("unexpected error fetching base url: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		reloaded := config
+		reloaded.Log.Hooks = []configuration.LogHook{{Type: "mail", Levels: []string{"panic"}}}
+		if i%2 == 0 {
+			reloaded.Auth = configuration.Auth{"silly": {"realm": "realm-test", "service": "service-test"}}
+		}
+
+		if err := env.app.Reload(&reloaded); err != nil {
+			t.Fatalf("unexpected error reloading configuration: %v", err)
+		}
+	}
+	<-done
+}
@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"bufio"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strconv"
 
 	"github.com/docker/distribution"
+	ctxu "github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/api/errcode"
 	"github.com/docker/distribution/registry/api/v2"
 	"github.com/gorilla/handlers"
@@ -31,16 +37,46 @@ type tagsAPIResponse struct {
 	Tags []string `json:"tags"`
 }
 
-// GetTags returns a json list of tags for a specific image name.
+// GetTags returns a json list of tags for a specific image name. If the n
+// query parameter is provided, at most n tags sorting after last are returned
+// and a Link header points at the next page. Otherwise, all tags are returned.
 func (th *tagsHandler) GetTags(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	manifests, err := th.Repository.Manifests(th)
-	if err != nil {
-		th.Errors = append(th.Errors, err)
-		return
+
+	q := r.URL.Query()
+	lastEntry := q.Get("last")
+
+	var (
+		tags        []string
+		moreEntries bool
+		maxEntries  int
+		err         error
+	)
+
+	tagService := th.Repository.Tags(th)
+	if q.Get("n") != "" {
+		maxEntries, err = strconv.Atoi(q.Get("n"))
+		if err != nil || maxEntries <= 0 {
+			maxEntries = maximumReturnedEntries
+		}
+
+		tags = make([]string, maxEntries)
+
+		var filled int
+		filled, err = tagService.List(th, tags, lastEntry)
+		tags = tags[:filled]
+		if err == io.EOF {
+			err = nil
+		} else if err == nil {
+			moreEntries = true
+		}
+	} else {
+		tags, err = tagService.All(th)
+		if err == nil {
+			tags = tagsAfter(tags, lastEntry)
+		}
 	}
 
-	tags, err := manifests.Tags()
 	if err != nil {
 		switch err := err.(type) {
 		case distribution.ErrRepositoryUnknown:
@@ -53,12 +89,69 @@ func (th *tagsHandler) GetTags(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
-	enc := json.NewEncoder(w)
-	if err := enc.Encode(tagsAPIResponse{
-		Name: th.Repository.Name(),
-		Tags: tags,
-	}); err != nil {
-		th.Errors = append(th.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
-		return
+	// Add a link header if there are more entries to retrieve
+	if moreEntries && len(tags) > 0 {
+		urlStr, err := createLinkEntry(r.URL.String(), maxEntries, tags[len(tags)-1])
+		if err != nil {
+			th.Errors = append(th.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+			return
+		}
+		w.Header().Set("Link", urlStr)
+	}
+
+	if err := writeTagsResponse(w, th.Repository.Name(), tags); err != nil {
+		// The response has been started, so the error can only be logged.
+		ctxu.GetLogger(th).Errorf("error writing tags response: %v", err)
+	}
+}
+
+// tagsAfter returns the lexically sorted tags following last.
+func tagsAfter(tags []string, last string) []string {
+	sort.Strings(tags)
+
+	start := sort.SearchStrings(tags, last)
+	if start < len(tags) && tags[start] == last {
+		start++
 	}
+
+	return tags[start:]
+}
+
+// writeTagsResponse encodes the tags response one tag at a time, so that
+// large repositories start receiving data without the whole body being
+// buffered first. The output is equivalent to encoding a tagsAPIResponse.
+func writeTagsResponse(w io.Writer, name string, tags []string) error {
+	bw := bufio.NewWriter(w)
+
+	p, err := json.Marshal(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(bw, `{"name":%s,"tags":[`, p); err != nil {
+		return err
+	}
+
+	for i, tag := range tags {
+		if i > 0 {
+			if err := bw.WriteByte(','); err != nil {
+				return err
+			}
+		}
+
+		p, err := json.Marshal(tag)
+		if err != nil {
+			return err
+		}
+
+		if _, err := bw.Write(p); err != nil {
+			return err
+		}
+	}
+
+	if _, err := bw.WriteString("]}\n"); err != nil {
+		return err
+	}
+
+	return bw.Flush()
 }
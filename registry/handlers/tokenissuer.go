@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/distribution/registry/auth/acl"
+	"github.com/docker/distribution/registry/auth/htpasswd"
+	"github.com/docker/distribution/registry/auth/token"
+	"github.com/docker/libtrust"
+)
+
+const (
+	// defaultTokenIssuerPath is the path of the token endpoint if not
+	// configured.
+	defaultTokenIssuerPath = "/auth/token"
+
+	// defaultTokenExpiration is how long tokens are valid if not
+	// configured.
+	defaultTokenExpiration = 5 * time.Minute
+
+	// routeNameToken is the name of the route of the token endpoint.
+	routeNameToken = "token"
+)
+
+// tokenIssuer serves the tokens verified by the token access controller,
+// implementing the docker token protocol. Users are authenticated with basic
+// credentials and granted the access they request which the access control
+// list allows.
+type tokenIssuer struct {
+	issuer   *token.Issuer
+	service  string
+	htpasswd *htpasswd.HTPasswd
+	acl      *acl.File
+}
+
+// tokenResponse is the body of the responses of the token endpoint.
+type tokenResponse struct {
+	Token       string    `json:"token"`
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int       `json:"expires_in"`
+	IssuedAt    time.Time `json:"issued_at"`
+}
+
+// configureTokenIssuer registers the token endpoint on the router of the
+// app, if a signing key is configured. The endpoint goes through the
+// dispatcher, and so is rate limited and measured like the API, but isn't
+// authorized by the access controller, as it issues the tokens the access
+// controller asks for.
+func (app *App) configureTokenIssuer(configuration *configuration.Configuration) {
+	config := configuration.TokenIssuer
+	if config.SigningKey == "" {
+		return
+	}
+
+	signingKey, err := libtrust.LoadKeyFile(config.SigningKey)
+	if err != nil {
+		panic(fmt.Sprintf("unable to load token issuer signing key: %v", err))
+	}
+
+	if config.Service == "" {
+		panic("token issuer service must be configured")
+	}
+
+	// Without an access control list, any user could be granted any
+	// action, including deletes.
+	if config.ACL == "" {
+		panic("token issuer access control list must be configured")
+	}
+
+	expiration := config.Expiration
+	if expiration == 0 {
+		expiration = defaultTokenExpiration
+	}
+
+	ti := &tokenIssuer{
+		issuer: &token.Issuer{
+			Name:       config.Issuer,
+			SigningKey: signingKey,
+			Expiration: expiration,
+		},
+		service: config.Service,
+	}
+
+	ti.htpasswd, err = htpasswd.LoadHTPasswd(config.HTPasswd)
+	if err != nil {
+		panic(fmt.Sprintf("unable to load token issuer htpasswd file: %v", err))
+	}
+
+	ti.acl, err = acl.OpenFile(config.ACL)
+	if err != nil {
+		panic(fmt.Sprintf("unable to load token issuer access control list: %v", err))
+	}
+
+	path := config.Path
+	if path == "" {
+		path = defaultTokenIssuerPath
+	}
+
+	app.router.Path(path).Methods("GET").Name(routeNameToken)
+	app.register(routeNameToken, ti.dispatch)
+	ctxu.GetLogger(app).Infof("issuing tokens for %q at %s", config.Service, path)
+}
+
+// dispatch returns the handler issuing a token to the user of the request.
+func (ti *tokenIssuer) dispatch(ctx *Context, r *http.Request) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ti.issueToken(ctx, w, r)
+	})
+}
+
+// issueToken issues a token for the scopes requested.
+func (ti *tokenIssuer) issueToken(ctx *Context, w http.ResponseWriter, r *http.Request) {
+	username, password, ok := r.BasicAuth()
+	if !ok || ti.htpasswd.AuthenticateUser(username, password) != nil {
+		if ok {
+			ctxu.GetLogger(ctx).Errorf("error authenticating user %q for a token", username)
+		}
+
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", ti.service))
+		ctx.Errors = append(ctx.Errors, errcode.ErrorCodeUnauthorized)
+		return
+	}
+
+	ctx.Context = auth.WithUser(ctx.Context, auth.UserInfo{Name: username})
+
+	query := r.URL.Query()
+	if service := query.Get("service"); service != ti.service {
+		ctx.Errors = append(ctx.Errors, errcode.ErrorCodeInvalidRequest.WithDetail(fmt.Sprintf("unknown service: %q", service)))
+		return
+	}
+
+	var access []*token.ResourceActions
+	for _, scope := range query["scope"] {
+		resource, actions, err := parseScope(scope)
+		if err != nil {
+			ctx.Errors = append(ctx.Errors, errcode.ErrorCodeInvalidRequest.WithDetail(err.Error()))
+			return
+		}
+
+		actions = ti.acl.ACL().Granted(acl.Subject{Name: username}, resource, actions)
+
+		// Resources with no granted action are left out, the access
+		// controller denying access to them.
+		if len(actions) > 0 {
+			access = append(access, &token.ResourceActions{
+				Type:    resource.Type,
+				Name:    resource.Name,
+				Actions: actions,
+			})
+		}
+	}
+
+	rawToken, err := ti.issuer.CreateJWT(username, ti.service, access)
+	if err != nil {
+		ctx.Errors = append(ctx.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(tokenResponse{
+		Token:       rawToken,
+		AccessToken: rawToken,
+		ExpiresIn:   int(ti.issuer.Expiration.Seconds()),
+		IssuedAt:    time.Now().UTC(),
+	}); err != nil {
+		ctxu.GetLogger(ctx).Errorf("error encoding token response: %v", err)
+	}
+}
+
+// parseScope parses a scope of the docker token protocol, such as
+// "repository:foo/bar:pull,push". The name of the resource may contain
+// colons, such as registry host names with a port.
+func parseScope(scope string) (auth.Resource, []string, error) {
+	typeEnd, nameEnd := strings.Index(scope, ":"), strings.LastIndex(scope, ":")
+	if typeEnd <= 0 || nameEnd == typeEnd {
+		return auth.Resource{}, nil, fmt.Errorf("invalid scope: %q", scope)
+	}
+
+	resource := auth.Resource{
+		Type: scope[:typeEnd],
+		Name: scope[typeEnd+1 : nameEnd],
+	}
+
+	var actions []string
+	for _, action := range strings.Split(scope[nameEnd+1:], ",") {
+		if action != "" {
+			actions = append(actions, action)
+		}
+	}
+
+	return resource, actions, nil
+}
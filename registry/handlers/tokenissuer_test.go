@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/configuration"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/client"
+	"github.com/docker/distribution/registry/client/auth"
+	"github.com/docker/distribution/registry/client/transport"
+	"github.com/docker/libtrust"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const tokenIssuerACL = `
+rules:
+  - users: [alice]
+    repositories: ["*"]
+    actions: ["*"]
+  - users: ["*"]
+    repositories: ["${user}/*"]
+    actions: [pull, push]
+  - users: ["*"]
+    repositories: ["library/*"]
+    actions: [pull]
+`
+
+type tokenIssuerCredentials struct {
+	username, password string
+}
+
+func (tic tokenIssuerCredentials) Basic(*url.URL) (string, string) {
+	return tic.username, tic.password
+}
+
+// newTokenIssuerServer starts a registry authorizing requests with the tokens
+// of its token issuer, for alice and bob.
+func newTokenIssuerServer(t *testing.T) (*httptest.Server, func()) {
+	dir, err := ioutil.TempDir("", "tokenissuer")
+	checkErr(t, err, "creating temporary directory")
+
+	signingKey, err := libtrust.GenerateECP256PrivateKey()
+	checkErr(t, err, "generating signing key")
+	checkErr(t, libtrust.SaveKey(filepath.Join(dir, "key.json"), signingKey), "saving signing key")
+
+	cert, err := libtrust.GenerateCACert(signingKey, signingKey.PublicKey())
+	checkErr(t, err, "generating certificate")
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	checkErr(t, ioutil.WriteFile(filepath.Join(dir, "bundle.pem"), bundle, 0644), "writing certificate bundle")
+
+	var htpasswd []byte
+	for _, user := range []string{"alice", "bob"} {
+		hash, err := bcrypt.GenerateFromPassword([]byte(user+"-password"), bcrypt.MinCost)
+		checkErr(t, err, "hashing password")
+		htpasswd = append(htpasswd, fmt.Sprintf("%s:%s\n", user, hash)...)
+	}
+	checkErr(t, ioutil.WriteFile(filepath.Join(dir, "htpasswd"), htpasswd, 0644), "writing htpasswd file")
+	checkErr(t, ioutil.WriteFile(filepath.Join(dir, "acl.yml"), []byte(tokenIssuerACL), 0644), "writing acl file")
+
+	// The realm of the access controller is the token endpoint of the
+	// server, whose address is known once listening.
+	server := httptest.NewUnstartedServer(nil)
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		Auth: configuration.Auth{
+			"token": {
+				"realm":          "http://" + server.Listener.Addr().String() + "/auth/token",
+				"issuer":         "test-issuer",
+				"service":        "test-service",
+				"rootcertbundle": filepath.Join(dir, "bundle.pem"),
+			},
+		},
+		TokenIssuer: configuration.TokenIssuer{
+			Issuer:     "test-issuer",
+			Service:    "test-service",
+			SigningKey: filepath.Join(dir, "key.json"),
+			HTPasswd:   filepath.Join(dir, "htpasswd"),
+			ACL:        filepath.Join(dir, "acl.yml"),
+		},
+	}
+	config.HTTP.Headers = headerConfig
+
+	server.Config.Handler = NewApp(context.Background(), &config)
+	server.Start()
+
+	return server, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// tokenRepository returns a client of the named repository authorized with
+// the tokens issued to username.
+func tokenRepository(t *testing.T, serverURL, name, username string) distribution.Repository {
+	challengeManager := auth.NewSimpleChallengeManager()
+	resp, err := http.Get(serverURL + "/v2/")
+	checkErr(t, err, "pinging registry")
+	resp.Body.Close()
+	checkResponse(t, "pinging registry without a token", resp, http.StatusUnauthorized)
+	checkErr(t, challengeManager.AddResponse(resp), "adding challenge")
+
+	creds := tokenIssuerCredentials{username: username, password: username + "-password"}
+	tr := transport.NewTransport(nil, auth.NewAuthorizer(challengeManager,
+		auth.NewTokenHandler(nil, creds, name, "pull", "push")))
+
+	repo, err := client.NewRepository(context.Background(), name, serverURL, tr)
+	checkErr(t, err, "creating repository client")
+	return repo
+}
+
+// TestTokenIssuer checks that tokens issued by the token endpoint grant the
+// access allowed by the access control list to the token access controller.
+func TestTokenIssuer(t *testing.T) {
+	server, cleanup := newTokenIssuerServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	content := []byte("token issuer layer")
+
+	desc, err := tokenRepository(t, server.URL, "library/ubuntu", "alice").Blobs(ctx).Put(ctx, "application/octet-stream", content)
+	if err != nil {
+		t.Fatalf("unexpected error pushing as alice: %v", err)
+	}
+
+	bob := tokenRepository(t, server.URL, "library/ubuntu", "bob")
+	if p, err := bob.Blobs(ctx).Get(ctx, desc.Digest); err != nil || string(p) != string(content) {
+		t.Fatalf("unexpected result pulling as bob: %q, %v", p, err)
+	}
+
+	if _, err := bob.Blobs(ctx).Put(ctx, "application/octet-stream", content); err == nil {
+		t.Fatalf("expected bob to be denied pushing to library/ubuntu")
+	}
+
+	if _, err := tokenRepository(t, server.URL, "bob/app", "bob").Blobs(ctx).Put(ctx, "application/octet-stream", content); err != nil {
+		t.Fatalf("unexpected error pushing to bob/app as bob: %v", err)
+	}
+
+	if _, err := tokenRepository(t, server.URL, "library/ubuntu", "mallory").Blobs(ctx).Get(ctx, desc.Digest); err == nil {
+		t.Fatalf("expected unknown user to be denied pulling")
+	}
+}
+
+// TestTokenIssuerRequests checks the responses of the token endpoint to
+// unauthenticated and invalid requests.
+func TestTokenIssuerRequests(t *testing.T) {
+	server, cleanup := newTokenIssuerServer(t)
+	defer cleanup()
+
+	tokenURL := server.URL + "/auth/token?service=test-service&scope=repository:bob/app:pull"
+
+	resp, err := http.Get(tokenURL)
+	checkErr(t, err, "requesting token")
+	defer resp.Body.Close()
+	checkResponse(t, "requesting token without credentials", resp, http.StatusUnauthorized)
+	checkBodyHasErrorCodes(t, "requesting token without credentials", resp, errcode.ErrorCodeUnauthorized)
+	if resp.Header.Get("WWW-Authenticate") != `Basic realm="test-service"` {
+		t.Fatalf("unexpected challenge: %q", resp.Header.Get("WWW-Authenticate"))
+	}
+
+	for _, testcase := range []struct {
+		url    string
+		status int
+	}{
+		{tokenURL, http.StatusOK},
+		{server.URL + "/auth/token?service=other-service&scope=repository:bob/app:pull", http.StatusBadRequest},
+		{server.URL + "/auth/token?service=test-service&scope=repository", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("GET", testcase.url, nil)
+		checkErr(t, err, "creating token request")
+		req.SetBasicAuth("bob", "bob-password")
+
+		resp, err := http.DefaultClient.Do(req)
+		checkErr(t, err, "requesting token")
+		defer resp.Body.Close()
+		checkResponse(t, "requesting "+testcase.url, resp, testcase.status)
+		if testcase.status == http.StatusBadRequest {
+			checkBodyHasErrorCodes(t, "requesting "+testcase.url, resp, errcode.ErrorCodeInvalidRequest)
+		}
+	}
+}
+
+// TestTokenIssuerWithoutACL checks that the token issuer can't be configured
+// without an access control list, which would grant any access requested.
+func TestTokenIssuerWithoutACL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokenissuer")
+	checkErr(t, err, "creating temporary directory")
+	defer os.RemoveAll(dir)
+
+	signingKey, err := libtrust.GenerateECP256PrivateKey()
+	checkErr(t, err, "generating signing key")
+	checkErr(t, libtrust.SaveKey(filepath.Join(dir, "key.json"), signingKey), "saving signing key")
+	checkErr(t, ioutil.WriteFile(filepath.Join(dir, "htpasswd"), nil, 0644), "writing htpasswd file")
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+		},
+		TokenIssuer: configuration.TokenIssuer{
+			Issuer:     "test-issuer",
+			Service:    "test-service",
+			SigningKey: filepath.Join(dir, "key.json"),
+			HTPasswd:   filepath.Join(dir, "htpasswd"),
+		},
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected token issuer without access control list to be rejected")
+		}
+	}()
+	NewApp(context.Background(), &config)
+}
+
+func TestParseScope(t *testing.T) {
+	resource, actions, err := parseScope("repository:localhost:5000/foo/bar:pull,push")
+	if err != nil {
+		t.Fatalf("unexpected error parsing scope: %v", err)
+	}
+
+	if resource.Type != "repository" || resource.Name != "localhost:5000/foo/bar" || len(actions) != 2 {
+		t.Fatalf("unexpected scope: %v %v", resource, actions)
+	}
+
+	for _, invalid := range []string{"", "repository", ":foo:pull", "repository:pull"} {
+		if _, _, err := parseScope(invalid); err == nil {
+			t.Fatalf("expected error parsing scope %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/gorilla/mux"
+)
+
+// traceRequest starts the span of a request dispatched to a route, as a
+// child of the span propagated by the traceparent header of the request, if
+// any. The spans of the request handling are children of this span. The
+// returned function finishes the span, with the errors of the request.
+func traceRequest(context *Context, r *http.Request) func() {
+	ctx := context.Context
+	if header := r.Header.Get(ctxu.TraceparentHeader); header != "" {
+		sc, err := ctxu.ParseTraceparent(header)
+		if err != nil {
+			ctxu.GetLogger(ctx).Debugf("ignoring traceparent: %v", err)
+		} else {
+			ctx = ctxu.WithSpanContext(ctx, sc)
+		}
+	}
+
+	name := "http"
+	if route := mux.CurrentRoute(r); route != nil {
+		name += "." + route.GetName()
+	}
+
+	ctx, span := ctxu.WithSpan(ctx, name)
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.path", r.URL.Path)
+	if repository := getName(ctx); repository != "" {
+		span.SetAttribute("vars.name", repository)
+	}
+	context.Context = ctx
+
+	return func() {
+		if status, ok := ctx.Value("http.response.status").(int); ok && status != 0 {
+			span.SetAttribute("http.status", status)
+		}
+
+		var err error
+		if context.Errors.Len() > 0 {
+			err = context.Errors
+		}
+		span.Finish(err)
+	}
+}
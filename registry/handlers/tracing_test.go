@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/distribution/configuration"
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+)
+
+// TestTracing checks that the spans of a request belong to the trace of its
+// traceparent header, and that the spans of the blob store, the blob
+// descriptor cache and the storage driver are nested in order.
+func TestTracing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "spans.json")
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"cache":    configuration.Parameters{"blobdescriptor": "inmemory"},
+		},
+		Tracing: configuration.Tracing{
+			Exporter: "jsonfile",
+			Options:  configuration.Parameters{"path": path},
+		},
+	}
+	config.HTTP.Headers = headerConfig
+	env := newTestEnvWithConfig(t, &config)
+
+	dgst := digest.Digest("sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	blobURL, err := env.builder.BuildBlobURL("foo/bar", dgst)
+	checkErr(t, err, "building blob url")
+
+	req, err := http.NewRequest("HEAD", blobURL, nil)
+	checkErr(t, err, "creating request")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	resp, err := http.DefaultClient.Do(req)
+	checkErr(t, err, "checking blob")
+	resp.Body.Close()
+	checkResponse(t, "checking unknown blob", resp, http.StatusNotFound)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening spans: %v", err)
+	}
+	defer f.Close()
+
+	spans := make(map[string]*ctxu.Span)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		span := new(ctxu.Span)
+		if err := json.Unmarshal(scanner.Bytes(), span); err != nil {
+			t.Fatalf("unexpected error decoding span %q: %v", scanner.Text(), err)
+		}
+
+		if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("span %s is not part of the trace: %+v", span.Name, span)
+		}
+		spans[span.Name] = span
+	}
+
+	parents := map[string]string{
+		"http.blob":                "",
+		"blobStore.Stat":           "http.blob",
+		"blobDescriptorCache.Stat": "blobStore.Stat",
+		"storagedriver.GetContent": "blobDescriptorCache.Stat",
+	}
+
+	for name, parent := range parents {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("span %s was not exported: %v", name, spans)
+		}
+
+		expected := "00f067aa0ba902b7"
+		if parent != "" {
+			expected = spans[parent].SpanID
+		}
+
+		if span.ParentID != expected {
+			t.Fatalf("unexpected parent of span %s: %s != %s", name, span.ParentID, expected)
+		}
+	}
+
+	if spans["http.blob"].Attributes["http.status"] != float64(http.StatusNotFound) {
+		t.Fatalf("unexpected request span: %+v", spans["http.blob"])
+	}
+}
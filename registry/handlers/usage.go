@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/gorilla/handlers"
+)
+
+// usageDispatcher constructs the usage handler api endpoint.
+func usageDispatcher(ctx *Context, r *http.Request) http.Handler {
+	usageHandler := &usageHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		"GET": http.HandlerFunc(usageHandler.GetUsage),
+	}
+}
+
+// usageHandler handles requests for the storage used by a repository.
+type usageHandler struct {
+	*Context
+}
+
+// usageAPIResponse describes the storage used by a repository and by the
+// namespaces with a quota enclosing it. Limits are zero when no quota is
+// configured.
+type usageAPIResponse struct {
+	Name       string           `json:"name"`
+	Size       int64            `json:"size"`
+	Limit      int64            `json:"limit,omitempty"`
+	Namespaces []namespaceUsage `json:"namespaces,omitempty"`
+}
+
+type namespaceUsage struct {
+	Namespace string `json:"namespace"`
+	Size      int64  `json:"size"`
+	Limit     int64  `json:"limit"`
+}
+
+// GetUsage returns the storage used by the repository and by the namespaces
+// with a quota enclosing it, along with their limits.
+func (uh *usageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := uh.App.usage(uh, uh.Repository.Name())
+	if err != nil {
+		uh.Errors = append(uh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(usage); err != nil {
+		uh.Errors = append(uh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
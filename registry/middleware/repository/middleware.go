@@ -38,3 +38,10 @@ func Get(ctx context.Context, name string, options map[string]interface{}, repos
 
 	return nil, fmt.Errorf("no repository middleware registered with name: %s", name)
 }
+
+// Registered reports whether a RepositoryMiddleware backend is registered with
+// the given name.
+func Registered(name string) bool {
+	_, exists := middlewares[name]
+	return exists
+}
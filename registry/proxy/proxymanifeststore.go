@@ -6,8 +6,6 @@ import (
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
-	"github.com/docker/distribution/manifest/schema1"
-	"github.com/docker/distribution/registry/client"
 	"github.com/docker/distribution/registry/proxy/scheduler"
 )
 
@@ -36,10 +34,10 @@ func (pms proxyManifestStore) Exists(dgst digest.Digest) (bool, error) {
 	return pms.remoteManifests.Exists(dgst)
 }
 
-func (pms proxyManifestStore) Get(dgst digest.Digest) (*schema1.SignedManifest, error) {
+func (pms proxyManifestStore) Get(dgst digest.Digest) (distribution.Manifest, error) {
 	sm, err := pms.localManifests.Get(dgst)
 	if err == nil {
-		proxyMetrics.ManifestPush(uint64(len(sm.Raw)))
+		proxyMetrics.ManifestPush(payloadSize(sm))
 		return sm, err
 	}
 
@@ -48,8 +46,8 @@ func (pms proxyManifestStore) Get(dgst digest.Digest) (*schema1.SignedManifest,
 		return nil, err
 	}
 
-	proxyMetrics.ManifestPull(uint64(len(sm.Raw)))
-	err = pms.localManifests.Put(sm)
+	proxyMetrics.ManifestPull(payloadSize(sm))
+	_, err = pms.localManifests.Put(sm)
 	if err != nil {
 		return nil, err
 	}
@@ -60,93 +58,22 @@ func (pms proxyManifestStore) Get(dgst digest.Digest) (*schema1.SignedManifest,
 	// Ensure the manifest blob is cleaned up
 	pms.scheduler.AddBlob(dgst.String(), repositoryTTL)
 
-	proxyMetrics.ManifestPush(uint64(len(sm.Raw)))
+	proxyMetrics.ManifestPush(payloadSize(sm))
 
 	return sm, err
 }
 
-func (pms proxyManifestStore) Tags() ([]string, error) {
-	return pms.localManifests.Tags()
-}
-
-func (pms proxyManifestStore) ExistsByTag(tag string) (bool, error) {
-	exists, err := pms.localManifests.ExistsByTag(tag)
-	if err != nil {
-		return false, err
-	}
-	if exists {
-		return true, nil
-	}
-
-	return pms.remoteManifests.ExistsByTag(tag)
-}
-
-func (pms proxyManifestStore) GetByTag(tag string, options ...distribution.ManifestServiceOption) (*schema1.SignedManifest, error) {
-	var localDigest digest.Digest
-
-	localManifest, err := pms.localManifests.GetByTag(tag, options...)
-	switch err.(type) {
-	case distribution.ErrManifestUnknown, distribution.ErrManifestUnknownRevision:
-		goto fromremote
-	case nil:
-		break
-	default:
-		return nil, err
-	}
-
-	localDigest, err = manifestDigest(localManifest)
-	if err != nil {
-		return nil, err
-	}
-
-fromremote:
-	var sm *schema1.SignedManifest
-	sm, err = pms.remoteManifests.GetByTag(tag, client.AddEtagToTag(tag, localDigest.String()))
-	if err != nil && err != distribution.ErrManifestNotModified {
-		return nil, err
-	}
-
-	if err == distribution.ErrManifestNotModified {
-		context.GetLogger(pms.ctx).Debugf("Local manifest for %q is latest, dgst=%s", tag, localDigest.String())
-		return localManifest, nil
-	}
-	context.GetLogger(pms.ctx).Debugf("Updated manifest for %q, dgst=%s", tag, localDigest.String())
-
-	err = pms.localManifests.Put(sm)
-	if err != nil {
-		return nil, err
-	}
-
-	dgst, err := manifestDigest(sm)
-	if err != nil {
-		return nil, err
-	}
-	pms.scheduler.AddBlob(dgst.String(), repositoryTTL)
-	pms.scheduler.AddManifest(pms.repositoryName, repositoryTTL)
-
-	proxyMetrics.ManifestPull(uint64(len(sm.Raw)))
-	proxyMetrics.ManifestPush(uint64(len(sm.Raw)))
-
-	return sm, err
-}
-
-func manifestDigest(sm *schema1.SignedManifest) (digest.Digest, error) {
-	payload, err := sm.Payload()
+// payloadSize returns the size of the manifest as served, for metrics.
+func payloadSize(m distribution.Manifest) uint64 {
+	_, p, err := m.Payload()
 	if err != nil {
-		return "", err
-
+		return 0
 	}
-
-	dgst, err := digest.FromBytes(payload)
-	if err != nil {
-		return "", err
-	}
-
-	return dgst, nil
+	return uint64(len(p))
 }
 
-func (pms proxyManifestStore) Put(manifest *schema1.SignedManifest) error {
-	return distribution.ErrUnsupported
+func (pms proxyManifestStore) Put(manifest distribution.Manifest) (digest.Digest, error) {
+	return "", distribution.ErrUnsupported
 }
 
 func (pms proxyManifestStore) Delete(dgst digest.Digest) error {
@@ -47,31 +47,16 @@ func (sm statsManifest) Exists(dgst digest.Digest) (bool, error) {
 	return sm.manifests.Exists(dgst)
 }
 
-func (sm statsManifest) ExistsByTag(tag string) (bool, error) {
-	sm.stats["existbytag"]++
-	return sm.manifests.ExistsByTag(tag)
-}
-
-func (sm statsManifest) Get(dgst digest.Digest) (*schema1.SignedManifest, error) {
+func (sm statsManifest) Get(dgst digest.Digest) (distribution.Manifest, error) {
 	sm.stats["get"]++
 	return sm.manifests.Get(dgst)
 }
 
-func (sm statsManifest) GetByTag(tag string, options ...distribution.ManifestServiceOption) (*schema1.SignedManifest, error) {
-	sm.stats["getbytag"]++
-	return sm.manifests.GetByTag(tag, options...)
-}
-
-func (sm statsManifest) Put(manifest *schema1.SignedManifest) error {
+func (sm statsManifest) Put(manifest distribution.Manifest) (digest.Digest, error) {
 	sm.stats["put"]++
 	return sm.manifests.Put(manifest)
 }
 
-func (sm statsManifest) Tags() ([]string, error) {
-	sm.stats["tags"]++
-	return sm.manifests.Tags()
-}
-
 func newManifestStoreTestEnv(t *testing.T, name, tag string) *manifestStoreTestEnv {
 	ctx := context.Background()
 	truthRegistry, err := storage.NewRegistry(ctx, inmemory.New(), storage.BlobDescriptorCacheProvider(memory.NewInMemoryBlobDescriptorCacheProvider()))
@@ -169,15 +154,14 @@ func populateRepo(t *testing.T, ctx context.Context, repository distribution.Rep
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
-	ms.Put(sm)
+	dgst, err := ms.Put(sm)
 	if err != nil {
 		t.Fatalf("unexpected errors putting manifest: %v", err)
 	}
-	pl, err := sm.Payload()
-	if err != nil {
-		t.Fatal(err)
+	if err := repository.Tags(ctx).Tag(ctx, tag, distribution.Descriptor{Digest: dgst}); err != nil {
+		t.Fatalf("unexpected errors tagging manifest: %v", err)
 	}
-	return digest.FromBytes(pl)
+	return dgst, nil
 }
 
 // TestProxyManifests contains basic acceptance tests
@@ -190,7 +174,7 @@ func TestProxyManifests(t *testing.T) {
 	remoteStats := env.RemoteStats()
 
 	// Stat - must check local and remote
-	exists, err := env.manifests.ExistsByTag("latest")
+	exists, err := env.manifests.Exists(env.manifestDigest)
 	if err != nil {
 		t.Fatalf("Error checking existance")
 	}
@@ -198,7 +182,7 @@ func TestProxyManifests(t *testing.T) {
 		t.Errorf("Unexpected non-existant manifest")
 	}
 
-	if (*localStats)["existbytag"] != 1 && (*remoteStats)["existbytag"] != 1 {
+	if (*localStats)["exists"] != 1 && (*remoteStats)["exists"] != 1 {
 		t.Errorf("Unexpected exists count")
 	}
 
@@ -216,7 +200,7 @@ func TestProxyManifests(t *testing.T) {
 	}
 
 	// Stat - should only go to local
-	exists, err = env.manifests.ExistsByTag("latest")
+	exists, err = env.manifests.Exists(env.manifestDigest)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -224,7 +208,7 @@ func TestProxyManifests(t *testing.T) {
 		t.Errorf("Unexpected non-existant manifest")
 	}
 
-	if (*localStats)["existbytag"] != 2 && (*remoteStats)["existbytag"] != 1 {
+	if (*localStats)["exists"] != 2 && (*remoteStats)["exists"] != 1 {
 		t.Errorf("Unexpected exists count")
 
 	}
@@ -235,7 +219,7 @@ func TestProxyManifests(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if (*remoteStats)["get"] != 2 && (*remoteStats)["existsbytag"] != 1 && (*localStats)["put"] != 1 {
+	if (*remoteStats)["get"] != 2 && (*remoteStats)["exists"] != 1 && (*localStats)["put"] != 1 {
 		t.Errorf("Unexpected get count")
 	}
 
@@ -3,6 +3,8 @@ package proxy
 import (
 	"expvar"
 	"sync/atomic"
+
+	"github.com/docker/distribution/metrics"
 )
 
 // Metrics is used to hold metric counters
@@ -71,4 +73,31 @@ func init() {
 		return proxyMetrics.manifestMetrics
 	}))
 
+	metrics.Register(
+		proxyCounterFunc("registry_proxy_requests_total",
+			"Number of blobs and manifests served from the proxy cache, by type.",
+			func(m *Metrics) *uint64 { return &m.Requests }),
+		proxyCounterFunc("registry_proxy_hits_total",
+			"Number of blobs and manifests found in the proxy cache, by type.",
+			func(m *Metrics) *uint64 { return &m.Hits }),
+		proxyCounterFunc("registry_proxy_misses_total",
+			"Number of blobs and manifests pulled from the remote registry, by type.",
+			func(m *Metrics) *uint64 { return &m.Misses }),
+		proxyCounterFunc("registry_proxy_pulled_bytes_total",
+			"Bytes of blobs and manifests pulled from the remote registry, by type.",
+			func(m *Metrics) *uint64 { return &m.BytesPulled }),
+		proxyCounterFunc("registry_proxy_pushed_bytes_total",
+			"Bytes of blobs and manifests served from the proxy cache, by type.",
+			func(m *Metrics) *uint64 { return &m.BytesPushed }))
+}
+
+// proxyCounterFunc returns a counter reading the field of the blob and
+// manifest proxy metrics returned by field.
+func proxyCounterFunc(name, help string, field func(m *Metrics) *uint64) metrics.Collector {
+	return metrics.NewCounterFunc(name, help, func() []metrics.Sample {
+		return []metrics.Sample{
+			{Values: []string{"blob"}, Value: float64(atomic.LoadUint64(field(&proxyMetrics.blobMetrics)))},
+			{Values: []string{"manifest"}, Value: float64(atomic.LoadUint64(field(&proxyMetrics.manifestMetrics)))},
+		}
+	}, "type")
 }
@@ -72,7 +72,8 @@ func (pr *proxyingRegistry) Repositories(ctx context.Context, repos []string, la
 
 func (pr *proxyingRegistry) Repository(ctx context.Context, name string) (distribution.Repository, error) {
 	tr := transport.NewTransport(http.DefaultTransport,
-		auth.NewAuthorizer(pr.challengeManager, auth.NewTokenHandler(http.DefaultTransport, pr.credentialStore, name, "pull")))
+		auth.NewAuthorizer(pr.challengeManager, auth.NewTokenHandler(http.DefaultTransport, pr.credentialStore, name, "pull")),
+		transport.NewTraceparentRequestModifier(ctx))
 
 	localRepo, err := pr.embedded.Repository(ctx, name)
 	if err != nil {
@@ -108,6 +109,10 @@ func (pr *proxyingRegistry) Repository(ctx context.Context, name string) (distri
 		},
 		name:       name,
 		signatures: localRepo.Signatures(),
+		tags: proxyTagService{
+			localTags:  localRepo.Tags(ctx),
+			remoteTags: remoteRepo.Tags(ctx),
+		},
 	}, nil
 }
 
@@ -119,6 +124,7 @@ type proxiedRepository struct {
 	manifests  distribution.ManifestService
 	name       string
 	signatures distribution.SignatureService
+	tags       distribution.TagService
 }
 
 func (pr *proxiedRepository) Manifests(ctx context.Context, options ...distribution.ManifestServiceOption) (distribution.ManifestService, error) {
@@ -137,3 +143,7 @@ func (pr *proxiedRepository) Name() string {
 func (pr *proxiedRepository) Signatures() distribution.SignatureService {
 	return pr.signatures
 }
+
+func (pr *proxiedRepository) Tags(ctx context.Context) distribution.TagService {
+	return pr.tags
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"io"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+)
+
+// proxyTagService supports local and remote lookup of tags.
+type proxyTagService struct {
+	localTags  distribution.TagService
+	remoteTags distribution.TagService
+}
+
+var _ distribution.TagService = proxyTagService{}
+
+// Get attempts to get the most recent digest for the tag by checking the remote
+// tag service first and then caching it locally. If the remote is unavailable
+// the local association is returned.
+func (pt proxyTagService) Get(ctx context.Context, tag string) (distribution.Descriptor, error) {
+	desc, err := pt.remoteTags.Get(ctx, tag)
+	if err == nil {
+		// The manifest may not have been pulled through yet, in which case
+		// the tag is cached on a later request.
+		if err := pt.localTags.Tag(ctx, tag, desc); err != nil {
+			context.GetLogger(ctx).Debugf("not caching tag %q locally: %v", tag, err)
+		}
+		return desc, nil
+	}
+
+	desc, err = pt.localTags.Get(ctx, tag)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	return desc, nil
+}
+
+func (pt proxyTagService) Tag(ctx context.Context, tag string, desc distribution.Descriptor) error {
+	return distribution.ErrUnsupported
+}
+
+func (pt proxyTagService) Untag(ctx context.Context, tag string) error {
+	return distribution.ErrUnsupported
+}
+
+// All lists the tags of the remote repository, falling back to the tags
+// cached locally if the remote is unavailable.
+func (pt proxyTagService) All(ctx context.Context) ([]string, error) {
+	tags, err := pt.remoteTags.All(ctx)
+	if err == nil {
+		return tags, nil
+	}
+	return pt.localTags.All(ctx)
+}
+
+// List pages through the tags of the remote repository, falling back to the
+// tags cached locally if the remote is unavailable.
+func (pt proxyTagService) List(ctx context.Context, tags []string, last string) (int, error) {
+	n, err := pt.remoteTags.List(ctx, tags, last)
+	if err == nil || err == io.EOF {
+		return n, err
+	}
+	return pt.localTags.List(ctx, tags, last)
+}
+
+func (pt proxyTagService) Lookup(ctx context.Context, desc distribution.Descriptor) ([]string, error) {
+	return pt.localTags.Lookup(ctx, desc)
+}
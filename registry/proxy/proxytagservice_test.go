@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+)
+
+type mockTagStore struct {
+	mapping map[string]distribution.Descriptor
+	sync.Mutex
+}
+
+var _ distribution.TagService = &mockTagStore{}
+
+func (m *mockTagStore) Get(ctx context.Context, tag string) (distribution.Descriptor, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if d, ok := m.mapping[tag]; ok {
+		return d, nil
+	}
+	return distribution.Descriptor{}, distribution.ErrTagUnknown{Tag: tag}
+}
+
+func (m *mockTagStore) Tag(ctx context.Context, tag string, desc distribution.Descriptor) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.mapping[tag] = desc
+	return nil
+}
+
+func (m *mockTagStore) Untag(ctx context.Context, tag string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.mapping[tag]; ok {
+		delete(m.mapping, tag)
+		return nil
+	}
+	return distribution.ErrTagUnknown{Tag: tag}
+}
+
+func (m *mockTagStore) All(ctx context.Context) ([]string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	var tags []string
+	for tag := range m.mapping {
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+func (m *mockTagStore) List(ctx context.Context, tags []string, last string) (int, error) {
+	all, err := m.All(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(all)
+
+	var remaining []string
+	for _, tag := range all {
+		if tag > last {
+			remaining = append(remaining, tag)
+		}
+	}
+
+	n := copy(tags, remaining)
+	if n == len(remaining) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *mockTagStore) Lookup(ctx context.Context, desc distribution.Descriptor) ([]string, error) {
+	panic("not implemented")
+}
+
+func testProxyTagService(local, remote map[string]distribution.Descriptor) *proxyTagService {
+	if remote == nil {
+		remote = make(map[string]distribution.Descriptor)
+	}
+	if local == nil {
+		local = make(map[string]distribution.Descriptor)
+	}
+	return &proxyTagService{
+		localTags:  &mockTagStore{mapping: local},
+		remoteTags: &mockTagStore{mapping: remote},
+	}
+}
+
+func TestProxyTagGet(t *testing.T) {
+	remoteDesc := distribution.Descriptor{Size: 42}
+	remoteTag := "remote"
+	proxyTags := testProxyTagService(nil, map[string]distribution.Descriptor{remoteTag: remoteDesc})
+
+	ctx := context.Background()
+
+	// Get pre-loaded tag
+	d, err := proxyTags.Get(ctx, remoteTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(d, remoteDesc) {
+		t.Fatal("unable to get put tag")
+	}
+
+	local, err := proxyTags.localTags.Get(ctx, remoteTag)
+	if err != nil {
+		t.Fatal("remote tag not pulled into store")
+	}
+
+	if !reflect.DeepEqual(local, remoteDesc) {
+		t.Fatalf("unexpected descriptor pulled through")
+	}
+
+	// Manually overwrite remote tag
+	newRemoteDesc := distribution.Descriptor{Size: 43}
+	err = proxyTags.remoteTags.Tag(ctx, remoteTag, newRemoteDesc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = proxyTags.Get(ctx, remoteTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(d, newRemoteDesc) {
+		t.Fatal("unable to get put tag")
+	}
+
+	_, err = proxyTags.localTags.Get(ctx, remoteTag)
+	if err != nil {
+		t.Fatal("remote tag not pulled into store")
+	}
+
+	// The proxy is read-only.
+	err = proxyTags.Untag(ctx, remoteTag)
+	if err != distribution.ErrUnsupported {
+		t.Fatalf("unexpected error untagging: %v", err)
+	}
+
+	// Tags are served from the remote, falling back to the local cache.
+	err = proxyTags.remoteTags.Tag(ctx, "other", remoteDesc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := proxyTags.All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(all)
+
+	if expected := []string{"other", remoteTag}; !reflect.DeepEqual(all, expected) {
+		t.Fatalf("unexpected tags: %v != %v", all, expected)
+	}
+
+	// A tag missing from the remote is resolved from the local cache.
+	if err := proxyTags.remoteTags.Untag(ctx, remoteTag); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = proxyTags.Get(ctx, remoteTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(d, newRemoteDesc) {
+		t.Fatal("unexpected descriptor from local tag store")
+	}
+
+	tags := make([]string, 1)
+	n, err := proxyTags.List(ctx, tags, "")
+	if err != io.EOF {
+		t.Fatalf("expected all tags to be listed: %v", err)
+	}
+
+	if n != 1 || tags[0] != "other" {
+		t.Fatalf("unexpected first page of tags: %v", tags[:n])
+	}
+
+	if err := proxyTags.Tag(ctx, "new", remoteDesc); err != distribution.ErrUnsupported {
+		t.Fatalf("unexpected error tagging: %v", err)
+	}
+}
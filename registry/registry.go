@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -19,12 +21,12 @@ import (
 	"github.com/docker/distribution/registry/listener"
 	"github.com/docker/distribution/uuid"
 	"github.com/docker/distribution/version"
-	gorhandlers "github.com/gorilla/handlers"
 	"github.com/spf13/cobra"
 	"github.com/yvasiyarov/gorelic"
 )
 
-// Cmd is a cobra command for running the registry.
+// Cmd is a cobra command for running the registry. Its subcommands maintain
+// the storage and configuration of the registry.
 var Cmd = &cobra.Command{
 	Use:   "registry <config>",
 	Short: "registry stores and distributes Docker images",
@@ -59,15 +61,41 @@ var Cmd = &cobra.Command{
 			log.Fatalln(err)
 		}
 
+		go registry.reloadOnSignal(args)
+
 		if err = registry.ListenAndServe(); err != nil {
 			log.Fatalln(err)
 		}
 	},
 }
 
+// Execute runs the registry command, or the subcommand named by the first
+// argument. Cobra takes any other argument of a command with subcommands for
+// an unknown subcommand, so the configuration file argument of the registry
+// command is handled here. The help subcommand is only added by cobra once
+// executing.
+func Execute() error {
+	args := os.Args[1:]
+	if _, _, err := Cmd.Find(args); err == nil || args[0] == "help" {
+		return Cmd.Execute()
+	}
+
+	if err := Cmd.ParseFlags(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		Cmd.Usage()
+		return err
+	}
+
+	Cmd.Run(Cmd, Cmd.Flags().Args())
+	return nil
+}
+
 var showVersion bool
 
 func init() {
+	Cmd.AddCommand(GCCmd)
+	Cmd.AddCommand(RebuildCatalogIndexCmd)
+	Cmd.AddCommand(ValidateConfigCmd)
 	Cmd.PersistentFlags().BoolVarP(&showVersion, "version", "v", false, "show the version and exit")
 }
 
@@ -99,7 +127,6 @@ func NewRegistry(ctx context.Context, config *configuration.Configuration) (*Reg
 	handler = alive("/", handler)
 	handler = health.Handler(handler)
 	handler = panicHandler(handler)
-	handler = gorhandlers.CombinedLoggingHandler(os.Stdout, handler)
 
 	server := &http.Server{
 		Handler: handler,
@@ -112,6 +139,54 @@ func NewRegistry(ctx context.Context, config *configuration.Configuration) (*Reg
 	}, nil
 }
 
+// Reload applies the reloadable parts of config to the running registry: the
+// log level, formatter and hooks, the notification endpoints, the access
+// controller and the health checks. The configuration is refused if it
+// changes anything else, such as the storage driver, which requires a
+// restart.
+func (registry *Registry) Reload(config *configuration.Configuration) error {
+	// The formatter is checked first, so that nothing is applied if the
+	// configuration is refused.
+	switch config.Log.Formatter {
+	case "", "text", "json", "logstash":
+	default:
+		return fmt.Errorf("unsupported logging formatter: %q", config.Log.Formatter)
+	}
+
+	// The configuration the server was started with is kept, as it only
+	// differs in the reloadable parts, which the app applies.
+	if err := registry.app.Reload(config); err != nil {
+		return err
+	}
+
+	if _, err := configureLogging(registry.app, config); err != nil {
+		return fmt.Errorf("error configuring logger: %v", err)
+	}
+
+	return nil
+}
+
+// reloadOnSignal reloads the configuration resolved from args each time the
+// process receives SIGHUP.
+func (registry *Registry) reloadOnSignal(args []string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		context.GetLogger(registry.app).Infof("reloading configuration")
+
+		config, err := resolveConfiguration(args)
+		if err != nil {
+			context.GetLogger(registry.app).Errorf("configuration not reloaded: %v", err)
+			continue
+		}
+
+		if err := registry.Reload(config); err != nil {
+			context.GetLogger(registry.app).Errorf("configuration not reloaded: %v", err)
+		}
+	}
+}
+
 // ListenAndServe runs the registry's HTTP server.
 func (registry *Registry) ListenAndServe() error {
 	config := registry.config
@@ -179,31 +254,31 @@ func (registry *Registry) ListenAndServe() error {
 func configureReporting(app *handlers.App) http.Handler {
 	var handler http.Handler = app
 
-	if app.Config.Reporting.Bugsnag.APIKey != "" {
+	if app.Config().Reporting.Bugsnag.APIKey != "" {
 		bugsnagConfig := bugsnag.Configuration{
-			APIKey: app.Config.Reporting.Bugsnag.APIKey,
+			APIKey: app.Config().Reporting.Bugsnag.APIKey,
 			// TODO(brianbland): provide the registry version here
 			// AppVersion: "2.0",
 		}
-		if app.Config.Reporting.Bugsnag.ReleaseStage != "" {
-			bugsnagConfig.ReleaseStage = app.Config.Reporting.Bugsnag.ReleaseStage
+		if app.Config().Reporting.Bugsnag.ReleaseStage != "" {
+			bugsnagConfig.ReleaseStage = app.Config().Reporting.Bugsnag.ReleaseStage
 		}
-		if app.Config.Reporting.Bugsnag.Endpoint != "" {
-			bugsnagConfig.Endpoint = app.Config.Reporting.Bugsnag.Endpoint
+		if app.Config().Reporting.Bugsnag.Endpoint != "" {
+			bugsnagConfig.Endpoint = app.Config().Reporting.Bugsnag.Endpoint
 		}
 		bugsnag.Configure(bugsnagConfig)
 
 		handler = bugsnag.Handler(handler)
 	}
 
-	if app.Config.Reporting.NewRelic.LicenseKey != "" {
+	if app.Config().Reporting.NewRelic.LicenseKey != "" {
 		agent := gorelic.NewAgent()
-		agent.NewrelicLicense = app.Config.Reporting.NewRelic.LicenseKey
-		if app.Config.Reporting.NewRelic.Name != "" {
-			agent.NewrelicName = app.Config.Reporting.NewRelic.Name
+		agent.NewrelicLicense = app.Config().Reporting.NewRelic.LicenseKey
+		if app.Config().Reporting.NewRelic.Name != "" {
+			agent.NewrelicName = app.Config().Reporting.NewRelic.Name
 		}
 		agent.CollectHTTPStat = true
-		agent.Verbose = app.Config.Reporting.NewRelic.Verbose
+		agent.Verbose = app.Config().Reporting.NewRelic.Verbose
 		agent.Run()
 
 		handler = agent.WrapHTTPHandler(handler)
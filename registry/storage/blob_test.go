@@ -12,6 +12,7 @@ import (
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/storage/cache/memory"
 	"github.com/docker/distribution/registry/storage/driver/inmemory"
 	"github.com/docker/distribution/testutil"
@@ -321,6 +322,79 @@ func TestSimpleBlobRead(t *testing.T) {
 }
 
 // TestLayerUploadZeroLength uploads zero-length
+// TestBlobMount covers mounting a blob linked in one repository into another
+// and the fall back to an upload when the blob cannot be mounted.
+func TestBlobMount(t *testing.T) {
+	env := newGCTestEnv(t)
+	ctx := env.ctx
+	source := env.repository(t, "foo/source")
+	target := env.repository(t, "foo/target")
+
+	desc := uploadRandomBlob(t, ctx, source)
+
+	sourceName, err := reference.ParseNamed(source.Name())
+	if err != nil {
+		t.Fatalf("unexpected error parsing name: %v", err)
+	}
+
+	canonical, err := reference.WithDigest(sourceName, desc.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error building reference: %v", err)
+	}
+
+	bs := target.Blobs(ctx)
+	if _, err := bs.Stat(ctx, desc.Digest); err != distribution.ErrBlobUnknown {
+		t.Fatalf("expected blob to be unknown in target before mount: %v", err)
+	}
+
+	wr, err := bs.Create(ctx, withMountFrom(canonical))
+	ebm, ok := err.(distribution.ErrBlobMounted)
+	if !ok {
+		t.Fatalf("expected blob mounted error: %#v", err)
+	}
+
+	if wr != nil {
+		t.Fatalf("unexpected writer returned for mounted blob")
+	}
+
+	if ebm.Descriptor.Digest != desc.Digest || ebm.Descriptor.Size != desc.Size {
+		t.Fatalf("unexpected descriptor for mounted blob: %v != %v", ebm.Descriptor, desc)
+	}
+
+	mounted, err := bs.Stat(ctx, desc.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error checking mounted blob: %v", err)
+	}
+
+	if mounted.Digest != desc.Digest {
+		t.Fatalf("unexpected digest for mounted blob: %s != %s", mounted.Digest, desc.Digest)
+	}
+
+	// A blob not linked in the source repository falls back to an upload.
+	other := uploadRandomBlob(t, ctx, env.repository(t, "foo/other"))
+	canonical, err = reference.WithDigest(sourceName, other.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error building reference: %v", err)
+	}
+
+	wr, err = bs.Create(ctx, withMountFrom(canonical))
+	if err != nil {
+		t.Fatalf("unexpected error starting upload: %v", err)
+	}
+
+	if err := wr.Cancel(ctx); err != nil {
+		t.Fatalf("unexpected error cancelling upload: %v", err)
+	}
+}
+
+func withMountFrom(ref reference.Canonical) distribution.BlobCreateOption {
+	return func(opts *distribution.CreateOptions) error {
+		opts.Mount.ShouldMount = true
+		opts.Mount.From = ref
+		return nil
+	}
+}
+
 func TestLayerUploadZeroLength(t *testing.T) {
 	ctx := context.Background()
 	imageName := "foo/bar"
@@ -4,6 +4,7 @@ import (
 	"expvar"
 	"sync/atomic"
 
+	"github.com/docker/distribution/metrics"
 	"github.com/docker/distribution/registry/storage/cache"
 )
 
@@ -57,4 +58,14 @@ func init() {
 		// numbers will always *eventually* be reported correctly.
 		return blobStatterCacheMetrics
 	}))
+
+	metrics.Register(metrics.NewCounterFunc("registry_storage_cache_requests_total",
+		"Number of blob descriptor cache requests, by result.",
+		func() []metrics.Sample {
+			bsc := blobStatterCacheMetrics.(*blobStatCollector)
+			return []metrics.Sample{
+				{Values: []string{"hit"}, Value: float64(atomic.LoadUint64(&bsc.metrics.Hits))},
+				{Values: []string{"miss"}, Value: float64(atomic.LoadUint64(&bsc.metrics.Misses))},
+			}
+		}, "result"))
 }
@@ -49,8 +49,12 @@ func NewCachedBlobStatterWithMetrics(cache distribution.BlobDescriptorService, b
 	}
 }
 
-func (cbds *cachedBlobStatter) Stat(ctx context.Context, dgst digest.Digest) (distribution.Descriptor, error) {
-	desc, err := cbds.cache.Stat(ctx, dgst)
+func (cbds *cachedBlobStatter) Stat(ctx context.Context, dgst digest.Digest) (desc distribution.Descriptor, err error) {
+	ctx, span := context.WithSpan(ctx, "blobDescriptorCache.Stat")
+	span.SetAttribute("vars.digest", dgst.String())
+	defer func() { span.Finish(err) }()
+
+	desc, err = cbds.cache.Stat(ctx, dgst)
 	if err != nil {
 		if err != distribution.ErrBlobUnknown {
 			context.GetLogger(ctx).Errorf("error retrieving descriptor from cache: %v", err)
@@ -62,11 +66,13 @@ func (cbds *cachedBlobStatter) Stat(ctx context.Context, dgst digest.Digest) (di
 	if cbds.tracker != nil {
 		cbds.tracker.Hit()
 	}
+	span.SetAttribute("cache.hit", true)
 	return desc, nil
 fallback:
 	if cbds.tracker != nil {
 		cbds.tracker.Miss()
 	}
+	span.SetAttribute("cache.hit", false)
 	desc, err = cbds.backend.Stat(ctx, dgst)
 	if err != nil {
 		return desc, err
@@ -13,7 +13,8 @@ import (
 
 // Returns a list, or partial list, of repositories in the registry.
 // Because it's a quite expensive operation, it should only be used when building up
-// an initial set of repositories.
+// an initial set of repositories. If the catalog index is enabled, the
+// repositories are paged from the index instead of walking the storage.
 func (reg *registry) Repositories(ctx context.Context, repos []string, last string) (n int, err error) {
 	var foundRepos []string
 	var errVal error
@@ -22,9 +23,8 @@ func (reg *registry) Repositories(ctx context.Context, repos []string, last stri
 		return 0, errors.New("no space in slice")
 	}
 
-	root, err := pathFor(repositoriesRootPathSpec{})
-	if err != nil {
-		return 0, err
+	if reg.catalogIndexEnabled {
+		return listCatalogIndex(ctx, reg.blobStore.driver, repos, last)
 	}
 
 	// Walk each of the directories in our storage.  Unfortunately since there's no
@@ -32,7 +32,38 @@ func (reg *registry) Repositories(ctx context.Context, repos []string, last stri
 	// to store everything another slice, sort it and then copy it back to our
 	// passed in slice.
 
-	Walk(ctx, reg.blobStore.driver, root, func(fileInfo driver.FileInfo) error {
+	err = walkRepositories(ctx, reg.blobStore.driver, func(repoPath string) error {
+		if repoPath > last {
+			foundRepos = append(foundRepos, repoPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sort.Strings(foundRepos)
+	n = copy(repos, foundRepos)
+
+	// Signal that we have no more entries by setting EOF
+	if len(foundRepos) <= len(repos) {
+		errVal = io.EOF
+	}
+
+	return n, errVal
+
+}
+
+// walkRepositories calls fn with the name of every repository in the
+// storage, in no particular order. A repository is any directory holding a
+// _layers directory.
+func walkRepositories(ctx context.Context, storageDriver driver.StorageDriver, fn func(name string) error) error {
+	root, err := pathFor(repositoriesRootPathSpec{})
+	if err != nil {
+		return err
+	}
+
+	err = Walk(ctx, storageDriver, root, func(fileInfo driver.FileInfo) error {
 		filePath := fileInfo.Path()
 
 		// lop the base path off
@@ -41,8 +72,8 @@ func (reg *registry) Repositories(ctx context.Context, repos []string, last stri
 		_, file := path.Split(repoPath)
 		if file == "_layers" {
 			repoPath = strings.TrimSuffix(repoPath, "/_layers")
-			if repoPath > last {
-				foundRepos = append(foundRepos, repoPath)
+			if err := fn(repoPath); err != nil {
+				return err
 			}
 			return ErrSkipDir
 		} else if strings.HasPrefix(file, "_") {
@@ -52,14 +83,10 @@ func (reg *registry) Repositories(ctx context.Context, repos []string, last stri
 		return nil
 	})
 
-	sort.Strings(foundRepos)
-	n = copy(repos, foundRepos)
-
-	// Signal that we have no more entries by setting EOF
-	if len(foundRepos) <= len(repos) {
-		errVal = io.EOF
+	if isPathNotFound(err, root) {
+		// No repositories have been created yet.
+		return nil
 	}
 
-	return n, errVal
-
+	return err
 }
@@ -2,12 +2,18 @@ package storage
 
 import (
 	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/registry/storage/cache/memory"
 	"github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/filesystem"
 	"github.com/docker/distribution/registry/storage/driver/inmemory"
 )
 
@@ -115,6 +121,202 @@ func TestCatalogInParts(t *testing.T) {
 
 }
 
+// pageCatalog collects the whole catalog of registry in pages of size n.
+func pageCatalog(t *testing.T, ctx context.Context, registry distribution.Namespace, n int) []string {
+	var all []string
+	last := ""
+	for {
+		p := make([]string, n)
+		filled, err := registry.Repositories(ctx, p, last)
+		if err != nil && err != io.EOF {
+			t.Fatalf("unexpected error listing catalog: %v", err)
+		}
+
+		all = append(all, p[:filled]...)
+		if err == io.EOF {
+			return all
+		}
+		last = p[filled-1]
+	}
+}
+
+func TestCatalogIndex(t *testing.T) {
+	ctx := context.Background()
+	d := inmemory.New()
+	registry, err := NewRegistry(ctx, d, EnableCatalogIndex)
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	// Names sharing a prefix and names prefixing others.
+	names := []string{"foo/b", "a", "bar/d", "ab/c", "foo/a", "b/x"}
+	for _, name := range names {
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repository: %v", err)
+		}
+		uploadRandomBlob(t, ctx, repo)
+	}
+
+	expected := []string{"a", "ab/c", "b/x", "bar/d", "foo/a", "foo/b"}
+	for _, n := range []int{1, 2, 4, 6, 50} {
+		if all := pageCatalog(t, ctx, registry, n); !reflect.DeepEqual(all, expected) {
+			t.Fatalf("unexpected catalog in pages of %d: %v != %v", n, all, expected)
+		}
+	}
+
+	if err := NewVacuum(ctx, d).RemoveRepository("bar/d"); err != nil {
+		t.Fatalf("unexpected error removing repository: %v", err)
+	}
+
+	expected = []string{"a", "ab/c", "b/x", "foo/a", "foo/b"}
+	if all := pageCatalog(t, ctx, registry, 2); !reflect.DeepEqual(all, expected) {
+		t.Fatalf("unexpected catalog after removal: %v != %v", all, expected)
+	}
+}
+
+func TestCatalogIndexOnUpload(t *testing.T) {
+	ctx := context.Background()
+	registry, err := NewRegistry(ctx, inmemory.New(), EnableCatalogIndex)
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	repo, err := registry.Repository(ctx, "library/new")
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	// The repository is indexed as soon as the first upload starts.
+	wr, err := repo.Blobs(ctx).Create(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error starting upload: %v", err)
+	}
+	defer wr.Cancel(ctx)
+
+	if all, expected := pageCatalog(t, ctx, registry, 10), []string{"library/new"}; !reflect.DeepEqual(all, expected) {
+		t.Fatalf("unexpected catalog: %v != %v", all, expected)
+	}
+}
+
+func TestCatalogIndexOrder(t *testing.T) {
+	ctx := context.Background()
+	registry, err := NewRegistry(ctx, inmemory.New(), EnableCatalogIndex)
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	// Separators sorting before and after the slash replaced in the index.
+	names := []string{"a_b", "a/b", "a.b", "a0", "a-b", "a--b", "a", "a/b-c", "a/b/c", "a__b"}
+	for _, name := range names {
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repository: %v", err)
+		}
+		uploadRandomBlob(t, ctx, repo)
+	}
+
+	expected := append([]string(nil), names...)
+	sort.Strings(expected)
+	for _, n := range []int{1, 3, 50} {
+		if all := pageCatalog(t, ctx, registry, n); !reflect.DeepEqual(all, expected) {
+			t.Fatalf("unexpected catalog in pages of %d: %v != %v", n, all, expected)
+		}
+	}
+}
+
+func TestCatalogIndexLongNames(t *testing.T) {
+	root, err := ioutil.TempDir("", "catalog-index-")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	ctx := context.Background()
+	d := filesystem.New(root)
+
+	names := []string{
+		// Entries fitting in a file name.
+		strings.Repeat("a", 120) + "/" + strings.Repeat("b", 130),
+		// Entries truncated after escaping, sharing their prefix.
+		"x" + strings.Repeat("-y", 127),
+		"x" + strings.Repeat("-y", 126) + "-z",
+	}
+
+	// Without the index, nothing is written to it.
+	registry, err := NewRegistry(ctx, d)
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	for _, name := range names {
+		repo, err := registry.Repository(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error getting repository: %v", err)
+		}
+		uploadRandomBlob(t, ctx, repo)
+	}
+
+	indexPath, err := pathFor(catalogIndexPathSpec{})
+	if err != nil {
+		t.Fatalf("unexpected error resolving index path: %v", err)
+	}
+
+	if indexed, err := exists(ctx, d, indexPath); err != nil || indexed {
+		t.Fatalf("unexpected catalog index written while disabled: %v", err)
+	}
+
+	registry, err = NewRegistry(ctx, d, EnableCatalogIndex)
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	if _, err := RebuildCatalogIndex(ctx, d); err != nil {
+		t.Fatalf("unexpected error rebuilding catalog index: %v", err)
+	}
+
+	// Truncated entries sharing their prefix are listed in the order of
+	// their hashes.
+	for _, n := range []int{1, 50} {
+		all := pageCatalog(t, ctx, registry, n)
+		sort.Strings(all)
+		if expected := names; !reflect.DeepEqual(all, expected) {
+			t.Fatalf("unexpected catalog in pages of %d: %v != %v", n, all, expected)
+		}
+	}
+}
+
+func TestRebuildCatalogIndex(t *testing.T) {
+	env := setupFS(t)
+
+	registry, err := NewRegistry(env.ctx, env.driver, EnableCatalogIndex)
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	// The repositories were written without maintaining the index.
+	if all := pageCatalog(t, env.ctx, registry, 2); len(all) != 0 {
+		t.Fatalf("unexpected catalog before rebuild: %v", all)
+	}
+
+	if err := indexRepository(env.ctx, env.driver, "gone/repo"); err != nil {
+		t.Fatalf("unexpected error indexing repository: %v", err)
+	}
+
+	indexed, err := RebuildCatalogIndex(env.ctx, env.driver)
+	if err != nil {
+		t.Fatalf("unexpected error rebuilding catalog index: %v", err)
+	}
+
+	if indexed != len(env.expected) {
+		t.Fatalf("unexpected number of indexed repositories: %d != %d", indexed, len(env.expected))
+	}
+
+	if all := pageCatalog(t, env.ctx, registry, 2); !reflect.DeepEqual(all, env.expected) {
+		t.Fatalf("unexpected catalog after rebuild: %v != %v", all, env.expected)
+	}
+}
+
 func testEq(a, b []string, size int) bool {
 	for cnt := 0; cnt < size-1; cnt++ {
 		if a[cnt] != b[cnt] {
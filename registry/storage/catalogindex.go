@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage/driver"
+)
+
+// The catalog index keeps a file per repository, named after the escaped
+// repository name, in a single directory. The escaping keeps the order of the
+// names, so that paging through the index only lists the requested page from
+// backends supporting it, such as s3, rather than walking every repository in
+// the storage. Repositories are indexed when they come into existence, with
+// their first upload, mount or manifest, and removed with the repository.
+// Repositories only holding abandoned uploads stay indexed until the index is
+// rebuilt.
+//
+// The index is only maintained while it is enabled, so RebuildCatalogIndex
+// must be run before enabling it on storage written without it.
+
+// catalogIndexEntryMaxLength is the length of the longest catalog index entry
+// name, which file systems limit to 255 bytes.
+const catalogIndexEntryMaxLength = 255
+
+// catalogIndexEntryName returns the name of the catalog index entry of the
+// named repository. Slashes can't appear in file names, so they are replaced
+// by periods, while hyphens and periods, which sort before slashes, are
+// escaped with a hyphen. This keeps the order of the names. Entries which
+// would be too long are truncated and suffixed with the hash of the name,
+// ordering them by hash among the names sharing their prefix.
+func catalogIndexEntryName(name string) string {
+	var entry bytes.Buffer
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case '-', '.':
+			entry.WriteByte('-')
+			entry.WriteByte(c)
+		case '/':
+			entry.WriteByte('.')
+		default:
+			entry.WriteByte(c)
+		}
+	}
+
+	if entry.Len() < catalogIndexEntryMaxLength {
+		return entry.String()
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	return string(entry.Bytes()[:catalogIndexEntryMaxLength-2*sha256.Size]) + hex.EncodeToString(sum[:])
+}
+
+// catalogIndexEntryRepository returns the name of the repository of the
+// catalog index entry at entryPath. Truncated entries hold the name of their
+// repository.
+func catalogIndexEntryRepository(ctx context.Context, storageDriver driver.StorageDriver, entryPath string) (string, error) {
+	entry := path.Base(entryPath)
+	if len(entry) >= catalogIndexEntryMaxLength {
+		name, err := storageDriver.GetContent(ctx, entryPath)
+		return string(name), err
+	}
+
+	var name bytes.Buffer
+	for i := 0; i < len(entry); i++ {
+		switch c := entry[i]; c {
+		case '-':
+			if i++; i == len(entry) || (entry[i] != '-' && entry[i] != '.') {
+				return "", fmt.Errorf("invalid escape in catalog index entry %q", entry)
+			}
+			name.WriteByte(entry[i])
+		case '.':
+			name.WriteByte('/')
+		default:
+			name.WriteByte(c)
+		}
+	}
+
+	return name.String(), nil
+}
+
+// indexRepository adds the named repository to the catalog index, if it is
+// not there yet.
+func indexRepository(ctx context.Context, storageDriver driver.StorageDriver, name string) error {
+	entryPath, err := pathFor(catalogIndexEntryPathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	indexed, err := exists(ctx, storageDriver, entryPath)
+	if err != nil || indexed {
+		return err
+	}
+
+	// The entry holds the plain name to ease inspection of the index.
+	return storageDriver.PutContent(ctx, entryPath, []byte(name))
+}
+
+// unindexRepository removes the named repository from the catalog index.
+func unindexRepository(ctx context.Context, storageDriver driver.StorageDriver, name string) error {
+	entryPath, err := pathFor(catalogIndexEntryPathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	if err := storageDriver.Delete(ctx, entryPath); err != nil {
+		switch err.(type) {
+		case driver.PathNotFoundError:
+			return nil
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listCatalogIndex fills repos with the lexically sorted repository names
+// following last, as recorded in the catalog index. io.EOF is returned if
+// there are no more repositories.
+func listCatalogIndex(ctx context.Context, storageDriver driver.StorageDriver, repos []string, last string) (int, error) {
+	indexPath, err := pathFor(catalogIndexPathSpec{})
+	if err != nil {
+		return 0, err
+	}
+
+	var after string
+	if last != "" {
+		after, err = pathFor(catalogIndexEntryPathSpec{name: last})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := listIndexPage(ctx, storageDriver, indexPath, after, repos, func(entryPath string) (string, error) {
+		return catalogIndexEntryRepository(ctx, storageDriver, entryPath)
+	})
+	if _, ok := err.(driver.PathNotFoundError); ok {
+		// No repositories have been indexed yet.
+		return 0, io.EOF
+	}
+
+	return n, err
+}
+
+// listIndexPage fills page with the names decoded by decode from the entries
+// of the index directory at dirPath which follow the entry after. Only one
+// more entry than requested is listed, telling whether io.EOF must be
+// returned.
+func listIndexPage(ctx context.Context, storageDriver driver.StorageDriver, dirPath, after string, page []string, decode func(entryPath string) (string, error)) (int, error) {
+	entries, err := driver.ListAfter(ctx, storageDriver, dirPath, after, len(page)+1)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, entry := range entries {
+		if n == len(page) {
+			return n, nil
+		}
+
+		name, err := decode(entry)
+		if err != nil {
+			context.GetLogger(ctx).Warnf("ignoring invalid index entry %q: %v", entry, err)
+			continue
+		}
+
+		page[n] = name
+		n++
+	}
+
+	return n, io.EOF
+}
+
+// RebuildCatalogIndex makes the catalog index match the repositories in the
+// storage, adding missing repositories and removing stale entries, and
+// rebuilds the tag list of every repository the same way. It must be run
+// once before enabling the index on an existing deployment and is safe to run
+// while the registry accepts writes. The number of indexed repositories is
+// returned.
+func RebuildCatalogIndex(ctx context.Context, storageDriver driver.StorageDriver) (int, error) {
+	found := make(map[string]struct{})
+	err := walkRepositories(ctx, storageDriver, func(name string) error {
+		found[name] = struct{}{}
+		if err := indexRepository(ctx, storageDriver, name); err != nil {
+			return err
+		}
+		return rebuildTagList(ctx, storageDriver, name)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	indexPath, err := pathFor(catalogIndexPathSpec{})
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := listOrEmpty(ctx, storageDriver, indexPath)
+	if err != nil {
+		return 0, err
+	}
+
+	root, err := pathFor(repositoriesRootPathSpec{})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		name, err := catalogIndexEntryRepository(ctx, storageDriver, entry)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, ok := found[name]; ok {
+			continue
+		}
+
+		// Repositories created since the walk are indexed by their
+		// writers, if the index is enabled, so only remove entries which
+		// are really gone.
+		live, err := repositoryHasContent(ctx, storageDriver, path.Join(root, name))
+		if err != nil {
+			return 0, err
+		} else if live {
+			continue
+		}
+
+		context.GetLogger(ctx).Infof("removing stale catalog index entry for %s", name)
+		if err := unindexRepository(ctx, storageDriver, name); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(found), nil
+}
+
+// repositoryHasContent reports whether any layer or manifest is linked in the
+// repository directory at repoPath.
+func repositoryHasContent(ctx context.Context, storageDriver driver.StorageDriver, repoPath string) (bool, error) {
+	for _, dir := range []string{"_layers", "_manifests"} {
+		ok, err := exists(ctx, storageDriver, path.Join(repoPath, dir))
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
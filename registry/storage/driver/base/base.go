@@ -86,7 +86,9 @@ func (base *Base) GetContent(ctx context.Context, path string) ([]byte, error) {
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startCall(ctx, "GetContent", path)
 	b, e := base.StorageDriver.GetContent(ctx, path)
+	base.observe("GetContent", span, e)
 	return b, base.setDriverName(e)
 }
 
@@ -99,7 +101,10 @@ func (base *Base) PutContent(ctx context.Context, path string, content []byte) e
 		return storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
-	return base.setDriverName(base.StorageDriver.PutContent(ctx, path, content))
+	ctx, span := base.startCall(ctx, "PutContent", path)
+	e := base.StorageDriver.PutContent(ctx, path, content)
+	base.observe("PutContent", span, e)
+	return base.setDriverName(e)
 }
 
 // ReadStream wraps ReadStream of underlying storage driver.
@@ -115,7 +120,9 @@ func (base *Base) ReadStream(ctx context.Context, path string, offset int64) (io
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startCall(ctx, "ReadStream", path)
 	rc, e := base.StorageDriver.ReadStream(ctx, path, offset)
+	base.observe("ReadStream", span, e)
 	return rc, base.setDriverName(e)
 }
 
@@ -132,7 +139,9 @@ func (base *Base) WriteStream(ctx context.Context, path string, offset int64, re
 		return 0, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startCall(ctx, "WriteStream", path)
 	i64, e := base.StorageDriver.WriteStream(ctx, path, offset, reader)
+	base.observe("WriteStream", span, e)
 	return i64, base.setDriverName(e)
 }
 
@@ -145,7 +154,9 @@ func (base *Base) Stat(ctx context.Context, path string) (storagedriver.FileInfo
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startCall(ctx, "Stat", path)
 	fi, e := base.StorageDriver.Stat(ctx, path)
+	base.observe("Stat", span, e)
 	return fi, base.setDriverName(e)
 }
 
@@ -158,7 +169,25 @@ func (base *Base) List(ctx context.Context, path string) ([]string, error) {
 		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startCall(ctx, "List", path)
 	str, e := base.StorageDriver.List(ctx, path)
+	base.observe("List", span, e)
+	return str, base.setDriverName(e)
+}
+
+// ListAfter wraps ListAfter of underlying storage driver, falling back to
+// listing the whole directory if it is not a PagedLister.
+func (base *Base) ListAfter(ctx context.Context, path string, after string, limit int) ([]string, error) {
+	ctx, done := context.WithTrace(ctx)
+	defer done("%s.ListAfter(%q, %q, %d)", base.Name(), path, after, limit)
+
+	if !storagedriver.PathRegexp.MatchString(path) && path != "/" {
+		return nil, storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
+	}
+
+	ctx, span := base.startCall(ctx, "ListAfter", path)
+	str, e := storagedriver.ListAfter(ctx, base.StorageDriver, path, after, limit)
+	base.observe("ListAfter", span, e)
 	return str, base.setDriverName(e)
 }
 
@@ -173,7 +202,10 @@ func (base *Base) Move(ctx context.Context, sourcePath string, destPath string)
 		return storagedriver.InvalidPathError{Path: destPath, DriverName: base.StorageDriver.Name()}
 	}
 
-	return base.setDriverName(base.StorageDriver.Move(ctx, sourcePath, destPath))
+	ctx, span := base.startCall(ctx, "Move", sourcePath)
+	e := base.StorageDriver.Move(ctx, sourcePath, destPath)
+	base.observe("Move", span, e)
+	return base.setDriverName(e)
 }
 
 // Delete wraps Delete of underlying storage driver.
@@ -185,7 +217,10 @@ func (base *Base) Delete(ctx context.Context, path string) error {
 		return storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
-	return base.setDriverName(base.StorageDriver.Delete(ctx, path))
+	ctx, span := base.startCall(ctx, "Delete", path)
+	e := base.StorageDriver.Delete(ctx, path)
+	base.observe("Delete", span, e)
+	return base.setDriverName(e)
 }
 
 // URLFor wraps URLFor of underlying storage driver.
@@ -197,6 +232,8 @@ func (base *Base) URLFor(ctx context.Context, path string, options map[string]in
 		return "", storagedriver.InvalidPathError{Path: path, DriverName: base.StorageDriver.Name()}
 	}
 
+	ctx, span := base.startCall(ctx, "URLFor", path)
 	str, e := base.StorageDriver.URLFor(ctx, path, options)
+	base.observe("URLFor", span, e)
 	return str, base.setDriverName(e)
 }
@@ -0,0 +1,45 @@
+package base
+
+import (
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/metrics"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+)
+
+var (
+	driverDuration = metrics.NewHistogramVec("registry_storage_driver_duration_seconds",
+		"Duration of storage driver calls in seconds, by driver and method.",
+		metrics.DefaultBuckets, "driver", "method")
+	driverErrors = metrics.NewCounterVec("registry_storage_driver_errors_total",
+		"Number of storage driver calls failing, other than for missing paths, by driver and method.",
+		"driver", "method")
+)
+
+func init() {
+	metrics.Register(driverDuration, driverErrors)
+}
+
+// startCall starts the span of a call to method of the underlying storage
+// driver.
+func (base *Base) startCall(ctx context.Context, method, path string) (context.Context, *context.Span) {
+	ctx, span := context.WithSpan(ctx, "storagedriver."+method)
+	span.SetAttribute("storage.driver", base.StorageDriver.Name())
+	span.SetAttribute("storage.path", path)
+	return ctx, span
+}
+
+// observe finishes the span of a call to method of the underlying storage
+// driver, recording its duration and error, if any. Missing paths are an
+// expected outcome of many calls, so they aren't counted as errors.
+func (base *Base) observe(method string, span *context.Span, err error) {
+	span.Finish(err)
+
+	name := base.StorageDriver.Name()
+	driverDuration.Observe(span.Duration.Seconds(), name, method)
+
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+			driverErrors.Inc(name, method)
+		}
+	}
+}
@@ -74,7 +74,7 @@ func (d *driver) GetContent(ctx context.Context, path string) ([]byte, error) {
 	d.mutex.RLock()
 	defer d.mutex.RUnlock()
 
-	rc, err := d.ReadStream(ctx, path, 0)
+	rc, err := d.readStream(ctx, path, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -107,6 +107,12 @@ func (d *driver) ReadStream(ctx context.Context, path string, offset int64) (io.
 	d.mutex.RLock()
 	defer d.mutex.RUnlock()
 
+	return d.readStream(ctx, path, offset)
+}
+
+// readStream implements ReadStream. The caller must hold the read lock, which
+// can't be taken recursively without deadlocking against a waiting writer.
+func (d *driver) readStream(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
 	if offset < 0 {
 		return nil, storagedriver.InvalidOffsetError{Path: path, Offset: offset}
 	}
@@ -113,6 +113,12 @@ func (lh *cloudFrontStorageMiddleware) URLFor(ctx context.Context, path string,
 	return cfURL, nil
 }
 
+// ListAfter passes ListAfter through to the wrapped storage driver, so that
+// it keeps listing pages without listing whole directories.
+func (lh *cloudFrontStorageMiddleware) ListAfter(ctx context.Context, path string, after string, limit int) ([]string, error) {
+	return storagedriver.ListAfter(ctx, lh.StorageDriver, path, after, limit)
+}
+
 // init registers the cloudfront layerHandler backend.
 func init() {
 	storagemiddleware.Register("cloudfront", storagemiddleware.InitFunc(newCloudFrontStorageMiddleware))
@@ -0,0 +1,325 @@
+// Package instrumented provides a storage middleware recording the calls
+// made to the storage driver it wraps. Call, error and byte counts, along
+// with latency distributions, are kept per driver and method, and exposed
+// via expvar under "registry.storagedriver". Calls slower than a threshold
+// are logged along with the request they were made for.
+package instrumented
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/context"
+	storagedriver "github.com/docker/distribution/registry/storage/driver"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+)
+
+// defaultSlowThreshold is the duration above which calls are logged, unless
+// configured otherwise.
+const defaultSlowThreshold = time.Second
+
+// latencyBounds are the upper bounds of the latency distributions.
+var latencyBounds = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// instrumentedStorageMiddleware records the calls made to the storage driver
+// it wraps.
+type instrumentedStorageMiddleware struct {
+	storagedriver.StorageDriver
+	stats         *driverStats
+	slowThreshold time.Duration
+}
+
+var _ storagedriver.StorageDriver = &instrumentedStorageMiddleware{}
+var _ storagedriver.PagedLister = &instrumentedStorageMiddleware{}
+
+// newInstrumentedStorageMiddleware constructs and returns a new instrumented
+// StorageDriver. The optional slowthreshold option is the duration above
+// which calls are logged, zero disabling logging.
+func newInstrumentedStorageMiddleware(storageDriver storagedriver.StorageDriver, options map[string]interface{}) (storagedriver.StorageDriver, error) {
+	slowThreshold := defaultSlowThreshold
+	if t, ok := options["slowthreshold"]; ok {
+		switch t := t.(type) {
+		case time.Duration:
+			slowThreshold = t
+		case string:
+			d, err := time.ParseDuration(t)
+			if err != nil {
+				return nil, fmt.Errorf("invalid slowthreshold: %s", err)
+			}
+			slowThreshold = d
+		default:
+			return nil, fmt.Errorf("slowthreshold must be a duration, such as 500ms: %v", t)
+		}
+	}
+
+	return &instrumentedStorageMiddleware{
+		StorageDriver: storageDriver,
+		stats:         statsFor(storageDriver.Name()),
+		slowThreshold: slowThreshold,
+	}, nil
+}
+
+// record records a call to method and logs it if slow.
+func (d *instrumentedStorageMiddleware) record(ctx context.Context, method, path string, start time.Time, bytes int64, err error) {
+	duration := time.Since(start)
+	d.stats.record(method, duration, bytes, err)
+
+	if d.slowThreshold > 0 && duration > d.slowThreshold {
+		context.GetLoggerWithFields(ctx, map[interface{}]interface{}{
+			"storage.driver":   d.Name(),
+			"storage.method":   method,
+			"storage.path":     path,
+			"storage.duration": duration,
+		}, "http.request.id").Warnf("slow storage driver call: %s.%s(%q) took %v", d.Name(), method, path, duration)
+	}
+}
+
+// GetContent wraps GetContent of the underlying storage driver.
+func (d *instrumentedStorageMiddleware) GetContent(ctx context.Context, path string) ([]byte, error) {
+	start := time.Now()
+	p, err := d.StorageDriver.GetContent(ctx, path)
+	d.record(ctx, "GetContent", path, start, int64(len(p)), err)
+	return p, err
+}
+
+// PutContent wraps PutContent of the underlying storage driver.
+func (d *instrumentedStorageMiddleware) PutContent(ctx context.Context, path string, content []byte) error {
+	start := time.Now()
+	err := d.StorageDriver.PutContent(ctx, path, content)
+
+	var bytes int64
+	if err == nil {
+		bytes = int64(len(content))
+	}
+	d.record(ctx, "PutContent", path, start, bytes, err)
+	return err
+}
+
+// ReadStream wraps ReadStream of the underlying storage driver. Its latency
+// is the time taken to open the stream, while its bytes are those read from
+// the stream.
+func (d *instrumentedStorageMiddleware) ReadStream(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	start := time.Now()
+	rc, err := d.StorageDriver.ReadStream(ctx, path, offset)
+	d.record(ctx, "ReadStream", path, start, 0, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &countingReadCloser{ReadCloser: rc, stats: d.stats, method: "ReadStream"}, nil
+}
+
+// WriteStream wraps WriteStream of the underlying storage driver.
+func (d *instrumentedStorageMiddleware) WriteStream(ctx context.Context, path string, offset int64, reader io.Reader) (int64, error) {
+	start := time.Now()
+	nn, err := d.StorageDriver.WriteStream(ctx, path, offset, reader)
+	d.record(ctx, "WriteStream", path, start, nn, err)
+	return nn, err
+}
+
+// Stat wraps Stat of the underlying storage driver.
+func (d *instrumentedStorageMiddleware) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	start := time.Now()
+	fi, err := d.StorageDriver.Stat(ctx, path)
+	d.record(ctx, "Stat", path, start, 0, err)
+	return fi, err
+}
+
+// List wraps List of the underlying storage driver.
+func (d *instrumentedStorageMiddleware) List(ctx context.Context, path string) ([]string, error) {
+	start := time.Now()
+	children, err := d.StorageDriver.List(ctx, path)
+	d.record(ctx, "List", path, start, 0, err)
+	return children, err
+}
+
+// ListAfter wraps ListAfter of the underlying storage driver.
+func (d *instrumentedStorageMiddleware) ListAfter(ctx context.Context, path string, after string, limit int) ([]string, error) {
+	start := time.Now()
+	children, err := storagedriver.ListAfter(ctx, d.StorageDriver, path, after, limit)
+	d.record(ctx, "ListAfter", path, start, 0, err)
+	return children, err
+}
+
+// Move wraps Move of the underlying storage driver.
+func (d *instrumentedStorageMiddleware) Move(ctx context.Context, sourcePath string, destPath string) error {
+	start := time.Now()
+	err := d.StorageDriver.Move(ctx, sourcePath, destPath)
+	d.record(ctx, "Move", sourcePath, start, 0, err)
+	return err
+}
+
+// Delete wraps Delete of the underlying storage driver.
+func (d *instrumentedStorageMiddleware) Delete(ctx context.Context, path string) error {
+	start := time.Now()
+	err := d.StorageDriver.Delete(ctx, path)
+	d.record(ctx, "Delete", path, start, 0, err)
+	return err
+}
+
+// URLFor wraps URLFor of the underlying storage driver.
+func (d *instrumentedStorageMiddleware) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
+	start := time.Now()
+	url, err := d.StorageDriver.URLFor(ctx, path, options)
+	d.record(ctx, "URLFor", path, start, 0, err)
+	return url, err
+}
+
+// countingReadCloser adds the bytes read from a stream to the stats of a
+// method.
+type countingReadCloser struct {
+	io.ReadCloser
+	stats  *driverStats
+	method string
+}
+
+func (rc *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := rc.ReadCloser.Read(p)
+	rc.stats.addBytes(rc.method, int64(n))
+	return n, err
+}
+
+// methodStats are the stats of the calls to a storage driver method.
+// Latency holds the number of calls per latency bucket, the last one having
+// no upper bound.
+type methodStats struct {
+	Calls   uint64
+	Errors  uint64
+	Bytes   uint64
+	Latency []latencyBucket
+}
+
+type latencyBucket struct {
+	UpperBound string
+	Count      uint64
+}
+
+// driverStats holds the stats of the methods of a storage driver.
+type driverStats struct {
+	mu      sync.Mutex
+	methods map[string]*methodStats
+}
+
+// method returns the stats of the named method, creating them if missing.
+// The lock must be held.
+func (ds *driverStats) method(name string) *methodStats {
+	ms, ok := ds.methods[name]
+	if !ok {
+		ms = &methodStats{Latency: make([]latencyBucket, len(latencyBounds)+1)}
+		for i, bound := range latencyBounds {
+			ms.Latency[i].UpperBound = bound.String()
+		}
+		ms.Latency[len(latencyBounds)].UpperBound = "+Inf"
+		ds.methods[name] = ms
+	}
+
+	return ms
+}
+
+// record records a call to the named method. Missing paths are an expected
+// outcome of many calls, so they aren't counted as errors.
+func (ds *driverStats) record(name string, duration time.Duration, bytes int64, err error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ms := ds.method(name)
+	ms.Calls++
+	ms.Bytes += uint64(bytes)
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); !ok {
+			ms.Errors++
+		}
+	}
+
+	bucket := len(latencyBounds)
+	for i, bound := range latencyBounds {
+		if duration <= bound {
+			bucket = i
+			break
+		}
+	}
+	ms.Latency[bucket].Count++
+}
+
+func (ds *driverStats) addBytes(name string, bytes int64) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.method(name).Bytes += uint64(bytes)
+}
+
+// snapshot returns a copy of the stats of each method.
+func (ds *driverStats) snapshot() map[string]methodStats {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	methods := make(map[string]methodStats, len(ds.methods))
+	for name, ms := range ds.methods {
+		snapshot := *ms
+		snapshot.Latency = append([]latencyBucket(nil), ms.Latency...)
+		methods[name] = snapshot
+	}
+
+	return methods
+}
+
+// drivers holds the stats of each storage driver, by name, so that they are
+// kept across instances of the middleware.
+var drivers struct {
+	mu    sync.Mutex
+	stats map[string]*driverStats
+}
+
+// statsFor returns the stats of the named storage driver.
+func statsFor(name string) *driverStats {
+	drivers.mu.Lock()
+	defer drivers.mu.Unlock()
+
+	ds, ok := drivers.stats[name]
+	if !ok {
+		ds = &driverStats{methods: make(map[string]*methodStats)}
+		drivers.stats[name] = ds
+	}
+
+	return ds
+}
+
+// init registers the instrumented storage middleware and publishes its stats
+// to expvar.
+func init() {
+	drivers.stats = make(map[string]*driverStats)
+
+	registry := expvar.Get("registry")
+	if registry == nil {
+		registry = expvar.NewMap("registry")
+	}
+
+	registry.(*expvar.Map).Set("storagedriver", expvar.Func(func() interface{} {
+		drivers.mu.Lock()
+		defer drivers.mu.Unlock()
+
+		stats := make(map[string]map[string]methodStats, len(drivers.stats))
+		for name, ds := range drivers.stats {
+			stats[name] = ds.snapshot()
+		}
+
+		return stats
+	}))
+
+	storagemiddleware.Register("instrumented", storagemiddleware.InitFunc(newInstrumentedStorageMiddleware))
+}
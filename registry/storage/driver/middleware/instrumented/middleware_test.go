@@ -0,0 +1,87 @@
+package instrumented
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	storagemiddleware "github.com/docker/distribution/registry/storage/driver/middleware"
+)
+
+// TestInstrumentedStorageMiddleware checks that calls, errors and bytes are
+// counted per method.
+func TestInstrumentedStorageMiddleware(t *testing.T) {
+	ctx := context.Background()
+	driver, err := storagemiddleware.Get("instrumented", map[string]interface{}{
+		"slowthreshold": "1ns",
+	}, inmemory.New())
+	if err != nil {
+		t.Fatalf("unexpected error creating middleware: %v", err)
+	}
+
+	content := []byte("instrumented")
+	if err := driver.PutContent(ctx, "/a", content); err != nil {
+		t.Fatalf("unexpected error putting content: %v", err)
+	}
+
+	if _, err := driver.GetContent(ctx, "/a"); err != nil {
+		t.Fatalf("unexpected error getting content: %v", err)
+	}
+
+	rc, err := driver.ReadStream(ctx, "/a", 2)
+	if err != nil {
+		t.Fatalf("unexpected error reading stream: %v", err)
+	}
+	if _, err := ioutil.ReadAll(rc); err != nil {
+		t.Fatalf("unexpected error reading stream: %v", err)
+	}
+	rc.Close()
+
+	if _, err := driver.Stat(ctx, "/missing"); err == nil {
+		t.Fatalf("expected error getting status of missing path")
+	}
+
+	if _, err := driver.ReadStream(ctx, "/a", -1); err == nil {
+		t.Fatalf("expected error reading stream at a negative offset")
+	}
+
+	expected := map[string]methodStats{
+		"PutContent": {Calls: 1, Bytes: uint64(len(content))},
+		"GetContent": {Calls: 1, Bytes: uint64(len(content))},
+		"ReadStream": {Calls: 2, Errors: 1, Bytes: uint64(len(content) - 2)},
+		"Stat":       {Calls: 1},
+	}
+
+	stats := statsFor(driver.Name()).snapshot()
+	if len(stats) != len(expected) {
+		t.Fatalf("unexpected methods: %v", stats)
+	}
+
+	for method, e := range expected {
+		s := stats[method]
+		if s.Calls != e.Calls || s.Errors != e.Errors || s.Bytes != e.Bytes {
+			t.Fatalf("unexpected stats of %s: %+v != %+v", method, s, e)
+		}
+
+		var calls uint64
+		for _, bucket := range s.Latency {
+			calls += bucket.Count
+		}
+		if calls != s.Calls {
+			t.Fatalf("unexpected latency distribution of %s: %+v", method, s.Latency)
+		}
+	}
+}
+
+// TestInvalidSlowThreshold checks that the slow threshold must be a duration.
+func TestInvalidSlowThreshold(t *testing.T) {
+	for _, threshold := range []interface{}{"fast", 3000} {
+		_, err := newInstrumentedStorageMiddleware(inmemory.New(), map[string]interface{}{
+			"slowthreshold": threshold,
+		})
+		if err == nil {
+			t.Fatalf("expected error for slow threshold %v", threshold)
+		}
+	}
+}
@@ -37,3 +37,10 @@ func Get(name string, options map[string]interface{}, storageDriver storagedrive
 
 	return nil, fmt.Errorf("no storage middleware registered with name: %s", name)
 }
+
+// Registered reports whether a StorageMiddleware backend is registered with the
+// given name.
+func Registered(name string) bool {
+	_, exists := storageMiddlewares[name]
+	return exists
+}
@@ -710,6 +710,66 @@ func (d *driver) List(ctx context.Context, path string) ([]string, error) {
 	return append(files, directories...), nil
 }
 
+// ListAfter returns, in lexical order, at most limit of the direct
+// descendants of the given path which sort after the descendant after. The
+// listing starts at after, so only the requested page is read from S3 when
+// the path holds files. S3 orders subdirectories as if followed by a slash,
+// which is not their lexical order, so paths holding subdirectories are
+// listed in full instead.
+func (d *driver) ListAfter(ctx context.Context, path string, after string, limit int) ([]string, error) {
+	dirPath := path
+	if dirPath != "/" && dirPath[len(dirPath)-1] != '/' {
+		dirPath = dirPath + "/"
+	}
+
+	// See List for the handling of an empty root directory.
+	prefix := ""
+	if d.s3Path("") == "" {
+		prefix = "/"
+	}
+
+	marker := ""
+	if after != "" {
+		marker = d.s3Path(after)
+	}
+
+	children := []string{}
+	for len(children) < limit {
+		max := limit - len(children)
+		if max > listMax {
+			max = listMax
+		}
+
+		listResponse, err := d.Bucket.List(d.s3Path(dirPath), "/", marker, max)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(listResponse.CommonPrefixes) > 0 {
+			all, err := d.List(ctx, path)
+			if err != nil {
+				return nil, err
+			}
+			return storagedriver.PageAfter(all, after, limit), nil
+		}
+
+		for _, key := range listResponse.Contents {
+			children = append(children, strings.Replace(key.Key, d.s3Path(""), prefix, 1))
+		}
+
+		if !listResponse.IsTruncated || len(listResponse.Contents) == 0 {
+			break
+		}
+
+		marker = listResponse.NextMarker
+		if marker == "" {
+			marker = listResponse.Contents[len(listResponse.Contents)-1].Key
+		}
+	}
+
+	return children, nil
+}
+
 // Move moves an object stored at sourcePath to destPath, removing the original
 // object.
 func (d *driver) Move(ctx context.Context, sourcePath string, destPath string) error {
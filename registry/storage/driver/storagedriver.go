@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -84,6 +85,54 @@ type StorageDriver interface {
 	URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error)
 }
 
+// PagedLister is implemented by storage drivers which can list a page of the
+// direct descendants of a path without listing all of them.
+type PagedLister interface {
+	// ListAfter returns, in lexical order, at most limit of the direct
+	// descendants of the given path which sort after the descendant after,
+	// or all of them if after is empty.
+	ListAfter(ctx context.Context, path string, after string, limit int) ([]string, error)
+}
+
+// ListAfter returns, in lexical order, at most limit of the direct
+// descendants of path which sort after the descendant after, or all of them
+// if after is empty. Drivers which are not PagedListers list the whole
+// directory.
+func ListAfter(ctx context.Context, driver StorageDriver, path string, after string, limit int) ([]string, error) {
+	if pl, ok := driver.(PagedLister); ok {
+		return pl.ListAfter(ctx, path, after, limit)
+	}
+
+	children, err := driver.List(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return PageAfter(children, after, limit), nil
+}
+
+// PageAfter sorts the listed children and returns at most limit of them
+// which sort after the child after, for drivers implementing ListAfter on
+// top of a full listing.
+func PageAfter(children []string, after string, limit int) []string {
+	sort.Strings(children)
+
+	// Skip everything up to and including after.
+	if after != "" {
+		i := sort.SearchStrings(children, after)
+		if i < len(children) && children[i] == after {
+			i++
+		}
+		children = children[i:]
+	}
+
+	if len(children) > limit {
+		children = children[:limit]
+	}
+
+	return children
+}
+
 // PathRegexp is the regular expression which each file path must match. A
 // file path is absolute, beginning with a slash and containing a positive
 // number of path components separated by slashes, where each component is
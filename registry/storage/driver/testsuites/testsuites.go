@@ -503,6 +503,45 @@ func (suite *DriverSuite) TestList(c *check.C) {
 	// 3. Ensure that we only respond to directory listings that end with a slash (maybe?).
 }
 
+// TestListAfter checks that pages of the children of a directory follow each
+// other in lexical order, both for files and for subdirectories.
+func (suite *DriverSuite) TestListAfter(c *check.C) {
+	rootDirectory := "/" + randomFilename(int64(8+rand.Intn(8)))
+	defer suite.StorageDriver.Delete(suite.ctx, rootDirectory)
+
+	names := []string{"v1", "v1-rc", "v1.5", "v10", "v1_1", "v2"}
+	for _, dir := range []string{"files", "dirs"} {
+		parentDirectory := rootDirectory + "/" + dir
+		var children []string
+		for _, name := range names {
+			child := parentDirectory + "/" + name
+			children = append(children, child)
+
+			filePath := child
+			if dir == "dirs" {
+				filePath = child + "/file"
+			}
+			err := suite.StorageDriver.PutContent(suite.ctx, filePath, randomContents(32))
+			c.Assert(err, check.IsNil)
+		}
+
+		var listed []string
+		after := ""
+		for {
+			page, err := storagedriver.ListAfter(suite.ctx, suite.StorageDriver, parentDirectory, after, 4)
+			c.Assert(err, check.IsNil)
+			c.Assert(len(page) <= 4, check.Equals, true)
+			if len(page) == 0 {
+				break
+			}
+
+			listed = append(listed, page...)
+			after = page[len(page)-1]
+		}
+		c.Assert(listed, check.DeepEquals, children)
+	}
+}
+
 // TestMove checks that a moved object no longer exists at the source path and
 // does exist at the destination.
 func (suite *DriverSuite) TestMove(c *check.C) {
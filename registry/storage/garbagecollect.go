@@ -0,0 +1,576 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/registry/storage/driver"
+)
+
+// GCOpts contains options for a garbage collection run.
+type GCOpts struct {
+	// DryRun reports which blobs would be deleted without removing
+	// anything from the storage backend.
+	DryRun bool
+
+	// RemoveUntagged deletes manifest revisions that are not the current
+	// revision of any tag before marking, so that their blobs can be
+	// swept.
+	RemoveUntagged bool
+
+	// Repositories restricts the collection to the named repositories.
+	// Blobs are marked from every repository but only blobs linked into
+	// one of these repositories are swept, and only their untagged
+	// manifests are removed. If empty, all repositories are collected.
+	Repositories []string
+
+	// Online enables the write barrier, allowing collection while the
+	// registry accepts writes. Blobs referenced by writers during the cycle
+	// are left in place. It can't be combined with RemoveUntagged.
+	Online bool
+
+	// GracePeriod keeps blobs written less than this long before an online
+	// cycle starts, so that layers uploaded for a manifest that is still
+	// being pushed aren't swept. Blobs written during the cycle are always
+	// kept.
+	GracePeriod time.Duration
+}
+
+// GCResult describes the outcome of a garbage collection run.
+type GCResult struct {
+	// Marked holds every blob found to be reachable from a manifest
+	// revision.
+	Marked map[digest.Digest]struct{}
+
+	// RemovedManifests lists the untagged manifests that were removed or,
+	// in dry run mode, would have been removed, as "<name>@<digest>".
+	RemovedManifests []string
+
+	// Swept lists the blobs that were deleted or, in dry run mode, would
+	// have been deleted.
+	Swept []digest.Digest
+
+	// ReclaimedBytes is the total size of the swept blobs.
+	ReclaimedBytes int64
+
+	// Errors contains the failures encountered while sweeping blobs. A
+	// blob that could not be deleted is still listed in Swept.
+	Errors []error
+}
+
+// MarkAndSweep removes every blob in the blob store that is not reachable
+// from the manifest revisions of any repository. The mark phase collects the
+// manifest payloads, their references and their signatures. The sweep phase
+// then deletes the remaining blobs using a Vacuum, releasing their sizes from
+// the usage of the repositories linking them. If marking fails, nothing is
+// deleted.
+//
+// Like the Vacuum, this is only safe when no content is pushed to the
+// registry while it runs, unless opts.Online is set. Online collection relies
+// on the storage backend being strongly consistent.
+func MarkAndSweep(ctx context.Context, storageDriver driver.StorageDriver, registry distribution.Namespace, opts GCOpts) (GCResult, error) {
+	result, removed, err := markAndSweep(ctx, storageDriver, registry, opts)
+	if err != nil {
+		return result, err
+	}
+
+	// The usage is released once the cycle ended, so that writers aren't held
+	// back meanwhile.
+	if len(removed) > 0 {
+		if err := releaseSweptUsage(ctx, storageDriver, removed); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("failed to release the usage of swept blobs: %v", err))
+		}
+	}
+
+	context.GetLogger(ctx).Infof("Garbage collection finished: marked=%d, removed manifests=%d, swept=%d, reclaimed=%d bytes, errors=%d, dryrun=%t",
+		len(result.Marked), len(result.RemovedManifests), len(result.Swept), result.ReclaimedBytes, len(result.Errors), opts.DryRun)
+
+	return result, nil
+}
+
+// markAndSweep runs a collection for MarkAndSweep, returning the sizes of the
+// blobs it removed.
+func markAndSweep(ctx context.Context, storageDriver driver.StorageDriver, registry distribution.Namespace, opts GCOpts) (GCResult, map[digest.Digest]int64, error) {
+	result := GCResult{
+		Marked: make(map[digest.Digest]struct{}),
+	}
+
+	// Manifests that are being pushed are not tagged yet, so they can't be
+	// told apart from untagged ones.
+	if opts.Online && opts.RemoveUntagged {
+		return result, nil, fmt.Errorf("untagged manifests can't be removed during online garbage collection")
+	}
+
+	// A dry run deletes nothing, so it doesn't need to hold back writers.
+	var (
+		cycle  string
+		cutoff time.Time
+	)
+	if opts.Online && !opts.DryRun {
+		cutoff = time.Now().Add(-opts.GracePeriod)
+
+		var err error
+		cycle, err = beginGCCycle(ctx, storageDriver)
+		if err != nil {
+			return result, nil, err
+		}
+
+		defer func() {
+			if err := endGCCycle(ctx, storageDriver, cycle); err != nil {
+				context.GetLogger(ctx).Errorf("failed to end garbage collection cycle %s: %v", cycle, err)
+			}
+		}()
+	}
+
+	var selected map[string]struct{}
+	if len(opts.Repositories) > 0 {
+		selected = make(map[string]struct{}, len(opts.Repositories))
+		for _, name := range opts.Repositories {
+			selected[name] = struct{}{}
+		}
+	}
+
+	// The scope must be resolved before any untagged manifests are
+	// removed, as removing them deletes their links.
+	var scope map[digest.Digest]struct{}
+	if selected != nil {
+		scope = make(map[digest.Digest]struct{})
+		for name := range selected {
+			if err := linkedBlobs(ctx, storageDriver, name, scope); err != nil {
+				return result, nil, fmt.Errorf("failed to find blobs linked into %s: %v", name, err)
+			}
+		}
+	}
+
+	err := enumerateRepositories(ctx, storageDriver, func(name string) error {
+		_, isSelected := selected[name]
+		removeUntagged := opts.RemoveUntagged && (selected == nil || isSelected)
+		return markRepository(ctx, storageDriver, registry, name, removeUntagged, opts, &result)
+	})
+	if err != nil {
+		return result, nil, fmt.Errorf("failed to mark blobs: %v", err)
+	}
+
+	candidates, err := sweepCandidates(ctx, storageDriver, result.Marked, cutoff)
+	if err != nil {
+		return result, nil, fmt.Errorf("failed to find unreferenced blobs: %v", err)
+	}
+
+	vacuum := NewVacuum(ctx, storageDriver)
+	removed := make(map[digest.Digest]int64)
+	for _, candidate := range candidates {
+		if scope != nil {
+			if _, ok := scope[candidate.Digest]; !ok {
+				continue
+			}
+		}
+
+		if cycle != "" {
+			ok, err := claimGCCandidate(ctx, storageDriver, cycle, candidate.Digest)
+			if err != nil {
+				result.Errors = pushError(result.Errors, string(candidate.Digest), err)
+				continue
+			}
+
+			if !ok {
+				context.GetLogger(ctx).Infof("Keeping blob referenced during garbage collection: %s", candidate.Digest)
+				continue
+			}
+		}
+
+		result.Swept = append(result.Swept, candidate.Digest)
+		result.ReclaimedBytes += candidate.Size
+
+		if opts.DryRun {
+			context.GetLogger(ctx).Infof("Would delete blob: %s (%d bytes)", candidate.Digest, candidate.Size)
+			continue
+		}
+
+		if err := vacuum.RemoveBlob(string(candidate.Digest)); err != nil {
+			result.Errors = pushError(result.Errors, string(candidate.Digest), err)
+			continue
+		}
+		removed[candidate.Digest] = candidate.Size
+	}
+
+	return result, removed, nil
+}
+
+// enumerateRepositories calls fn with the name of every repository that has
+// a manifests directory.
+func enumerateRepositories(ctx context.Context, storageDriver driver.StorageDriver, fn func(name string) error) error {
+	root, err := pathFor(repositoriesRootPathSpec{})
+	if err != nil {
+		return err
+	}
+
+	err = Walk(ctx, storageDriver, root, func(fileInfo driver.FileInfo) error {
+		filePath := fileInfo.Path()
+		repoPath := filePath[len(root)+1:]
+
+		_, file := path.Split(repoPath)
+		if file == "_manifests" {
+			if err := fn(strings.TrimSuffix(repoPath, "/_manifests")); err != nil {
+				return err
+			}
+			return ErrSkipDir
+		} else if strings.HasPrefix(file, "_") {
+			return ErrSkipDir
+		}
+
+		return nil
+	})
+
+	if isPathNotFound(err, root) {
+		// Nothing has been pushed yet.
+		return nil
+	}
+
+	return err
+}
+
+// markRepository adds every blob referenced by the manifest revisions of the
+// named repository to the marked set of result. If removeUntagged is set,
+// revisions that are not the current revision of a tag are deleted instead
+// of being marked.
+func markRepository(ctx context.Context, storageDriver driver.StorageDriver, registry distribution.Namespace, name string, removeUntagged bool, opts GCOpts, result *GCResult) error {
+	context.GetLogger(ctx).Debugf("Marking repository: %s", name)
+
+	repo, err := registry.Repository(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	manifestService, err := repo.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+
+	revisionsPath, err := pathFor(manifestRevisionsPathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	revisions := make(map[digest.Digest]struct{})
+	if err := readLinks(ctx, storageDriver, revisionsPath, "signatures", revisions); err != nil {
+		return err
+	}
+
+	var tagged map[digest.Digest]struct{}
+	if removeUntagged {
+		tagged, err = taggedRevisions(ctx, storageDriver, name)
+		if err != nil {
+			return err
+		}
+
+		// Manifests referenced by a tagged manifest list are kept along
+		// with it.
+		for revision := range tagged {
+			references, err := revisionReferences(ctx, storageDriver, repo, revision)
+			if err != nil {
+				return err
+			}
+
+			for _, reference := range references {
+				if _, ok := revisions[reference.Digest]; ok {
+					tagged[reference.Digest] = struct{}{}
+				}
+			}
+		}
+	}
+
+	for revision := range revisions {
+		if removeUntagged {
+			if _, ok := tagged[revision]; !ok {
+				reference := fmt.Sprintf("%s@%s", name, revision)
+				result.RemovedManifests = append(result.RemovedManifests, reference)
+
+				if opts.DryRun {
+					context.GetLogger(ctx).Infof("Would delete untagged manifest: %s", reference)
+					continue
+				}
+
+				context.GetLogger(ctx).Infof("Deleting untagged manifest: %s", reference)
+				if err := manifestService.Delete(revision); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if err := markRevision(ctx, storageDriver, repo, revision, result.Marked); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// taggedRevisions returns the current revision of every tag in the named
+// repository.
+func taggedRevisions(ctx context.Context, storageDriver driver.StorageDriver, name string) (map[digest.Digest]struct{}, error) {
+	tagsPath, err := pathFor(manifestTagsPathSpec{name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	bs := &blobStore{driver: storageDriver}
+	tagged := make(map[digest.Digest]struct{})
+	tagPaths, err := storageDriver.List(ctx, tagsPath)
+	if err != nil {
+		if _, ok := err.(driver.PathNotFoundError); ok {
+			return tagged, nil
+		}
+		return nil, err
+	}
+
+	for _, tagPath := range tagPaths {
+		currentPath, err := pathFor(manifestTagCurrentPathSpec{name: name, tag: path.Base(tagPath)})
+		if err != nil {
+			return nil, err
+		}
+
+		revision, err := bs.readlink(ctx, currentPath)
+		if err != nil {
+			if _, ok := err.(driver.PathNotFoundError); ok {
+				continue
+			}
+			return nil, err
+		}
+		tagged[revision] = struct{}{}
+	}
+
+	return tagged, nil
+}
+
+// linkedBlobs adds the targets of all layer, manifest and signature links in
+// the named repository to linked.
+func linkedBlobs(ctx context.Context, storageDriver driver.StorageDriver, name string, linked map[digest.Digest]struct{}) error {
+	root, err := pathFor(repositoriesRootPathSpec{})
+	if err != nil {
+		return err
+	}
+
+	repoPath := path.Join(root, name)
+	found, err := exists(ctx, storageDriver, repoPath)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return distribution.ErrRepositoryUnknown{Name: name}
+	}
+
+	for _, dir := range []string{"_layers", "_manifests/revisions"} {
+		if err := readLinks(ctx, storageDriver, path.Join(repoPath, dir), "", linked); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readLinks walks the tree at root, adding the target of every link file to
+// targets. Directories named skip are not entered. A missing root is not an
+// error.
+func readLinks(ctx context.Context, storageDriver driver.StorageDriver, root, skip string, targets map[digest.Digest]struct{}) error {
+	bs := &blobStore{driver: storageDriver}
+
+	err := Walk(ctx, storageDriver, root, func(fileInfo driver.FileInfo) error {
+		_, file := path.Split(fileInfo.Path())
+		if fileInfo.IsDir() {
+			if skip != "" && file == skip {
+				return ErrSkipDir
+			}
+			return nil
+		}
+
+		if file != "link" {
+			return nil
+		}
+
+		target, err := bs.readlink(ctx, fileInfo.Path())
+		if err != nil {
+			return fmt.Errorf("%s: %v", fileInfo.Path(), err)
+		}
+
+		targets[target] = struct{}{}
+		return nil
+	})
+
+	if isPathNotFound(err, root) {
+		return nil
+	}
+
+	return err
+}
+
+// markRevision marks the manifest payload of revision along with its
+// references and signatures. The payload and signatures are read directly
+// rather than through the manifest service so that revisions left incomplete
+// by an interrupted or concurrent push don't stop the collection.
+func markRevision(ctx context.Context, storageDriver driver.StorageDriver, repo distribution.Repository, revision digest.Digest, markSet map[digest.Digest]struct{}) error {
+	context.GetLogger(ctx).Debugf("Marking manifest: %s@%s", repo.Name(), revision)
+	markSet[revision] = struct{}{}
+
+	references, err := revisionReferences(ctx, storageDriver, repo, revision)
+	if err != nil {
+		return err
+	}
+
+	blobs := repo.Blobs(ctx)
+	for _, reference := range references {
+		markSet[reference.Digest] = struct{}{}
+
+		if reference.Digest.Algorithm() == digest.Canonical {
+			continue
+		}
+
+		// Layers referenced by a non-canonical digest, such as tarsum, are
+		// stored in the blob store under their canonical digest.
+		desc, err := blobs.Stat(ctx, reference.Digest)
+		if err != nil {
+			if err == distribution.ErrBlobUnknown {
+				continue
+			}
+			return err
+		}
+		markSet[desc.Digest] = struct{}{}
+	}
+
+	// Signatures are marked through their links, which name their digests.
+	signaturesPath, err := pathFor(manifestSignaturesPathSpec{name: repo.Name(), revision: revision})
+	if err != nil {
+		return err
+	}
+
+	return readLinks(ctx, storageDriver, signaturesPath, "", markSet)
+}
+
+// revisionReferences returns the descriptors referenced by the manifest
+// payload of revision. A missing payload has no references.
+func revisionReferences(ctx context.Context, storageDriver driver.StorageDriver, repo distribution.Repository, revision digest.Digest) ([]distribution.Descriptor, error) {
+	bs := &blobStore{driver: storageDriver}
+	payload, err := bs.Get(ctx, revision)
+	if err != nil {
+		if err == distribution.ErrBlobUnknown {
+			// A failed push can leave a link to a swept payload behind,
+			// in which case there is nothing left to mark.
+			context.GetLogger(ctx).Warnf("Skipping manifest with missing payload: %s@%s", repo.Name(), revision)
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var versioned manifest.Versioned
+	if err := json.Unmarshal(payload, &versioned); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s@%s: %v", repo.Name(), revision, err)
+	}
+
+	// Schema 1 payloads are stored without their signatures, so they can't
+	// be unmarshaled as signed manifests.
+	if versioned.SchemaVersion == 1 {
+		var sm schema1.Manifest
+		if err := json.Unmarshal(payload, &sm); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest %s@%s: %v", repo.Name(), revision, err)
+		}
+
+		references := make([]distribution.Descriptor, len(sm.FSLayers))
+		for i, fsLayer := range sm.FSLayers {
+			references[i] = distribution.Descriptor{Digest: fsLayer.BlobSum}
+		}
+		return references, nil
+	}
+
+	mediaType, err := schema2MediaType(versioned, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s@%s: %v", repo.Name(), revision, err)
+	}
+
+	m, _, err := distribution.UnmarshalManifest(mediaType, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s@%s: %v", repo.Name(), revision, err)
+	}
+
+	return m.References(), nil
+}
+
+// sweepCandidates returns a descriptor for every blob in the blob store that
+// is not present in markSet. If cutoff is set, blobs modified after it are
+// left out.
+func sweepCandidates(ctx context.Context, storageDriver driver.StorageDriver, markSet map[digest.Digest]struct{}, cutoff time.Time) ([]distribution.Descriptor, error) {
+	root, err := pathFor(blobsPathSpec{})
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []distribution.Descriptor
+	err = Walk(ctx, storageDriver, root, func(fileInfo driver.FileInfo) error {
+		if fileInfo.IsDir() || path.Base(fileInfo.Path()) != "data" {
+			return nil
+		}
+
+		dgst, err := digestFromBlobDataPath(root, fileInfo.Path())
+		if err != nil {
+			// Leave anything we don't understand in place.
+			context.GetLogger(ctx).Warnf("Skipping unrecognized blob path %q: %v", fileInfo.Path(), err)
+			return nil
+		}
+
+		if !cutoff.IsZero() && fileInfo.ModTime().After(cutoff) {
+			context.GetLogger(ctx).Debugf("Keeping recently written blob: %s", dgst)
+			return nil
+		}
+
+		if _, marked := markSet[dgst]; !marked {
+			candidates = append(candidates, distribution.Descriptor{
+				Digest: dgst,
+				Size:   fileInfo.Size(),
+			})
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if isPathNotFound(err, root) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// digestFromBlobDataPath reverses the mapping of blobDataPathSpec, returning
+// the digest of the blob stored at blobDataPath.
+func digestFromBlobDataPath(root, blobDataPath string) (digest.Digest, error) {
+	components := strings.Split(strings.TrimPrefix(blobDataPath, root+"/"), "/")
+
+	var algorithm string
+	switch {
+	case len(components) == 4:
+		// <algorithm>/<first two hex bytes>/<hex digest>/data
+		algorithm = components[0]
+	case len(components) == 6 && components[0] == "tarsum":
+		// tarsum/<version>/<algorithm>/<first two hex bytes>/<hex digest>/data
+		algorithm = fmt.Sprintf("tarsum.%s+%s", components[1], components[2])
+	default:
+		return "", fmt.Errorf("unexpected blob path layout")
+	}
+
+	dgst := digest.NewDigestFromHex(algorithm, components[len(components)-2])
+	if err := dgst.Validate(); err != nil {
+		return "", err
+	}
+
+	return dgst, nil
+}
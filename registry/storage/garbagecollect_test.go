@@ -0,0 +1,525 @@
+package storage
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+	"github.com/docker/libtrust"
+)
+
+type gcTestEnv struct {
+	ctx      context.Context
+	driver   driver.StorageDriver
+	registry distribution.Namespace
+}
+
+func newGCTestEnv(t *testing.T, options ...RegistryOption) *gcTestEnv {
+	ctx := context.Background()
+	d := inmemory.New()
+	registry, err := NewRegistry(ctx, d, append([]RegistryOption{EnableDelete}, options...)...)
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	return &gcTestEnv{
+		ctx:      ctx,
+		driver:   d,
+		registry: registry,
+	}
+}
+
+func (env *gcTestEnv) repository(t *testing.T, name string) distribution.Repository {
+	repo, err := env.registry.Repository(env.ctx, name)
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+	return repo
+}
+
+// uploadRandomBlob puts random content into the repository and returns its
+// descriptor.
+func uploadRandomBlob(t *testing.T, ctx context.Context, repo distribution.Repository) distribution.Descriptor {
+	p := make([]byte, 1024)
+	if _, err := rand.Read(p); err != nil {
+		t.Fatalf("unexpected error generating blob content: %v", err)
+	}
+
+	desc, err := repo.Blobs(ctx).Put(ctx, "application/octet-stream", p)
+	if err != nil {
+		t.Fatalf("unexpected error putting blob: %v", err)
+	}
+	return desc
+}
+
+// putManifest signs and stores a manifest referencing layers, returning its
+// revision digest.
+func putManifest(t *testing.T, ctx context.Context, repo distribution.Repository, tag string, layers ...digest.Digest) digest.Digest {
+	m := schema1.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: repo.Name(),
+		Tag:  tag,
+	}
+
+	for _, layer := range layers {
+		m.FSLayers = append(m.FSLayers, schema1.FSLayer{BlobSum: layer})
+		m.History = append(m.History, schema1.History{V1Compatibility: ""})
+	}
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error generating private key: %v", err)
+	}
+
+	sm, err := schema1.Sign(&m, pk)
+	if err != nil {
+		t.Fatalf("error signing manifest: %v", err)
+	}
+
+	ms, err := repo.Manifests(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest service: %v", err)
+	}
+
+	dgst, err := ms.Put(sm)
+	if err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	if err := repo.Tags(ctx).Tag(ctx, tag, distribution.Descriptor{Digest: dgst}); err != nil {
+		t.Fatalf("unexpected error tagging manifest: %v", err)
+	}
+
+	return dgst
+}
+
+func (env *gcTestEnv) blobExists(t *testing.T, dgst digest.Digest) bool {
+	blobPath, err := pathFor(blobDataPathSpec{digest: dgst})
+	if err != nil {
+		t.Fatalf("unexpected error resolving blob path: %v", err)
+	}
+
+	ok, err := exists(env.ctx, env.driver, blobPath)
+	if err != nil {
+		t.Fatalf("unexpected error checking blob: %v", err)
+	}
+	return ok
+}
+
+func TestGCListFailure(t *testing.T) {
+	env := newGCTestEnv(t)
+	repo := env.repository(t, "foo/bar")
+
+	layer := uploadRandomBlob(t, env.ctx, repo)
+	revision := putManifest(t, env.ctx, repo, "latest", layer.Digest)
+
+	root, err := pathFor(repositoriesRootPathSpec{})
+	if err != nil {
+		t.Fatalf("unexpected error resolving repositories path: %v", err)
+	}
+
+	// Failing to list the repositories must not sweep the blobs they
+	// reference.
+	d := failingListDriver{StorageDriver: env.driver, fail: root + "/foo"}
+	if _, err := MarkAndSweep(env.ctx, d, env.registry, GCOpts{}); err == nil {
+		t.Fatal("expected garbage collection to fail")
+	}
+
+	for _, dgst := range []digest.Digest{layer.Digest, revision} {
+		if !env.blobExists(t, dgst) {
+			t.Fatalf("referenced blob %s was swept", dgst)
+		}
+	}
+}
+
+func TestGCNoDeletionNoEffect(t *testing.T) {
+	env := newGCTestEnv(t)
+	repo := env.repository(t, "foo/bar")
+
+	layer := uploadRandomBlob(t, env.ctx, repo)
+	revision := putManifest(t, env.ctx, repo, "latest", layer.Digest)
+
+	result, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error running garbage collection: %v", err)
+	}
+
+	if len(result.Swept) != 0 {
+		t.Fatalf("unexpected blobs swept: %v", result.Swept)
+	}
+
+	for _, dgst := range []digest.Digest{layer.Digest, revision} {
+		if _, ok := result.Marked[dgst]; !ok {
+			t.Errorf("expected %s to be marked", dgst)
+		}
+		if !env.blobExists(t, dgst) {
+			t.Errorf("expected %s to be present", dgst)
+		}
+	}
+
+	// The manifest should still be retrievable with its signatures intact.
+	ms, err := repo.Manifests(env.ctx)
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest service: %v", err)
+	}
+
+	if _, err := ms.Get(revision); err != nil {
+		t.Fatalf("unexpected error fetching manifest after garbage collection: %v", err)
+	}
+}
+
+func TestGCDeletedManifest(t *testing.T) {
+	env := newGCTestEnv(t)
+	repo := env.repository(t, "foo/bar")
+
+	shared := uploadRandomBlob(t, env.ctx, repo)
+	unique := uploadRandomBlob(t, env.ctx, repo)
+
+	kept := putManifest(t, env.ctx, repo, "kept", shared.Digest)
+	deleted := putManifest(t, env.ctx, repo, "deleted", shared.Digest, unique.Digest)
+
+	ms, err := repo.Manifests(env.ctx)
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest service: %v", err)
+	}
+
+	if err := ms.Delete(deleted); err != nil {
+		t.Fatalf("unexpected error deleting manifest: %v", err)
+	}
+
+	result, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error running garbage collection: %v", err)
+	}
+
+	if len(result.Errors) != 0 {
+		t.Fatalf("unexpected errors sweeping: %v", result.Errors)
+	}
+
+	// The deleted manifest, its unique layer and its signature are swept.
+	if len(result.Swept) != 3 {
+		t.Fatalf("unexpected number of blobs swept: %d != 3: %v", len(result.Swept), result.Swept)
+	}
+
+	for _, dgst := range []digest.Digest{deleted, unique.Digest} {
+		if env.blobExists(t, dgst) {
+			t.Errorf("expected %s to be swept", dgst)
+		}
+	}
+
+	for _, dgst := range []digest.Digest{kept, shared.Digest} {
+		if !env.blobExists(t, dgst) {
+			t.Errorf("expected %s to be present", dgst)
+		}
+	}
+}
+
+func TestGCOrphanedBlobs(t *testing.T) {
+	env := newGCTestEnv(t)
+	repo := env.repository(t, "foo/bar")
+	other := env.repository(t, "foo/other")
+
+	layer := uploadRandomBlob(t, env.ctx, repo)
+	putManifest(t, env.ctx, repo, "latest", layer.Digest)
+
+	// Blobs linked into a repository but not referenced by any manifest are
+	// collected.
+	orphan := uploadRandomBlob(t, env.ctx, other)
+
+	result, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error running garbage collection: %v", err)
+	}
+
+	if len(result.Swept) != 1 || result.Swept[0] != orphan.Digest {
+		t.Fatalf("unexpected blobs swept: %v", result.Swept)
+	}
+
+	if result.ReclaimedBytes != orphan.Size {
+		t.Fatalf("unexpected reclaimed bytes: %d != %d", result.ReclaimedBytes, orphan.Size)
+	}
+
+	if env.blobExists(t, orphan.Digest) {
+		t.Fatalf("expected orphaned blob to be swept")
+	}
+
+	if !env.blobExists(t, layer.Digest) {
+		t.Fatalf("expected referenced layer to be present")
+	}
+}
+
+func TestGCDryRun(t *testing.T) {
+	env := newGCTestEnv(t)
+	repo := env.repository(t, "foo/bar")
+
+	orphans := []distribution.Descriptor{
+		uploadRandomBlob(t, env.ctx, repo),
+		uploadRandomBlob(t, env.ctx, repo),
+	}
+
+	result, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error running garbage collection: %v", err)
+	}
+
+	if len(result.Swept) != len(orphans) {
+		t.Fatalf("unexpected number of blobs reported: %d != %d", len(result.Swept), len(orphans))
+	}
+
+	var expectedBytes int64
+	for _, orphan := range orphans {
+		expectedBytes += orphan.Size
+		if !env.blobExists(t, orphan.Digest) {
+			t.Fatalf("dry run deleted blob %s", orphan.Digest)
+		}
+	}
+
+	if result.ReclaimedBytes != expectedBytes {
+		t.Fatalf("unexpected reclaimed bytes: %d != %d", result.ReclaimedBytes, expectedBytes)
+	}
+}
+
+func TestGCRemoveUntagged(t *testing.T) {
+	env := newGCTestEnv(t)
+	repo := env.repository(t, "foo/bar")
+
+	oldLayer := uploadRandomBlob(t, env.ctx, repo)
+	newLayer := uploadRandomBlob(t, env.ctx, repo)
+
+	// Pushing a new revision to the same tag leaves the old one untagged.
+	old := putManifest(t, env.ctx, repo, "latest", oldLayer.Digest)
+	current := putManifest(t, env.ctx, repo, "latest", newLayer.Digest)
+
+	result, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{DryRun: true, RemoveUntagged: true})
+	if err != nil {
+		t.Fatalf("unexpected error running garbage collection: %v", err)
+	}
+
+	expected := "foo/bar@" + old.String()
+	if len(result.RemovedManifests) != 1 || result.RemovedManifests[0] != expected {
+		t.Fatalf("unexpected removed manifests: %v != [%s]", result.RemovedManifests, expected)
+	}
+
+	if !env.blobExists(t, old) || !env.blobExists(t, oldLayer.Digest) {
+		t.Fatalf("dry run deleted untagged manifest content")
+	}
+
+	ms, err := repo.Manifests(env.ctx)
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest service: %v", err)
+	}
+
+	if ok, err := ms.Exists(old); err != nil || !ok {
+		t.Fatalf("dry run removed untagged manifest: %v", err)
+	}
+
+	result, err = MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{RemoveUntagged: true})
+	if err != nil {
+		t.Fatalf("unexpected error running garbage collection: %v", err)
+	}
+
+	if ok, err := ms.Exists(old); err != nil || ok {
+		t.Fatalf("expected untagged manifest to be removed: %v", err)
+	}
+
+	for _, dgst := range []digest.Digest{old, oldLayer.Digest} {
+		if env.blobExists(t, dgst) {
+			t.Errorf("expected %s to be swept", dgst)
+		}
+	}
+
+	for _, dgst := range []digest.Digest{current, newLayer.Digest} {
+		if !env.blobExists(t, dgst) {
+			t.Errorf("expected %s to be present", dgst)
+		}
+	}
+}
+
+func TestGCRepositoryScope(t *testing.T) {
+	env := newGCTestEnv(t)
+	selected := env.repository(t, "foo/selected")
+	other := env.repository(t, "foo/other")
+
+	selectedOrphan := uploadRandomBlob(t, env.ctx, selected)
+	otherOrphan := uploadRandomBlob(t, env.ctx, other)
+
+	result, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{Repositories: []string{"foo/selected"}})
+	if err != nil {
+		t.Fatalf("unexpected error running garbage collection: %v", err)
+	}
+
+	if len(result.Swept) != 1 || result.Swept[0] != selectedOrphan.Digest {
+		t.Fatalf("unexpected blobs swept: %v", result.Swept)
+	}
+
+	if !env.blobExists(t, otherOrphan.Digest) {
+		t.Fatalf("blob outside of the selected repositories was swept")
+	}
+
+	if _, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{Repositories: []string{"foo/unknown"}}); err == nil {
+		t.Fatalf("expected error collecting unknown repository")
+	}
+}
+
+func TestGCEmptyRegistry(t *testing.T) {
+	env := newGCTestEnv(t)
+
+	result, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error running garbage collection: %v", err)
+	}
+
+	if len(result.Marked) != 0 || len(result.Swept) != 0 {
+		t.Fatalf("unexpected result for empty registry: %#v", result)
+	}
+}
+
+func TestDigestFromBlobDataPath(t *testing.T) {
+	root, err := pathFor(blobsPathSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dgst := range []digest.Digest{
+		"sha256:96443a84ce518ac22acb2e985eda402b58ac19ce6f91980bde63726a79d80b36",
+		"tarsum.v1+sha256:abcdefabcdefabcdef908909909",
+	} {
+		blobPath, err := pathFor(blobDataPathSpec{digest: dgst})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := digestFromBlobDataPath(root, blobPath)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %v", blobPath, err)
+		}
+
+		if parsed != dgst {
+			t.Fatalf("unexpected digest: %s != %s", parsed, dgst)
+		}
+	}
+
+	if _, err := digestFromBlobDataPath(root, root+"/sha256/data"); err == nil {
+		t.Fatalf("expected error parsing truncated path")
+	}
+}
+
+func TestGCRemoveUntaggedKeepsListedManifests(t *testing.T) {
+	env := newGCTestEnv(t)
+	repo := env.repository(t, "foo/bar")
+
+	ms, err := repo.Manifests(env.ctx)
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest service: %v", err)
+	}
+
+	config := uploadRandomBlob(t, env.ctx, repo)
+	layer := uploadRandomBlob(t, env.ctx, repo)
+	m, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    config,
+		Layers:    []distribution.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building manifest: %v", err)
+	}
+
+	platformManifest, err := ms.Put(m)
+	if err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	desc, err := distribution.DescribeManifest(m)
+	if err != nil {
+		t.Fatalf("unexpected error describing manifest: %v", err)
+	}
+
+	list, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{{Descriptor: desc}})
+	if err != nil {
+		t.Fatalf("unexpected error building manifest list: %v", err)
+	}
+
+	listDigest, err := ms.Put(list)
+	if err != nil {
+		t.Fatalf("unexpected error putting manifest list: %v", err)
+	}
+
+	// Only the list is tagged, the manifest it references is not.
+	if err := repo.Tags(env.ctx).Tag(env.ctx, "latest", distribution.Descriptor{Digest: listDigest}); err != nil {
+		t.Fatalf("unexpected error tagging manifest list: %v", err)
+	}
+
+	result, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{RemoveUntagged: true})
+	if err != nil {
+		t.Fatalf("unexpected error running garbage collection: %v", err)
+	}
+
+	if len(result.RemovedManifests) != 0 || len(result.Swept) != 0 {
+		t.Fatalf("unexpected removal: manifests=%v, blobs=%v", result.RemovedManifests, result.Swept)
+	}
+
+	for _, dgst := range []digest.Digest{listDigest, platformManifest, config.Digest, layer.Digest} {
+		if !env.blobExists(t, dgst) {
+			t.Errorf("expected %s to be present", dgst)
+		}
+	}
+}
+
+func TestGCOCIManifest(t *testing.T) {
+	env := newGCTestEnv(t)
+	repo := env.repository(t, "foo/bar")
+
+	ms, err := repo.Manifests(env.ctx)
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest service: %v", err)
+	}
+
+	config := uploadRandomBlob(t, env.ctx, repo)
+	layer := uploadRandomBlob(t, env.ctx, repo)
+	orphan := uploadRandomBlob(t, env.ctx, repo)
+
+	// OCI manifests may leave out their media type.
+	m, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: manifest.Versioned{SchemaVersion: 2},
+		Config:    ocischema.Descriptor{Descriptor: config},
+		Layers:    []ocischema.Descriptor{{Descriptor: layer}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building manifest: %v", err)
+	}
+
+	dgst, err := ms.Put(m)
+	if err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	if err := repo.Tags(env.ctx).Tag(env.ctx, "latest", distribution.Descriptor{Digest: dgst}); err != nil {
+		t.Fatalf("unexpected error tagging manifest: %v", err)
+	}
+
+	result, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error running garbage collection: %v", err)
+	}
+
+	if len(result.Swept) != 1 || result.Swept[0] != orphan.Digest {
+		t.Fatalf("expected only the orphaned blob to be swept: %v", result.Swept)
+	}
+
+	for _, dgst := range []digest.Digest{dgst, config.Digest, layer.Digest} {
+		if !env.blobExists(t, dgst) {
+			t.Errorf("expected %s to be present", dgst)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/storage/driver"
+	"github.com/docker/distribution/uuid"
+)
+
+// The garbage collection write barrier allows blobs to be swept while the
+// registry accepts writes. After linking blobs into a repository, writers
+// record their digests in the journal of the active cycle, if any, and then
+// check for a tombstone of each digest. Before deleting a blob, the collector
+// writes its tombstone and then checks the journal. Because each side writes
+// its own record before reading the other's, at least one of them observes
+// the conflict on a strongly consistent backend: either the collector leaves
+// the blob in place or the writer fails and the client retries. Links
+// written before a cycle starts are found by its mark phase.
+
+var (
+	// errGCCycleActive is returned when a garbage collection cycle is
+	// started while another one is in progress.
+	errGCCycleActive = errors.New("garbage collection cycle already in progress")
+
+	// errBlobSwept is returned to writers that reference a blob which is
+	// being removed by the active garbage collection cycle.
+	errBlobSwept = errors.New("blob is being removed by garbage collection, retry later")
+)
+
+// currentGCCycle returns the identifier of the active garbage collection
+// cycle or an empty string if no collection is running.
+func currentGCCycle(ctx context.Context, storageDriver driver.StorageDriver) (string, error) {
+	currentPath, err := pathFor(gcCurrentCyclePathSpec{})
+	if err != nil {
+		return "", err
+	}
+
+	content, err := storageDriver.GetContent(ctx, currentPath)
+	if err != nil {
+		if _, ok := err.(driver.PathNotFoundError); ok {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// beginGCCycle marks the start of a garbage collection cycle, after which
+// writers start journaling their references.
+func beginGCCycle(ctx context.Context, storageDriver driver.StorageDriver) (string, error) {
+	active, err := currentGCCycle(ctx, storageDriver)
+	if err != nil {
+		return "", err
+	}
+
+	if active != "" {
+		return "", fmt.Errorf("%v: %s", errGCCycleActive, active)
+	}
+
+	currentPath, err := pathFor(gcCurrentCyclePathSpec{})
+	if err != nil {
+		return "", err
+	}
+
+	cycle := uuid.Generate().String()
+	if err := storageDriver.PutContent(ctx, currentPath, []byte(cycle)); err != nil {
+		return "", err
+	}
+
+	context.GetLogger(ctx).Infof("Started garbage collection cycle %s", cycle)
+	return cycle, nil
+}
+
+// endGCCycle stops writers from journaling and removes the records of the
+// cycle.
+func endGCCycle(ctx context.Context, storageDriver driver.StorageDriver, cycle string) error {
+	currentPath, err := pathFor(gcCurrentCyclePathSpec{})
+	if err != nil {
+		return err
+	}
+
+	if err := storageDriver.Delete(ctx, currentPath); err != nil {
+		if _, ok := err.(driver.PathNotFoundError); !ok {
+			return err
+		}
+	}
+
+	cyclePath, err := pathFor(gcCyclePathSpec{cycle: cycle})
+	if err != nil {
+		return err
+	}
+
+	if err := storageDriver.Delete(ctx, cyclePath); err != nil {
+		if _, ok := err.(driver.PathNotFoundError); !ok {
+			return err
+		}
+	}
+
+	context.GetLogger(ctx).Infof("Finished garbage collection cycle %s", cycle)
+	return nil
+}
+
+// recordGCReferences is the writer side of the barrier. It must be called
+// after dgsts are linked into a repository. If a garbage collection cycle
+// is active, the digests are journaled and errBlobSwept is returned if any
+// of them may already have been deleted. As the tombstones of a cycle are
+// removed when it ends, the blobs are also checked to still exist.
+func recordGCReferences(ctx context.Context, storageDriver driver.StorageDriver, dgsts ...digest.Digest) error {
+	cycle, err := currentGCCycle(ctx, storageDriver)
+	if err != nil {
+		return err
+	}
+
+	if cycle != "" {
+		for _, dgst := range dgsts {
+			journalPath, err := pathFor(gcJournalEntryPathSpec{cycle: cycle, digest: dgst})
+			if err != nil {
+				return err
+			}
+
+			if err := storageDriver.PutContent(ctx, journalPath, []byte(dgst)); err != nil {
+				return err
+			}
+		}
+
+		for _, dgst := range dgsts {
+			tombstonePath, err := pathFor(gcTombstonePathSpec{cycle: cycle, digest: dgst})
+			if err != nil {
+				return err
+			}
+
+			swept, err := exists(ctx, storageDriver, tombstonePath)
+			if err != nil {
+				return err
+			}
+
+			if swept {
+				context.GetLogger(ctx).Warnf("Rejecting reference to %s swept by garbage collection cycle %s", dgst, cycle)
+				return errBlobSwept
+			}
+		}
+	}
+
+	for _, dgst := range dgsts {
+		blobPath, err := pathFor(blobDataPathSpec{digest: dgst})
+		if err != nil {
+			return err
+		}
+
+		ok, err := exists(ctx, storageDriver, blobPath)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			context.GetLogger(ctx).Warnf("Rejecting reference to %s removed by garbage collection", dgst)
+			return errBlobSwept
+		}
+	}
+
+	return nil
+}
+
+// claimGCCandidate is the collector side of the barrier. It tombstones dgst
+// and reports whether it is still safe to delete, which is the case if no
+// writer has journaled it during the cycle.
+func claimGCCandidate(ctx context.Context, storageDriver driver.StorageDriver, cycle string, dgst digest.Digest) (bool, error) {
+	tombstonePath, err := pathFor(gcTombstonePathSpec{cycle: cycle, digest: dgst})
+	if err != nil {
+		return false, err
+	}
+
+	if err := storageDriver.PutContent(ctx, tombstonePath, []byte(dgst)); err != nil {
+		return false, err
+	}
+
+	journalPath, err := pathFor(gcJournalEntryPathSpec{cycle: cycle, digest: dgst})
+	if err != nil {
+		return false, err
+	}
+
+	referenced, err := exists(ctx, storageDriver, journalPath)
+	if err != nil {
+		return false, err
+	}
+
+	return !referenced, nil
+}
@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/libtrust"
+)
+
+func TestGCBarrier(t *testing.T) {
+	env := newGCTestEnv(t)
+	repo := env.repository(t, "foo/bar")
+
+	referenced := uploadRandomBlob(t, env.ctx, repo).Digest
+	swept := uploadRandomBlob(t, env.ctx, repo).Digest
+
+	// Without an active cycle, writers don't journal anything.
+	if err := recordGCReferences(env.ctx, env.driver, swept); err != nil {
+		t.Fatalf("unexpected error recording outside of a cycle: %v", err)
+	}
+
+	cycle, err := beginGCCycle(env.ctx, env.driver)
+	if err != nil {
+		t.Fatalf("unexpected error beginning cycle: %v", err)
+	}
+
+	if _, err := beginGCCycle(env.ctx, env.driver); err == nil {
+		t.Fatalf("expected error beginning a concurrent cycle")
+	}
+
+	if err := recordGCReferences(env.ctx, env.driver, referenced); err != nil {
+		t.Fatalf("unexpected error recording reference: %v", err)
+	}
+
+	ok, err := claimGCCandidate(env.ctx, env.driver, cycle, referenced)
+	if err != nil {
+		t.Fatalf("unexpected error claiming candidate: %v", err)
+	}
+
+	if ok {
+		t.Fatalf("journaled blob should not be claimed for deletion")
+	}
+
+	ok, err = claimGCCandidate(env.ctx, env.driver, cycle, swept)
+	if err != nil {
+		t.Fatalf("unexpected error claiming candidate: %v", err)
+	}
+
+	if !ok {
+		t.Fatalf("unreferenced blob should be claimed for deletion")
+	}
+
+	if err := recordGCReferences(env.ctx, env.driver, swept); err != errBlobSwept {
+		t.Fatalf("expected errBlobSwept referencing a claimed blob: %v", err)
+	}
+
+	if err := endGCCycle(env.ctx, env.driver, cycle); err != nil {
+		t.Fatalf("unexpected error ending cycle: %v", err)
+	}
+
+	if err := recordGCReferences(env.ctx, env.driver, swept); err != nil {
+		t.Fatalf("unexpected error recording after the cycle ended: %v", err)
+	}
+
+	gcRoot, err := pathFor(gcCyclePathSpec{cycle: cycle})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := exists(env.ctx, env.driver, gcRoot); err != nil || ok {
+		t.Fatalf("expected cycle records to be removed: %v", err)
+	}
+
+	// Writers that saw the blob before it was deleted must notice once the
+	// tombstones are gone.
+	if err := NewVacuum(env.ctx, env.driver).RemoveBlob(string(swept)); err != nil {
+		t.Fatalf("unexpected error removing blob: %v", err)
+	}
+
+	if err := recordGCReferences(env.ctx, env.driver, swept); err != errBlobSwept {
+		t.Fatalf("expected errBlobSwept referencing a removed blob: %v", err)
+	}
+}
+
+func TestGCOnlineConcurrentPush(t *testing.T) {
+	env := newGCTestEnv(t)
+	repo := env.repository(t, "foo/bar")
+
+	// Orphaned blobs are sweep candidates. Pushers reuse the content of
+	// some of them so that they race with the collector over the same
+	// digests. The others must be swept.
+	var orphans [][]byte
+	for i := 0; i < 20; i++ {
+		p := randomContent(t)
+		if _, err := repo.Blobs(env.ctx).Put(env.ctx, "application/octet-stream", p); err != nil {
+			t.Fatalf("unexpected error putting blob: %v", err)
+		}
+		orphans = append(orphans, p)
+	}
+
+	var untouched []digest.Digest
+	for i := 0; i < 5; i++ {
+		desc, err := repo.Blobs(env.ctx).Put(env.ctx, "application/octet-stream", randomContent(t))
+		if err != nil {
+			t.Fatalf("unexpected error putting blob: %v", err)
+		}
+		untouched = append(untouched, desc.Digest)
+	}
+
+	type pushed struct {
+		revision digest.Digest
+		layers   []digest.Digest
+	}
+
+	var (
+		mu       sync.Mutex
+		images   []pushed
+		retries  int
+		wg       sync.WaitGroup
+		finished = make(chan struct{})
+	)
+
+	for w := 0; w < 4; w++ {
+		fresh := make([][]byte, 25)
+		for i := range fresh {
+			fresh[i] = randomContent(t)
+		}
+
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := range fresh {
+				layers := [][]byte{orphans[(w*5+i)%len(orphans)], fresh[i]}
+				revision, dgsts, err := pushTestImage(env.ctx, repo, fmt.Sprintf("tag-%d-%d", w, i), layers)
+				if err != nil {
+					// Writers racing with the sweep are told to retry.
+					mu.Lock()
+					retries++
+					mu.Unlock()
+					continue
+				}
+
+				mu.Lock()
+				images = append(images, pushed{revision: revision, layers: dgsts})
+				mu.Unlock()
+			}
+		}(w)
+	}
+
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	var cycles, sweptTotal int
+	for running := true; running; cycles++ {
+		select {
+		case <-finished:
+			running = false
+		default:
+		}
+
+		result, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{Online: true})
+		if err != nil {
+			<-finished
+			t.Fatalf("unexpected error running garbage collection: %v", err)
+		}
+
+		if len(result.Errors) != 0 {
+			t.Errorf("unexpected errors sweeping: %v", result.Errors)
+		}
+		sweptTotal += len(result.Swept)
+	}
+
+	if len(images) == 0 {
+		t.Fatalf("no images were pushed during garbage collection")
+	}
+
+	for _, dgst := range untouched {
+		if env.blobExists(t, dgst) {
+			t.Fatalf("orphaned blob %s was not swept", dgst)
+		}
+	}
+
+	t.Logf("pushed %d images with %d retries, swept %d blobs in %d cycles", len(images), retries, sweptTotal, cycles)
+
+	ms, err := repo.Manifests(env.ctx)
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest service: %v", err)
+	}
+
+	for _, image := range images {
+		if _, err := ms.Get(image.revision); err != nil {
+			t.Fatalf("manifest %s pushed during garbage collection is broken: %v", image.revision, err)
+		}
+
+		for _, layer := range image.layers {
+			if !env.blobExists(t, layer) {
+				t.Fatalf("layer %s of manifest %s was swept", layer, image.revision)
+			}
+		}
+	}
+
+	// With writers stopped, a final collection must not remove anything
+	// that was pushed.
+	if _, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{}); err != nil {
+		t.Fatalf("unexpected error running garbage collection: %v", err)
+	}
+
+	for _, image := range images {
+		for _, dgst := range append(image.layers, image.revision) {
+			if !env.blobExists(t, dgst) {
+				t.Fatalf("blob %s was swept after being pushed", dgst)
+			}
+		}
+	}
+}
+
+func randomContent(t *testing.T) []byte {
+	p := make([]byte, 512)
+	if _, err := rand.Read(p); err != nil {
+		t.Fatalf("unexpected error generating content: %v", err)
+	}
+	return p
+}
+
+// pushTestImage uploads layers and a manifest referencing them the way a
+// client would, returning errors instead of failing the test so that it can
+// be used from multiple goroutines.
+func pushTestImage(ctx context.Context, repo distribution.Repository, tag string, layers [][]byte) (digest.Digest, []digest.Digest, error) {
+	m := schema1.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name: repo.Name(),
+		Tag:  tag,
+	}
+
+	var dgsts []digest.Digest
+	for _, layer := range layers {
+		// Put avoids streaming writes, which the inmemory driver doesn't
+		// support concurrently with other operations.
+		desc, err := repo.Blobs(ctx).Put(ctx, "application/octet-stream", layer)
+		if err != nil {
+			return "", nil, err
+		}
+		dgst := desc.Digest
+
+		dgsts = append(dgsts, dgst)
+		m.FSLayers = append(m.FSLayers, schema1.FSLayer{BlobSum: dgst})
+		m.History = append(m.History, schema1.History{V1Compatibility: ""})
+	}
+
+	pk, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	sm, err := schema1.Sign(&m, pk)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ms, err := repo.Manifests(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	revision, err := ms.Put(sm)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := repo.Tags(ctx).Tag(ctx, tag, distribution.Descriptor{Digest: revision}); err != nil {
+		return "", nil, err
+	}
+
+	return revision, dgsts, nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/reference"
 	"github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/uuid"
 )
@@ -23,9 +24,12 @@ type linkedBlobStore struct {
 	blobServer             distribution.BlobServer
 	blobAccessController   distribution.BlobDescriptorService
 	repository             distribution.Repository
+	registry               *registry       // used to look up the source repository of mounts
+	usage                  *usageStore     // accounts linked content, if set
 	ctx                    context.Context // only to be used where context can't come through method args
 	deleteEnabled          bool
 	resumableDigestEnabled bool
+	catalogIndexEnabled    bool
 
 	// linkPathFns specifies one or more path functions allowing one to
 	// control the repository blob link set to which the blob store
@@ -94,13 +98,40 @@ func (lbs *linkedBlobStore) Put(ctx context.Context, mediaType string, p []byte)
 	// returned by Put above. Note that we should allow updates for a given
 	// repository.
 
+	if err := lbs.indexRepository(ctx); err != nil {
+		return distribution.Descriptor{}, err
+	}
+
 	return desc, lbs.linkBlob(ctx, desc)
 }
 
-// Writer begins a blob write session, returning a handle.
-func (lbs *linkedBlobStore) Create(ctx context.Context) (distribution.BlobWriter, error) {
+// Writer begins a blob write session, returning a handle. If the options ask
+// for a mount and the blob is linked into the source repository, it is linked
+// into this repository instead and ErrBlobMounted is returned.
+func (lbs *linkedBlobStore) Create(ctx context.Context, options ...distribution.BlobCreateOption) (distribution.BlobWriter, error) {
 	context.GetLogger(ctx).Debug("(*linkedBlobStore).Writer")
 
+	var opts distribution.CreateOptions
+	for _, option := range options {
+		if err := option(&opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Mount.ShouldMount {
+		desc, err := lbs.mount(ctx, opts.Mount.From)
+		if err == nil {
+			return nil, distribution.ErrBlobMounted{From: opts.Mount.From, Descriptor: desc}
+		}
+
+		// The blob can't be mounted, so it has to be uploaded.
+		context.GetLogger(ctx).Debugf("unable to mount blob %s: %v", opts.Mount.From, err)
+	}
+
+	if err := lbs.indexRepository(ctx); err != nil {
+		return nil, err
+	}
+
 	uuid := uuid.Generate().String()
 	startedAt := time.Now().UTC()
 
@@ -174,18 +205,22 @@ func (lbs *linkedBlobStore) Delete(ctx context.Context, dgst digest.Digest) erro
 		return distribution.ErrUnsupported
 	}
 
-	// Ensure the blob is available for deletion
-	_, err := lbs.blobAccessController.Stat(ctx, dgst)
-	if err != nil {
-		return err
+	unlink := func() (int64, error) {
+		// Ensure the blob is available for deletion
+		desc, err := lbs.blobAccessController.Stat(ctx, dgst)
+		if err != nil {
+			return 0, err
+		}
+
+		return desc.Size, lbs.blobAccessController.Clear(ctx, dgst)
 	}
 
-	err = lbs.blobAccessController.Clear(ctx, dgst)
-	if err != nil {
-		return err
+	if lbs.usage != nil {
+		return lbs.usage.unlink(ctx, lbs.repository.Name(), unlink)
 	}
 
-	return nil
+	_, err := unlink()
+	return err
 }
 
 // newBlobUpload allocates a new upload controller with the given state.
@@ -207,6 +242,43 @@ func (lbs *linkedBlobStore) newBlobUpload(ctx context.Context, uuid, path string
 	return bw, nil
 }
 
+// mount links the blob identified by the digest of source into this
+// repository, provided it is linked into the repository named by source.
+func (lbs *linkedBlobStore) mount(ctx context.Context, source reference.Canonical) (distribution.Descriptor, error) {
+	if lbs.registry == nil {
+		return distribution.Descriptor{}, distribution.ErrUnsupported
+	}
+
+	repo, err := lbs.registry.Repository(ctx, source.Name())
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	desc, err := repo.Blobs(ctx).Stat(ctx, source.Digest())
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	if err := lbs.indexRepository(ctx); err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	if err := lbs.linkBlob(ctx, desc); err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	return desc, nil
+}
+
+// indexRepository adds the repository to the catalog index, if it is enabled.
+func (lbs *linkedBlobStore) indexRepository(ctx context.Context) error {
+	if !lbs.catalogIndexEnabled {
+		return nil
+	}
+
+	return indexRepository(ctx, lbs.driver, lbs.repository.Name())
+}
+
 // linkBlob links a valid, written blob into the registry under the named
 // repository for the upload controller.
 func (lbs *linkedBlobStore) linkBlob(ctx context.Context, canonical distribution.Descriptor, aliases ...digest.Digest) error {
@@ -222,23 +294,43 @@ func (lbs *linkedBlobStore) linkBlob(ctx context.Context, canonical distribution
 	// only use the first link
 	linkPathFn := lbs.linkPathFns[0]
 
-	for _, dgst := range dgsts {
-		if _, seen := seenDigests[dgst]; seen {
-			continue
+	link := func() error {
+		for _, dgst := range dgsts {
+			if _, seen := seenDigests[dgst]; seen {
+				continue
+			}
+			seenDigests[dgst] = struct{}{}
+
+			blobLinkPath, err := linkPathFn(lbs.repository.Name(), dgst)
+			if err != nil {
+				return err
+			}
+
+			if err := lbs.blobStore.link(ctx, blobLinkPath, canonical.Digest); err != nil {
+				return err
+			}
 		}
-		seenDigests[dgst] = struct{}{}
 
-		blobLinkPath, err := linkPathFn(lbs.repository.Name(), dgst)
+		return nil
+	}
+
+	if lbs.usage != nil {
+		// Content is accounted once, when its canonical link is created.
+		canonicalLinkPath, err := linkPathFn(lbs.repository.Name(), canonical.Digest)
 		if err != nil {
 			return err
 		}
 
-		if err := lbs.blobStore.link(ctx, blobLinkPath, canonical.Digest); err != nil {
+		if err := lbs.usage.link(ctx, lbs.repository.Name(), canonicalLinkPath, canonical.Size, link); err != nil {
 			return err
 		}
+	} else if err := link(); err != nil {
+		return err
 	}
 
-	return nil
+	// A garbage collection cycle that starts from here on finds the new
+	// links. One that is already running must be told about the blob.
+	return recordGCReferences(ctx, lbs.driver, canonical.Digest)
 }
 
 type linkedBlobStatter struct {
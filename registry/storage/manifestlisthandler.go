@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
+)
+
+// manifestListHandler is a manifestHandler that covers manifest lists and
+// OCI image indexes.
+type manifestListHandler struct {
+	repository *repository
+	blobStore  *linkedBlobStore
+	ctx        context.Context
+}
+
+var _ manifestHandler = &manifestListHandler{}
+
+func (ms *manifestListHandler) Unmarshal(ctx context.Context, dgst digest.Digest, content []byte) (distribution.Manifest, error) {
+	context.GetLogger(ms.ctx).Debug("(*manifestListHandler).Unmarshal")
+
+	var versioned manifest.Versioned
+	if err := json.Unmarshal(content, &versioned); err != nil {
+		return nil, err
+	}
+
+	if versioned.MediaType == manifestlist.ManifestListMediaType {
+		var m manifestlist.DeserializedManifestList
+		if err := json.Unmarshal(content, &m); err != nil {
+			return nil, err
+		}
+
+		return &m, nil
+	}
+
+	var m ocischema.DeserializedImageIndex
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (ms *manifestListHandler) Put(ctx context.Context, manifestList distribution.Manifest, skipDependencyVerification bool) (digest.Digest, error) {
+	context.GetLogger(ms.ctx).Debug("(*manifestListHandler).Put")
+
+	switch manifestList.(type) {
+	case *manifestlist.DeserializedManifestList, *ocischema.DeserializedImageIndex:
+	default:
+		return "", fmt.Errorf("non-manifest list put to manifestListHandler: %T", manifestList)
+	}
+
+	manifestService, err := ms.repository.Manifests(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Every referenced manifest must already be stored in the repository.
+	return storeManifest(ctx, ms.repository, ms.blobStore, manifestList, skipDependencyVerification, manifestService.Exists)
+}
@@ -1,29 +1,60 @@
 package storage
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
 	"github.com/docker/distribution/manifest/schema1"
-	"github.com/docker/libtrust"
+	"github.com/docker/distribution/manifest/schema2"
 )
 
+// A manifestHandler gets and puts manifests of a particular type.
+type manifestHandler interface {
+	// Unmarshal unmarshals the manifest from a byte slice.
+	Unmarshal(ctx context.Context, dgst digest.Digest, content []byte) (distribution.Manifest, error)
+
+	// Put creates or updates the given manifest returning the manifest
+	// digest. References are verified unless skipDependencyVerification is
+	// set.
+	Put(ctx context.Context, manifest distribution.Manifest, skipDependencyVerification bool) (digest.Digest, error)
+}
+
 type manifestStore struct {
 	repository                 *repository
-	revisionStore              *revisionStore
-	tagStore                   *tagStore
+	blobStore                  *linkedBlobStore
 	ctx                        context.Context
 	skipDependencyVerification bool
+
+	schema1Handler      manifestHandler
+	schema2Handler      manifestHandler
+	ocischemaHandler    manifestHandler
+	manifestListHandler manifestHandler
 }
 
 var _ distribution.ManifestService = &manifestStore{}
 
-func (ms *manifestStore) Exists(dgst digest.Digest) (bool, error) {
-	context.GetLogger(ms.ctx).Debug("(*manifestStore).Exists")
+// withSpan starts the span of an operation on the manifests, so that the
+// operations of the storage driver are grouped by manifest operation.
+func (ms *manifestStore) withSpan(operation string) (context.Context, *context.Span) {
+	ctx, span := context.WithSpan(ms.ctx, "manifestStore."+operation)
+	span.SetAttribute("vars.name", ms.repository.Name())
+	return ctx, span
+}
+
+func (ms *manifestStore) Exists(dgst digest.Digest) (exists bool, err error) {
+	ctx, span := ms.withSpan("Exists")
+	span.SetAttribute("vars.digest", dgst.String())
+	defer func() { span.Finish(err) }()
+
+	context.GetLogger(ctx).Debug("(*manifestStore).Exists")
 
-	_, err := ms.revisionStore.blobStore.Stat(ms.ctx, dgst)
+	_, err = ms.blobStore.Stat(ctx, dgst)
 	if err != nil {
 		if err == distribution.ErrBlobUnknown {
 			return false, nil
@@ -35,9 +66,89 @@ func (ms *manifestStore) Exists(dgst digest.Digest) (bool, error) {
 	return true, nil
 }
 
-func (ms *manifestStore) Get(dgst digest.Digest) (*schema1.SignedManifest, error) {
-	context.GetLogger(ms.ctx).Debug("(*manifestStore).Get")
-	return ms.revisionStore.get(ms.ctx, dgst)
+func (ms *manifestStore) Get(dgst digest.Digest) (m distribution.Manifest, err error) {
+	ctx, span := ms.withSpan("Get")
+	span.SetAttribute("vars.digest", dgst.String())
+	defer func() { span.Finish(err) }()
+
+	context.GetLogger(ctx).Debug("(*manifestStore).Get")
+
+	// Ensure that this revision is available in this repository.
+	_, err = ms.blobStore.Stat(ctx, dgst)
+	if err != nil {
+		if err == distribution.ErrBlobUnknown {
+			return nil, distribution.ErrManifestUnknownRevision{
+				Name:     ms.repository.Name(),
+				Revision: dgst,
+			}
+		}
+
+		return nil, err
+	}
+
+	content, err := ms.blobStore.Get(ctx, dgst)
+	if err != nil {
+		if err == distribution.ErrBlobUnknown {
+			return nil, distribution.ErrManifestUnknownRevision{
+				Name:     ms.repository.Name(),
+				Revision: dgst,
+			}
+		}
+
+		return nil, err
+	}
+
+	// The stored payload identifies its own format.
+	var versioned manifest.Versioned
+	if err = json.Unmarshal(content, &versioned); err != nil {
+		return nil, err
+	}
+
+	switch versioned.SchemaVersion {
+	case 1:
+		return ms.schema1Handler.Unmarshal(ctx, dgst, content)
+	case 2:
+		mediaType, err := schema2MediaType(versioned, content)
+		if err != nil {
+			return nil, err
+		}
+
+		// This can be an image manifest or a manifest list
+		switch mediaType {
+		case schema2.ManifestMediaType:
+			return ms.schema2Handler.Unmarshal(ctx, dgst, content)
+		case ocischema.ManifestMediaType:
+			return ms.ocischemaHandler.Unmarshal(ctx, dgst, content)
+		case manifestlist.ManifestListMediaType, ocischema.IndexMediaType:
+			return ms.manifestListHandler.Unmarshal(ctx, dgst, content)
+		default:
+			return nil, distribution.ErrManifestVerification{fmt.Errorf("unrecognized manifest content type %s", versioned.MediaType)}
+		}
+	}
+
+	return nil, fmt.Errorf("unrecognized manifest schema version %d", versioned.SchemaVersion)
+}
+
+// schema2MediaType returns the media type of a stored schema version 2
+// payload. OCI content may leave out its media type, in which case an image
+// index is told apart from an image manifest by its manifests field.
+func schema2MediaType(versioned manifest.Versioned, content []byte) (string, error) {
+	if versioned.MediaType != "" {
+		return versioned.MediaType, nil
+	}
+
+	var index struct {
+		Manifests json.RawMessage `json:"manifests"`
+	}
+	if err := json.Unmarshal(content, &index); err != nil {
+		return "", err
+	}
+
+	if index.Manifests != nil {
+		return ocischema.IndexMediaType, nil
+	}
+
+	return ocischema.ManifestMediaType, nil
 }
 
 // SkipLayerVerification allows a manifest to be Put before it's
@@ -50,100 +161,134 @@ func SkipLayerVerification(ms distribution.ManifestService) error {
 	return fmt.Errorf("skip layer verification only valid for manifeststore")
 }
 
-func (ms *manifestStore) Put(manifest *schema1.SignedManifest) error {
-	context.GetLogger(ms.ctx).Debug("(*manifestStore).Put")
+func (ms *manifestStore) Put(manifest distribution.Manifest) (dgst digest.Digest, err error) {
+	ctx, span := ms.withSpan("Put")
+	defer func() { span.Finish(err) }()
 
-	if err := ms.verifyManifest(ms.ctx, manifest); err != nil {
-		return err
-	}
+	context.GetLogger(ctx).Debug("(*manifestStore).Put")
 
-	// Store the revision of the manifest
-	revision, err := ms.revisionStore.put(ms.ctx, manifest)
-	if err != nil {
-		return err
+	switch manifest.(type) {
+	case *schema1.SignedManifest:
+		return ms.schema1Handler.Put(ctx, manifest, ms.skipDependencyVerification)
+	case *schema2.DeserializedManifest:
+		return ms.schema2Handler.Put(ctx, manifest, ms.skipDependencyVerification)
+	case *ocischema.DeserializedManifest:
+		return ms.ocischemaHandler.Put(ctx, manifest, ms.skipDependencyVerification)
+	case *manifestlist.DeserializedManifestList, *ocischema.DeserializedImageIndex:
+		return ms.manifestListHandler.Put(ctx, manifest, ms.skipDependencyVerification)
 	}
 
-	// Now, tag the manifest
-	return ms.tagStore.tag(manifest.Tag, revision.Digest)
+	return "", fmt.Errorf("unrecognized manifest type %T", manifest)
 }
 
 // Delete removes the revision of the specified manfiest.
-func (ms *manifestStore) Delete(dgst digest.Digest) error {
-	context.GetLogger(ms.ctx).Debug("(*manifestStore).Delete")
-	return ms.revisionStore.delete(ms.ctx, dgst)
-}
-
-func (ms *manifestStore) Tags() ([]string, error) {
-	context.GetLogger(ms.ctx).Debug("(*manifestStore).Tags")
-	return ms.tagStore.tags()
-}
+func (ms *manifestStore) Delete(dgst digest.Digest) (err error) {
+	ctx, span := ms.withSpan("Delete")
+	span.SetAttribute("vars.digest", dgst.String())
+	defer func() { span.Finish(err) }()
 
-func (ms *manifestStore) ExistsByTag(tag string) (bool, error) {
-	context.GetLogger(ms.ctx).Debug("(*manifestStore).ExistsByTag")
-	return ms.tagStore.exists(tag)
+	context.GetLogger(ctx).Debug("(*manifestStore).Delete")
+	return ms.blobStore.Delete(ctx, dgst)
 }
 
-func (ms *manifestStore) GetByTag(tag string, options ...distribution.ManifestServiceOption) (*schema1.SignedManifest, error) {
-	for _, option := range options {
-		err := option(ms)
-		if err != nil {
-			return nil, err
+// storeManifest stores the payload of the manifest in the repository, which
+// links the revision, and protects its references. Unless
+// skipDependencyVerification is set, the references must first be reported
+// to exist by exists.
+func storeManifest(ctx context.Context, repo *repository, blobStore *linkedBlobStore, m distribution.Manifest, skipDependencyVerification bool, exists func(digest.Digest) (bool, error)) (digest.Digest, error) {
+	if !skipDependencyVerification {
+		if err := verifyReferences(m.References(), exists); err != nil {
+			return "", err
 		}
 	}
 
-	context.GetLogger(ms.ctx).Debug("(*manifestStore).GetByTag")
-	dgst, err := ms.tagStore.resolve(tag)
+	mt, payload, err := m.Payload()
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+
+	revision, err := blobStore.Put(ctx, mt, payload)
+	if err != nil {
+		context.GetLogger(ctx).Errorf("error putting payload into blobstore: %v", err)
+		return "", err
+	}
+
+	if !skipDependencyVerification {
+		if err := protectReferences(ctx, repo, m.References()); err != nil {
+			return "", err
+		}
 	}
 
-	return ms.revisionStore.get(ms.ctx, dgst)
+	return revision.Digest, nil
 }
 
-// verifyManifest ensures that the manifest content is valid from the
-// perspective of the registry. It ensures that the signature is valid for the
-// enclosed payload. As a policy, the registry only tries to store valid
+// verifyReferences ensures that the references of a manifest are present, as
+// reported by exists. As a policy, the registry only tries to store valid
 // content, leaving trust policies of that content up to consumers.
-func (ms *manifestStore) verifyManifest(ctx context.Context, mnfst *schema1.SignedManifest) error {
+func verifyReferences(references []distribution.Descriptor, exists func(digest.Digest) (bool, error)) error {
 	var errs distribution.ErrManifestVerification
-	if mnfst.Name != ms.repository.Name() {
-		errs = append(errs, fmt.Errorf("repository name does not match manifest name"))
+	for _, reference := range references {
+		ok, err := exists(reference.Digest)
+		if err != nil && err != distribution.ErrBlobUnknown {
+			errs = append(errs, err)
+		}
+		if err != nil || !ok {
+			// On error here, we always append unknown blob errors.
+			errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: reference.Digest})
+		}
 	}
-
-	if len(mnfst.History) != len(mnfst.FSLayers) {
-		errs = append(errs, fmt.Errorf("mismatched history and fslayer cardinality %d != %d",
-			len(mnfst.History), len(mnfst.FSLayers)))
+	if len(errs) != 0 {
+		return errs
 	}
 
-	if _, err := schema1.Verify(mnfst); err != nil {
-		switch err {
-		case libtrust.ErrMissingSignatureKey, libtrust.ErrInvalidJSONContent, libtrust.ErrMissingSignatureKey:
-			errs = append(errs, distribution.ErrManifestUnverified{})
-		default:
-			if err.Error() == "invalid signature" { // TODO(stevvooe): This should be exported by libtrust
-				errs = append(errs, distribution.ErrManifestUnverified{})
-			} else {
-				errs = append(errs, err)
-			}
+	return nil
+}
+
+// blobExists returns a function reporting whether blobs are linked into the
+// repository.
+func blobExists(ctx context.Context, repo *repository) func(digest.Digest) (bool, error) {
+	return func(dgst digest.Digest) (bool, error) {
+		if _, err := repo.Blobs(ctx).Stat(ctx, dgst); err != nil {
+			return false, err
 		}
+
+		return true, nil
 	}
+}
 
-	if !ms.skipDependencyVerification {
-		for _, fsLayer := range mnfst.FSLayers {
-			_, err := ms.repository.Blobs(ctx).Stat(ctx, fsLayer.BlobSum)
+// protectReferences records the references of a manifest with an active
+// garbage collection cycle, if any. It is called after the revision is
+// linked: a cycle that starts later marks the references through the
+// revision, while one that already swept a reference causes an error, so the
+// manifest is not tagged and the client pushes it again.
+func protectReferences(ctx context.Context, repo *repository, references []distribution.Descriptor) error {
+	seen := make(map[digest.Digest]struct{}, len(references))
+	for _, reference := range references {
+		if _, ok := seen[reference.Digest]; ok {
+			continue
+		}
+		seen[reference.Digest] = struct{}{}
+
+		// The collector works with canonical digests.
+		dgst := reference.Digest
+		if dgst.Algorithm() != digest.Canonical {
+			desc, err := repo.Blobs(ctx).Stat(ctx, dgst)
 			if err != nil {
-				if err != distribution.ErrBlobUnknown {
-					errs = append(errs, err)
+				if err == distribution.ErrBlobUnknown {
+					return distribution.ErrManifestVerification{distribution.ErrManifestBlobUnknown{Digest: reference.Digest}}
 				}
+				return err
+			}
+			dgst = desc.Digest
+		}
 
-				// On error here, we always append unknown blob errors.
-				errs = append(errs, distribution.ErrManifestBlobUnknown{Digest: fsLayer.BlobSum})
+		if err := recordGCReferences(ctx, repo.blobStore.driver, dgst); err != nil {
+			if err == errBlobSwept {
+				return distribution.ErrManifestVerification{distribution.ErrManifestBlobUnknown{Digest: reference.Digest}}
 			}
+			return err
 		}
 	}
-	if len(errs) != 0 {
-		return errs
-	}
 
 	return nil
 }
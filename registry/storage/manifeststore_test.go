@@ -10,7 +10,10 @@ import (
 	"github.com/docker/distribution/context"
 	"github.com/docker/distribution/digest"
 	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/ocischema"
 	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/distribution/registry/storage/cache/memory"
 	"github.com/docker/distribution/registry/storage/driver"
 	"github.com/docker/distribution/registry/storage/driver/inmemory"
@@ -50,6 +53,16 @@ func newManifestStoreTestEnv(t *testing.T, name, tag string) *manifestStoreTestE
 	}
 }
 
+// getByTag resolves the tag and fetches the manifest it points at.
+func getByTag(env *manifestStoreTestEnv, ms distribution.ManifestService, tag string) (distribution.Manifest, error) {
+	desc, err := env.repository.Tags(env.ctx).Get(env.ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return ms.Get(desc.Digest)
+}
+
 func TestManifestStorage(t *testing.T) {
 	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
 	ctx := context.Background()
@@ -58,21 +71,13 @@ func TestManifestStorage(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	exists, err := ms.ExistsByTag(env.tag)
-	if err != nil {
-		t.Fatalf("unexpected error checking manifest existence: %v", err)
-	}
-
-	if exists {
-		t.Fatalf("manifest should not exist")
-	}
-
-	if _, err := ms.GetByTag(env.tag); true {
+	tags := env.repository.Tags(ctx)
+	if _, err := tags.Get(ctx, env.tag); true {
 		switch err.(type) {
-		case distribution.ErrManifestUnknown:
+		case distribution.ErrTagUnknown:
 			break
 		default:
-			t.Fatalf("expected manifest unknown error: %#v", err)
+			t.Fatalf("expected tag unknown error: %#v", err)
 		}
 	}
 
@@ -114,7 +119,7 @@ func TestManifestStorage(t *testing.T) {
 		t.Fatalf("error signing manifest: %v", err)
 	}
 
-	err = ms.Put(sm)
+	_, err = ms.Put(sm)
 	if err == nil {
 		t.Fatalf("expected errors putting manifest with full verification")
 	}
@@ -150,20 +155,30 @@ func TestManifestStorage(t *testing.T) {
 		}
 	}
 
-	if err = ms.Put(sm); err != nil {
+	manifestDigest, err := ms.Put(sm)
+	if err != nil {
 		t.Fatalf("unexpected error putting manifest: %v", err)
 	}
 
-	exists, err = ms.ExistsByTag(env.tag)
+	// Putting a manifest doesn't tag it.
+	if _, err := tags.Get(ctx, env.tag); err == nil {
+		t.Fatalf("manifest should not be tagged before Tag")
+	}
+
+	if err := tags.Tag(ctx, env.tag, distribution.Descriptor{Digest: manifestDigest}); err != nil {
+		t.Fatalf("unexpected error tagging manifest: %v", err)
+	}
+
+	desc, err := tags.Get(ctx, env.tag)
 	if err != nil {
-		t.Fatalf("unexpected error checking manifest existence: %v", err)
+		t.Fatalf("unexpected error resolving tag: %v", err)
 	}
 
-	if !exists {
-		t.Fatalf("manifest should exist")
+	if desc.Digest != manifestDigest {
+		t.Fatalf("unexpected digest for tag: %s != %s", desc.Digest, manifestDigest)
 	}
 
-	fetchedManifest, err := ms.GetByTag(env.tag)
+	fetchedManifest, err := ms.Get(desc.Digest)
 
 	if err != nil {
 		t.Fatalf("unexpected error fetching manifest: %v", err)
@@ -173,7 +188,12 @@ func TestManifestStorage(t *testing.T) {
 		t.Fatalf("fetched manifest not equal: %#v != %#v", fetchedManifest, sm)
 	}
 
-	fetchedJWS, err := libtrust.ParsePrettySignature(fetchedManifest.Raw, "signatures")
+	fetchedSM, ok := fetchedManifest.(*schema1.SignedManifest)
+	if !ok {
+		t.Fatalf("unexpected manifest type: %T", fetchedManifest)
+	}
+
+	fetchedJWS, err := libtrust.ParsePrettySignature(fetchedSM.Raw, "signatures")
 	if err != nil {
 		t.Fatalf("unexpected error parsing jws: %v", err)
 	}
@@ -190,7 +210,11 @@ func TestManifestStorage(t *testing.T) {
 		t.Fatalf("error getting manifest digest: %v", err)
 	}
 
-	exists, err = ms.Exists(dgst)
+	if dgst != manifestDigest {
+		t.Fatalf("unexpected digest returned by Put: %s != %s", manifestDigest, dgst)
+	}
+
+	exists, err := ms.Exists(dgst)
 	if err != nil {
 		t.Fatalf("error checking manifest existence by digest: %v", err)
 	}
@@ -218,17 +242,17 @@ func TestManifestStorage(t *testing.T) {
 	}
 
 	// Grabs the tags and check that this tagged manifest is present
-	tags, err := ms.Tags()
+	allTags, err := tags.All(ctx)
 	if err != nil {
 		t.Fatalf("unexpected error fetching tags: %v", err)
 	}
 
-	if len(tags) != 1 {
-		t.Fatalf("unexpected tags returned: %v", tags)
+	if len(allTags) != 1 {
+		t.Fatalf("unexpected tags returned: %v", allTags)
 	}
 
-	if tags[0] != env.tag {
-		t.Fatalf("unexpected tag found in tags: %v != %v", tags, []string{env.tag})
+	if allTags[0] != env.tag {
+		t.Fatalf("unexpected tag found in tags: %v != %v", allTags, []string{env.tag})
 	}
 
 	// Now, push the same manifest with a different key
@@ -256,16 +280,26 @@ func TestManifestStorage(t *testing.T) {
 		t.Fatalf("unexpected number of signatures: %d != %d", len(sigs2), 1)
 	}
 
-	if err = ms.Put(sm2); err != nil {
+	manifestDigest2, err := ms.Put(sm2)
+	if err != nil {
 		t.Fatalf("unexpected error putting manifest: %v", err)
 	}
 
-	fetched, err := ms.GetByTag(env.tag)
+	if manifestDigest2 != manifestDigest {
+		t.Fatalf("unexpected digest for re-signed manifest: %s != %s", manifestDigest2, manifestDigest)
+	}
+
+	fetched, err := getByTag(env, ms, env.tag)
 	if err != nil {
 		t.Fatalf("unexpected error fetching manifest: %v", err)
 	}
 
-	if _, err := schema1.Verify(fetched); err != nil {
+	fetchedSM, ok = fetched.(*schema1.SignedManifest)
+	if !ok {
+		t.Fatalf("unexpected manifest type: %T", fetched)
+	}
+
+	if _, err := schema1.Verify(fetchedSM); err != nil {
 		t.Fatalf("unexpected error verifying manifest: %v", err)
 	}
 
@@ -280,7 +314,7 @@ func TestManifestStorage(t *testing.T) {
 		t.Fatalf("unexpected error getting expected signatures: %v", err)
 	}
 
-	receivedJWS, err := libtrust.ParsePrettySignature(fetched.Raw, "signatures")
+	receivedJWS, err := libtrust.ParsePrettySignature(fetchedSM.Raw, "signatures")
 	if err != nil {
 		t.Fatalf("unexpected error parsing jws: %v", err)
 	}
@@ -335,7 +369,7 @@ func TestManifestStorage(t *testing.T) {
 	}
 
 	// Re-upload should restore manifest to a good state
-	err = ms.Put(sm)
+	_, err = ms.Put(sm)
 	if err != nil {
 		t.Errorf("Error re-uploading deleted manifest")
 	}
@@ -407,3 +441,292 @@ func TestLinkPathFuncs(t *testing.T) {
 	}
 
 }
+
+func TestSchema2ManifestStorage(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
+	ms, err := env.repository.Manifests(env.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := uploadRandomBlob(t, env.ctx, env.repository)
+	config.MediaType = schema2.ConfigMediaType
+	layer := distribution.Descriptor{
+		MediaType: schema2.LayerMediaType,
+		Size:      512,
+		Digest:    digest.Digest("sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b"),
+	}
+
+	m, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    config,
+		Layers:    []distribution.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building manifest: %v", err)
+	}
+
+	_, err = ms.Put(m)
+	if err == nil {
+		t.Fatalf("expected errors putting manifest with missing layer")
+	}
+
+	switch err := err.(type) {
+	case distribution.ErrManifestVerification:
+		if len(err) != 1 {
+			t.Fatalf("expected 1 verification error: %#v", err)
+		}
+
+		if err, ok := err[0].(distribution.ErrManifestBlobUnknown); !ok || err.Digest != layer.Digest {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	default:
+		t.Fatalf("unexpected error verifying manifest: %v", err)
+	}
+
+	layer = uploadRandomBlob(t, env.ctx, env.repository)
+	layer.MediaType = schema2.LayerMediaType
+	m, err = schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    config,
+		Layers:    []distribution.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building manifest: %v", err)
+	}
+
+	dgst, err := ms.Put(m)
+	if err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	mediaType, payload, err := m.Payload()
+	if err != nil {
+		t.Fatalf("unexpected error getting payload: %v", err)
+	}
+
+	if expected, err := digest.FromBytes(payload); err != nil || dgst != expected {
+		t.Fatalf("unexpected digest returned by Put: %s != %s", dgst, expected)
+	}
+
+	if err := env.repository.Tags(env.ctx).Tag(env.ctx, env.tag, distribution.Descriptor{Digest: dgst}); err != nil {
+		t.Fatalf("unexpected error tagging manifest: %v", err)
+	}
+
+	fetched, err := getByTag(env, ms, env.tag)
+	if err != nil {
+		t.Fatalf("unexpected error fetching manifest: %v", err)
+	}
+
+	if _, ok := fetched.(*schema2.DeserializedManifest); !ok {
+		t.Fatalf("unexpected manifest type: %T", fetched)
+	}
+
+	fetchedMediaType, fetchedPayload, err := fetched.Payload()
+	if err != nil {
+		t.Fatalf("unexpected error getting payload: %v", err)
+	}
+
+	if fetchedMediaType != mediaType || !bytes.Equal(fetchedPayload, payload) {
+		t.Fatalf("fetched manifest differs: %s %q != %s %q", fetchedMediaType, fetchedPayload, mediaType, payload)
+	}
+
+	if !reflect.DeepEqual(fetched.References(), m.References()) {
+		t.Fatalf("unexpected references: %v != %v", fetched.References(), m.References())
+	}
+}
+
+func TestManifestListStorage(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
+	ms, err := env.repository.Manifests(env.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := uploadRandomBlob(t, env.ctx, env.repository)
+	config.MediaType = schema2.ConfigMediaType
+	m, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    config,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building manifest: %v", err)
+	}
+
+	desc, err := distribution.DescribeManifest(m)
+	if err != nil {
+		t.Fatalf("unexpected error describing manifest: %v", err)
+	}
+
+	descriptor := manifestlist.ManifestDescriptor{
+		Descriptor: desc,
+		Platform: manifestlist.PlatformSpec{
+			Architecture: "amd64",
+			OS:           "linux",
+		},
+	}
+
+	list, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{descriptor})
+	if err != nil {
+		t.Fatalf("unexpected error building manifest list: %v", err)
+	}
+
+	_, err = ms.Put(list)
+	if verr, ok := err.(distribution.ErrManifestVerification); !ok || len(verr) != 1 {
+		t.Fatalf("expected a verification error putting a list of missing manifests: %v", err)
+	}
+
+	if _, err := ms.Put(m); err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	dgst, err := ms.Put(list)
+	if err != nil {
+		t.Fatalf("unexpected error putting manifest list: %v", err)
+	}
+
+	if err := env.repository.Tags(env.ctx).Tag(env.ctx, env.tag, distribution.Descriptor{Digest: dgst}); err != nil {
+		t.Fatalf("unexpected error tagging manifest list: %v", err)
+	}
+
+	fetched, err := getByTag(env, ms, env.tag)
+	if err != nil {
+		t.Fatalf("unexpected error fetching manifest list: %v", err)
+	}
+
+	fetchedList, ok := fetched.(*manifestlist.DeserializedManifestList)
+	if !ok {
+		t.Fatalf("unexpected manifest type: %T", fetched)
+	}
+
+	if !reflect.DeepEqual(fetchedList.Manifests, list.Manifests) {
+		t.Fatalf("unexpected manifests in list: %v != %v", fetchedList.Manifests, list.Manifests)
+	}
+
+	if err := env.repository.Tags(env.ctx).Tag(env.ctx, "other", distribution.Descriptor{Digest: config.Digest}); err == nil {
+		t.Fatalf("expected error tagging an unknown revision")
+	}
+}
+
+func TestOCIManifestStorage(t *testing.T) {
+	env := newManifestStoreTestEnv(t, "foo/bar", "thetag")
+	ms, err := env.repository.Manifests(env.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := uploadRandomBlob(t, env.ctx, env.repository)
+	config.MediaType = ocischema.ConfigMediaType
+	layer := distribution.Descriptor{
+		MediaType: ocischema.LayerMediaType,
+		Size:      512,
+		Digest:    digest.Digest("sha256:62d8908bee94c202b2d35224a221aaa2058318bfa9879fa541efaecba272331b"),
+	}
+
+	// The media type is left out, as allowed by the OCI image
+	// specification.
+	versioned := manifest.Versioned{SchemaVersion: 2}
+	m, err := ocischema.FromStruct(ocischema.Manifest{
+		Versioned: versioned,
+		Config:    ocischema.Descriptor{Descriptor: config},
+		Layers:    []ocischema.Descriptor{{Descriptor: layer}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building manifest: %v", err)
+	}
+
+	_, err = ms.Put(m)
+	if verr, ok := err.(distribution.ErrManifestVerification); !ok || len(verr) != 1 {
+		t.Fatalf("expected a verification error putting a manifest with a missing layer: %v", err)
+	}
+
+	layer = uploadRandomBlob(t, env.ctx, env.repository)
+	layer.MediaType = ocischema.LayerMediaType
+	m, err = ocischema.FromStruct(ocischema.Manifest{
+		Versioned: versioned,
+		Config:    ocischema.Descriptor{Descriptor: config},
+		Layers: []ocischema.Descriptor{
+			{
+				Descriptor:  layer,
+				Annotations: map[string]string{"org.example.layer": "base"},
+			},
+		},
+		Annotations: map[string]string{"org.opencontainers.image.ref.name": "thetag"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building manifest: %v", err)
+	}
+
+	dgst, err := ms.Put(m)
+	if err != nil {
+		t.Fatalf("unexpected error putting manifest: %v", err)
+	}
+
+	_, payload, err := m.Payload()
+	if err != nil {
+		t.Fatalf("unexpected error getting payload: %v", err)
+	}
+
+	fetched, err := ms.Get(dgst)
+	if err != nil {
+		t.Fatalf("unexpected error fetching manifest: %v", err)
+	}
+
+	fetchedManifest, ok := fetched.(*ocischema.DeserializedManifest)
+	if !ok {
+		t.Fatalf("unexpected manifest type: %T", fetched)
+	}
+
+	fetchedMediaType, fetchedPayload, err := fetched.Payload()
+	if err != nil {
+		t.Fatalf("unexpected error getting payload: %v", err)
+	}
+
+	if fetchedMediaType != ocischema.ManifestMediaType || !bytes.Equal(fetchedPayload, payload) {
+		t.Fatalf("fetched manifest differs: %s %q != %q", fetchedMediaType, fetchedPayload, payload)
+	}
+
+	if !reflect.DeepEqual(fetchedManifest.Manifest, m.Manifest) {
+		t.Fatalf("annotations were not preserved: %v != %v", fetchedManifest.Manifest, m.Manifest)
+	}
+
+	desc, err := distribution.DescribeManifest(m)
+	if err != nil {
+		t.Fatalf("unexpected error describing manifest: %v", err)
+	}
+
+	index, err := ocischema.FromImageIndex(ocischema.ImageIndex{
+		Versioned: versioned,
+		Manifests: []ocischema.ManifestDescriptor{
+			{
+				Descriptor: ocischema.Descriptor{Descriptor: desc},
+				Platform:   &ocischema.Platform{Architecture: "amd64", OS: "linux"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error building image index: %v", err)
+	}
+
+	indexDigest, err := ms.Put(index)
+	if err != nil {
+		t.Fatalf("unexpected error putting image index: %v", err)
+	}
+
+	if err := env.repository.Tags(env.ctx).Tag(env.ctx, env.tag, distribution.Descriptor{Digest: indexDigest}); err != nil {
+		t.Fatalf("unexpected error tagging image index: %v", err)
+	}
+
+	fetched, err = getByTag(env, ms, env.tag)
+	if err != nil {
+		t.Fatalf("unexpected error fetching image index: %v", err)
+	}
+
+	if _, ok := fetched.(*ocischema.DeserializedImageIndex); !ok {
+		t.Fatalf("unexpected manifest type: %T", fetched)
+	}
+
+	if !reflect.DeepEqual(fetched.References(), index.References()) {
+		t.Fatalf("unexpected references: %v != %v", fetched.References(), index.References())
+	}
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/manifest/ocischema"
+)
+
+// ocischemaManifestHandler is a manifestHandler that covers OCI image manifests.
+type ocischemaManifestHandler struct {
+	repository *repository
+	blobStore  *linkedBlobStore
+	ctx        context.Context
+}
+
+var _ manifestHandler = &ocischemaManifestHandler{}
+
+func (ms *ocischemaManifestHandler) Unmarshal(ctx context.Context, dgst digest.Digest, content []byte) (distribution.Manifest, error) {
+	context.GetLogger(ms.ctx).Debug("(*ocischemaManifestHandler).Unmarshal")
+
+	var m ocischema.DeserializedManifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (ms *ocischemaManifestHandler) Put(ctx context.Context, manifest distribution.Manifest, skipDependencyVerification bool) (digest.Digest, error) {
+	context.GetLogger(ms.ctx).Debug("(*ocischemaManifestHandler).Put")
+
+	if _, ok := manifest.(*ocischema.DeserializedManifest); !ok {
+		return "", fmt.Errorf("non-OCI manifest put to ocischemaManifestHandler: %T", manifest)
+	}
+
+	// The image configuration and the layers must all be present.
+	return storeManifest(ctx, ms.repository, ms.blobStore, manifest, skipDependencyVerification, blobExists(ctx, ms.repository))
+}
@@ -42,8 +42,14 @@ const (
 // 						data
 // 						startedat
 // 						hashstates/<algorithm>/<offset>
+// 					-> _usage
 //			-> blob/<algorithm>
 //				<split directory content addressable storage>
+//			-> gc/
+//				<journal of an active garbage collection cycle>
+//			-> catalog/
+//				<index of escaped repository names>
+//			-> namespaces/<namespace>/_usage
 //
 // The storage backend layout is broken up into a content-addressable blob
 // store and repositories. The content-addressable blob store holds most data
@@ -74,6 +80,7 @@ const (
 //
 //	Manifests:
 //
+// 	manifestRevisionsPathSpec:     <root>/v2/repositories/<name>/_manifests/revisions/
 // 	manifestRevisionPathSpec:      <root>/v2/repositories/<name>/_manifests/revisions/<algorithm>/<hex digest>/
 // 	manifestRevisionLinkPathSpec:  <root>/v2/repositories/<name>/_manifests/revisions/<algorithm>/<hex digest>/link
 // 	manifestSignaturesPathSpec:    <root>/v2/repositories/<name>/_manifests/revisions/<algorithm>/<hex digest>/signatures/
@@ -87,6 +94,9 @@ const (
 // 	manifestTagIndexPathSpec:              <root>/v2/repositories/<name>/_manifests/tags/<tag>/index/
 // 	manifestTagIndexEntryPathSpec:         <root>/v2/repositories/<name>/_manifests/tags/<tag>/index/<algorithm>/<hex digest>/
 // 	manifestTagIndexEntryLinkPathSpec:     <root>/v2/repositories/<name>/_manifests/tags/<tag>/index/<algorithm>/<hex digest>/link
+// 	tagListPathSpec:                       <root>/v2/repositories/<name>/_manifests/taglist/tags/
+// 	tagListEntryPathSpec:                  <root>/v2/repositories/<name>/_manifests/taglist/tags/<tag>
+// 	tagListCompletePathSpec:               <root>/v2/repositories/<name>/_manifests/taglist/complete
 //
 // 	Blobs:
 //
@@ -100,10 +110,28 @@ const (
 //
 //	Blob Store:
 //
+// 	blobsPathSpec:                  <root>/v2/blobs/
 // 	blobPathSpec:                   <root>/v2/blobs/<algorithm>/<first two hex bytes of digest>/<hex digest>
 // 	blobDataPathSpec:               <root>/v2/blobs/<algorithm>/<first two hex bytes of digest>/<hex digest>/data
 // 	blobMediaTypePathSpec:               <root>/v2/blobs/<algorithm>/<first two hex bytes of digest>/<hex digest>/data
 //
+//	Garbage Collection:
+//
+// 	gcCurrentCyclePathSpec:         <root>/v2/gc/current
+// 	gcCyclePathSpec:                <root>/v2/gc/<cycle>/
+// 	gcJournalEntryPathSpec:         <root>/v2/gc/<cycle>/journal/<algorithm>/<hex digest>
+// 	gcTombstonePathSpec:            <root>/v2/gc/<cycle>/swept/<algorithm>/<hex digest>
+//
+//	Catalog Index:
+//
+// 	catalogIndexPathSpec:           <root>/v2/catalog/
+// 	catalogIndexEntryPathSpec:      <root>/v2/catalog/<escaped name>
+//
+//	Usage:
+//
+// 	repositoryUsagePathSpec:        <root>/v2/repositories/<name>/_usage
+// 	namespaceUsagePathSpec:         <root>/v2/namespaces/<namespace>/_usage
+//
 // For more information on the semantic meaning of each path and their
 // contents, please see the path spec documentation.
 func pathFor(spec pathSpec) (string, error) {
@@ -125,6 +153,8 @@ func pathFor(spec pathSpec) (string, error) {
 
 	switch v := spec.(type) {
 
+	case manifestRevisionsPathSpec:
+		return path.Join(append(repoPrefix, v.name, "_manifests", "revisions")...), nil
 	case manifestRevisionPathSpec:
 		components, err := digestPathComponents(v.revision, false)
 		if err != nil {
@@ -172,6 +202,20 @@ func pathFor(spec pathSpec) (string, error) {
 		return path.Join(root, path.Join(append(signatureComponents, "link")...)), nil
 	case manifestTagsPathSpec:
 		return path.Join(append(repoPrefix, v.name, "_manifests", "tags")...), nil
+	case tagListPathSpec:
+		return path.Join(append(repoPrefix, v.name, "_manifests", "taglist", "tags")...), nil
+	case tagListEntryPathSpec:
+		root, err := pathFor(tagListPathSpec{
+			name: v.name,
+		})
+
+		if err != nil {
+			return "", err
+		}
+
+		return path.Join(root, v.tag), nil
+	case tagListCompletePathSpec:
+		return path.Join(append(repoPrefix, v.name, "_manifests", "taglist", "complete")...), nil
 	case manifestTagPathSpec:
 		root, err := pathFor(manifestTagsPathSpec{
 			name: v.name,
@@ -246,6 +290,9 @@ func pathFor(spec pathSpec) (string, error) {
 		blobLinkPathComponents := append(repoPrefix, v.name, "_layers")
 
 		return path.Join(path.Join(append(blobLinkPathComponents, components...)...), "link"), nil
+	case blobsPathSpec:
+		blobsPathPrefix := append(rootPrefix, "blobs")
+		return path.Join(blobsPathPrefix...), nil
 	case blobDataPathSpec:
 		components, err := digestPathComponents(v.digest, true)
 		if err != nil {
@@ -268,6 +315,40 @@ func pathFor(spec pathSpec) (string, error) {
 		return path.Join(append(repoPrefix, v.name, "_uploads", v.id, "hashstates", string(v.alg), offset)...), nil
 	case repositoriesRootPathSpec:
 		return path.Join(repoPrefix...), nil
+	case gcCurrentCyclePathSpec:
+		return path.Join(append(rootPrefix, "gc", "current")...), nil
+	case gcCyclePathSpec:
+		return path.Join(append(rootPrefix, "gc", v.cycle)...), nil
+	case gcJournalEntryPathSpec:
+		components, err := digestPathComponents(v.digest, false)
+		if err != nil {
+			return "", err
+		}
+
+		return path.Join(append(append(rootPrefix, "gc", v.cycle, "journal"), components...)...), nil
+	case gcTombstonePathSpec:
+		components, err := digestPathComponents(v.digest, false)
+		if err != nil {
+			return "", err
+		}
+
+		return path.Join(append(append(rootPrefix, "gc", v.cycle, "swept"), components...)...), nil
+	case catalogIndexPathSpec:
+		return path.Join(append(rootPrefix, "catalog")...), nil
+	case catalogIndexEntryPathSpec:
+		if v.name == "" {
+			return "", fmt.Errorf("empty repository name in catalog index entry")
+		}
+
+		return path.Join(append(rootPrefix, "catalog", catalogIndexEntryName(v.name))...), nil
+	case repositoryUsagePathSpec:
+		return path.Join(append(repoPrefix, v.name, "_usage")...), nil
+	case namespaceUsagePathSpec:
+		if v.namespace == "" {
+			return "", fmt.Errorf("empty namespace in usage path")
+		}
+
+		return path.Join(append(rootPrefix, "namespaces", v.namespace, "_usage")...), nil
 	default:
 		// TODO(sday): This is an internal error. Ensure it doesn't escape (panic?).
 		return "", fmt.Errorf("unknown path spec: %#v", v)
@@ -281,6 +362,14 @@ type pathSpec interface {
 	pathSpec()
 }
 
+// manifestRevisionsPathSpec describes the directory path for all manifest
+// revisions of a repository.
+type manifestRevisionsPathSpec struct {
+	name string
+}
+
+func (manifestRevisionsPathSpec) pathSpec() {}
+
 // manifestRevisionPathSpec describes the components of the directory path for
 // a manifest revision.
 type manifestRevisionPathSpec struct {
@@ -339,6 +428,33 @@ type manifestTagPathSpec struct {
 
 func (manifestTagPathSpec) pathSpec() {}
 
+// tagListPathSpec describes the directory listing the tags of a repository
+// as files named after the tags. Unlike the tag directories, the files are
+// listed in the order of the tags by every backend, so that pages of tags can
+// be listed without listing them all.
+type tagListPathSpec struct {
+	name string
+}
+
+func (tagListPathSpec) pathSpec() {}
+
+// tagListCompletePathSpec describes the file marking that the tag list of a
+// repository lists all of its tags.
+type tagListCompletePathSpec struct {
+	name string
+}
+
+func (tagListCompletePathSpec) pathSpec() {}
+
+// tagListEntryPathSpec describes the file listing a tag in the tag list of a
+// repository.
+type tagListEntryPathSpec struct {
+	name string
+	tag  string
+}
+
+func (tagListEntryPathSpec) pathSpec() {}
+
 // manifestTagCurrentPathSpec describes the link to the current revision for a
 // given tag.
 type manifestTagCurrentPathSpec struct {
@@ -412,6 +528,12 @@ var blobAlgorithmReplacer = strings.NewReplacer(
 
 // func (blobPathSpec) pathSpec() {}
 
+// blobsPathSpec contains the path for the root of the registry global blob
+// store.
+type blobsPathSpec struct{}
+
+func (blobsPathSpec) pathSpec() {}
+
 // blobDataPathSpec contains the path for the registry global blob store. For
 // now, this contains layer data, exclusively.
 type blobDataPathSpec struct {
@@ -463,6 +585,69 @@ type repositoriesRootPathSpec struct {
 
 func (repositoriesRootPathSpec) pathSpec() {}
 
+// gcCurrentCyclePathSpec describes the file naming the active garbage
+// collection cycle. If it is missing, no collection is running.
+type gcCurrentCyclePathSpec struct{}
+
+func (gcCurrentCyclePathSpec) pathSpec() {}
+
+// gcCyclePathSpec describes the directory holding the journal and tombstones
+// of a garbage collection cycle.
+type gcCyclePathSpec struct {
+	cycle string
+}
+
+func (gcCyclePathSpec) pathSpec() {}
+
+// gcJournalEntryPathSpec describes the file recording that a digest was
+// referenced by a writer during a garbage collection cycle.
+type gcJournalEntryPathSpec struct {
+	cycle  string
+	digest digest.Digest
+}
+
+func (gcJournalEntryPathSpec) pathSpec() {}
+
+// gcTombstonePathSpec describes the file recording that a digest is about to
+// be swept during a garbage collection cycle.
+type gcTombstonePathSpec struct {
+	cycle  string
+	digest digest.Digest
+}
+
+func (gcTombstonePathSpec) pathSpec() {}
+
+// catalogIndexPathSpec describes the root directory of the catalog index.
+type catalogIndexPathSpec struct{}
+
+func (catalogIndexPathSpec) pathSpec() {}
+
+// catalogIndexEntryPathSpec describes the file marking that a repository
+// exists. Names are escaped, as repository names may contain slashes, in a
+// way preserving their lexical order within the index.
+type catalogIndexEntryPathSpec struct {
+	name string
+}
+
+func (catalogIndexEntryPathSpec) pathSpec() {}
+
+// repositoryUsagePathSpec describes the file holding the number of bytes
+// linked into a repository.
+type repositoryUsagePathSpec struct {
+	name string
+}
+
+func (repositoryUsagePathSpec) pathSpec() {}
+
+// namespaceUsagePathSpec describes the file holding the number of bytes
+// linked into all repositories below a namespace, such as "a" and "a/b" for
+// the repository "a/b/c".
+type namespaceUsagePathSpec struct {
+	namespace string
+}
+
+func (namespaceUsagePathSpec) pathSpec() {}
+
 // digestPathComponents provides a consistent path breakdown for a given
 // digest. For a generic digest, it will be as follows:
 //
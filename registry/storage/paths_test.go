@@ -12,6 +12,12 @@ func TestPathMapper(t *testing.T) {
 		expected string
 		err      error
 	}{
+		{
+			spec: manifestRevisionsPathSpec{
+				name: "foo/bar",
+			},
+			expected: "/docker/registry/v2/repositories/foo/bar/_manifests/revisions",
+		},
 		{
 			spec: manifestRevisionPathSpec{
 				name:     "foo/bar",
@@ -91,6 +97,10 @@ func TestPathMapper(t *testing.T) {
 			},
 			expected: "/docker/registry/v2/repositories/foo/bar/_layers/tarsum/v1/test/abcdef/link",
 		},
+		{
+			spec:     blobsPathSpec{},
+			expected: "/docker/registry/v2/blobs",
+		},
 		{
 			spec: blobDataPathSpec{
 				digest: digest.Digest("tarsum.dev+sha512:abcdefabcdefabcdef908909909"),
@@ -118,6 +128,24 @@ func TestPathMapper(t *testing.T) {
 			},
 			expected: "/docker/registry/v2/repositories/foo/bar/_uploads/asdf-asdf-asdf-adsf/startedat",
 		},
+		{
+			spec:     gcCurrentCyclePathSpec{},
+			expected: "/docker/registry/v2/gc/current",
+		},
+		{
+			spec: gcJournalEntryPathSpec{
+				cycle:  "asdf-asdf-asdf-adsf",
+				digest: "sha256:abcdef0123456789",
+			},
+			expected: "/docker/registry/v2/gc/asdf-asdf-asdf-adsf/journal/sha256/abcdef0123456789",
+		},
+		{
+			spec: gcTombstonePathSpec{
+				cycle:  "asdf-asdf-asdf-adsf",
+				digest: "sha256:abcdef0123456789",
+			},
+			expected: "/docker/registry/v2/gc/asdf-asdf-asdf-adsf/swept/sha256/abcdef0123456789",
+		},
 	} {
 		p, err := pathFor(testcase.spec)
 		if err != nil {
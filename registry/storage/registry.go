@@ -14,9 +14,11 @@ type registry struct {
 	blobStore                   *blobStore
 	blobServer                  *blobServer
 	statter                     *blobStatter // global statter service.
+	usage                       *usageStore  // tracks usage, if enabled
 	blobDescriptorCacheProvider cache.BlobDescriptorCacheProvider
 	deleteEnabled               bool
 	resumableDigestEnabled      bool
+	catalogIndexEnabled         bool
 }
 
 // RegistryOption is the type used for functional options for NewRegistry.
@@ -36,6 +38,23 @@ func EnableDelete(registry *registry) error {
 	return nil
 }
 
+// EnableCatalogIndex is a functional option for NewRegistry. It makes the
+// catalog page through the catalog index rather than walking all
+// repositories. The index is only maintained while enabled, so
+// RebuildCatalogIndex must have been run once on storage written without it.
+func EnableCatalogIndex(registry *registry) error {
+	registry.catalogIndexEnabled = true
+	return nil
+}
+
+// EnableUsage is a functional option for NewRegistry. It tracks the storage
+// used by repositories and their namespaces as content is linked and
+// deleted, as needed to enforce quotas.
+func EnableUsage(registry *registry) error {
+	registry.usage = newUsageStore(registry.blobStore.driver)
+	return nil
+}
+
 // DisableDigestResumption is a functional option for NewRegistry. It should be
 // used if the registry is acting as a caching proxy.
 func DisableDigestResumption(registry *registry) error {
@@ -156,33 +175,48 @@ func (repo *repository) Manifests(ctx context.Context, options ...distribution.M
 		blobLinkPath,
 	}
 
+	blobStore := &linkedBlobStore{
+		ctx:                 ctx,
+		blobStore:           repo.blobStore,
+		repository:          repo,
+		usage:               repo.registry.usage,
+		deleteEnabled:       repo.registry.deleteEnabled,
+		catalogIndexEnabled: repo.registry.catalogIndexEnabled,
+		blobAccessController: &linkedBlobStatter{
+			blobStore:   repo.blobStore,
+			repository:  repo,
+			linkPathFns: manifestLinkPathFns,
+		},
+
+		// TODO(stevvooe): linkPath limits this blob store to only
+		// manifests. This instance cannot be used for blob checks.
+		linkPathFns:            manifestLinkPathFns,
+		resumableDigestEnabled: repo.resumableDigestEnabled,
+	}
+
 	ms := &manifestStore{
 		ctx:        ctx,
 		repository: repo,
-		revisionStore: &revisionStore{
+		blobStore:  blobStore,
+		schema1Handler: &signedManifestHandler{
 			ctx:        ctx,
 			repository: repo,
-			blobStore: &linkedBlobStore{
-				ctx:           ctx,
-				blobStore:     repo.blobStore,
-				repository:    repo,
-				deleteEnabled: repo.registry.deleteEnabled,
-				blobAccessController: &linkedBlobStatter{
-					blobStore:   repo.blobStore,
-					repository:  repo,
-					linkPathFns: manifestLinkPathFns,
-				},
-
-				// TODO(stevvooe): linkPath limits this blob store to only
-				// manifests. This instance cannot be used for blob checks.
-				linkPathFns:            manifestLinkPathFns,
-				resumableDigestEnabled: repo.resumableDigestEnabled,
-			},
+			blobStore:  blobStore,
 		},
-		tagStore: &tagStore{
+		schema2Handler: &schema2ManifestHandler{
 			ctx:        ctx,
 			repository: repo,
-			blobStore:  repo.registry.blobStore,
+			blobStore:  blobStore,
+		},
+		ocischemaHandler: &ocischemaManifestHandler{
+			ctx:        ctx,
+			repository: repo,
+			blobStore:  blobStore,
+		},
+		manifestListHandler: &manifestListHandler{
+			ctx:        ctx,
+			repository: repo,
+			blobStore:  blobStore,
 		},
 	}
 
@@ -197,6 +231,15 @@ func (repo *repository) Manifests(ctx context.Context, options ...distribution.M
 	return ms, nil
 }
 
+// Tags returns an instance of the TagService, which manages the tags of the
+// repository.
+func (repo *repository) Tags(ctx context.Context) distribution.TagService {
+	return &tagStore{
+		repository: repo,
+		blobStore:  repo.registry.blobStore,
+	}
+}
+
 // Blobs returns an instance of the BlobStore. Instantiation is cheap and
 // may be context sensitive in the future. The instance should be used similar
 // to a request local.
@@ -211,11 +254,13 @@ func (repo *repository) Blobs(ctx context.Context) distribution.BlobStore {
 		statter = cache.NewCachedBlobStatter(repo.descriptorCache, statter)
 	}
 
-	return &linkedBlobStore{
+	blobStore := &linkedBlobStore{
 		blobStore:            repo.blobStore,
 		blobServer:           repo.blobServer,
 		blobAccessController: statter,
 		repository:           repo,
+		registry:             repo.registry,
+		usage:                repo.registry.usage,
 		ctx:                  ctx,
 
 		// TODO(stevvooe): linkPath limits this blob store to only layers.
@@ -223,6 +268,12 @@ func (repo *repository) Blobs(ctx context.Context) distribution.BlobStore {
 		linkPathFns:            []linkPathFunc{blobLinkPath},
 		deleteEnabled:          repo.registry.deleteEnabled,
 		resumableDigestEnabled: repo.resumableDigestEnabled,
+		catalogIndexEnabled:    repo.registry.catalogIndexEnabled,
+	}
+
+	return &tracedBlobStore{
+		BlobStore: blobStore,
+		name:      repo.Name(),
 	}
 }
 
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"path"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/storage/driver"
+)
+
+// The tag list of a repository keeps a file per tag, named after the tag,
+// next to the tag directories. Unlike the tag directories, which some
+// backends do not list in lexical order, the files can be listed a page at a
+// time. Entries are added and removed along with the tags. Repositories
+// tagged by registries predating the tag lists have their list built the
+// first time their tags are listed, after which the list is marked complete.
+
+// indexTag adds the tag to the tag list of the named repository, if it is not
+// there yet.
+func indexTag(ctx context.Context, storageDriver driver.StorageDriver, name, tag string) error {
+	entryPath, err := pathFor(tagListEntryPathSpec{name: name, tag: tag})
+	if err != nil {
+		return err
+	}
+
+	indexed, err := exists(ctx, storageDriver, entryPath)
+	if err != nil || indexed {
+		return err
+	}
+
+	return storageDriver.PutContent(ctx, entryPath, []byte(tag))
+}
+
+// unindexTag removes the tag from the tag list of the named repository.
+func unindexTag(ctx context.Context, storageDriver driver.StorageDriver, name, tag string) error {
+	entryPath, err := pathFor(tagListEntryPathSpec{name: name, tag: tag})
+	if err != nil {
+		return err
+	}
+
+	if err := storageDriver.Delete(ctx, entryPath); err != nil {
+		switch err.(type) {
+		case driver.PathNotFoundError:
+			return nil
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listTagList fills tags with the lexically sorted tags of the named
+// repository following last, as recorded in its tag list, which is built
+// first if it isn't complete yet. Only the requested page is listed from
+// backends supporting it. io.EOF is returned if there are no more tags.
+func listTagList(ctx context.Context, storageDriver driver.StorageDriver, name string, tags []string, last string) (int, error) {
+	if err := ensureTagList(ctx, storageDriver, name); err != nil {
+		return 0, err
+	}
+
+	listPath, err := pathFor(tagListPathSpec{name: name})
+	if err != nil {
+		return 0, err
+	}
+
+	var after string
+	if last != "" {
+		after, err = pathFor(tagListEntryPathSpec{name: name, tag: last})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := listIndexPage(ctx, storageDriver, listPath, after, tags, func(entryPath string) (string, error) {
+		return path.Base(entryPath), nil
+	})
+	if _, ok := err.(driver.PathNotFoundError); ok {
+		return 0, distribution.ErrRepositoryUnknown{Name: name}
+	}
+
+	return n, err
+}
+
+// ensureTagList builds the tag list of the named repository unless it is
+// marked complete. Repositories without tags are unknown, and nothing is
+// written for them.
+func ensureTagList(ctx context.Context, storageDriver driver.StorageDriver, name string) error {
+	completePath, err := pathFor(tagListCompletePathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	complete, err := exists(ctx, storageDriver, completePath)
+	if err != nil || complete {
+		return err
+	}
+
+	tagsPath, err := pathFor(manifestTagsPathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	tagged, err := exists(ctx, storageDriver, tagsPath)
+	if err != nil {
+		return err
+	} else if !tagged {
+		return distribution.ErrRepositoryUnknown{Name: name}
+	}
+
+	context.GetLogger(ctx).Infof("building the tag list of %s", name)
+	return rebuildTagList(ctx, storageDriver, name)
+}
+
+// rebuildTagList makes the tag list of the named repository match its tags,
+// adding missing tags and removing stale entries, and marks it complete if
+// the repository has tags.
+func rebuildTagList(ctx context.Context, storageDriver driver.StorageDriver, name string) error {
+	tagsPath, err := pathFor(manifestTagsPathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]struct{})
+	tagPaths, err := listOrEmpty(ctx, storageDriver, tagsPath)
+	if err != nil {
+		return err
+	}
+
+	for _, tagPath := range tagPaths {
+		tag := path.Base(tagPath)
+		found[tag] = struct{}{}
+		if err := indexTag(ctx, storageDriver, name, tag); err != nil {
+			return err
+		}
+	}
+
+	listPath, err := pathFor(tagListPathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	entries, err := listOrEmpty(ctx, storageDriver, listPath)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		tag := path.Base(entry)
+		if _, ok := found[tag]; ok {
+			continue
+		}
+
+		// Tags created since the listing are indexed by their writers, so
+		// only remove entries of tags which are really gone.
+		tagPath, err := pathFor(manifestTagPathSpec{name: name, tag: tag})
+		if err != nil {
+			return err
+		}
+
+		live, err := exists(ctx, storageDriver, tagPath)
+		if err != nil {
+			return err
+		} else if live {
+			continue
+		}
+
+		context.GetLogger(ctx).Infof("removing stale tag list entry for %s:%s", name, tag)
+		if err := unindexTag(ctx, storageDriver, name, tag); err != nil {
+			return err
+		}
+	}
+
+	if len(tagPaths) == 0 {
+		return nil
+	}
+
+	completePath, err := pathFor(tagListCompletePathSpec{name: name})
+	if err != nil {
+		return err
+	}
+
+	return storageDriver.PutContent(ctx, completePath, []byte(time.Now().UTC().Format(time.RFC3339)))
+}
+
+// listOrEmpty lists the children of dirPath, a missing directory being
+// empty.
+func listOrEmpty(ctx context.Context, storageDriver driver.StorageDriver, dirPath string) ([]string, error) {
+	children, err := storageDriver.List(ctx, dirPath)
+	if err != nil {
+		switch err.(type) {
+		case driver.PathNotFoundError:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return children, nil
+}
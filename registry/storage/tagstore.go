@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"path"
 
 	"github.com/docker/distribution"
@@ -9,15 +10,16 @@ import (
 	storagedriver "github.com/docker/distribution/registry/storage/driver"
 )
 
+var _ distribution.TagService = &tagStore{}
+
 // tagStore provides methods to manage manifest tags in a backend storage driver.
 type tagStore struct {
 	repository *repository
 	blobStore  *blobStore
-	ctx        context.Context
 }
 
-// tags lists the manifest tags for the specified repository.
-func (ts *tagStore) tags() ([]string, error) {
+// All lists the manifest tags for the specified repository.
+func (ts *tagStore) All(ctx context.Context) ([]string, error) {
 	p, err := pathFor(manifestTagPathSpec{
 		name: ts.repository.Name(),
 	})
@@ -27,7 +29,7 @@ func (ts *tagStore) tags() ([]string, error) {
 	}
 
 	var tags []string
-	entries, err := ts.blobStore.driver.List(ts.ctx, p)
+	entries, err := ts.blobStore.driver.List(ctx, p)
 	if err != nil {
 		switch err := err.(type) {
 		case storagedriver.PathNotFoundError:
@@ -46,28 +48,40 @@ func (ts *tagStore) tags() ([]string, error) {
 	return tags, nil
 }
 
-// exists returns true if the specified manifest tag exists in the repository.
-func (ts *tagStore) exists(tag string) (bool, error) {
-	tagPath, err := pathFor(manifestTagCurrentPathSpec{
-		name: ts.repository.Name(),
-		tag:  tag,
-	})
+// List fills tags with the lexically sorted tags of the repository following
+// last. Pages are listed from the tag list of the repository, which backends
+// can list a page at a time.
+func (ts *tagStore) List(ctx context.Context, tags []string, last string) (n int, err error) {
+	if len(tags) == 0 {
+		return 0, errors.New("no space in slice")
+	}
 
+	return listTagList(ctx, ts.blobStore.driver, ts.repository.Name(), tags, last)
+}
+
+// Tag tags the digest with the given tag, updating the the store to point at
+// the current tag. The digest must point to a manifest stored in the
+// repository.
+func (ts *tagStore) Tag(ctx context.Context, tag string, desc distribution.Descriptor) error {
+	context.GetLogger(ctx).Debug("(*tagStore).Tag")
+
+	manifests, err := ts.repository.Manifests(ctx)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	exists, err := exists(ts.ctx, ts.blobStore.driver, tagPath)
+	exists, err := manifests.Exists(desc.Digest)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	return exists, nil
-}
+	if !exists {
+		return distribution.ErrManifestUnknownRevision{
+			Name:     ts.repository.Name(),
+			Revision: desc.Digest,
+		}
+	}
 
-// tag tags the digest with the given tag, updating the the store to point at
-// the current tag. The digest must point to a manifest.
-func (ts *tagStore) tag(tag string, revision digest.Digest) error {
 	currentPath, err := pathFor(manifestTagCurrentPathSpec{
 		name: ts.repository.Name(),
 		tag:  tag,
@@ -77,43 +91,54 @@ func (ts *tagStore) tag(tag string, revision digest.Digest) error {
 		return err
 	}
 
-	nbs := ts.linkedBlobStore(ts.ctx, tag)
+	nbs := ts.linkedBlobStore(ctx, tag)
 	// Link into the index
-	if err := nbs.linkBlob(ts.ctx, distribution.Descriptor{Digest: revision}); err != nil {
+	if err := nbs.linkBlob(ctx, distribution.Descriptor{Digest: desc.Digest}); err != nil {
 		return err
 	}
 
 	// Overwrite the current link
-	return ts.blobStore.link(ts.ctx, currentPath, revision)
+	if err := ts.blobStore.link(ctx, currentPath, desc.Digest); err != nil {
+		return err
+	}
+
+	return indexTag(ctx, ts.blobStore.driver, ts.repository.Name(), tag)
 }
 
-// resolve the current revision for name and tag.
-func (ts *tagStore) resolve(tag string) (digest.Digest, error) {
+// Get resolves the current revision for name and tag.
+func (ts *tagStore) Get(ctx context.Context, tag string) (distribution.Descriptor, error) {
 	currentPath, err := pathFor(manifestTagCurrentPathSpec{
 		name: ts.repository.Name(),
 		tag:  tag,
 	})
 
 	if err != nil {
-		return "", err
+		return distribution.Descriptor{}, err
 	}
 
-	revision, err := ts.blobStore.readlink(ts.ctx, currentPath)
+	revision, err := ts.blobStore.readlink(ctx, currentPath)
 	if err != nil {
 		switch err.(type) {
 		case storagedriver.PathNotFoundError:
-			return "", distribution.ErrManifestUnknown{Name: ts.repository.Name(), Tag: tag}
+			return distribution.Descriptor{}, distribution.ErrTagUnknown{Tag: tag}
 		}
 
-		return "", err
+		return distribution.Descriptor{}, err
 	}
 
-	return revision, nil
+	return distribution.Descriptor{Digest: revision}, nil
 }
 
-// delete removes the tag from repository, including the history of all
-// revisions that have the specified tag.
-func (ts *tagStore) delete(tag string) error {
+// Untag removes the tag from repository, including the history of all
+// revisions that have the specified tag. The manifests themselves are kept.
+// Untagging is only allowed when deletes are enabled.
+func (ts *tagStore) Untag(ctx context.Context, tag string) error {
+	context.GetLogger(ctx).Debug("(*tagStore).Untag")
+
+	if !ts.repository.registry.deleteEnabled {
+		return distribution.ErrUnsupported
+	}
+
 	tagPath, err := pathFor(manifestTagPathSpec{
 		name: ts.repository.Name(),
 		tag:  tag,
@@ -123,7 +148,56 @@ func (ts *tagStore) delete(tag string) error {
 		return err
 	}
 
-	return ts.blobStore.driver.Delete(ts.ctx, tagPath)
+	// The tag leaves the tag list first, so that the list never holds a
+	// tag which is gone.
+	if err := unindexTag(ctx, ts.blobStore.driver, ts.repository.Name(), tag); err != nil {
+		return err
+	}
+
+	if err := ts.blobStore.driver.Delete(ctx, tagPath); err != nil {
+		switch err.(type) {
+		case storagedriver.PathNotFoundError:
+			return distribution.ErrTagUnknown{Tag: tag}
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Lookup returns the tags that currently point at the digest of desc.
+func (ts *tagStore) Lookup(ctx context.Context, desc distribution.Descriptor) ([]string, error) {
+	allTags, err := ts.All(ctx)
+	switch err.(type) {
+	case distribution.ErrRepositoryUnknown:
+		// The repository has no tags yet.
+		return nil, nil
+	case nil:
+		break
+	default:
+		return nil, err
+	}
+
+	var tags []string
+	for _, tag := range allTags {
+		tagDesc, err := ts.Get(ctx, tag)
+		if err != nil {
+			switch err.(type) {
+			case distribution.ErrTagUnknown:
+				// The tag was removed concurrently.
+				continue
+			default:
+				return nil, err
+			}
+		}
+
+		if tagDesc.Digest == desc.Digest {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, nil
 }
 
 // linkedBlobStore returns the linkedBlobStore for the named tag, allowing one
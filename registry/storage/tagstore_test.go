@@ -0,0 +1,385 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/storage/driver/filesystem"
+	"github.com/docker/distribution/registry/storage/driver/inmemory"
+)
+
+func TestTagStore(t *testing.T) {
+	env := newGCTestEnv(t)
+	ctx := env.ctx
+	repo := env.repository(t, "foo/tags")
+	tags := repo.Tags(ctx)
+
+	if _, err := tags.All(ctx); true {
+		switch err.(type) {
+		case distribution.ErrRepositoryUnknown:
+			break
+		default:
+			t.Fatalf("expected repository unknown error: %#v", err)
+		}
+	}
+
+	if _, err := tags.Get(ctx, "latest"); true {
+		switch err.(type) {
+		case distribution.ErrTagUnknown:
+			break
+		default:
+			t.Fatalf("expected tag unknown error: %#v", err)
+		}
+	}
+
+	// Tagging a digest that is not a manifest in the repository fails.
+	unknown := digest.Digest("sha256:ad8b7ea8bca0e64cd36f0ab8c7f0c93fd3d51b1d2e9b3b5b8c5a4e2e9b3e5f30")
+	if err := tags.Tag(ctx, "latest", distribution.Descriptor{Digest: unknown}); true {
+		switch err.(type) {
+		case distribution.ErrManifestUnknownRevision:
+			break
+		default:
+			t.Fatalf("expected manifest unknown revision error: %#v", err)
+		}
+	}
+
+	layer := uploadRandomBlob(t, ctx, repo)
+	first := putManifest(t, ctx, repo, "latest", layer.Digest)
+	second := putManifest(t, ctx, repo, "pr-1234", layer.Digest)
+
+	if err := tags.Tag(ctx, "v1", distribution.Descriptor{Digest: first}); err != nil {
+		t.Fatalf("unexpected error tagging manifest: %v", err)
+	}
+
+	desc, err := tags.Get(ctx, "v1")
+	if err != nil {
+		t.Fatalf("unexpected error resolving tag: %v", err)
+	}
+
+	if desc.Digest != first {
+		t.Fatalf("unexpected digest for tag: %s != %s", desc.Digest, first)
+	}
+
+	all, err := tags.All(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error listing tags: %v", err)
+	}
+	sort.Strings(all)
+
+	if expected := []string{"latest", "pr-1234", "v1"}; !reflect.DeepEqual(all, expected) {
+		t.Fatalf("unexpected tags: %v != %v", all, expected)
+	}
+
+	found, err := tags.Lookup(ctx, distribution.Descriptor{Digest: first})
+	if err != nil {
+		t.Fatalf("unexpected error looking up tags: %v", err)
+	}
+	sort.Strings(found)
+
+	if expected := []string{"latest", "v1"}; !reflect.DeepEqual(found, expected) {
+		t.Fatalf("unexpected tags for %s: %v != %v", first, found, expected)
+	}
+
+	// Retagging moves the tag to the new digest.
+	if err := tags.Tag(ctx, "v1", distribution.Descriptor{Digest: second}); err != nil {
+		t.Fatalf("unexpected error tagging manifest: %v", err)
+	}
+
+	found, err = tags.Lookup(ctx, distribution.Descriptor{Digest: first})
+	if err != nil {
+		t.Fatalf("unexpected error looking up tags: %v", err)
+	}
+
+	if expected := []string{"latest"}; !reflect.DeepEqual(found, expected) {
+		t.Fatalf("unexpected tags for %s: %v != %v", first, found, expected)
+	}
+
+	if err := tags.Untag(ctx, "pr-1234"); err != nil {
+		t.Fatalf("unexpected error untagging: %v", err)
+	}
+
+	if _, err := tags.Get(ctx, "pr-1234"); err == nil {
+		t.Fatalf("expected error resolving removed tag")
+	}
+
+	if err := tags.Untag(ctx, "pr-1234"); true {
+		switch err.(type) {
+		case distribution.ErrTagUnknown:
+			break
+		default:
+			t.Fatalf("expected tag unknown error: %#v", err)
+		}
+	}
+
+	// Untagging leaves the manifest in place.
+	ms, err := repo.Manifests(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error getting manifest service: %v", err)
+	}
+
+	exists, err := ms.Exists(second)
+	if err != nil {
+		t.Fatalf("unexpected error checking manifest existence: %v", err)
+	}
+
+	if !exists {
+		t.Fatalf("manifest %s should still exist", second)
+	}
+
+	found, err = tags.Lookup(ctx, distribution.Descriptor{Digest: second})
+	if err != nil {
+		t.Fatalf("unexpected error looking up tags: %v", err)
+	}
+
+	if expected := []string{"v1"}; !reflect.DeepEqual(found, expected) {
+		t.Fatalf("unexpected tags for %s: %v != %v", second, found, expected)
+	}
+}
+
+func TestTagStoreUntagDisabled(t *testing.T) {
+	ctx := context.Background()
+	registry, err := NewRegistry(ctx, inmemory.New())
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	repo, err := registry.Repository(ctx, "foo/tags")
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	layer := uploadRandomBlob(t, ctx, repo)
+	putManifest(t, ctx, repo, "latest", layer.Digest)
+
+	if err := repo.Tags(ctx).Untag(ctx, "latest"); err != distribution.ErrUnsupported {
+		t.Fatalf("expected unsupported error untagging with delete disabled: %v", err)
+	}
+
+	if _, err := repo.Tags(ctx).Get(ctx, "latest"); err != nil {
+		t.Fatalf("unexpected error resolving tag: %v", err)
+	}
+}
+
+func TestTagStoreList(t *testing.T) {
+	env := newGCTestEnv(t)
+	ctx := env.ctx
+	repo := env.repository(t, "foo/paginated")
+	tags := repo.Tags(ctx)
+
+	if _, err := tags.List(ctx, make([]string, 2), ""); true {
+		switch err.(type) {
+		case distribution.ErrRepositoryUnknown:
+			break
+		default:
+			t.Fatalf("expected repository unknown error: %#v", err)
+		}
+	}
+
+	layer := uploadRandomBlob(t, ctx, repo)
+	for _, tag := range []string{"c", "a", "e", "b", "d"} {
+		putManifest(t, ctx, repo, tag, layer.Digest)
+	}
+
+	var pages [][]string
+	last := ""
+	for {
+		page := make([]string, 2)
+		n, err := tags.List(ctx, page, last)
+		if err != nil && err != io.EOF {
+			t.Fatalf("unexpected error listing tags: %v", err)
+		}
+
+		pages = append(pages, page[:n])
+		if err == io.EOF {
+			break
+		}
+		last = page[n-1]
+	}
+
+	if expected := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}; !reflect.DeepEqual(pages, expected) {
+		t.Fatalf("unexpected tag pages: %v != %v", pages, expected)
+	}
+
+	// A page that exactly fits the remaining tags ends the listing.
+	page := make([]string, 2)
+	n, err := tags.List(ctx, page, "c")
+	if err != io.EOF {
+		t.Fatalf("expected end of tags: %v", err)
+	}
+
+	if expected := []string{"d", "e"}; !reflect.DeepEqual(page[:n], expected) {
+		t.Fatalf("unexpected tags after c: %v != %v", page[:n], expected)
+	}
+}
+
+// listAllTags pages through the tags of the repository, n at a time.
+func listAllTags(t *testing.T, ctx context.Context, tags distribution.TagService, n int) []string {
+	var all []string
+	last := ""
+	for {
+		page := make([]string, n)
+		n, err := tags.List(ctx, page, last)
+		if err != nil && err != io.EOF {
+			t.Fatalf("unexpected error listing tags: %v", err)
+		}
+
+		all = append(all, page[:n]...)
+		if err == io.EOF {
+			return all
+		}
+		last = page[n-1]
+	}
+}
+
+func TestTagStoreListOrder(t *testing.T) {
+	env := newGCTestEnv(t)
+	ctx := env.ctx
+	repo := env.repository(t, "foo/versions")
+	tags := repo.Tags(ctx)
+
+	// Tags extending other tags with characters sorting before a slash are
+	// listed in lexical order.
+	layer := uploadRandomBlob(t, ctx, repo)
+	for _, tag := range []string{"v1.5", "v1", "v1-rc", "v10", "v1_1"} {
+		putManifest(t, ctx, repo, tag, layer.Digest)
+	}
+
+	expected := []string{"v1", "v1-rc", "v1.5", "v10", "v1_1"}
+	if all := listAllTags(t, ctx, tags, 2); !reflect.DeepEqual(all, expected) {
+		t.Fatalf("unexpected tags: %v != %v", all, expected)
+	}
+
+	if err := tags.Untag(ctx, "v1-rc"); err != nil {
+		t.Fatalf("unexpected error untagging: %v", err)
+	}
+
+	expected = []string{"v1", "v1.5", "v10", "v1_1"}
+	if all := listAllTags(t, ctx, tags, 3); !reflect.DeepEqual(all, expected) {
+		t.Fatalf("unexpected tags after untag: %v != %v", all, expected)
+	}
+}
+
+func TestRebuildTagList(t *testing.T) {
+	env := newGCTestEnv(t)
+	ctx := env.ctx
+	repo := env.repository(t, "foo/rebuilt")
+	tags := repo.Tags(ctx)
+
+	layer := uploadRandomBlob(t, ctx, repo)
+	for _, tag := range []string{"a", "b", "c"} {
+		putManifest(t, ctx, repo, tag, layer.Digest)
+	}
+
+	// Lose an entry, as storage written before tag lists would, and leave
+	// a stale one behind.
+	if err := unindexTag(ctx, env.driver, "foo/rebuilt", "b"); err != nil {
+		t.Fatalf("unexpected error removing tag list entry: %v", err)
+	}
+	if err := indexTag(ctx, env.driver, "foo/rebuilt", "gone"); err != nil {
+		t.Fatalf("unexpected error adding tag list entry: %v", err)
+	}
+
+	if _, err := RebuildCatalogIndex(ctx, env.driver); err != nil {
+		t.Fatalf("unexpected error rebuilding index: %v", err)
+	}
+
+	if all, expected := listAllTags(t, ctx, tags, 10), []string{"a", "b", "c"}; !reflect.DeepEqual(all, expected) {
+		t.Fatalf("unexpected tags after rebuild: %v != %v", all, expected)
+	}
+}
+
+func TestTagStoreListLegacy(t *testing.T) {
+	env := newGCTestEnv(t)
+	ctx := env.ctx
+	repo := env.repository(t, "foo/legacy")
+	tags := repo.Tags(ctx)
+
+	layer := uploadRandomBlob(t, ctx, repo)
+	for _, tag := range []string{"b", "a", "c"} {
+		putManifest(t, ctx, repo, tag, layer.Digest)
+	}
+
+	// Drop the tag list, as storage written before tag lists.
+	listPath, err := pathFor(tagListPathSpec{name: "foo/legacy"})
+	if err != nil {
+		t.Fatalf("unexpected error resolving tag list path: %v", err)
+	}
+	if err := env.driver.Delete(ctx, path.Dir(listPath)); err != nil {
+		t.Fatalf("unexpected error removing tag list: %v", err)
+	}
+
+	if all, expected := listAllTags(t, ctx, tags, 2), []string{"a", "b", "c"}; !reflect.DeepEqual(all, expected) {
+		t.Fatalf("unexpected tags: %v != %v", all, expected)
+	}
+
+	completePath, err := pathFor(tagListCompletePathSpec{name: "foo/legacy"})
+	if err != nil {
+		t.Fatalf("unexpected error resolving tag list path: %v", err)
+	}
+	if complete, err := exists(ctx, env.driver, completePath); err != nil || !complete {
+		t.Fatalf("expected tag list to be marked complete: %v", err)
+	}
+
+	putManifest(t, ctx, repo, "d", layer.Digest)
+	if all, expected := listAllTags(t, ctx, tags, 3), []string{"a", "b", "c", "d"}; !reflect.DeepEqual(all, expected) {
+		t.Fatalf("unexpected tags after tagging: %v != %v", all, expected)
+	}
+}
+
+func TestTagStoreListUnknown(t *testing.T) {
+	env := newGCTestEnv(t)
+	ctx := env.ctx
+	repo := env.repository(t, "foo/unknown")
+
+	if _, err := repo.Tags(ctx).List(ctx, make([]string, 2), ""); true {
+		if _, ok := err.(distribution.ErrRepositoryUnknown); !ok {
+			t.Fatalf("expected repository unknown error: %#v", err)
+		}
+	}
+
+	// Listing the tags of an unknown repository writes nothing.
+	root, err := pathFor(repositoriesRootPathSpec{})
+	if err != nil {
+		t.Fatalf("unexpected error resolving repositories path: %v", err)
+	}
+	if written, err := exists(ctx, env.driver, path.Join(root, "foo/unknown")); err != nil || written {
+		t.Fatalf("unexpected repository written: %v", err)
+	}
+}
+
+func TestTagStoreLongTag(t *testing.T) {
+	root, err := ioutil.TempDir("", "tag-list-")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	ctx := context.Background()
+	registry, err := NewRegistry(ctx, filesystem.New(root))
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	repo, err := registry.Repository(ctx, "foo/long")
+	if err != nil {
+		t.Fatalf("unexpected error getting repository: %v", err)
+	}
+
+	// The longest tag allowed by the reference grammar.
+	tag := strings.Repeat("t", 128)
+	layer := uploadRandomBlob(t, ctx, repo)
+	putManifest(t, ctx, repo, tag, layer.Digest)
+
+	if all, expected := listAllTags(t, ctx, repo.Tags(ctx), 2), []string{tag}; !reflect.DeepEqual(all, expected) {
+		t.Fatalf("unexpected tags: %v != %v", all, expected)
+	}
+}
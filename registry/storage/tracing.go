@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"net/http"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+)
+
+// tracedBlobStore starts a span for every operation of the blob store it
+// wraps, so that the operations of the storage driver are grouped by blob
+// operation. Readers and writers returned by the blob store outlive their
+// operation, so their storage driver operations belong to the caller's span
+// instead.
+type tracedBlobStore struct {
+	distribution.BlobStore
+	name string
+}
+
+var _ distribution.BlobStore = &tracedBlobStore{}
+
+// withSpan starts the span of an operation on a blob.
+func (bs *tracedBlobStore) withSpan(ctx context.Context, operation string, dgst digest.Digest) (context.Context, *context.Span) {
+	ctx, span := context.WithSpan(ctx, "blobStore."+operation)
+	span.SetAttribute("vars.name", bs.name)
+	if dgst != "" {
+		span.SetAttribute("vars.digest", dgst.String())
+	}
+
+	return ctx, span
+}
+
+func (bs *tracedBlobStore) Stat(ctx context.Context, dgst digest.Digest) (desc distribution.Descriptor, err error) {
+	ctx, span := bs.withSpan(ctx, "Stat", dgst)
+	defer func() { span.Finish(err) }()
+
+	return bs.BlobStore.Stat(ctx, dgst)
+}
+
+func (bs *tracedBlobStore) Get(ctx context.Context, dgst digest.Digest) (p []byte, err error) {
+	ctx, span := bs.withSpan(ctx, "Get", dgst)
+	defer func() { span.Finish(err) }()
+
+	return bs.BlobStore.Get(ctx, dgst)
+}
+
+func (bs *tracedBlobStore) Open(ctx context.Context, dgst digest.Digest) (rsc distribution.ReadSeekCloser, err error) {
+	_, span := bs.withSpan(ctx, "Open", dgst)
+	defer func() { span.Finish(err) }()
+
+	return bs.BlobStore.Open(ctx, dgst)
+}
+
+func (bs *tracedBlobStore) ServeBlob(ctx context.Context, w http.ResponseWriter, r *http.Request, dgst digest.Digest) (err error) {
+	ctx, span := bs.withSpan(ctx, "ServeBlob", dgst)
+	defer func() { span.Finish(err) }()
+
+	return bs.BlobStore.ServeBlob(ctx, w, r, dgst)
+}
+
+func (bs *tracedBlobStore) Put(ctx context.Context, mediaType string, p []byte) (desc distribution.Descriptor, err error) {
+	ctx, span := bs.withSpan(ctx, "Put", "")
+	defer func() { span.Finish(err) }()
+
+	return bs.BlobStore.Put(ctx, mediaType, p)
+}
+
+func (bs *tracedBlobStore) Create(ctx context.Context, options ...distribution.BlobCreateOption) (bw distribution.BlobWriter, err error) {
+	_, span := bs.withSpan(ctx, "Create", "")
+	defer func() {
+		// Mounting a blob is a success, even though it is returned as an
+		// error.
+		if _, ok := err.(distribution.ErrBlobMounted); ok {
+			span.SetAttribute("mounted", true)
+			span.Finish(nil)
+			return
+		}
+		span.Finish(err)
+	}()
+
+	return bs.BlobStore.Create(ctx, options...)
+}
+
+func (bs *tracedBlobStore) Resume(ctx context.Context, id string) (bw distribution.BlobWriter, err error) {
+	_, span := bs.withSpan(ctx, "Resume", "")
+	span.SetAttribute("vars.uuid", id)
+	defer func() { span.Finish(err) }()
+
+	return bs.BlobStore.Resume(ctx, id)
+}
+
+func (bs *tracedBlobStore) Delete(ctx context.Context, dgst digest.Digest) (err error) {
+	ctx, span := bs.withSpan(ctx, "Delete", dgst)
+	defer func() { span.Finish(err) }()
+
+	return bs.BlobStore.Delete(ctx, dgst)
+}
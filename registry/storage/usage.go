@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/distribution/context"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/distribution/registry/storage/driver"
+)
+
+// The usage of a repository is the sum of the sizes of the layers and
+// manifests linked into it, counting a blob linked into several repositories
+// once for each of them. It is tracked incrementally as content is linked and
+// deleted, both for the repository and for every namespace enclosing it, so
+// that quotas can be checked without walking the storage. Blobs removed by
+// garbage collection are released from the repositories still linking them.
+// Content linked before usage was tracked is not accounted.
+
+// usageStore updates the usage files of repositories and their namespaces.
+// The read-modify-write cycles on a usage file are serialized by a lock of
+// its own. Updates from other registry instances sharing the storage are not
+// serialized, so usage is only approximate under concurrent pushes.
+type usageStore struct {
+	driver driver.StorageDriver
+
+	// mu guards locks, which only holds the locks in use.
+	mu    sync.Mutex
+	locks map[string]*usageLock
+}
+
+// usageLock serializes the updates of a usage file.
+type usageLock struct {
+	sync.Mutex
+	refs int // holders and waiters
+}
+
+func newUsageStore(storageDriver driver.StorageDriver) *usageStore {
+	return &usageStore{
+		driver: storageDriver,
+		locks:  make(map[string]*usageLock),
+	}
+}
+
+// lock acquires the lock of the usage file described by spec, returning the
+// function releasing it.
+func (us *usageStore) lock(spec pathSpec) (func(), error) {
+	usagePath, err := pathFor(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	us.mu.Lock()
+	l, ok := us.locks[usagePath]
+	if !ok {
+		l = &usageLock{}
+		us.locks[usagePath] = l
+	}
+	l.refs++
+	us.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		us.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(us.locks, usagePath)
+		}
+		us.mu.Unlock()
+	}, nil
+}
+
+// link calls linkFn to link content of size bytes into the named repository,
+// accounting the content unless its canonical link at linkPath already
+// existed. The check and the update are made under the lock of the
+// repository, so that concurrent pushes of the same content account it once.
+func (us *usageStore) link(ctx context.Context, name, linkPath string, size int64, linkFn func() error) error {
+	unlock, err := us.lock(repositoryUsagePathSpec{name: name})
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	linked, err := exists(ctx, us.driver, linkPath)
+	if err != nil {
+		return err
+	}
+
+	if err := linkFn(); err != nil || linked {
+		return err
+	}
+
+	return us.update(ctx, name, size)
+}
+
+// unlink calls unlinkFn to remove content from the named repository,
+// releasing the size it returns.
+func (us *usageStore) unlink(ctx context.Context, name string, unlinkFn func() (int64, error)) error {
+	unlock, err := us.lock(repositoryUsagePathSpec{name: name})
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	size, err := unlinkFn()
+	if err != nil {
+		return err
+	}
+
+	return us.update(ctx, name, -size)
+}
+
+// update adds delta to the usage of the named repository, whose lock is
+// held, and of every namespace enclosing it, each under its own lock.
+func (us *usageStore) update(ctx context.Context, name string, delta int64) error {
+	if err := updateUsage(ctx, us.driver, repositoryUsagePathSpec{name: name}, delta); err != nil {
+		return err
+	}
+
+	for _, namespace := range namespaces(name) {
+		spec := namespaceUsagePathSpec{namespace: namespace}
+		unlock, err := us.lock(spec)
+		if err != nil {
+			return err
+		}
+
+		err = updateUsage(ctx, us.driver, spec, delta)
+		unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseSweptUsage subtracts the sizes of blobs removed by garbage
+// collection from the usage of the repositories still linking them, and of
+// their namespaces. Only repositories having a usage file are visited.
+func releaseSweptUsage(ctx context.Context, storageDriver driver.StorageDriver, swept map[digest.Digest]int64) error {
+	root, err := pathFor(repositoriesRootPathSpec{})
+	if err != nil {
+		return err
+	}
+
+	err = Walk(ctx, storageDriver, root, func(fileInfo driver.FileInfo) error {
+		filePath := fileInfo.Path()
+		if fileInfo.IsDir() {
+			if strings.HasPrefix(path.Base(filePath), "_") {
+				return ErrSkipDir
+			}
+			return nil
+		}
+
+		if path.Base(filePath) != "_usage" {
+			return nil
+		}
+
+		name := strings.TrimPrefix(path.Dir(filePath), root+"/")
+		linked := make(map[digest.Digest]struct{})
+		if err := linkedBlobs(ctx, storageDriver, name, linked); err != nil {
+			return err
+		}
+
+		var released int64
+		for dgst := range linked {
+			released += swept[dgst]
+		}
+
+		if released != 0 {
+			context.GetLogger(ctx).Infof("Releasing %d bytes of swept blobs from the usage of %s", released, name)
+			return updateRepositoryUsage(ctx, storageDriver, name, -released)
+		}
+
+		return nil
+	})
+
+	if isPathNotFound(err, root) {
+		return nil
+	}
+
+	return err
+}
+
+// updateRepositoryUsage adds delta to the usage of the named repository and
+// of every namespace enclosing it.
+func updateRepositoryUsage(ctx context.Context, storageDriver driver.StorageDriver, name string, delta int64) error {
+	if err := updateUsage(ctx, storageDriver, repositoryUsagePathSpec{name: name}, delta); err != nil {
+		return err
+	}
+
+	return updateNamespacesUsage(ctx, storageDriver, name, delta)
+}
+
+// updateNamespacesUsage adds delta to the usage of every namespace enclosing
+// the named repository.
+func updateNamespacesUsage(ctx context.Context, storageDriver driver.StorageDriver, name string, delta int64) error {
+	for _, namespace := range namespaces(name) {
+		if err := updateUsage(ctx, storageDriver, namespaceUsagePathSpec{namespace: namespace}, delta); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateUsage adds delta to the usage file described by spec. Usage never
+// drops below zero, as deleted content may predate usage tracking.
+func updateUsage(ctx context.Context, storageDriver driver.StorageDriver, spec pathSpec, delta int64) error {
+	usage, err := readUsage(ctx, storageDriver, spec)
+	if err != nil {
+		return err
+	}
+
+	usage += delta
+	if usage < 0 {
+		usage = 0
+	}
+
+	usagePath, err := pathFor(spec)
+	if err != nil {
+		return err
+	}
+
+	return storageDriver.PutContent(ctx, usagePath, []byte(strconv.FormatInt(usage, 10)))
+}
+
+// readUsage returns the usage stored in the file described by spec. A missing
+// file means nothing was accounted yet.
+func readUsage(ctx context.Context, storageDriver driver.StorageDriver, spec pathSpec) (int64, error) {
+	usagePath, err := pathFor(spec)
+	if err != nil {
+		return 0, err
+	}
+
+	content, err := storageDriver.GetContent(ctx, usagePath)
+	if err != nil {
+		switch err.(type) {
+		case driver.PathNotFoundError:
+			return 0, nil
+		default:
+			return 0, err
+		}
+	}
+
+	return strconv.ParseInt(string(content), 10, 64)
+}
+
+// namespaces returns the namespaces enclosing the named repository, from the
+// outermost one. For "a/b/c", these are "a" and "a/b".
+func namespaces(name string) []string {
+	var enclosing []string
+	for i, c := range name {
+		if c == '/' {
+			enclosing = append(enclosing, name[:i])
+		}
+	}
+
+	return enclosing
+}
+
+// RepositoryUsage returns the number of bytes of layers and manifests linked
+// into the named repository.
+func RepositoryUsage(ctx context.Context, storageDriver driver.StorageDriver, name string) (int64, error) {
+	return readUsage(ctx, storageDriver, repositoryUsagePathSpec{name: name})
+}
+
+// NamespaceUsage returns the number of bytes of layers and manifests linked
+// into all repositories below namespace, that is whose name starts with the
+// namespace followed by a slash.
+func NamespaceUsage(ctx context.Context, storageDriver driver.StorageDriver, namespace string) (int64, error) {
+	return readUsage(ctx, storageDriver, namespaceUsagePathSpec{namespace: strings.TrimSuffix(namespace, "/")})
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"crypto/rand"
+	"sync"
+	"testing"
+
+	"github.com/docker/distribution"
+)
+
+// TestUsage checks that the usage of repositories and their namespaces
+// follows the blobs linked into and deleted from them.
+func TestUsage(t *testing.T) {
+	env := newGCTestEnv(t, EnableUsage)
+
+	checkUsage := func(msg string, repositories, namespaces map[string]int64) {
+		for name, expected := range repositories {
+			usage, err := RepositoryUsage(env.ctx, env.driver, name)
+			if err != nil {
+				t.Fatalf("%s: unexpected error reading usage of %s: %v", msg, name, err)
+			}
+			if usage != expected {
+				t.Fatalf("%s: unexpected usage of repository %s: %d != %d", msg, name, usage, expected)
+			}
+		}
+
+		for namespace, expected := range namespaces {
+			usage, err := NamespaceUsage(env.ctx, env.driver, namespace)
+			if err != nil {
+				t.Fatalf("%s: unexpected error reading usage of %s: %v", msg, namespace, err)
+			}
+			if usage != expected {
+				t.Fatalf("%s: unexpected usage of namespace %s: %d != %d", msg, namespace, usage, expected)
+			}
+		}
+	}
+
+	checkUsage("empty registry", map[string]int64{"a/b/c": 0}, map[string]int64{"a": 0, "a/b": 0})
+
+	abc := env.repository(t, "a/b/c")
+	desc := uploadRandomBlob(t, env.ctx, abc)
+	checkUsage("after upload", map[string]int64{"a/b/c": desc.Size}, map[string]int64{"a": desc.Size, "a/b": desc.Size})
+
+	// Linking the same blob again doesn't use more storage.
+	p, err := abc.Blobs(env.ctx).Get(env.ctx, desc.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error getting blob: %v", err)
+	}
+	if _, err := abc.Blobs(env.ctx).Put(env.ctx, "application/octet-stream", p); err != nil {
+		t.Fatalf("unexpected error putting blob again: %v", err)
+	}
+	checkUsage("after upload of the same blob", map[string]int64{"a/b/c": desc.Size}, map[string]int64{"a": desc.Size, "a/b": desc.Size})
+
+	ad := env.repository(t, "a/d")
+	other := uploadRandomBlob(t, env.ctx, ad)
+	checkUsage("after upload to another repository",
+		map[string]int64{"a/b/c": desc.Size, "a/d": other.Size},
+		map[string]int64{"a/": desc.Size + other.Size, "a/b": desc.Size})
+
+	if err := abc.Blobs(env.ctx).Delete(env.ctx, desc.Digest); err != nil {
+		t.Fatalf("unexpected error deleting blob: %v", err)
+	}
+	checkUsage("after delete", map[string]int64{"a/b/c": 0, "a/d": other.Size}, map[string]int64{"a": other.Size, "a/b": 0})
+
+	if err := NewVacuum(env.ctx, env.driver).RemoveRepository("a/d"); err != nil {
+		t.Fatalf("unexpected error removing repository: %v", err)
+	}
+	checkUsage("after repository removal", map[string]int64{"a/d": 0}, map[string]int64{"a": 0})
+}
+
+// TestUsageConcurrentPush checks that content pushed concurrently to a
+// repository is accounted once, and to sibling repositories once for each.
+func TestUsageConcurrentPush(t *testing.T) {
+	env := newGCTestEnv(t, EnableUsage)
+	repos := []distribution.Repository{env.repository(t, "a/b"), env.repository(t, "a/c")}
+
+	p := make([]byte, 1024)
+	if _, err := rand.Read(p); err != nil {
+		t.Fatalf("unexpected error generating blob content: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(repo distribution.Repository) {
+			defer wg.Done()
+			_, err := repo.Blobs(env.ctx).Put(env.ctx, "application/octet-stream", p)
+			errs <- err
+		}(repos[i%len(repos)])
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error putting blob: %v", err)
+		}
+	}
+
+	for _, check := range []struct {
+		usage    func() (int64, error)
+		expected int64
+	}{
+		{func() (int64, error) { return RepositoryUsage(env.ctx, env.driver, "a/b") }, int64(len(p))},
+		{func() (int64, error) { return RepositoryUsage(env.ctx, env.driver, "a/c") }, int64(len(p))},
+		{func() (int64, error) { return NamespaceUsage(env.ctx, env.driver, "a") }, 2 * int64(len(p))},
+	} {
+		n, err := check.usage()
+		if err != nil {
+			t.Fatalf("unexpected error reading usage: %v", err)
+		}
+		if n != check.expected {
+			t.Fatalf("unexpected usage: %d != %d", n, check.expected)
+		}
+	}
+
+	// Locks are dropped once released.
+	if locks := env.registry.(*registry).usage.locks; len(locks) != 0 {
+		t.Fatalf("unexpected usage locks left: %v", locks)
+	}
+}
+
+// TestUsageDisabled checks that no usage is tracked unless enabled.
+func TestUsageDisabled(t *testing.T) {
+	env := newGCTestEnv(t)
+	uploadRandomBlob(t, env.ctx, env.repository(t, "a/b"))
+
+	for _, spec := range []pathSpec{repositoryUsagePathSpec{name: "a/b"}, namespaceUsagePathSpec{namespace: "a"}} {
+		usagePath, err := pathFor(spec)
+		if err != nil {
+			t.Fatalf("unexpected error resolving usage path: %v", err)
+		}
+
+		if written, err := exists(env.ctx, env.driver, usagePath); err != nil || written {
+			t.Fatalf("unexpected usage file %s written: %v", usagePath, err)
+		}
+	}
+}
+
+// TestUsageAfterGarbageCollection checks that blobs swept by garbage
+// collection are released from the usage of the repositories linking them.
+func TestUsageAfterGarbageCollection(t *testing.T) {
+	env := newGCTestEnv(t, EnableUsage)
+	abc, ad := env.repository(t, "a/b/c"), env.repository(t, "a/d")
+
+	layer := uploadRandomBlob(t, env.ctx, abc)
+	putManifest(t, env.ctx, abc, "latest", layer.Digest)
+	unreferenced := uploadRandomBlob(t, env.ctx, abc)
+
+	// The unreferenced blob is also linked into another repository.
+	p, err := abc.Blobs(env.ctx).Get(env.ctx, unreferenced.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error getting blob: %v", err)
+	}
+	if _, err := ad.Blobs(env.ctx).Put(env.ctx, "application/octet-stream", p); err != nil {
+		t.Fatalf("unexpected error putting blob: %v", err)
+	}
+
+	before, err := RepositoryUsage(env.ctx, env.driver, "a/b/c")
+	if err != nil {
+		t.Fatalf("unexpected error reading usage: %v", err)
+	}
+
+	result, err := MarkAndSweep(env.ctx, env.driver, env.registry, GCOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error collecting garbage: %v", err)
+	}
+	if len(result.Swept) != 1 || result.Swept[0] != unreferenced.Digest || len(result.Errors) != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	for _, testcase := range []struct {
+		usage    func() (int64, error)
+		expected int64
+	}{
+		{func() (int64, error) { return RepositoryUsage(env.ctx, env.driver, "a/b/c") }, before - unreferenced.Size},
+		{func() (int64, error) { return RepositoryUsage(env.ctx, env.driver, "a/d") }, 0},
+		{func() (int64, error) { return NamespaceUsage(env.ctx, env.driver, "a/b") }, before - unreferenced.Size},
+		{func() (int64, error) { return NamespaceUsage(env.ctx, env.driver, "a") }, before - unreferenced.Size},
+	} {
+		n, err := testcase.usage()
+		if err != nil {
+			t.Fatalf("unexpected error reading usage: %v", err)
+		}
+		if n != testcase.expected {
+			t.Fatalf("unexpected usage: %d != %d", n, testcase.expected)
+		}
+	}
+}
@@ -55,11 +55,23 @@ func (v Vacuum) RemoveRepository(repoName string) error {
 		return err
 	}
 	repoDir := path.Join(rootForRepository, repoName)
+
+	// The usage file goes away with the repository, but the namespaces
+	// enclosing it have to release its usage.
+	usage, err := RepositoryUsage(v.ctx, v.driver, repoName)
+	if err != nil {
+		return err
+	}
+
 	context.GetLogger(v.ctx).Infof("Deleting repo: %s", repoDir)
 	err = v.driver.Delete(v.ctx, repoDir)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if err := updateNamespacesUsage(v.ctx, v.driver, repoName, -usage); err != nil {
+		return err
+	}
+
+	return unindexRepository(v.ctx, v.driver, repoName)
 }
@@ -20,15 +20,24 @@ var ErrSkipDir = errors.New("skip this directory")
 type WalkFn func(fileInfo storageDriver.FileInfo) error
 
 // Walk traverses a filesystem defined within driver, starting
-// from the given path, calling f on each file
+// from the given path, calling f on each file. Errors listing or
+// stating nested entries are returned, except for entries removed
+// since their parent was listed, which are skipped.
 func Walk(ctx context.Context, driver storageDriver.StorageDriver, from string, f WalkFn) error {
 	children, err := driver.List(ctx, from)
 	if err != nil {
 		return err
 	}
+	return walkChildren(ctx, driver, children, f)
+}
+
+func walkChildren(ctx context.Context, driver storageDriver.StorageDriver, children []string, f WalkFn) error {
 	for _, child := range children {
 		fileInfo, err := driver.Stat(ctx, child)
 		if err != nil {
+			if isPathNotFound(err, child) {
+				continue
+			}
 			return err
 		}
 		err = f(fileInfo)
@@ -38,12 +47,28 @@ func Walk(ctx context.Context, driver storageDriver.StorageDriver, from string,
 		}
 
 		if fileInfo.IsDir() && !skipDir {
-			Walk(ctx, driver, child, f)
+			grandchildren, err := driver.List(ctx, child)
+			if err != nil {
+				if isPathNotFound(err, child) {
+					continue
+				}
+				return err
+			}
+			if err := walkChildren(ctx, driver, grandchildren, f); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// isPathNotFound reports whether err reports p itself as not found, rather
+// than some path a WalkFn looked up.
+func isPathNotFound(err error, p string) bool {
+	notFound, ok := err.(storageDriver.PathNotFoundError)
+	return ok && notFound.Path == p
+}
+
 // pushError formats an error type given a path and an error
 // and pushes it to a slice of errors
 func pushError(errors []error, path string, err error) []error {
@@ -52,8 +52,8 @@ func TestWalkErrors(t *testing.T) {
 	if len(expected) != fileCount-1 {
 		t.Error("Walk failed to terminate with error")
 	}
-	if err != nil {
-		t.Error(err.Error())
+	if err == nil || err.Error() != "Early termination" {
+		t.Errorf("Expected early termination err: %v", err)
 	}
 
 	err = Walk(ctx, d, "/nonexistant", func(fileInfo driver.FileInfo) error {
@@ -65,6 +65,46 @@ func TestWalkErrors(t *testing.T) {
 
 }
 
+// failingListDriver fails to list the directories named fail.
+type failingListDriver struct {
+	driver.StorageDriver
+	fail string
+}
+
+func (d failingListDriver) List(ctx context.Context, path string) ([]string, error) {
+	if path == d.fail {
+		return nil, fmt.Errorf("failed listing %s", path)
+	}
+	return d.StorageDriver.List(ctx, path)
+}
+
+func TestWalkNestedErrors(t *testing.T) {
+	d, _, ctx := testFS(t)
+
+	err := Walk(ctx, failingListDriver{StorageDriver: d, fail: "/a/b/c"}, "/", func(fileInfo driver.FileInfo) error {
+		return nil
+	})
+	if err == nil || err.Error() != "failed listing /a/b/c" {
+		t.Errorf("Expected nested list err: %v", err)
+	}
+
+	// Entries removed since their parent was listed are skipped.
+	var walked []string
+	err = Walk(ctx, d, "/", func(fileInfo driver.FileInfo) error {
+		walked = append(walked, fileInfo.Path())
+		if fileInfo.Path() == "/a/b/c/d" {
+			return d.Delete(ctx, "/a/b/c/e")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error walking removed entries: %v", err)
+	}
+	if len(walked) != 4 {
+		t.Errorf("unexpected walked entries: %v", walked)
+	}
+}
+
 func TestWalk(t *testing.T) {
 	d, expected, ctx := testFS(t)
 	err := Walk(ctx, d, "/", func(fileInfo driver.FileInfo) error {
@@ -0,0 +1,38 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/docker/distribution/configuration"
+	"github.com/spf13/cobra"
+)
+
+// ValidateConfigCmd is the cobra command that corresponds to the
+// validate-config subcommand. It reports every problem found in the
+// configuration with its YAML path, rather than failing on the first one
+// when the registry starts.
+var ValidateConfigCmd = &cobra.Command{
+	Use:   "validate-config <config>",
+	Short: "`validate-config` checks a configuration file",
+	Long:  "`validate-config` checks a configuration file, reporting every problem found.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := resolveConfiguration(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		if err := config.Validate(); err != nil {
+			errs := err.(configuration.ValidationErrors)
+			for _, err := range errs {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			fmt.Fprintf(os.Stderr, "configuration is invalid: %d problem(s) found\n", len(errs))
+			os.Exit(1)
+		}
+
+		fmt.Println("configuration is valid")
+	},
+}
@@ -0,0 +1,31 @@
+package distribution
+
+import (
+	"github.com/docker/distribution/context"
+)
+
+// TagService provides access to information about tagged objects.
+type TagService interface {
+	// Get retrieves the descriptor identified by the tag. If the tag is not
+	// known, ErrTagUnknown is returned.
+	Get(ctx context.Context, tag string) (Descriptor, error)
+
+	// Tag associates the tag with the provided descriptor, updating the
+	// current association, if needed.
+	Tag(ctx context.Context, tag string, desc Descriptor) error
+
+	// Untag removes the given tag association.
+	Untag(ctx context.Context, tag string) error
+
+	// All returns the set of tags managed by this tag service.
+	All(ctx context.Context) ([]string, error)
+
+	// List fills 'tags' with a lexically sorted set of tags, up to the size of
+	// 'tags', and returns the number of entries filled. Only tags sorting
+	// after 'last' are included. 'err' is set to io.EOF if there are no more
+	// tags to obtain.
+	List(ctx context.Context, tags []string, last string) (n int, err error)
+
+	// Lookup returns the set of tags referencing the given descriptor.
+	Lookup(ctx context.Context, desc Descriptor) ([]string, error)
+}
@@ -0,0 +1,53 @@
+// Package tracing provides the span exporters the registry can be configured
+// with. Exporters register themselves under a name, like storage drivers and
+// access controllers, and are then configured by name.
+//
+// The "jsonfile" exporter, writing spans as JSON lines to a file, is always
+// available.
+package tracing
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution/context"
+)
+
+// InitFunc is the type of a span exporter factory function and is used to
+// register the constructor for different exporters.
+type InitFunc func(options map[string]interface{}) (context.SpanExporter, error)
+
+var exporters map[string]InitFunc
+
+// Register is used to register an InitFunc for a span exporter with the
+// given name.
+func Register(name string, initFunc InitFunc) error {
+	if exporters == nil {
+		exporters = make(map[string]InitFunc)
+	}
+	if _, exists := exporters[name]; exists {
+		return fmt.Errorf("name already registered: %s", name)
+	}
+
+	exporters[name] = initFunc
+
+	return nil
+}
+
+// Get constructs a span exporter with the given options using the named
+// backend.
+func Get(name string, options map[string]interface{}) (context.SpanExporter, error) {
+	if exporters != nil {
+		if initFunc, exists := exporters[name]; exists {
+			return initFunc(options)
+		}
+	}
+
+	return nil, fmt.Errorf("no span exporter registered with name: %s", name)
+}
+
+// Registered reports whether a span exporter backend is registered with the
+// given name.
+func Registered(name string) bool {
+	_, exists := exporters[name]
+	return exists
+}
@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/docker/distribution/context"
+)
+
+// jsonFileExporter appends spans to a file, one JSON object per line, so that
+// traces can be collected without a tracing backend.
+type jsonFileExporter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// newJSONFileExporter constructs a jsonFileExporter appending to the file at
+// the required path option, which is created if missing.
+func newJSONFileExporter(options map[string]interface{}) (context.SpanExporter, error) {
+	path, ok := options["path"].(string)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("path must be provided to the jsonfile span exporter")
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonFileExporter{file: file}, nil
+}
+
+func (e *jsonFileExporter) ExportSpan(span *context.Span) error {
+	p, err := json.Marshal(span)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, err = e.file.Write(append(p, '\n'))
+	return err
+}
+
+func init() {
+	Register("jsonfile", InitFunc(newJSONFileExporter))
+}
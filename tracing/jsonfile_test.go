@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/distribution/context"
+)
+
+// TestJSONFileExporter checks that spans are appended to the file as JSON
+// lines.
+func TestJSONFileExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "spans.json")
+	exporter, err := Get("jsonfile", map[string]interface{}{"path": path})
+	if err != nil {
+		t.Fatalf("unexpected error creating exporter: %v", err)
+	}
+
+	ctx := context.WithSpanExporter(context.Background(), exporter)
+	ctx, root := context.WithSpan(ctx, "root")
+	_, child := context.WithSpan(ctx, "child")
+	child.SetAttribute("key", "value")
+	child.Finish(nil)
+	root.Finish(nil)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening spans: %v", err)
+	}
+	defer f.Close()
+
+	var spans []*context.Span
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		span := new(context.Span)
+		if err := json.Unmarshal(scanner.Bytes(), span); err != nil {
+			t.Fatalf("unexpected error decoding span %q: %v", scanner.Text(), err)
+		}
+		spans = append(spans, span)
+	}
+
+	if len(spans) != 2 {
+		t.Fatalf("unexpected number of spans: %d != 2", len(spans))
+	}
+
+	if spans[0].Name != "child" || spans[0].ParentID != root.SpanID || spans[0].Attributes["key"] != "value" {
+		t.Fatalf("unexpected child span: %+v", spans[0])
+	}
+
+	if spans[1].Name != "root" || spans[1].TraceID != root.TraceID || spans[1].Duration != root.Duration {
+		t.Fatalf("unexpected root span: %+v", spans[1])
+	}
+}
+
+// TestJSONFileExporterPath checks that a path is required.
+func TestJSONFileExporterPath(t *testing.T) {
+	if _, err := Get("jsonfile", nil); err == nil {
+		t.Fatalf("expected error creating exporter without a path")
+	}
+}